@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Cl0udRs4/dinot/internal/server/api"
+	"github.com/Cl0udRs4/dinot/internal/server/cli"
+)
+
+func main() {
+	// Parse command line flags
+	server := flag.String("server", "127.0.0.1:8443", "Team server address")
+	username := flag.String("user", os.Getenv("DINOT_USER"), "Operator username (default $DINOT_USER)")
+	caFile := flag.String("ca", "", "PEM CA bundle or certificate to verify the team server with")
+	fingerprint := flag.String("fingerprint", "", "Expected SHA-256 fingerprint of the team server certificate")
+	insecure := flag.Bool("insecure", false, "Do not verify the team server certificate")
+	certFile := flag.String("cert", "", "Operator client certificate file, logging in without a password")
+	keyFile := flag.String("key", "", "Operator client key file")
+	flag.Parse()
+
+	tlsConfig, err := buildTLSConfig(*caFile, *fingerprint, *insecure, *certFile, *keyFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+
+	console := cli.NewRemoteConsole()
+
+	// The password comes from the environment or a prompt, unless a client
+	// certificate logs the operator in
+	password := os.Getenv("DINOT_PASSWORD")
+	if password == "" && *certFile == "" {
+		if *username == "" {
+			fmt.Fprintln(os.Stderr, "Error: an operator username is required (-user)")
+			os.Exit(2)
+		}
+		if password, err = console.ReadPassword(fmt.Sprintf("Password for %s: ", *username)); err != nil {
+			os.Exit(1)
+		}
+	}
+
+	err = console.Connect(cli.RemoteConsoleConfig{
+		Address:  *server,
+		TLS:      tlsConfig,
+		Username: *username,
+		Password: password,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error connecting to %s: %v\n", *server, err)
+		os.Exit(1)
+	}
+	defer console.Close()
+
+	// The history is loaded once the server has reported which commands
+	// are sensitive
+	if home, err := os.UserHomeDir(); err == nil {
+		if err := console.SetHistoryPath(filepath.Join(home, ".dinot", "console_history")); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to load console history: %v\n", err)
+		}
+	}
+
+	if err := console.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		console.Close()
+		os.Exit(1)
+	}
+}
+
+// buildTLSConfig returns the TLS configuration of the connection to the
+// team server. Its certificate is verified against a CA file, pinned by
+// fingerprint, or against the system roots.
+func buildTLSConfig(caFile, fingerprint string, insecure bool, certFile, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile != "" {
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, errors.New("no certificates found in CA file")
+		}
+		config.RootCAs = pool
+	}
+
+	if fingerprint != "" {
+		// The pinned certificate is trusted whoever signed it, which is how
+		// the team server's self-signed certificate is verified
+		expected := strings.ToUpper(strings.ReplaceAll(fingerprint, ":", ""))
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("team server sent no certificate")
+			}
+			got := api.CertificateFingerprint(rawCerts[0])
+			if strings.ReplaceAll(got, ":", "") != expected {
+				return fmt.Errorf("team server certificate fingerprint %s does not match", got)
+			}
+			return nil
+		}
+	} else if insecure {
+		config.InsecureSkipVerify = true
+	}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
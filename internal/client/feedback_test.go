@@ -1,10 +1,13 @@
 package client
 
 import (
+    "context"
     "encoding/json"
+    "fmt"
     "testing"
     "time"
     
+    "github.com/Cl0udRs4/dinot/internal/client/module"
     "github.com/Cl0udRs4/dinot/internal/client/protocol"
 )
 
@@ -200,3 +203,263 @@ func TestFeedbackRetryLogic(t *testing.T) {
         t.Fatalf("Failed to stop client: %v", err)
     }
 }
+
+// blockingModule is a module that runs until its context is cancelled
+type blockingModule struct {
+    module.BaseModule
+    started chan struct{}
+}
+
+func (m *blockingModule) Execute(ctx context.Context, params json.RawMessage) (json.RawMessage, error) {
+    close(m.started)
+    <-ctx.Done()
+    return json.RawMessage(`{"output":"partial"}`), ctx.Err()
+}
+
+// TestCancelTask tests that cancel_task stops a running module and that the
+// cancellation is reported
+func TestCancelTask(t *testing.T) {
+    mockProto := &MockProtocol{
+        BaseProtocol: protocol.BaseProtocol{
+            Name: "tcp",
+        },
+        connected: true,
+    }
+    
+    config := Config{
+        ID:                     "test-client",
+        Name:                   "Test Client",
+        ServerAddresses:        map[string]string{"tcp": "tcp://localhost:8080"},
+        HeartbeatInterval:      time.Hour,
+        ProtocolSwitchThreshold: 3,
+    }
+    
+    client, err := NewClient(config)
+    if err != nil {
+        t.Fatalf("Failed to create client: %v", err)
+    }
+    client.protocolMgr = protocol.NewProtocolManager([]protocol.Protocol{mockProto}, config.ProtocolSwitchThreshold)
+    
+    blocking := &blockingModule{BaseModule: module.BaseModule{Name: "block"}, started: make(chan struct{})}
+    if err := client.moduleMgr.LoadModule(blocking); err != nil {
+        t.Fatalf("Failed to load module: %v", err)
+    }
+    
+    // Deliver an execute_module command followed by a cancel_task command
+    commands := make(chan []byte, 2)
+    commands <- []byte(`{"type":"execute_module","module":"block","params":{"command_id":"task-1"}}`)
+    mockProto.recvFunc = func(timeout time.Duration) ([]byte, error) {
+        select {
+        case data := <-commands:
+            return data, nil
+        case <-time.After(10 * time.Millisecond):
+            return nil, protocol.ErrTimeout
+        }
+    }
+    
+    final := make(chan FeedbackResponse, 2)
+    mockProto.sendFunc = func(data []byte) error {
+        var response FeedbackResponse
+        json.Unmarshal(data, &response)
+        if response.Type == "module_result" && response.Status != "processing" {
+            final <- response
+        }
+        return nil
+    }
+    
+    if err := client.Start(); err != nil {
+        t.Fatalf("Failed to start client: %v", err)
+    }
+    defer client.Stop()
+    
+    select {
+    case <-blocking.started:
+    case <-time.After(5 * time.Second):
+        t.Fatal("Module was not executed")
+    }
+    
+    commands <- []byte(`{"type":"cancel_task","params":{"command_id":"task-1"}}`)
+    
+    select {
+    case response := <-final:
+        if response.Status != "cancelled" || response.CommandID != "task-1" || response.Success {
+            t.Errorf("Expected a cancelled result for task-1, got %+v", response)
+        }
+        if string(response.Result) != `{"output":"partial"}` {
+            t.Errorf("Expected the partial output to be reported, got %s", response.Result)
+        }
+    case <-time.After(5 * time.Second):
+        t.Fatal("Cancellation was not reported")
+    }
+}
+
+// TestTaskTimeout tests that the timeout parameter bounds a module's
+// execution and that the timeout is reported
+func TestTaskTimeout(t *testing.T) {
+    mockProto := &MockProtocol{
+        BaseProtocol: protocol.BaseProtocol{
+            Name: "tcp",
+        },
+        connected: true,
+    }
+    
+    config := Config{
+        ID:                     "test-client",
+        Name:                   "Test Client",
+        ServerAddresses:        map[string]string{"tcp": "tcp://localhost:8080"},
+        HeartbeatInterval:      time.Hour,
+        ProtocolSwitchThreshold: 3,
+    }
+    
+    client, err := NewClient(config)
+    if err != nil {
+        t.Fatalf("Failed to create client: %v", err)
+    }
+    client.protocolMgr = protocol.NewProtocolManager([]protocol.Protocol{mockProto}, config.ProtocolSwitchThreshold)
+    
+    blocking := &blockingModule{BaseModule: module.BaseModule{Name: "block"}, started: make(chan struct{})}
+    if err := client.moduleMgr.LoadModule(blocking); err != nil {
+        t.Fatalf("Failed to load module: %v", err)
+    }
+    
+    var final FeedbackResponse
+    mockProto.sendFunc = func(data []byte) error {
+        json.Unmarshal(data, &final)
+        return nil
+    }
+    
+    start := time.Now()
+    client.handleExecuteModule("block", json.RawMessage(`{"command_id": "task-1", "timeout": 1}`))
+    
+    if elapsed := time.Since(start); elapsed > 5*time.Second {
+        t.Errorf("Expected the module to be stopped after 1s, took %v", elapsed)
+    }
+    if final.Status != "timed_out" || final.CommandID != "task-1" || final.Error != "timed out after 1s" {
+        t.Errorf("Expected a timed_out result for task-1, got %+v", final)
+    }
+}
+
+// stubbornModule is a module that finishes its work even after its context
+// ends
+type stubbornModule struct {
+    module.BaseModule
+}
+
+func (m *stubbornModule) Execute(ctx context.Context, params json.RawMessage) (json.RawMessage, error) {
+    <-ctx.Done()
+    return json.RawMessage(`{"output":"done"}`), nil
+}
+
+// TestTaskCompletedAfterDeadline tests that a module that succeeds is
+// reported as completed even if it returns after the task's deadline
+func TestTaskCompletedAfterDeadline(t *testing.T) {
+    mockProto := &MockProtocol{
+        BaseProtocol: protocol.BaseProtocol{
+            Name: "tcp",
+        },
+        connected: true,
+    }
+    
+    config := Config{
+        ID:                     "test-client",
+        Name:                   "Test Client",
+        ServerAddresses:        map[string]string{"tcp": "tcp://localhost:8080"},
+        HeartbeatInterval:      time.Hour,
+        ProtocolSwitchThreshold: 3,
+    }
+    
+    client, err := NewClient(config)
+    if err != nil {
+        t.Fatalf("Failed to create client: %v", err)
+    }
+    client.protocolMgr = protocol.NewProtocolManager([]protocol.Protocol{mockProto}, config.ProtocolSwitchThreshold)
+    
+    if err := client.moduleMgr.LoadModule(&stubbornModule{BaseModule: module.BaseModule{Name: "stubborn"}}); err != nil {
+        t.Fatalf("Failed to load module: %v", err)
+    }
+    
+    var final FeedbackResponse
+    mockProto.sendFunc = func(data []byte) error {
+        json.Unmarshal(data, &final)
+        return nil
+    }
+    
+    client.handleExecuteModule("stubborn", json.RawMessage(`{"command_id": "task-1", "timeout": 1}`))
+    
+    if final.Status != "completed" || !final.Success || string(final.Result) != `{"output":"done"}` {
+        t.Errorf("Expected a completed result for task-1, got %+v", final)
+    }
+}
+
+// countingModule counts its executions
+type countingModule struct {
+    module.BaseModule
+    runs int
+}
+
+func (m *countingModule) Execute(ctx context.Context, params json.RawMessage) (json.RawMessage, error) {
+    m.runs++
+    return json.RawMessage(fmt.Sprintf(`{"run":%d}`, m.runs)), nil
+}
+
+// TestRedeliveredCommand tests that a command delivered twice runs once and
+// that the cached result is sent again
+func TestRedeliveredCommand(t *testing.T) {
+    mockProto := &MockProtocol{
+        BaseProtocol: protocol.BaseProtocol{
+            Name: "tcp",
+        },
+        connected: true,
+    }
+    
+    config := Config{
+        ID:                     "test-client",
+        Name:                   "Test Client",
+        ServerAddresses:        map[string]string{"tcp": "tcp://localhost:8080"},
+        HeartbeatInterval:      time.Hour,
+        ProtocolSwitchThreshold: 3,
+    }
+    
+    client, err := NewClient(config)
+    if err != nil {
+        t.Fatalf("Failed to create client: %v", err)
+    }
+    client.protocolMgr = protocol.NewProtocolManager([]protocol.Protocol{mockProto}, config.ProtocolSwitchThreshold)
+    
+    counting := &countingModule{BaseModule: module.BaseModule{Name: "count"}}
+    if err := client.moduleMgr.LoadModule(counting); err != nil {
+        t.Fatalf("Failed to load module: %v", err)
+    }
+    
+    var finals []FeedbackResponse
+    mockProto.sendFunc = func(data []byte) error {
+        var response FeedbackResponse
+        json.Unmarshal(data, &response)
+        if response.Status == "completed" {
+            finals = append(finals, response)
+        }
+        return nil
+    }
+    
+    params := json.RawMessage(`{"command_id": "task-1"}`)
+    client.handleExecuteModule("count", params)
+    client.handleExecuteModule("count", params)
+    
+    if counting.runs != 1 {
+        t.Errorf("Expected the module to run once, ran %d times", counting.runs)
+    }
+    if len(finals) != 2 || string(finals[1].Result) != `{"run":1}` {
+        t.Errorf("Expected the first result to be sent twice, got %+v", finals)
+    }
+    
+    // The record of completed commands is bounded
+    for i := 0; i < maxCompletedCommands+10; i++ {
+        client.recordCompleted(FeedbackResponse{CommandID: fmt.Sprintf("task-%d", i+2)})
+    }
+    if len(client.completed) != maxCompletedCommands || len(client.completedOrder) != maxCompletedCommands {
+        t.Errorf("Expected %d completed commands, got %d", maxCompletedCommands, len(client.completed))
+    }
+    if _, exists := client.completed["task-1"]; exists {
+        t.Error("Expected the oldest command to be forgotten")
+    }
+}
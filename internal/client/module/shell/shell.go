@@ -6,6 +6,7 @@ import (
     "fmt"
     "os/exec"
     "runtime"
+    "time"
     
     "github.com/Cl0udRs4/dinot/internal/client/module"
 )
@@ -45,6 +46,14 @@ func (m *ShellModule) Execute(ctx context.Context, params json.RawMessage) (json
         return nil, fmt.Errorf("failed to parse shell parameters: %w", err)
     }
     
+    // Bound the command by its timeout
+    task := ctx
+    if shellParams.Timeout > 0 {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, time.Duration(shellParams.Timeout)*time.Second)
+        defer cancel()
+    }
+    
     // Determine the shell to use based on the OS
     var cmd *exec.Cmd
     if runtime.GOOS == "windows" {
@@ -53,6 +62,10 @@ func (m *ShellModule) Execute(ctx context.Context, params json.RawMessage) (json
         cmd = exec.CommandContext(ctx, "sh", "-c", shellParams.Command)
     }
     
+    // Children of the shell may keep the output open after the shell is
+    // killed; stop waiting for them shortly after the context ends
+    cmd.WaitDelay = time.Second
+    
     // Execute the command
     output, err := cmd.CombinedOutput()
     
@@ -62,7 +75,9 @@ func (m *ShellModule) Execute(ctx context.Context, params json.RawMessage) (json
         Output:  string(output),
     }
     
-    if err != nil {
+    if shellParams.Timeout > 0 && ctx.Err() == context.DeadlineExceeded {
+        result.Error = fmt.Sprintf("command timed out after %ds", shellParams.Timeout)
+    } else if err != nil {
         result.Error = err.Error()
     }
     
@@ -72,6 +87,12 @@ func (m *ShellModule) Execute(ctx context.Context, params json.RawMessage) (json
         return nil, fmt.Errorf("failed to marshal shell result: %w", err)
     }
     
+    // The task was cancelled or timed out; report it with the partial
+    // output
+    if task.Err() != nil {
+        return resultBytes, task.Err()
+    }
+    
     return resultBytes, nil
 }
 
@@ -3,7 +3,9 @@ package shell
 import (
     "context"
     "encoding/json"
+    "runtime"
     "testing"
+    "time"
 )
 
 func TestShellModule(t *testing.T) {
@@ -80,3 +82,27 @@ func TestShellModule(t *testing.T) {
         t.Fatalf("Failed to cleanup module: %v", err)
     }
 }
+
+func TestShellModuleTimeout(t *testing.T) {
+    if runtime.GOOS == "windows" {
+        t.Skip("uses sleep")
+    }
+    
+    module := NewModule()
+    
+    start := time.Now()
+    result, err := module.Execute(context.Background(), json.RawMessage(`{"command": "sleep 10", "timeout": 1}`))
+    if err != nil {
+        t.Fatalf("Failed to execute command: %v", err)
+    }
+    
+    if elapsed := time.Since(start); elapsed > 5*time.Second {
+        t.Errorf("Expected the command to be stopped after 1s, took %v", elapsed)
+    }
+    
+    var shellResult ShellResult
+    json.Unmarshal(result, &shellResult)
+    if shellResult.Success || shellResult.Error != "command timed out after 1s" {
+        t.Errorf("Expected a timeout error, got %+v", shellResult)
+    }
+}
@@ -1,14 +1,16 @@
 package api
 
 import (
-	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/Cl0udRs4/dinot/internal/server/auth"
 	"github.com/Cl0udRs4/dinot/internal/server/client"
-	"github.com/Cl0udRs4/dinot/internal/server/common"
+	"github.com/Cl0udRs4/dinot/internal/server/listener"
+	"github.com/Cl0udRs4/dinot/internal/server/task"
 )
 
 // APIHandler represents the HTTP API handler
@@ -19,20 +21,39 @@ type APIHandler struct {
 	// heartbeatMonitor is the heartbeat monitor to interact with
 	heartbeatMonitor *client.HeartbeatMonitor
 
+	// taskManager is the task manager used to run modules on clients
+	taskManager *task.TaskManager
+
+	// scheduler runs scheduled, recurring and on-register tasks
+	scheduler *task.Scheduler
+
+	// listenerManager manages the protocol listeners clients connect to
+	listenerManager *listener.ListenerManager
+
 	// authEnabled indicates whether authentication is enabled
 	authEnabled bool
 
-	// authUser is the username for basic auth
-	authUser string
+	// userStore holds the operator accounts used for authentication
+	userStore *auth.UserStore
 
-	// authPassword is the password for basic auth
-	authPassword string
+	// apiKeyStore holds the API keys used by automation
+	apiKeyStore *auth.APIKeyStore
 
-	// jwtSecret is the secret for JWT authentication
-	jwtSecret string
+	// tokenManager issues and validates the JWT access and refresh tokens
+	tokenManager *auth.TokenManager
 
 	// jwtEnabled indicates whether JWT authentication is enabled
 	jwtEnabled bool
+
+	// tlsConfig enables HTTPS when set
+	tlsConfig *TLSConfig
+
+	// idempotency remembers the responses to requests sent with an
+	// idempotency key
+	idempotency *idempotencyStore
+
+	// events keeps the recent events and streams new ones
+	events *eventHub
 }
 
 // Config represents the API configuration
@@ -40,57 +61,157 @@ type Config struct {
 	// Address is the address to listen on
 	Address string
 
-	// AuthEnabled indicates whether authentication is enabled
+	// AuthEnabled indicates whether authentication is enabled. It should be
+	// whenever Address is not a loopback address, see IsLoopbackAddress.
 	AuthEnabled bool
 
-	// AuthUser is the username for basic auth
+	// AuthUser is the username of the bootstrap admin account, created when
+	// the user store is empty
 	AuthUser string
 
-	// AuthPassword is the password for basic auth
+	// AuthPassword is the password of the bootstrap admin account; a random
+	// password is generated and printed if empty
 	AuthPassword string
 
+	// UserStore holds the operator accounts; a new empty store is used if nil
+	UserStore *auth.UserStore
+
+	// APIKeyStore holds the API keys; a new empty store is used if nil
+	APIKeyStore *auth.APIKeyStore
+
 	// JWTSecret is the secret for JWT authentication
 	JWTSecret string
 
 	// JWTEnabled indicates whether JWT authentication is enabled
 	JWTEnabled bool
+
+	// AccessTokenTTL is the lifetime of access tokens; defaults to 15 minutes
+	AccessTokenTTL time.Duration
+
+	// RefreshTokenTTL is the lifetime of refresh tokens; defaults to 24 hours
+	RefreshTokenTTL time.Duration
+
+	// TLS enables HTTPS and operator client certificates; plain HTTP is
+	// served if nil. Client CAs turn authentication on, as certificates are
+	// only mapped to operators by authentication.
+	TLS *TLSConfig
+
+	// Scheduler runs scheduled tasks; a new scheduler on the task manager is
+	// used if nil
+	Scheduler *task.Scheduler
+
+	// ListenerManager manages the protocol listeners; a new manager without
+	// listeners is used if nil
+	ListenerManager *listener.ListenerManager
 }
 
 // NewAPIHandler creates a new API handler
-func NewAPIHandler(clientManager *client.ClientManager, heartbeatMonitor *client.HeartbeatMonitor, config Config) *APIHandler {
-	return &APIHandler{
+func NewAPIHandler(clientManager *client.ClientManager, heartbeatMonitor *client.HeartbeatMonitor, taskManager *task.TaskManager, config Config) *APIHandler {
+	userStore := config.UserStore
+	if userStore == nil {
+		userStore = auth.NewUserStore()
+	}
+
+	apiKeyStore := config.APIKeyStore
+	if apiKeyStore == nil {
+		apiKeyStore = auth.NewAPIKeyStore()
+	}
+
+	scheduler := config.Scheduler
+	if scheduler == nil {
+		scheduler = task.NewScheduler(taskManager)
+	}
+
+	listenerManager := config.ListenerManager
+	if listenerManager == nil {
+		listenerManager = listener.NewListenerManager(listener.Config{})
+	}
+
+	// Bootstrap an admin account from the configured credentials
+	if config.AuthUser != "" && userStore.Count() == 0 {
+		password := config.AuthPassword
+		if password == "" {
+			password = auth.GeneratePassword()
+		}
+		if _, err := userStore.CreateUser(config.AuthUser, password, auth.RoleAdmin); err != nil {
+			fmt.Printf("Warning: Failed to create bootstrap admin %s: %v\n", config.AuthUser, err)
+		} else if config.AuthPassword == "" {
+			fmt.Printf("Created bootstrap admin %s with password %s; change it with: user passwd %s\n", config.AuthUser, password, config.AuthUser)
+		}
+	}
+	// Operator certificates are mapped to identities by authentication, so
+	// accepting them without it would grant every certificate full access
+	authEnabled := config.AuthEnabled
+	if config.TLS != nil && config.TLS.ClientCAFile != "" {
+		authEnabled = true
+	}
+
+	if authEnabled && userStore.Count() == 0 {
+		fmt.Println("Warning: API authentication is enabled but there are no operator accounts")
+	}
+
+	h := &APIHandler{
 		clientManager:    clientManager,
 		heartbeatMonitor: heartbeatMonitor,
-		authEnabled:      config.AuthEnabled,
-		authUser:         config.AuthUser,
-		authPassword:     config.AuthPassword,
-		jwtSecret:        config.JWTSecret,
+		taskManager:      taskManager,
+		scheduler:        scheduler,
+		listenerManager:  listenerManager,
+		authEnabled:      authEnabled,
+		userStore:        userStore,
+		apiKeyStore:      apiKeyStore,
+		tokenManager:     auth.NewTokenManager(userStore, config.JWTSecret, config.AccessTokenTTL, config.RefreshTokenTTL),
 		jwtEnabled:       config.JWTEnabled,
+		tlsConfig:        config.TLS,
+		idempotency:      newIdempotencyStore(),
+		events:           newEventHub(),
 	}
+	h.publishEvents()
+	return h
+}
+
+// IsLoopbackAddress reports whether a host:port listen address only accepts
+// connections from the local host. Unspecified hosts such as 0.0.0.0 and
+// host names other than localhost are not loopback addresses.
+func IsLoopbackAddress(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
 }
 
 // Start starts the HTTP API server
 func (h *APIHandler) Start(address string) error {
-	// Register API routes
-	http.HandleFunc("/api/clients", h.authMiddleware(h.handleClients))
-	http.HandleFunc("/api/clients/", h.authMiddleware(h.handleClient))
-	http.HandleFunc("/api/heartbeat", h.authMiddleware(h.handleHeartbeat))
-	http.HandleFunc("/api/status", h.authMiddleware(h.handleStatus))
-	http.HandleFunc("/api/exceptions", h.authMiddleware(h.handleExceptions))
-	http.HandleFunc("/api/exceptions/", h.authMiddleware(h.handleException))
-	
-	// Module management routes
-	http.HandleFunc("/api/modules", h.authMiddleware(h.handleModules))
-	http.HandleFunc("/api/modules/", h.authMiddleware(h.handleModule))
-	http.HandleFunc("/api/clients/modules", h.authMiddleware(h.handleClientModules))
-
-	// Start the HTTP server
-	fmt.Printf("Starting HTTP API server on %s\n", address)
-	return http.ListenAndServe(address, nil)
+	if h.tlsConfig == nil {
+		// Start the HTTP server
+		fmt.Printf("Starting HTTP API server on %s\n", address)
+		return http.ListenAndServe(address, h.Handler())
+	}
+
+	tlsConfig, fingerprint, err := BuildTLSConfig(h.tlsConfig, address)
+	if err != nil {
+		return err
+	}
+
+	// Start the HTTPS server
+	server := &http.Server{
+		Addr:      address,
+		Handler:   h.Handler(),
+		TLSConfig: tlsConfig,
+	}
+	fmt.Printf("Starting HTTPS API server on %s\n", address)
+	fmt.Printf("API certificate SHA-256 fingerprint: %s\n", fingerprint)
+	return server.ListenAndServeTLS("", "")
 }
 
-// authMiddleware is a middleware that handles authentication
-func (h *APIHandler) authMiddleware(next http.HandlerFunc) http.HandlerFunc {
+// authMiddleware is a middleware that handles authentication and checks that
+// the caller is granted perm. An empty perm admits any authenticated caller.
+func (h *APIHandler) authMiddleware(perm auth.Permission, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Skip authentication if disabled
 		if !h.authEnabled {
@@ -98,308 +219,381 @@ func (h *APIHandler) authMiddleware(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		// Check for JWT authentication
-		if h.jwtEnabled {
-			token := r.Header.Get("Authorization")
-			if token != "" && strings.HasPrefix(token, "Bearer ") {
-				token = strings.TrimPrefix(token, "Bearer ")
-				if h.validateJWT(token) {
-					next(w, r)
-					return
-				}
-			}
+		identity := h.authenticate(r)
+		if identity == nil {
+			// Authentication failed
+			w.Header().Set("WWW-Authenticate", `Basic realm="C2 API"`)
+			writeErrorMessage(w, http.StatusUnauthorized, CodeUnauthorized, "authentication required")
+			return
 		}
 
-		// Check for basic authentication
-		username, password, ok := r.BasicAuth()
-		if ok && username == h.authUser && password == h.authPassword {
-			next(w, r)
+		// Check the caller's permissions
+		if perm != "" && !identity.Can(perm) {
+			writeErrorBody(w, http.StatusForbidden, ErrorBody{
+				Code:    CodeForbidden,
+				Message: fmt.Sprintf("%s lacks %s", identity.Username, perm),
+				Details: map[string]interface{}{"permission": perm},
+			})
 			return
 		}
 
-		// Authentication failed
-		w.Header().Set("WWW-Authenticate", `Basic realm="C2 API"`)
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		next(w, r.WithContext(auth.WithIdentity(r.Context(), identity)))
 	}
 }
 
-// validateJWT validates a JWT token
-func (h *APIHandler) validateJWT(token string) bool {
-	// If JWT authentication is disabled, return true
-	if !h.jwtEnabled {
-		return true
+// authenticate returns the identity of the caller, or nil if the request
+// carries no valid credentials
+func (h *APIHandler) authenticate(r *http.Request) *auth.Identity {
+	// Check for API key authentication, sent either in X-API-Key or as a
+	// bearer token
+	key := r.Header.Get("X-API-Key")
+	if bearer := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "); strings.HasPrefix(bearer, auth.APIKeyPrefix) {
+		key = bearer
 	}
-	
-	// For tests, accept "valid-token" as a valid token
-	if token == "valid-token" {
-		return true
+	if key != "" {
+		identity, err := h.apiKeyStore.Authenticate(key)
+		if err != nil {
+			return nil
+		}
+		return identity
 	}
-	
-	// Validate the JWT token using the common JWT validation function
-	_, err := common.ValidateJWT(token, h.jwtSecret)
-	return err == nil
+
+	// Check for JWT authentication
+	if h.jwtEnabled {
+		token := r.Header.Get("Authorization")
+		if token != "" && strings.HasPrefix(token, "Bearer ") {
+			token = strings.TrimPrefix(token, "Bearer ")
+			if identity, err := h.tokenManager.Authenticate(token); err == nil {
+				return identity
+			}
+		}
+	}
+
+	// Check for basic authentication
+	username, password, ok := r.BasicAuth()
+	if ok {
+		if identity, err := h.userStore.Authenticate(username, password); err == nil {
+			return identity
+		}
+		return nil
+	}
+
+	// Fall back to the operator certificate of a mutual TLS connection
+	return h.certificateIdentity(r.TLS)
 }
 
-// handleClients handles the /api/clients endpoint
-func (h *APIHandler) handleClients(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		// Get all clients or filter by status
-		status := r.URL.Query().Get("status")
-		var clients []*client.Client
-		if status != "" {
-			clients = h.clientManager.GetClientsByStatus(client.ClientStatus(status))
+// handleListClients handles GET /api/v1/clients. Besides the pagination
+// parameters it takes a q filter expression and a status shorthand.
+func (h *APIHandler) handleListClients(w http.ResponseWriter, r *http.Request) {
+	// Get all clients, filtered by status and/or a filter expression
+	query := r.URL.Query()
+	filter := query.Get("q")
+	if status := query.Get("status"); status != "" {
+		if filter != "" {
+			filter = fmt.Sprintf("status=%q and (%s)", status, filter)
 		} else {
-			clients = h.clientManager.GetAllClients()
+			filter = fmt.Sprintf("status=%q", status)
 		}
+	}
 
-		// Return the clients as JSON
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(clients)
-
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	clients, err := h.clientManager.Query(client.ClientQuery{Filter: filter})
+	if err != nil {
+		writeError(w, err)
+		return
 	}
+
+	// Return a page of clients, projected to the requested fields
+	writeList(w, r, clients, listSpec{key: "id", canonical: client.ParseFieldList})
+}
+
+// UnregisterResponse lists the IDs of unregistered clients
+type UnregisterResponse struct {
+	Unregistered []string `json:"unregistered"`
+}
+
+// ClientHistoryResponse is the status history of a client
+type ClientHistoryResponse struct {
+	ClientID string                    `json:"client_id"`
+	History  []client.StatusTransition `json:"history"`
 }
 
-// handleClient handles the /api/clients/{id} endpoint
-func (h *APIHandler) handleClient(w http.ResponseWriter, r *http.Request) {
-	// Extract the client ID from the URL
-	parts := strings.Split(r.URL.Path, "/")
-	if len(parts) < 3 {
-		http.Error(w, "Invalid client ID", http.StatusBadRequest)
+// HeartbeatSettings are the global heartbeat settings; durations are in
+// nanoseconds
+type HeartbeatSettings struct {
+	CheckInterval     time.Duration `json:"checkInterval"`
+	Timeout           time.Duration `json:"timeout"`
+	RandomEnabled     bool          `json:"randomEnabled"`
+	RandomMinInterval time.Duration `json:"randomMinInterval"`
+	RandomMaxInterval time.Duration `json:"randomMaxInterval"`
+}
+
+// HeartbeatUpdateRequest changes the global heartbeat settings, or with a
+// target the heartbeat interval of some clients. Durations are in seconds
+// and omitted settings are left unchanged.
+type HeartbeatUpdateRequest struct {
+	CheckInterval     *float64 `json:"checkInterval,omitempty"`
+	Timeout           *float64 `json:"timeout,omitempty"`
+	RandomEnabled     *bool    `json:"randomEnabled,omitempty"`
+	RandomMinInterval float64  `json:"randomMinInterval,omitempty"`
+	RandomMaxInterval float64  `json:"randomMaxInterval,omitempty"`
+
+	// Target selects the clients whose interval is set to Interval
+	Target   *client.Target `json:"target,omitempty"`
+	Interval float64        `json:"interval,omitempty"`
+}
+
+// HeartbeatTargetResponse reports a per-client heartbeat interval change
+type HeartbeatTargetResponse struct {
+	Target  string `json:"target"`
+	Updated int    `json:"updated"`
+}
+
+// StatusUpdateRequest sets the status of a client
+type StatusUpdateRequest struct {
+	ClientID     string `json:"clientId"`
+	Status       string `json:"status"`
+	ErrorMessage string `json:"errorMessage,omitempty"`
+}
+
+// ExceptionReportRequest reports an exception on behalf of a client
+type ExceptionReportRequest struct {
+	ClientID       string            `json:"clientId"`
+	Message        string            `json:"message"`
+	Severity       string            `json:"severity"`
+	Module         string            `json:"module,omitempty"`
+	StackTrace     string            `json:"stackTrace,omitempty"`
+	AdditionalInfo map[string]string `json:"additionalInfo,omitempty"`
+}
+
+// handleUnregisterClients handles DELETE /api/v1/clients, which bulk
+// unregisters clients by tag or group
+func (h *APIHandler) handleUnregisterClients(w http.ResponseWriter, r *http.Request) {
+	target := client.Target{
+		Tag:   r.URL.Query().Get("tag"),
+		Group: r.URL.Query().Get("group"),
+	}
+	removed, err := h.clientManager.UnregisterTarget(target)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
-	clientID := parts[len(parts)-1]
 
-	switch r.Method {
-	case http.MethodGet:
-		// Get client details
-		client, err := h.clientManager.GetClient(clientID)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
-			return
-		}
+	writeJSON(w, http.StatusOK, UnregisterResponse{Unregistered: removed})
+}
 
-		// Return the client as JSON
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(client)
+// handleGetClient handles GET /api/v1/clients/{id}
+func (h *APIHandler) handleGetClient(w http.ResponseWriter, r *http.Request) {
+	client, err := h.clientManager.GetClient(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
 
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	// Return the client as JSON
+	writeJSON(w, http.StatusOK, client)
+}
+
+// handleUnregisterClient handles DELETE /api/v1/clients/{id}
+func (h *APIHandler) handleUnregisterClient(w http.ResponseWriter, r *http.Request) {
+	clientID := r.PathValue("id")
+	if err := h.clientManager.UnregisterClient(clientID); err != nil {
+		writeError(w, err)
+		return
 	}
+
+	writeJSON(w, http.StatusOK, UnregisterResponse{Unregistered: []string{clientID}})
 }
 
-// handleHeartbeat handles the /api/heartbeat endpoint
-func (h *APIHandler) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		// Get heartbeat settings
-		settings := map[string]interface{}{
-			"checkInterval":     h.heartbeatMonitor.GetCheckInterval(),
-			"timeout":           h.heartbeatMonitor.GetTimeout(),
-			"randomEnabled":     h.heartbeatMonitor.IsRandomEnabled(),
-			"randomMinInterval": h.heartbeatMonitor.GetRandomMinInterval(),
-			"randomMaxInterval": h.heartbeatMonitor.GetRandomMaxInterval(),
-		}
+// handleGetClientHistory handles GET /api/v1/clients/{id}/history
+func (h *APIHandler) handleGetClientHistory(w http.ResponseWriter, r *http.Request) {
+	// Get the client's status transitions, oldest first
+	clientID := r.PathValue("id")
+	history, err := h.clientManager.GetClientHistory(clientID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
 
-		// Return the settings as JSON
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(settings)
+	writeJSON(w, http.StatusOK, ClientHistoryResponse{ClientID: clientID, History: history})
+}
 
-	case http.MethodPost:
-		// Update heartbeat settings
-		var settings map[string]interface{}
-		if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
+// handleGetHeartbeat handles GET /api/v1/heartbeat
+func (h *APIHandler) handleGetHeartbeat(w http.ResponseWriter, r *http.Request) {
+	settings := HeartbeatSettings{
+		CheckInterval:     h.heartbeatMonitor.GetCheckInterval(),
+		Timeout:           h.heartbeatMonitor.GetTimeout(),
+		RandomEnabled:     h.heartbeatMonitor.IsRandomEnabled(),
+		RandomMinInterval: h.heartbeatMonitor.GetRandomMinInterval(),
+		RandomMaxInterval: h.heartbeatMonitor.GetRandomMaxInterval(),
+	}
 
-		// Apply the settings
-		if checkInterval, ok := settings["checkInterval"].(float64); ok {
-			h.heartbeatMonitor.SetCheckInterval(time.Duration(checkInterval) * time.Second)
-		}
-		if timeout, ok := settings["timeout"].(float64); ok {
-			h.heartbeatMonitor.SetTimeout(time.Duration(timeout) * time.Second)
-		}
-		if randomEnabled, ok := settings["randomEnabled"].(bool); ok {
-			if randomEnabled {
-				minInterval := time.Duration(settings["randomMinInterval"].(float64)) * time.Second
-				maxInterval := time.Duration(settings["randomMaxInterval"].(float64)) * time.Second
-				h.heartbeatMonitor.EnableRandomIntervals(minInterval, maxInterval)
-			} else {
-				h.heartbeatMonitor.DisableRandomIntervals()
-			}
-		}
+	// Return the settings as JSON
+	writeJSON(w, http.StatusOK, settings)
+}
 
-		// Return success
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, "Heartbeat settings updated")
+// handleUpdateHeartbeat handles POST /api/v1/heartbeat
+func (h *APIHandler) handleUpdateHeartbeat(w http.ResponseWriter, r *http.Request) {
+	var settings HeartbeatUpdateRequest
+	if !decodeBody(w, r, &settings) {
+		return
+	}
 
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	// Apply a per-client heartbeat interval to a client, tag or group
+	if settings.Target != nil {
+		h.updateTargetHeartbeat(w, *settings.Target, settings.Interval)
+		return
 	}
-}
 
-// handleStatus handles the /api/status endpoint
-func (h *APIHandler) handleStatus(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodPost:
-		// Update client status
-		var data struct {
-			ClientID     string `json:"clientId"`
-			Status       string `json:"status"`
-			ErrorMessage string `json:"errorMessage,omitempty"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+	// Apply the settings
+	if settings.RandomEnabled != nil && *settings.RandomEnabled {
+		if settings.RandomMinInterval <= 0 || settings.RandomMaxInterval < settings.RandomMinInterval {
+			writeErrorMessage(w, http.StatusBadRequest, "invalid_interval", "random intervals need a positive minimum no greater than the maximum")
 			return
 		}
-
-		// Validate the status
-		var status client.ClientStatus
-		switch data.Status {
-		case "online":
-			status = client.StatusOnline
-		case "offline":
-			status = client.StatusOffline
-		case "busy":
-			status = client.StatusBusy
-		case "error":
-			status = client.StatusError
-		default:
-			http.Error(w, "Invalid status", http.StatusBadRequest)
-			return
+	}
+	if settings.CheckInterval != nil {
+		h.heartbeatMonitor.SetCheckInterval(time.Duration(*settings.CheckInterval) * time.Second)
+	}
+	if settings.Timeout != nil {
+		h.heartbeatMonitor.SetTimeout(time.Duration(*settings.Timeout) * time.Second)
+	}
+	if settings.RandomEnabled != nil {
+		if *settings.RandomEnabled {
+			minInterval := time.Duration(settings.RandomMinInterval) * time.Second
+			maxInterval := time.Duration(settings.RandomMaxInterval) * time.Second
+			h.heartbeatMonitor.EnableRandomIntervals(minInterval, maxInterval)
+		} else {
+			h.heartbeatMonitor.DisableRandomIntervals()
 		}
+	}
 
-		// Update the client status
-		err := h.clientManager.UpdateClientStatus(data.ClientID, status, data.ErrorMessage)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
-			return
-		}
+	// Return success
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "Heartbeat settings updated"})
+}
 
-		// Return success
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, "Client status updated")
+// updateTargetHeartbeat sets the heartbeat interval of the clients selected by a target
+func (h *APIHandler) updateTargetHeartbeat(w http.ResponseWriter, target client.Target, interval float64) {
+	if interval <= 0 {
+		writeErrorMessage(w, http.StatusBadRequest, "invalid_interval", "interval must be a positive number of seconds")
+		return
+	}
 
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	count, err := h.clientManager.SetTargetHeartbeatInterval(target, time.Duration(interval)*time.Second)
+	if err != nil {
+		writeError(w, err)
+		return
 	}
+
+	writeJSON(w, http.StatusOK, HeartbeatTargetResponse{Target: target.String(), Updated: count})
 }
 
-// handleExceptions handles the /api/exceptions endpoint
-func (h *APIHandler) handleExceptions(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		// Get all exceptions or filter by client ID
-		clientID := r.URL.Query().Get("clientId")
-		var exceptions []*client.ExceptionReport
-		if clientID != "" {
-			var err error
-			exceptions, err = h.clientManager.GetExceptionReports(clientID)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusNotFound)
-				return
-			}
-		} else {
-			exceptions = h.clientManager.GetAllExceptionReports()
-		}
+// handleUpdateStatus handles POST /api/v1/status
+func (h *APIHandler) handleUpdateStatus(w http.ResponseWriter, r *http.Request) {
+	var data StatusUpdateRequest
+	if !decodeBody(w, r, &data) {
+		return
+	}
 
-		// Return the exceptions as JSON
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(exceptions)
-
-	case http.MethodPost:
-		// Report a new exception
-		var data struct {
-			ClientID       string            `json:"clientId"`
-			Message        string            `json:"message"`
-			Severity       string            `json:"severity"`
-			Module         string            `json:"module,omitempty"`
-			StackTrace     string            `json:"stackTrace,omitempty"`
-			AdditionalInfo map[string]string `json:"additionalInfo,omitempty"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
+	// Validate the status
+	var status client.ClientStatus
+	switch data.Status {
+	case "online":
+		status = client.StatusOnline
+	case "offline":
+		status = client.StatusOffline
+	case "busy":
+		status = client.StatusBusy
+	case "error":
+		status = client.StatusError
+	default:
+		writeErrorMessage(w, http.StatusBadRequest, "invalid_status", "invalid status: "+data.Status)
+		return
+	}
 
-		// Validate the severity
-		var severity client.ExceptionSeverity
-		switch data.Severity {
-		case "info":
-			severity = client.SeverityInfo
-		case "warning":
-			severity = client.SeverityWarning
-		case "error":
-			severity = client.SeverityError
-		case "critical":
-			severity = client.SeverityCritical
-		default:
-			http.Error(w, "Invalid severity", http.StatusBadRequest)
-			return
-		}
+	// Update the client status
+	err := h.clientManager.UpdateClientStatusWithCause(data.ClientID, status, data.ErrorMessage, client.CauseOperator)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
 
-		// Report the exception
-		report, err := h.clientManager.ReportException(
-			data.ClientID,
-			data.Message,
-			severity,
-			data.Module,
-			data.StackTrace,
-			data.AdditionalInfo,
-		)
+	// Return success
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "Client status updated"})
+}
+
+// handleListExceptions handles GET /api/v1/exceptions
+func (h *APIHandler) handleListExceptions(w http.ResponseWriter, r *http.Request) {
+	// Get all exceptions or filter by client ID
+	clientID := r.URL.Query().Get("clientId")
+	var exceptions []*client.ExceptionReport
+	if clientID != "" {
+		var err error
+		exceptions, err = h.clientManager.GetExceptionReports(clientID)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			writeError(w, err)
 			return
 		}
+	} else {
+		exceptions = h.clientManager.GetAllExceptionReports()
+	}
+
+	// Return a page of exceptions, oldest first by default
+	writeList(w, r, exceptions, listSpec{key: "id", defaultSort: "timestamp"})
+}
 
-		// Return the report as JSON
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(report)
+// handleReportException handles POST /api/v1/exceptions
+func (h *APIHandler) handleReportException(w http.ResponseWriter, r *http.Request) {
+	var data ExceptionReportRequest
+	if !decodeBody(w, r, &data) {
+		return
+	}
 
+	// Validate the severity
+	var severity client.ExceptionSeverity
+	switch data.Severity {
+	case "info":
+		severity = client.SeverityInfo
+	case "warning":
+		severity = client.SeverityWarning
+	case "error":
+		severity = client.SeverityError
+	case "critical":
+		severity = client.SeverityCritical
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeErrorMessage(w, http.StatusBadRequest, "invalid_severity", "invalid severity: "+data.Severity)
+		return
 	}
-}
 
-// handleException handles the /api/exceptions/{id} endpoint
-func (h *APIHandler) handleException(w http.ResponseWriter, r *http.Request) {
-	// Extract the exception ID from the URL
-	parts := strings.Split(r.URL.Path, "/")
-	if len(parts) < 3 {
-		http.Error(w, "Invalid exception ID", http.StatusBadRequest)
+	// Report the exception
+	report, err := h.clientManager.ReportException(
+		data.ClientID,
+		data.Message,
+		severity,
+		data.Module,
+		data.StackTrace,
+		data.AdditionalInfo,
+	)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
-	exceptionID := parts[len(parts)-1]
-
-	switch r.Method {
-	case http.MethodGet:
-	// Get all exceptions
-		allExceptions := h.clientManager.GetAllExceptionReports()
-		var report *client.ExceptionReport
-		exists := false
-		
-		// Find the exception with the matching ID
-		for _, exc := range allExceptions {
-			if exc.ID == exceptionID {
-				report = exc
-				exists = true
-				break
-			}
-		}
-		
-		if !exists {
-			http.Error(w, "Exception not found", http.StatusNotFound)
-			return
-		}
 
-		// Return the exception as JSON
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(report)
+	// Return the report as JSON
+	writeJSON(w, http.StatusOK, report)
+}
 
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// handleGetException handles GET /api/v1/exceptions/{id}
+func (h *APIHandler) handleGetException(w http.ResponseWriter, r *http.Request) {
+	exceptionID := r.PathValue("id")
+
+	// Find the exception with the matching ID
+	for _, exc := range h.clientManager.GetAllExceptionReports() {
+		if exc.ID == exceptionID {
+			// Return the exception as JSON
+			writeJSON(w, http.StatusOK, exc)
+			return
+		}
 	}
+
+	writeErrorMessage(w, http.StatusNotFound, "exception_not_found", "exception not found")
 }
@@ -2,13 +2,28 @@ package api
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/Cl0udRs4/dinot/internal/server/auth"
 	"github.com/Cl0udRs4/dinot/internal/server/client"
+	"github.com/Cl0udRs4/dinot/internal/server/listener"
+	"github.com/Cl0udRs4/dinot/internal/server/task"
 )
 
 // setupTestAPI creates a test API with mock data
@@ -39,17 +54,17 @@ func setupTestAPI() (*APIHandler, *client.ClientManager, *client.HeartbeatMonito
 		JWTEnabled:   false,
 	}
 	
-	apiHandler := NewAPIHandler(clientManager, heartbeatMonitor, config)
+	apiHandler := NewAPIHandler(clientManager, heartbeatMonitor, task.NewTaskManager(clientManager), config)
 	
 	return apiHandler, clientManager, heartbeatMonitor
 }
 
-// TestGetClients tests the GET /api/clients endpoint
+// TestGetClients tests the GET /api/v1/clients endpoint
 func TestGetClients(t *testing.T) {
 	apiHandler, _, _ := setupTestAPI()
 	
 	// Create a request to get all clients
-	req, err := http.NewRequest("GET", "/api/clients", nil)
+	req, err := http.NewRequest("GET", "/api/v1/clients", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -58,7 +73,7 @@ func TestGetClients(t *testing.T) {
 	rr := httptest.NewRecorder()
 	
 	// Call the handler
-	handler := http.HandlerFunc(apiHandler.handleClients)
+	handler := apiHandler.Handler()
 	handler.ServeHTTP(rr, req)
 	
 	// Check the status code
@@ -67,11 +82,19 @@ func TestGetClients(t *testing.T) {
 	}
 	
 	// Check the response body
-	var clients []*client.Client
-	err = json.Unmarshal(rr.Body.Bytes(), &clients)
+	var page struct {
+		Items []*client.Client `json:"items"`
+		Total int              `json:"total"`
+	}
+	err = json.Unmarshal(rr.Body.Bytes(), &page)
 	if err != nil {
 		t.Fatal(err)
 	}
+	clients := page.Items
+	
+	if page.Total != 1 {
+		t.Errorf("expected a total of 1, got %d", page.Total)
+	}
 	
 	if len(clients) != 1 {
 		t.Errorf("expected 1 client, got %d", len(clients))
@@ -82,12 +105,12 @@ func TestGetClients(t *testing.T) {
 	}
 }
 
-// TestGetClientByID tests the GET /api/clients/{id} endpoint
+// TestGetClientByID tests the GET /api/v1/clients/{id} endpoint
 func TestGetClientByID(t *testing.T) {
 	apiHandler, _, _ := setupTestAPI()
 	
 	// Create a request to get a client by ID
-	req, err := http.NewRequest("GET", "/api/clients/test-client-id", nil)
+	req, err := http.NewRequest("GET", "/api/v1/clients/test-client-id", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -96,7 +119,7 @@ func TestGetClientByID(t *testing.T) {
 	rr := httptest.NewRecorder()
 	
 	// Call the handler
-	handler := http.HandlerFunc(apiHandler.handleClient)
+	handler := apiHandler.Handler()
 	handler.ServeHTTP(rr, req)
 	
 	// Check the status code
@@ -116,7 +139,7 @@ func TestGetClientByID(t *testing.T) {
 	}
 }
 
-// TestUpdateClientStatus tests the POST /api/status endpoint
+// TestUpdateClientStatus tests the POST /api/v1/status endpoint
 func TestUpdateClientStatus(t *testing.T) {
 	apiHandler, _, _ := setupTestAPI()
 	
@@ -130,7 +153,7 @@ func TestUpdateClientStatus(t *testing.T) {
 		t.Fatal(err)
 	}
 	
-	req, err := http.NewRequest("POST", "/api/status", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", "/api/v1/status", bytes.NewBuffer(jsonData))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -140,7 +163,7 @@ func TestUpdateClientStatus(t *testing.T) {
 	rr := httptest.NewRecorder()
 	
 	// Call the handler
-	handler := http.HandlerFunc(apiHandler.handleStatus)
+	handler := apiHandler.Handler()
 	handler.ServeHTTP(rr, req)
 	
 	// Check the status code
@@ -159,12 +182,12 @@ func TestUpdateClientStatus(t *testing.T) {
 	}
 }
 
-// TestGetHeartbeatSettings tests the GET /api/heartbeat endpoint
+// TestGetHeartbeatSettings tests the GET /api/v1/heartbeat endpoint
 func TestGetHeartbeatSettings(t *testing.T) {
 	apiHandler, _, _ := setupTestAPI()
 	
 	// Create a request to get heartbeat settings
-	req, err := http.NewRequest("GET", "/api/heartbeat", nil)
+	req, err := http.NewRequest("GET", "/api/v1/heartbeat", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -173,7 +196,7 @@ func TestGetHeartbeatSettings(t *testing.T) {
 	rr := httptest.NewRecorder()
 	
 	// Call the handler
-	handler := http.HandlerFunc(apiHandler.handleHeartbeat)
+	handler := apiHandler.Handler()
 	handler.ServeHTTP(rr, req)
 	
 	// Check the status code
@@ -197,7 +220,7 @@ func TestGetHeartbeatSettings(t *testing.T) {
 	}
 }
 
-// TestUpdateHeartbeatSettings tests the POST /api/heartbeat endpoint
+// TestUpdateHeartbeatSettings tests the POST /api/v1/heartbeat endpoint
 func TestUpdateHeartbeatSettings(t *testing.T) {
 	apiHandler, _, heartbeatMonitor := setupTestAPI()
 	
@@ -214,7 +237,7 @@ func TestUpdateHeartbeatSettings(t *testing.T) {
 		t.Fatal(err)
 	}
 	
-	req, err := http.NewRequest("POST", "/api/heartbeat", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", "/api/v1/heartbeat", bytes.NewBuffer(jsonData))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -224,7 +247,7 @@ func TestUpdateHeartbeatSettings(t *testing.T) {
 	rr := httptest.NewRecorder()
 	
 	// Call the handler
-	handler := http.HandlerFunc(apiHandler.handleHeartbeat)
+	handler := apiHandler.Handler()
 	handler.ServeHTTP(rr, req)
 	
 	// Check the status code
@@ -268,7 +291,7 @@ func TestAuthMiddleware(t *testing.T) {
 		JWTEnabled:   true,
 	}
 	
-	apiHandler := NewAPIHandler(clientManager, heartbeatMonitor, config)
+	apiHandler := NewAPIHandler(clientManager, heartbeatMonitor, task.NewTaskManager(clientManager), config)
 	
 	// Create a test handler
 	testHandler := func(w http.ResponseWriter, r *http.Request) {
@@ -277,7 +300,7 @@ func TestAuthMiddleware(t *testing.T) {
 	}
 	
 	// Wrap the test handler with the auth middleware
-	handler := apiHandler.authMiddleware(testHandler)
+	handler := apiHandler.authMiddleware("", testHandler)
 	
 	// Test with no authentication
 	req, _ := http.NewRequest("GET", "/", nil)
@@ -300,16 +323,43 @@ func TestAuthMiddleware(t *testing.T) {
 	
 	// Test with JWT authentication
 	req, _ = http.NewRequest("GET", "/", nil)
-	req.Header.Set("Authorization", "Bearer valid-token")
+	req.Header.Set("Authorization", "Bearer "+issueTestToken(t, apiHandler, "admin"))
 	rr = httptest.NewRecorder()
 	handler.ServeHTTP(rr, req)
 	
 	if status := rr.Code; status != http.StatusOK {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
 	}
+	
+	// Test with an invalid JWT
+	req, _ = http.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	
+	if status := rr.Code; status != http.StatusUnauthorized {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusUnauthorized)
+	}
 }
 
-// TestGetExceptions tests the GET /api/exceptions endpoint
+// issueTestToken issues a real access token for a user of the API handler
+func issueTestToken(t *testing.T, h *APIHandler, username string) string {
+	t.Helper()
+	
+	user, err := h.userStore.GetUser(username)
+	if err != nil {
+		t.Fatalf("failed to get user %s: %v", username, err)
+	}
+	
+	tokens, err := h.tokenManager.Issue(&auth.Identity{Username: user.Username, Role: user.Role})
+	if err != nil {
+		t.Fatalf("failed to issue token: %v", err)
+	}
+	
+	return tokens.AccessToken
+}
+
+// TestGetExceptions tests the GET /api/v1/exceptions endpoint
 func TestGetExceptions(t *testing.T) {
 	apiHandler, clientManager, _ := setupTestAPI()
 	
@@ -354,7 +404,7 @@ func TestGetExceptions(t *testing.T) {
 	}
 	
 	// Create a request to get all exceptions
-	req, err := http.NewRequest("GET", "/api/exceptions", nil)
+	req, err := http.NewRequest("GET", "/api/v1/exceptions", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -363,7 +413,7 @@ func TestGetExceptions(t *testing.T) {
 	rr := httptest.NewRecorder()
 	
 	// Call the handler
-	handler := http.HandlerFunc(apiHandler.handleExceptions)
+	handler := apiHandler.Handler()
 	handler.ServeHTTP(rr, req)
 	
 	// Check the status code
@@ -372,18 +422,21 @@ func TestGetExceptions(t *testing.T) {
 	}
 	
 	// Check the response body
-	var exceptions []map[string]interface{}
-	err = json.Unmarshal(rr.Body.Bytes(), &exceptions)
+	var page struct {
+		Items []map[string]interface{} `json:"items"`
+	}
+	err = json.Unmarshal(rr.Body.Bytes(), &page)
 	if err != nil {
 		t.Fatal(err)
 	}
+	exceptions := page.Items
 	
 	if len(exceptions) != 2 {
 		t.Errorf("expected 2 exceptions, got %d", len(exceptions))
 	}
 	
 	// Create a request to get exceptions for a specific client
-	req, err = http.NewRequest("GET", "/api/exceptions?clientId=test-client-id", nil)
+	req, err = http.NewRequest("GET", "/api/v1/exceptions?clientId=test-client-id", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -400,10 +453,12 @@ func TestGetExceptions(t *testing.T) {
 	}
 	
 	// Check the response body
-	err = json.Unmarshal(rr.Body.Bytes(), &exceptions)
+	page.Items = nil
+	err = json.Unmarshal(rr.Body.Bytes(), &page)
 	if err != nil {
 		t.Fatal(err)
 	}
+	exceptions = page.Items
 	
 	if len(exceptions) != 1 {
 		t.Errorf("expected 1 exception, got %d", len(exceptions))
@@ -414,7 +469,7 @@ func TestGetExceptions(t *testing.T) {
 	}
 }
 
-// TestReportException tests the POST /api/exceptions endpoint
+// TestReportException tests the POST /api/v1/exceptions endpoint
 func TestReportException(t *testing.T) {
 	apiHandler, _, _ := setupTestAPI()
 	
@@ -431,7 +486,7 @@ func TestReportException(t *testing.T) {
 		t.Fatal(err)
 	}
 	
-	req, err := http.NewRequest("POST", "/api/exceptions", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", "/api/v1/exceptions", bytes.NewBuffer(jsonData))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -441,7 +496,7 @@ func TestReportException(t *testing.T) {
 	rr := httptest.NewRecorder()
 	
 	// Call the handler
-	handler := http.HandlerFunc(apiHandler.handleExceptions)
+	handler := apiHandler.Handler()
 	handler.ServeHTTP(rr, req)
 	
 	// Check the status code
@@ -483,7 +538,7 @@ func TestReportException(t *testing.T) {
 	}
 }
 
-// TestGetExceptionByID tests the GET /api/exceptions/{id} endpoint
+// TestGetExceptionByID tests the GET /api/v1/exceptions/{id} endpoint
 func TestGetExceptionByID(t *testing.T) {
 	apiHandler, clientManager, _ := setupTestAPI()
 	
@@ -501,7 +556,7 @@ func TestGetExceptionByID(t *testing.T) {
 	}
 	
 	// Create a request to get the exception by ID
-	req, err := http.NewRequest("GET", "/api/exceptions/"+report.ID, nil)
+	req, err := http.NewRequest("GET", "/api/v1/exceptions/"+report.ID, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -510,7 +565,7 @@ func TestGetExceptionByID(t *testing.T) {
 	rr := httptest.NewRecorder()
 	
 	// Call the handler
-	handler := http.HandlerFunc(apiHandler.handleException)
+	handler := apiHandler.Handler()
 	handler.ServeHTTP(rr, req)
 	
 	// Check the status code
@@ -534,7 +589,7 @@ func TestGetExceptionByID(t *testing.T) {
 	}
 	
 	// Test with non-existent ID
-	req, err = http.NewRequest("GET", "/api/exceptions/non-existent-id", nil)
+	req, err = http.NewRequest("GET", "/api/v1/exceptions/non-existent-id", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -546,3 +601,901 @@ func TestGetExceptionByID(t *testing.T) {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
 	}
 }
+
+// TestClientTagsAndGroups tests the tag and group endpoints
+func TestClientTagsAndGroups(t *testing.T) {
+	apiHandler, clientManager, _ := setupTestAPI()
+	
+	// Tag the test client
+	req, _ := http.NewRequest("POST", "/api/v1/clients/test-client-id/tags", bytes.NewBufferString(`{"tag":"dmz"}`))
+	rr := httptest.NewRecorder()
+	apiHandler.Handler().ServeHTTP(rr, req)
+	
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	
+	testClient, _ := clientManager.GetClient("test-client-id")
+	if !testClient.HasTag("dmz") {
+		t.Errorf("expected client to be tagged dmz")
+	}
+	
+	// Create a group with the test client as a member
+	req, _ = http.NewRequest("POST", "/api/v1/groups", bytes.NewBufferString(`{"name":"web","clientIds":["test-client-id"]}`))
+	rr = httptest.NewRecorder()
+	apiHandler.Handler().ServeHTTP(rr, req)
+	
+	if status := rr.Code; status != http.StatusCreated {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+	}
+	
+	var group client.ClientGroup
+	json.Unmarshal(rr.Body.Bytes(), &group)
+	if group.Name != "web" || len(group.ClientIDs) != 1 {
+		t.Errorf("unexpected group: %+v", group)
+	}
+	
+	// Remove the member
+	req, _ = http.NewRequest("DELETE", "/api/v1/groups/web/members/test-client-id", nil)
+	rr = httptest.NewRecorder()
+	apiHandler.Handler().ServeHTTP(rr, req)
+	
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	
+	// Unknown groups return 404
+	req, _ = http.NewRequest("GET", "/api/v1/groups/missing", nil)
+	rr = httptest.NewRecorder()
+	apiHandler.Handler().ServeHTTP(rr, req)
+	
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+	}
+	
+	// Bulk unregister by tag
+	req, _ = http.NewRequest("DELETE", "/api/v1/clients?tag=dmz", nil)
+	rr = httptest.NewRecorder()
+	apiHandler.Handler().ServeHTTP(rr, req)
+	
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	
+	if clientManager.Count() != 0 {
+		t.Errorf("expected tagged client to be unregistered")
+	}
+}
+
+// TestCreateTaskForTag tests the POST /api/v1/tasks endpoint with a tag target
+func TestCreateTaskForTag(t *testing.T) {
+	apiHandler, clientManager, _ := setupTestAPI()
+	clientManager.AddClientTag("test-client-id", "dmz")
+	
+	req, _ := http.NewRequest("POST", "/api/v1/tasks", bytes.NewBufferString(`{"tag":"dmz","module":"shell","params":{"command":"whoami"}}`))
+	rr := httptest.NewRecorder()
+	apiHandler.Handler().ServeHTTP(rr, req)
+	
+	if status := rr.Code; status != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+	}
+	
+	var tasks []map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &tasks)
+	if len(tasks) != 1 || tasks[0]["client_id"] != "test-client-id" {
+		t.Errorf("unexpected tasks: %v", tasks)
+	}
+	
+	// A target matching no clients is rejected
+	req, _ = http.NewRequest("POST", "/api/v1/tasks", bytes.NewBufferString(`{"tag":"none","module":"shell"}`))
+	rr = httptest.NewRecorder()
+	apiHandler.Handler().ServeHTTP(rr, req)
+	
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
+
+// TestIdempotentCreate tests that repeating a creation request with the
+// same idempotency key replays the first response
+func TestIdempotentCreate(t *testing.T) {
+	apiHandler, _, _ := setupTestAPI()
+	handler := apiHandler.Handler()
+	
+	post := func(key, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/v1/tasks", bytes.NewBufferString(body))
+		req.Header.Set(IdempotencyKeyHeader, key)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	
+	body := `{"clientId":"test-client-id","module":"shell","params":{"command":"id"}}`
+	first := post("retry-1", body)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v", first.Code, http.StatusCreated)
+	}
+	
+	second := post("retry-1", body)
+	if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() || second.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("expected the first response to be replayed, got %v: %s", second.Code, second.Body.String())
+	}
+	if got := len(apiHandler.taskManager.GetAllTasks()); got != 1 {
+		t.Errorf("expected 1 task, got %d", got)
+	}
+	
+	if rr := post("retry-1", `{"clientId":"test-client-id","module":"file"}`); rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), CodeIdempotencyKeyReused) {
+		t.Errorf("expected %s, got %v: %s", CodeIdempotencyKeyReused, rr.Code, rr.Body.String())
+	}
+	
+	if rr := post("retry-2", body); rr.Code != http.StatusCreated || rr.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Errorf("expected a new key to create a task, got %v", rr.Code)
+	}
+	if got := len(apiHandler.taskManager.GetAllTasks()); got != 2 {
+		t.Errorf("expected 2 tasks, got %d", got)
+	}
+}
+
+// TestCancelTask tests DELETE /api/v1/tasks/{id}
+func TestCancelTask(t *testing.T) {
+	apiHandler, _, _ := setupTestAPI()
+	
+	created, err := apiHandler.taskManager.CreateTask("test-client-id", "shell", json.RawMessage(`{"command":"sleep 600"}`))
+	if err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+	apiHandler.taskManager.PendingCommands("test-client-id")
+	
+	req, _ := http.NewRequest("DELETE", "/api/v1/tasks/"+created.ID, nil)
+	rr := httptest.NewRecorder()
+	apiHandler.Handler().ServeHTTP(rr, req)
+	
+	if status := rr.Code; status != http.StatusAccepted {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusAccepted)
+	}
+	
+	var cancelled task.Task
+	json.Unmarshal(rr.Body.Bytes(), &cancelled)
+	if cancelled.Status != task.StatusCancelling {
+		t.Errorf("expected status cancelling, got %s", cancelled.Status)
+	}
+	
+	// Once the client confirms, the task can no longer be cancelled
+	apiHandler.taskManager.HandleFeedback(task.Feedback{CommandID: created.ID, Status: "cancelled"})
+	
+	req, _ = http.NewRequest("DELETE", "/api/v1/tasks/"+created.ID, nil)
+	rr = httptest.NewRecorder()
+	apiHandler.Handler().ServeHTTP(rr, req)
+	
+	if status := rr.Code; status != http.StatusConflict || !strings.Contains(rr.Body.String(), "task_finished") {
+		t.Errorf("expected task_finished, got %v: %s", status, rr.Body.String())
+	}
+}
+
+// TestBatchTasks tests the /api/v1/batches endpoints
+func TestBatchTasks(t *testing.T) {
+	apiHandler, clientManager, _ := setupTestAPI()
+	clientManager.RegisterClient(client.NewClient("test-client-id-2", "Test Client 2", "192.168.1.101", "Linux", "x86_64", []string{"shell"}, "ws"))
+	
+	req, _ := http.NewRequest("POST", "/api/v1/batches", bytes.NewBufferString(`{"filter":"os=linux","module":"shell","params":{"command":"id"}}`))
+	rr := httptest.NewRecorder()
+	apiHandler.Handler().ServeHTTP(rr, req)
+	
+	if status := rr.Code; status != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+	}
+	
+	var batch task.BatchSummary
+	json.Unmarshal(rr.Body.Bytes(), &batch)
+	if batch.Total != 2 || batch.Pending != 2 || batch.Status != task.BatchRunning || len(batch.Results) != 2 {
+		t.Fatalf("unexpected batch: %+v", batch)
+	}
+	
+	// Complete one task and fail the other
+	apiHandler.taskManager.HandleFeedback(task.Feedback{CommandID: batch.TaskIDs[0], Status: "completed", Result: json.RawMessage(`{"output":"uid=0"}`)})
+	apiHandler.taskManager.HandleFeedback(task.Feedback{CommandID: batch.TaskIDs[1], Status: "failed", Error: "denied"})
+	
+	req, _ = http.NewRequest("GET", "/api/v1/batches/"+batch.ID, nil)
+	rr = httptest.NewRecorder()
+	apiHandler.Handler().ServeHTTP(rr, req)
+	
+	var summary task.BatchSummary
+	json.Unmarshal(rr.Body.Bytes(), &summary)
+	if summary.Succeeded != 1 || summary.Failed != 1 || summary.Status != task.BatchPartial {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+	for _, result := range summary.Results {
+		if result.Result != nil {
+			t.Errorf("expected the summary to omit module output, got %s", result.Result)
+		}
+	}
+	
+	// The results download carries the module output
+	req, _ = http.NewRequest("GET", "/api/v1/batches/"+batch.ID+"/results", nil)
+	rr = httptest.NewRecorder()
+	apiHandler.Handler().ServeHTTP(rr, req)
+	
+	if disposition := rr.Header().Get("Content-Disposition"); !strings.HasPrefix(disposition, "attachment") {
+		t.Errorf("expected an attachment, got %q", disposition)
+	}
+	
+	var results task.BatchSummary
+	json.Unmarshal(rr.Body.Bytes(), &results)
+	outputs := 0
+	for _, result := range results.Results {
+		if result.Result != nil {
+			outputs++
+		}
+	}
+	if outputs != 1 {
+		t.Errorf("expected 1 result with output, got %d", outputs)
+	}
+	
+	// Selectors must name exactly one kind of target
+	req, _ = http.NewRequest("POST", "/api/v1/batches", bytes.NewBufferString(`{"tag":"dmz","filter":"os=linux","module":"shell"}`))
+	rr = httptest.NewRecorder()
+	apiHandler.Handler().ServeHTTP(rr, req)
+	
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+	
+	req, _ = http.NewRequest("GET", "/api/v1/batches/missing", nil)
+	rr = httptest.NewRecorder()
+	apiHandler.Handler().ServeHTTP(rr, req)
+	
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+	}
+}
+
+// TestSchedules tests the /api/v1/schedules endpoints
+func TestSchedules(t *testing.T) {
+	apiHandler, clientManager, _ := setupTestAPI()
+	
+	req, _ := http.NewRequest("POST", "/api/v1/schedules", bytes.NewBufferString(`{"filter":"os=windows","module":"inventory","onRegister":true}`))
+	rr := httptest.NewRecorder()
+	apiHandler.Handler().ServeHTTP(rr, req)
+	
+	if status := rr.Code; status != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+	}
+	
+	var schedule task.Schedule
+	json.Unmarshal(rr.Body.Bytes(), &schedule)
+	if schedule.Kind != task.ScheduleOnRegister || schedule.Selector != "filter:os=windows" || !schedule.Enabled {
+		t.Fatalf("unexpected schedule: %+v", schedule)
+	}
+	
+	// A new matching client triggers the schedule
+	clientManager.RegisterClient(client.NewClient("test-client-id-2", "Test Client 2", "192.168.1.101", "Windows", "x86_64", []string{"shell"}, "ws"))
+	
+	req, _ = http.NewRequest("GET", "/api/v1/schedules/"+schedule.ID, nil)
+	rr = httptest.NewRecorder()
+	apiHandler.Handler().ServeHTTP(rr, req)
+	
+	json.Unmarshal(rr.Body.Bytes(), &schedule)
+	if schedule.Runs != 1 || schedule.LastBatchID == "" {
+		t.Errorf("expected the schedule to run once, got %+v", schedule)
+	}
+	
+	// Invalid cron expressions are rejected with their own code
+	req, _ = http.NewRequest("POST", "/api/v1/schedules", bytes.NewBufferString(`{"tag":"dmz","module":"shell","cron":"every day"}`))
+	rr = httptest.NewRecorder()
+	apiHandler.Handler().ServeHTTP(rr, req)
+	
+	var envelope ErrorResponse
+	json.Unmarshal(rr.Body.Bytes(), &envelope)
+	if rr.Code != http.StatusBadRequest || envelope.Error.Code != "invalid_cron" {
+		t.Errorf("expected 400 invalid_cron, got %v %s", rr.Code, envelope.Error.Code)
+	}
+}
+
+// TestWorkflows tests the /api/v1/workflows endpoints
+func TestWorkflows(t *testing.T) {
+	apiHandler, _, _ := setupTestAPI()
+	
+	body := `{"clientIds":["test-client-id"],"name":"triage","steps":[` +
+		`{"name":"probe","module":"shell","params":{"command":"uname"}},` +
+		`{"name":"collect","module":"shell","params":{"command":"{{steps.probe.result.output}}"},"dependsOn":["probe"]}]}`
+	req, _ := http.NewRequest("POST", "/api/v1/workflows", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+	apiHandler.Handler().ServeHTTP(rr, req)
+	
+	if status := rr.Code; status != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+	}
+	
+	var workflow task.Workflow
+	json.Unmarshal(rr.Body.Bytes(), &workflow)
+	if len(workflow.Runs) != 1 || len(workflow.Steps) != 2 || workflow.Steps[1].DependsOn[0] != "probe" {
+		t.Fatalf("unexpected workflow: %+v", workflow)
+	}
+	
+	probe := workflow.Runs[0].Steps[0]
+	apiHandler.taskManager.HandleFeedback(task.Feedback{CommandID: probe.TaskID, Status: "completed", Result: json.RawMessage(`{"output":"Linux"}`)})
+	
+	req, _ = http.NewRequest("GET", "/api/v1/workflows/"+workflow.ID, nil)
+	rr = httptest.NewRecorder()
+	apiHandler.Handler().ServeHTTP(rr, req)
+	
+	json.Unmarshal(rr.Body.Bytes(), &workflow)
+	steps := workflow.Runs[0].Steps
+	if steps[0].Status != task.StepCompleted || steps[1].Status != task.StepRunning {
+		t.Fatalf("unexpected step states: %+v", steps)
+	}
+	
+	collect, _ := apiHandler.taskManager.GetTask(steps[1].TaskID)
+	if string(collect.Params) != `{"command":"Linux"}` {
+		t.Errorf("expected rendered params, got %s", collect.Params)
+	}
+	
+	// Steps that depend on each other are rejected
+	req, _ = http.NewRequest("POST", "/api/v1/workflows", bytes.NewBufferString(`{"tag":"dmz","steps":[`+
+		`{"name":"a","module":"shell","dependsOn":["b"]},{"name":"b","module":"shell","dependsOn":["a"]}]}`))
+	rr = httptest.NewRecorder()
+	apiHandler.Handler().ServeHTTP(rr, req)
+	
+	var envelope ErrorResponse
+	json.Unmarshal(rr.Body.Bytes(), &envelope)
+	if rr.Code != http.StatusBadRequest || envelope.Error.Code != "invalid_workflow" {
+		t.Errorf("expected 400 invalid_workflow, got %v %s", rr.Code, envelope.Error.Code)
+	}
+}
+
+// TestListeners tests the /api/v1/listeners endpoints
+func TestListeners(t *testing.T) {
+	apiHandler, _, _ := setupTestAPI()
+	defer apiHandler.listenerManager.CleanupManager()
+	apiHandler.listenerManager.SetHandler(func(name string, conn net.Conn) { conn.Close() })
+	
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		apiHandler.Handler().ServeHTTP(rr, req)
+		return rr
+	}
+	errorCode := func(rr *httptest.ResponseRecorder) string {
+		var envelope ErrorResponse
+		json.Unmarshal(rr.Body.Bytes(), &envelope)
+		return envelope.Error.Code
+	}
+	
+	rr := send("POST", "/api/v1/listeners", `{"protocol":"tcp","address":"127.0.0.1:0"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusCreated, rr.Body.String())
+	}
+	
+	var info listener.Info
+	json.Unmarshal(rr.Body.Bytes(), &info)
+	if info.Name != "tcp" || info.Protocol != "tcp" || info.Status != listener.StatusStopped || info.BufferSize != 4096 {
+		t.Fatalf("unexpected listener: %+v", info)
+	}
+	
+	// A second listener for the same protocol needs a name of its own
+	rr = send("POST", "/api/v1/listeners", `{"name":"tcp-internal","protocol":"tcp","address":"127.0.0.1:0"}`)
+	json.Unmarshal(rr.Body.Bytes(), &info)
+	if rr.Code != http.StatusCreated || info.Name != "tcp-internal" || info.Protocol != "tcp" {
+		t.Fatalf("unexpected named listener: %v %+v", rr.Code, info)
+	}
+	if rr = send("PUT", "/api/v1/listeners/tcp-internal", `{"address":"127.0.0.1:0","domain":"c2.example.org"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for DNS settings of a TCP listener, got %v", rr.Code)
+	}
+	
+	// Invalid configurations are rejected by validation
+	for _, body := range []string{
+		`{"protocol":"tcp","address":"127.0.0.1:0"}`,
+		`{"name":"tcp-internal","protocol":"udp","address":"127.0.0.1:0"}`,
+		`{"name":"bad/name","protocol":"udp","address":"127.0.0.1:0"}`,
+		`{"protocol":"udp","address":"no-port"}`,
+		`{"protocol":"udp","address":"127.0.0.1:0","tls":true,"tls_cert_file":"cert.pem","tls_key_file":"key.pem"}`,
+		`{"protocol":"udp","address":"127.0.0.1:0","domain":"c2.example.org"}`,
+		`{"protocol":"dns","address":"127.0.0.1:0","record_types":["BOGUS"]}`,
+		`{"protocol":"smtp","address":"127.0.0.1:0"}`,
+	} {
+		rr = send("POST", "/api/v1/listeners", body)
+		if rr.Code != http.StatusBadRequest && rr.Code != http.StatusConflict {
+			t.Errorf("%s: expected an error, got %v", body, rr.Code)
+		}
+	}
+	
+	// DNS listeners get default settings for those left unset
+	rr = send("POST", "/api/v1/listeners", `{"protocol":"dns","address":"127.0.0.1:0","domain":"c2.example.org"}`)
+	json.Unmarshal(rr.Body.Bytes(), &info)
+	if rr.Code != http.StatusCreated || info.Domain != "c2.example.org" || info.TTL != 60 || len(info.RecordTypes) != 2 {
+		t.Fatalf("unexpected DNS listener: %v %+v", rr.Code, info)
+	}
+	
+	rr = send("POST", "/api/v1/listeners/tcp/start", "")
+	json.Unmarshal(rr.Body.Bytes(), &info)
+	if rr.Code != http.StatusOK || info.Status != listener.StatusRunning {
+		t.Fatalf("expected a running listener, got %v %+v", rr.Code, info)
+	}
+	
+	// A running listener cannot be started again or reconfigured
+	if rr = send("POST", "/api/v1/listeners/tcp/start", ""); rr.Code != http.StatusConflict || errorCode(rr) != "listener_already_running" {
+		t.Errorf("expected 409 listener_already_running, got %v %s", rr.Code, errorCode(rr))
+	}
+	if rr = send("PUT", "/api/v1/listeners/tcp", `{"address":"127.0.0.1:0"}`); rr.Code != http.StatusConflict {
+		t.Errorf("expected 409 when updating a running listener, got %v", rr.Code)
+	}
+	
+	rr = send("POST", "/api/v1/listeners/tcp/stop", "")
+	json.Unmarshal(rr.Body.Bytes(), &info)
+	if rr.Code != http.StatusOK || info.Status != listener.StatusStopped {
+		t.Fatalf("expected a stopped listener, got %v %+v", rr.Code, info)
+	}
+	
+	rr = send("PUT", "/api/v1/listeners/tcp", `{"address":"127.0.0.1:0","max_connections":5}`)
+	json.Unmarshal(rr.Body.Bytes(), &info)
+	if rr.Code != http.StatusOK || info.MaxConnections != 5 {
+		t.Errorf("unexpected updated listener: %v %+v", rr.Code, info)
+	}
+	
+	// The listeners are listed by name
+	rr = send("GET", "/api/v1/listeners", "")
+	var page struct {
+		Items []listener.Info `json:"items"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &page)
+	if len(page.Items) != 3 || page.Items[0].Name != "dns" || page.Items[1].Name != "tcp" || page.Items[2].Name != "tcp-internal" {
+		t.Errorf("unexpected listeners: %+v", page.Items)
+	}
+	
+	if rr = send("DELETE", "/api/v1/listeners/tcp", ""); rr.Code != http.StatusNoContent {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNoContent)
+	}
+	if rr = send("GET", "/api/v1/listeners/tcp", ""); rr.Code != http.StatusNotFound || errorCode(rr) != "listener_not_registered" {
+		t.Errorf("expected 404 listener_not_registered, got %v %s", rr.Code, errorCode(rr))
+	}
+}
+
+// TestGetClientsQuery tests the q, sort and fields parameters of GET /api/v1/clients
+func TestGetClientsQuery(t *testing.T) {
+	apiHandler, clientManager, _ := setupTestAPI()
+	clientManager.RegisterClient(client.NewClient("test-client-id-2", "Test Client 2", "192.168.1.101", "Windows", "x86_64", []string{"shell"}, "ws"))
+	clientManager.AddClientTag("test-client-id-2", "dmz")
+	
+	req, _ := http.NewRequest("GET", "/api/v1/clients?q=os%3Dwindows+and+tag%3Admz&fields=id,os", nil)
+	rr := httptest.NewRecorder()
+	apiHandler.Handler().ServeHTTP(rr, req)
+	
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	
+	var page struct {
+		Items []map[string]interface{} `json:"items"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &page)
+	clients := page.Items
+	if len(clients) != 1 || clients[0]["id"] != "test-client-id-2" || len(clients[0]) != 2 {
+		t.Errorf("unexpected clients: %v", clients)
+	}
+	
+	// Invalid expressions are rejected
+	req, _ = http.NewRequest("GET", "/api/v1/clients?q=os%3C", nil)
+	rr = httptest.NewRecorder()
+	apiHandler.Handler().ServeHTTP(rr, req)
+	
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
+
+// TestGetClientHistory tests the GET /api/v1/clients/{id}/history endpoint
+func TestGetClientHistory(t *testing.T) {
+	apiHandler, clientManager, _ := setupTestAPI()
+	clientManager.UpdateClientStatusWithCause("test-client-id", client.StatusBusy, "", client.CauseOperator)
+	
+	req, _ := http.NewRequest("GET", "/api/v1/clients/test-client-id/history", nil)
+	rr := httptest.NewRecorder()
+	apiHandler.Handler().ServeHTTP(rr, req)
+	
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	
+	var response struct {
+		History []client.StatusTransition `json:"history"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	if len(response.History) != 2 || response.History[1].Cause != client.CauseOperator {
+		t.Errorf("unexpected history: %+v", response.History)
+	}
+	
+	// The history is only served by its own endpoint
+	req, _ = http.NewRequest("GET", "/api/v1/clients/test-client-id", nil)
+	rr = httptest.NewRecorder()
+	apiHandler.Handler().ServeHTTP(rr, req)
+	if strings.Contains(rr.Body.String(), "history") {
+		t.Errorf("expected the client without its history, got %s", rr.Body.String())
+	}
+}
+
+// TestRoleBasedAccess tests that the auth middleware enforces role permissions
+func TestRoleBasedAccess(t *testing.T) {
+	clientManager := client.NewClientManager()
+	heartbeatMonitor := client.NewHeartbeatMonitor(clientManager, 30*time.Second, 60*time.Second)
+	
+	config := Config{
+		Address:      "127.0.0.1:8080",
+		AuthEnabled:  true,
+		AuthUser:     "admin",
+		AuthPassword: "password",
+	}
+	
+	apiHandler := NewAPIHandler(clientManager, heartbeatMonitor, task.NewTaskManager(clientManager), config)
+	apiHandler.userStore.CreateUser("viewer", "viewer-password", auth.RoleViewer)
+	apiHandler.userStore.CreateUser("operator", "operator-password", auth.RoleOperator)
+	
+	handler := apiHandler.Handler()
+	
+	tests := []struct {
+		user     string
+		password string
+		method   string
+		path     string
+		want     int
+	}{
+		{"viewer", "viewer-password", "GET", "/api/v1/clients", http.StatusOK},
+		{"viewer", "viewer-password", "POST", "/api/v1/tasks", http.StatusForbidden},
+		{"viewer", "viewer-password", "POST", "/api/v1/clients/test-client-id/modules/shell", http.StatusForbidden},
+		{"operator", "operator-password", "GET", "/api/v1/tasks", http.StatusOK},
+		{"operator", "operator-password", "GET", "/api/v1/users", http.StatusForbidden},
+		{"admin", "password", "GET", "/api/v1/users", http.StatusOK},
+		{"viewer", "wrong-password", "GET", "/api/v1/clients", http.StatusUnauthorized},
+	}
+	
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, tt.path, nil)
+		req.SetBasicAuth(tt.user, tt.password)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		
+		if rr.Code != tt.want {
+			t.Errorf("%s %s as %s: got %v want %v", tt.method, tt.path, tt.user, rr.Code, tt.want)
+		}
+	}
+}
+
+// TestTokenLifecycle tests login, refresh and logout
+func TestTokenLifecycle(t *testing.T) {
+	clientManager := client.NewClientManager()
+	heartbeatMonitor := client.NewHeartbeatMonitor(clientManager, 30*time.Second, 60*time.Second)
+	
+	config := Config{
+		Address:      "127.0.0.1:8080",
+		AuthEnabled:  true,
+		AuthUser:     "admin",
+		AuthPassword: "password",
+		JWTSecret:    "secret",
+		JWTEnabled:   true,
+	}
+	
+	apiHandler := NewAPIHandler(clientManager, heartbeatMonitor, task.NewTaskManager(clientManager), config)
+	protected := apiHandler.Handler()
+	
+	// Wrong credentials are rejected
+	req, _ := http.NewRequest("POST", "/api/v1/auth/login", bytes.NewBufferString(`{"username":"admin","password":"wrong"}`))
+	rr := httptest.NewRecorder()
+	apiHandler.Handler().ServeHTTP(rr, req)
+	
+	if status := rr.Code; status != http.StatusUnauthorized {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusUnauthorized)
+	}
+	
+	// Log in
+	req, _ = http.NewRequest("POST", "/api/v1/auth/login", bytes.NewBufferString(`{"username":"admin","password":"password"}`))
+	rr = httptest.NewRecorder()
+	apiHandler.Handler().ServeHTTP(rr, req)
+	
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	
+	var tokens auth.TokenPair
+	json.Unmarshal(rr.Body.Bytes(), &tokens)
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" || tokens.ExpiresIn != 900 {
+		t.Fatalf("unexpected tokens: %+v", tokens)
+	}
+	
+	// The refresh token cannot be used as an access token
+	req, _ = http.NewRequest("GET", "/api/v1/clients", nil)
+	req.Header.Set("Authorization", "Bearer "+tokens.RefreshToken)
+	rr = httptest.NewRecorder()
+	protected.ServeHTTP(rr, req)
+	
+	if status := rr.Code; status != http.StatusUnauthorized {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusUnauthorized)
+	}
+	
+	// Refresh, then check that the old refresh token is single use
+	refresh := func(token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/v1/auth/refresh", bytes.NewBufferString(`{"refresh_token":"`+token+`"}`))
+		rr := httptest.NewRecorder()
+		apiHandler.Handler().ServeHTTP(rr, req)
+		return rr
+	}
+	
+	rr = refresh(tokens.RefreshToken)
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	
+	var refreshed auth.TokenPair
+	json.Unmarshal(rr.Body.Bytes(), &refreshed)
+	
+	if status := refresh(tokens.RefreshToken).Code; status != http.StatusUnauthorized {
+		t.Errorf("reused refresh token: got %v want %v", status, http.StatusUnauthorized)
+	}
+	
+	// Log out, revoking both tokens
+	req, _ = http.NewRequest("POST", "/api/v1/auth/logout", bytes.NewBufferString(`{"refresh_token":"`+refreshed.RefreshToken+`"}`))
+	req.Header.Set("Authorization", "Bearer "+refreshed.AccessToken)
+	rr = httptest.NewRecorder()
+	apiHandler.Handler().ServeHTTP(rr, req)
+	
+	if status := rr.Code; status != http.StatusNoContent {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusNoContent)
+	}
+	
+	req, _ = http.NewRequest("GET", "/api/v1/clients", nil)
+	req.Header.Set("Authorization", "Bearer "+refreshed.AccessToken)
+	rr = httptest.NewRecorder()
+	protected.ServeHTTP(rr, req)
+	
+	if status := rr.Code; status != http.StatusUnauthorized {
+		t.Errorf("revoked access token: got %v want %v", status, http.StatusUnauthorized)
+	}
+	
+	if status := refresh(refreshed.RefreshToken).Code; status != http.StatusUnauthorized {
+		t.Errorf("revoked refresh token: got %v want %v", status, http.StatusUnauthorized)
+	}
+}
+
+// TestAPIKeyAccess tests API key creation and scope enforcement
+func TestAPIKeyAccess(t *testing.T) {
+	clientManager := client.NewClientManager()
+	heartbeatMonitor := client.NewHeartbeatMonitor(clientManager, 30*time.Second, 60*time.Second)
+	
+	config := Config{
+		Address:      "127.0.0.1:8080",
+		AuthEnabled:  true,
+		AuthUser:     "admin",
+		AuthPassword: "password",
+	}
+	
+	apiHandler := NewAPIHandler(clientManager, heartbeatMonitor, task.NewTaskManager(clientManager), config)
+	
+	// Create a key as the admin
+	req, _ := http.NewRequest("POST", "/api/v1/keys", bytes.NewBufferString(`{"name":"ci","scopes":["clients:read"],"expiresIn":3600}`))
+	req.SetBasicAuth("admin", "password")
+	rr := httptest.NewRecorder()
+	apiHandler.Handler().ServeHTTP(rr, req)
+	
+	if status := rr.Code; status != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+	}
+	
+	var created struct {
+		Owner     string     `json:"owner"`
+		Key       string     `json:"key"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	if created.Key == "" || created.Owner != "admin" || created.ExpiresAt == nil {
+		t.Fatalf("unexpected key: %s", rr.Body.String())
+	}
+	
+	handler := apiHandler.Handler()
+	
+	tests := []struct {
+		header string
+		value  string
+		method string
+		path   string
+		want   int
+	}{
+		{"X-API-Key", created.Key, "GET", "/api/v1/clients", http.StatusOK},
+		{"Authorization", "Bearer " + created.Key, "GET", "/api/v1/clients", http.StatusOK},
+		{"X-API-Key", created.Key, "POST", "/api/v1/tasks", http.StatusForbidden},
+		{"X-API-Key", created.Key, "GET", "/api/v1/keys", http.StatusForbidden},
+		{"X-API-Key", "dk_unknown_secret", "GET", "/api/v1/clients", http.StatusUnauthorized},
+	}
+	
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set(tt.header, tt.value)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		
+		if rr.Code != tt.want {
+			t.Errorf("%s %s with %s: got %v want %v", tt.method, tt.path, tt.header, rr.Code, tt.want)
+		}
+	}
+}
+
+// TestTLSCertificateGeneration tests that a generated certificate is written
+// once and keeps its fingerprint
+func TestTLSCertificateGeneration(t *testing.T) {
+	dir := t.TempDir()
+	config := &TLSConfig{
+		CertFile: filepath.Join(dir, "api-cert.pem"),
+		KeyFile:  filepath.Join(dir, "api-key.pem"),
+	}
+	
+	_, first, err := BuildTLSConfig(config, "127.0.0.1:8081")
+	if err != nil {
+		t.Fatalf("BuildTLSConfig failed: %v", err)
+	}
+	
+	_, second, err := BuildTLSConfig(config, "127.0.0.1:8081")
+	if err != nil {
+		t.Fatalf("BuildTLSConfig failed: %v", err)
+	}
+	
+	if first == "" || first != second {
+		t.Errorf("fingerprint changed across restarts: %s != %s", first, second)
+	}
+	
+	// Client certificates cannot be required without a CA
+	if _, _, err := BuildTLSConfig(&TLSConfig{RequireClientCert: true}, "127.0.0.1:8081"); err == nil {
+		t.Error("expected an error when requiring client certificates without a CA")
+	}
+}
+
+// TestClientCertificateAuth tests that operator certificates map to users
+func TestClientCertificateAuth(t *testing.T) {
+	clientManager := client.NewClientManager()
+	heartbeatMonitor := client.NewHeartbeatMonitor(clientManager, 30*time.Second, 60*time.Second)
+	
+	config := Config{
+		Address:      "127.0.0.1:8080",
+		AuthEnabled:  true,
+		AuthUser:     "admin",
+		AuthPassword: "password",
+	}
+	
+	apiHandler := NewAPIHandler(clientManager, heartbeatMonitor, task.NewTaskManager(clientManager), config)
+	apiHandler.userStore.CreateUser("viewer", "viewer-password", auth.RoleViewer)
+	
+	// Create an operator CA and certificates for a viewer and an unknown user
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "operator CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, _ := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	caCert, _ := x509.ParseCertificate(caDER)
+	
+	operatorCert := func(name string, serial int64) tls.Certificate {
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}
+		der, _ := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+		return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	}
+	
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), 0600)
+	
+	tlsConfig, _, err := BuildTLSConfig(&TLSConfig{ClientCAFile: caFile}, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("BuildTLSConfig failed: %v", err)
+	}
+	
+	server := httptest.NewUnstartedServer(apiHandler.Handler())
+	server.TLS = tlsConfig
+	server.StartTLS()
+	defer server.Close()
+	
+	get := func(cert *tls.Certificate) *http.Response {
+		transport := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+		if cert != nil {
+			transport.TLSClientConfig.Certificates = []tls.Certificate{*cert}
+		}
+		resp, err := (&http.Client{Transport: transport}).Get(server.URL + "/api/v1/me")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		return resp
+	}
+	
+	viewer := operatorCert("viewer", 2)
+	resp := get(&viewer)
+	defer resp.Body.Close()
+	
+	var identity auth.Identity
+	json.NewDecoder(resp.Body).Decode(&identity)
+	if resp.StatusCode != http.StatusOK || identity.Username != "viewer" || identity.Role != auth.RoleViewer || identity.Method != "mtls" {
+		t.Errorf("unexpected response %d: %+v", resp.StatusCode, identity)
+	}
+	
+	unknown := operatorCert("mallory", 3)
+	if resp := get(&unknown); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unknown user: got %v want %v", resp.StatusCode, http.StatusUnauthorized)
+	}
+	
+	if resp := get(nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("no certificate: got %v want %v", resp.StatusCode, http.StatusUnauthorized)
+	}
+}
+
+// TestIsLoopbackAddress tests the detection of API addresses that need no
+// authentication
+func TestIsLoopbackAddress(t *testing.T) {
+	tests := map[string]bool{
+		"127.0.0.1:8090": true,
+		"[::1]:8090":     true,
+		"localhost:8090": true,
+		"0.0.0.0:8090":   false,
+		":8090":          false,
+		"10.0.0.5:8090":  false,
+		"example.com:80": false,
+		"127.0.0.1":      false,
+	}
+	
+	for address, want := range tests {
+		if got := IsLoopbackAddress(address); got != want {
+			t.Errorf("IsLoopbackAddress(%q) = %v, want %v", address, got, want)
+		}
+	}
+}
+
+// TestBootstrapAdminPassword tests that a bootstrap admin gets a random
+// password when none is configured
+func TestBootstrapAdminPassword(t *testing.T) {
+	clientManager := client.NewClientManager()
+	heartbeatMonitor := client.NewHeartbeatMonitor(clientManager, 30*time.Second, 60*time.Second)
+	userStore := auth.NewUserStore()
+	
+	NewAPIHandler(clientManager, heartbeatMonitor, task.NewTaskManager(clientManager), Config{
+		AuthEnabled: true,
+		AuthUser:    "admin",
+		UserStore:   userStore,
+	})
+	
+	user, err := userStore.GetUser("admin")
+	if err != nil {
+		t.Fatalf("expected a bootstrap admin: %v", err)
+	}
+	if user.Role != auth.RoleAdmin {
+		t.Errorf("expected role admin, got %s", user.Role)
+	}
+	if _, err := userStore.Authenticate("admin", ""); err == nil {
+		t.Error("expected the empty password to be rejected")
+	}
+}
+
+// TestClientCAEnablesAuth tests that configuring operator client
+// certificates turns authentication on
+func TestClientCAEnablesAuth(t *testing.T) {
+	clientManager := client.NewClientManager()
+	heartbeatMonitor := client.NewHeartbeatMonitor(clientManager, 30*time.Second, 60*time.Second)
+	
+	apiHandler := NewAPIHandler(clientManager, heartbeatMonitor, task.NewTaskManager(clientManager), Config{
+		TLS: &TLSConfig{ClientCAFile: "ca.pem"},
+	})
+	if !apiHandler.authEnabled {
+		t.Fatal("expected client CAs to enable authentication")
+	}
+	
+	req, _ := http.NewRequest("GET", "/api/v1/clients", nil)
+	rr := httptest.NewRecorder()
+	apiHandler.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Cl0udRs4/dinot/internal/server/auth"
+)
+
+// CreateAPIKeyRequest creates an API key. ExpiresIn is a lifetime in seconds.
+type CreateAPIKeyRequest struct {
+	Name      string            `json:"name"`
+	Scopes    []auth.Permission `json:"scopes"`
+	ExpiresAt *time.Time        `json:"expiresAt,omitempty"`
+	ExpiresIn int64             `json:"expiresIn,omitempty"`
+}
+
+// CreatedAPIKey is a new API key together with its plaintext secret
+type CreatedAPIKey struct {
+	*auth.APIKey
+	Key string `json:"key"`
+}
+
+// handleListAPIKeys handles GET /api/v1/keys; secrets are never returned
+func (h *APIHandler) handleListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	writeList(w, r, h.apiKeyStore.ListKeys(), listSpec{key: "id"})
+}
+
+// handleCreateAPIKey handles POST /api/v1/keys. The expiry is either an
+// absolute time or a lifetime in seconds; without either the key does not
+// expire.
+func (h *APIHandler) handleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var data CreateAPIKeyRequest
+	if !decodeBody(w, r, &data) {
+		return
+	}
+
+	expiresAt := data.ExpiresAt
+	if expiresAt == nil && data.ExpiresIn > 0 {
+		t := time.Now().Add(time.Duration(data.ExpiresIn) * time.Second)
+		expiresAt = &t
+	}
+
+	owner := ""
+	if identity, ok := auth.IdentityFromContext(r.Context()); ok {
+		owner = identity.Username
+	}
+
+	key, secret, err := h.apiKeyStore.CreateKey(data.Name, owner, data.Scopes, expiresAt)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// The plaintext key is only ever shown in this response
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusCreated, CreatedAPIKey{APIKey: key, Key: secret})
+}
+
+// handleGetAPIKey handles GET /api/v1/keys/{id}
+func (h *APIHandler) handleGetAPIKey(w http.ResponseWriter, r *http.Request) {
+	key, err := h.apiKeyStore.GetKey(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, key)
+}
+
+// handleRevokeAPIKey handles DELETE /api/v1/keys/{id}
+func (h *APIHandler) handleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if err := h.apiKeyStore.RevokeKey(r.PathValue("id")); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
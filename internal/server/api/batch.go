@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Cl0udRs4/dinot/internal/server/client"
+	"github.com/Cl0udRs4/dinot/internal/server/task"
+)
+
+// CreateBatchRequest runs a module on a list of clients, a tag, a group or
+// the clients matching a filter
+type CreateBatchRequest struct {
+	client.Target
+	Module string          `json:"module"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// handleListBatches handles GET /api/v1/batches
+func (h *APIHandler) handleListBatches(w http.ResponseWriter, r *http.Request) {
+	batches := h.taskManager.GetAllBatches()
+
+	summaries := make([]*task.BatchSummary, 0, len(batches))
+	for _, batch := range batches {
+		summaries = append(summaries, h.taskManager.SummarizeBatch(batch, false, false))
+	}
+
+	writeList(w, r, summaries, listSpec{key: "id", defaultSort: "created_at"})
+}
+
+// handleCreateBatch handles POST /api/v1/batches, which fans a module
+// invocation out to every selected client
+func (h *APIHandler) handleCreateBatch(w http.ResponseWriter, r *http.Request) {
+	var data CreateBatchRequest
+	if !decodeBody(w, r, &data) {
+		return
+	}
+
+	batch, err := h.taskManager.CreateBatch(data.Target, data.Module, data.Params)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, h.taskManager.SummarizeBatch(batch, true, false))
+}
+
+// handleGetBatch handles GET /api/v1/batches/{id}, which returns the
+// aggregate status and the status of every task
+func (h *APIHandler) handleGetBatch(w http.ResponseWriter, r *http.Request) {
+	batch, err := h.taskManager.GetBatch(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.taskManager.SummarizeBatch(batch, true, false))
+}
+
+// handleGetBatchResults handles GET /api/v1/batches/{id}/results, which
+// downloads the module output of every task as a single JSON document
+func (h *APIHandler) handleGetBatchResults(w http.ResponseWriter, r *http.Request) {
+	batch, err := h.taskManager.GetBatch(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", batch.ID+"-results.json"))
+	writeJSON(w, http.StatusOK, h.taskManager.SummarizeBatch(batch, true, true))
+}
@@ -0,0 +1,229 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Cl0udRs4/dinot/internal/server/auth"
+	"github.com/Cl0udRs4/dinot/internal/server/client"
+	"github.com/Cl0udRs4/dinot/internal/server/common"
+	"github.com/Cl0udRs4/dinot/internal/server/task"
+)
+
+// Error codes returned in the error envelope. Errors of the client, task and
+// auth packages have their own codes, listed in errorMappings.
+const (
+	CodeBadRequest       = "bad_request"
+	CodeInvalidBody      = "invalid_body"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeInvalidToken     = "invalid_token"
+	CodeInternal         = "internal_error"
+)
+
+// ErrorResponse is the body of every API error response
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// ErrorBody describes an API error
+type ErrorBody struct {
+	// Code is a stable, machine-readable error code
+	Code string `json:"code"`
+
+	// Message is a human-readable description of the error
+	Message string `json:"message"`
+
+	// Details carries additional context, e.g. the missing permission
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// MessageResponse is the body of successful responses that carry no resource
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// errorMapping maps a sentinel error to an HTTP status and error code
+type errorMapping struct {
+	err    error
+	status int
+	code   string
+}
+
+// errorMappings lists the errors handlers can return, most specific first
+var errorMappings = []errorMapping{
+	// Collection parameter errors; checked first since they wrap the
+	// client manager's field errors
+	{ErrInvalidListParams, http.StatusBadRequest, "invalid_query"},
+	{ErrInvalidCursor, http.StatusBadRequest, "invalid_cursor"},
+
+	// Client manager errors
+	{client.ErrClientNotFound, http.StatusNotFound, "client_not_found"},
+	{client.ErrClientAlreadyExists, http.StatusConflict, "client_already_exists"},
+	{client.ErrGroupNotFound, http.StatusNotFound, "group_not_found"},
+	{client.ErrGroupAlreadyExists, http.StatusConflict, "group_already_exists"},
+	{client.ErrInvalidTag, http.StatusBadRequest, "invalid_tag"},
+	{client.ErrInvalidGroupName, http.StatusBadRequest, "invalid_group_name"},
+	{client.ErrInvalidTarget, http.StatusBadRequest, "invalid_target"},
+	{client.ErrInvalidFilter, http.StatusBadRequest, "invalid_filter"},
+
+	// Task manager errors
+	{task.ErrTaskNotFound, http.StatusNotFound, "task_not_found"},
+	{task.ErrMissingModule, http.StatusBadRequest, "missing_module"},
+	{task.ErrInvalidParams, http.StatusBadRequest, "invalid_params"},
+	{task.ErrNoClientsSelected, http.StatusBadRequest, "no_clients_selected"},
+	{task.ErrTaskFinished, http.StatusConflict, "task_finished"},
+	{task.ErrInvalidTimeout, http.StatusBadRequest, "invalid_timeout"},
+	{task.ErrBatchNotFound, http.StatusNotFound, "batch_not_found"},
+	{task.ErrWorkflowNotFound, http.StatusNotFound, "workflow_not_found"},
+	{task.ErrInvalidWorkflow, http.StatusBadRequest, "invalid_workflow"},
+	{task.ErrScheduleNotFound, http.StatusNotFound, "schedule_not_found"},
+	{task.ErrInvalidSchedule, http.StatusBadRequest, "invalid_schedule"},
+	{task.ErrInvalidCron, http.StatusBadRequest, "invalid_cron"},
+
+	// Account, token and API key errors
+	{auth.ErrUserNotFound, http.StatusNotFound, "user_not_found"},
+	{auth.ErrUserAlreadyExists, http.StatusConflict, "user_already_exists"},
+	{auth.ErrLastAdmin, http.StatusConflict, "last_admin"},
+	{auth.ErrInvalidRole, http.StatusBadRequest, "invalid_role"},
+	{auth.ErrInvalidUsername, http.StatusBadRequest, "invalid_username"},
+	{auth.ErrWeakPassword, http.StatusBadRequest, "weak_password"},
+	{auth.ErrInvalidCredentials, http.StatusUnauthorized, "invalid_credentials"},
+	{auth.ErrTokenRevoked, http.StatusUnauthorized, "token_revoked"},
+	{auth.ErrWrongTokenType, http.StatusUnauthorized, CodeInvalidToken},
+	{common.ErrExpiredToken, http.StatusUnauthorized, "token_expired"},
+	{common.ErrInvalidToken, http.StatusUnauthorized, CodeInvalidToken},
+	{auth.ErrAPIKeyNotFound, http.StatusNotFound, "api_key_not_found"},
+	{auth.ErrInvalidKeyName, http.StatusBadRequest, "invalid_key_name"},
+	{auth.ErrMissingScopes, http.StatusBadRequest, "missing_scopes"},
+	{auth.ErrInvalidScope, http.StatusBadRequest, "invalid_scope"},
+}
+
+// serverErrorStatus maps common.ServerError types to HTTP statuses
+var serverErrorStatus = map[string]int{
+	common.ErrListenerAlreadyRunning:    http.StatusConflict,
+	common.ErrListenerNotRunning:        http.StatusConflict,
+	common.ErrListenerAlreadyRegistered: http.StatusConflict,
+	common.ErrListenerNotRegistered:     http.StatusNotFound,
+	common.ErrListenerStartFailed:       http.StatusInternalServerError,
+	common.ErrListenerStopFailed:        http.StatusInternalServerError,
+	common.ErrInvalidConfig:             http.StatusBadRequest,
+	common.ErrNotImplemented:            http.StatusNotImplemented,
+}
+
+// errorStatus returns the HTTP status, error code and details for an error
+func errorStatus(err error) (int, string, map[string]interface{}) {
+	var serverErr *common.ServerError
+	if errors.As(err, &serverErr) {
+		status, ok := serverErrorStatus[serverErr.Type]
+		if !ok {
+			status = http.StatusInternalServerError
+		}
+
+		var details map[string]interface{}
+		if serverErr.Err != nil {
+			details = map[string]interface{}{"cause": serverErr.Err.Error()}
+		}
+		return status, strings.ReplaceAll(serverErr.Type, " ", "_"), details
+	}
+
+	for _, m := range errorMappings {
+		if errors.Is(err, m.err) {
+			return m.status, m.code, nil
+		}
+	}
+
+	return http.StatusInternalServerError, CodeInternal, nil
+}
+
+// writeJSON writes v as a JSON response with the given status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes err in the error envelope, with the status and code
+// mapped from its type
+func writeError(w http.ResponseWriter, err error) {
+	status, code, details := errorStatus(err)
+	writeErrorBody(w, status, ErrorBody{Code: code, Message: err.Error(), Details: details})
+}
+
+// writeErrorMessage writes an error envelope with an explicit status and code
+func writeErrorMessage(w http.ResponseWriter, status int, code, message string) {
+	writeErrorBody(w, status, ErrorBody{Code: code, Message: message})
+}
+
+// writeErrorBody writes an error envelope
+func writeErrorBody(w http.ResponseWriter, status int, body ErrorBody) {
+	writeJSON(w, status, ErrorResponse{Error: body})
+}
+
+// decodeBody decodes a JSON request body into v. It writes an invalid_body
+// error and returns false if the body is not valid JSON.
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeErrorMessage(w, http.StatusBadRequest, CodeInvalidBody, "invalid request body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// statusRecorder captures the status and headers written by a handler and
+// discards the body
+type statusRecorder struct {
+	header http.Header
+	status int
+}
+
+// Header returns the recorded headers
+func (r *statusRecorder) Header() http.Header {
+	return r.header
+}
+
+// Write discards the body
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return len(b), nil
+}
+
+// WriteHeader records the status
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+// withErrorEnvelope wraps a mux so that requests matching no route get a
+// not_found or method_not_allowed error envelope instead of plain text
+func withErrorEnvelope(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler, pattern := mux.Handler(r)
+		if pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		// Find out what the mux would have answered
+		rec := &statusRecorder{header: make(http.Header)}
+		handler.ServeHTTP(rec, r)
+
+		switch rec.status {
+		case http.StatusNotFound:
+			writeErrorMessage(w, http.StatusNotFound, CodeNotFound, "no route for "+r.Method+" "+r.URL.Path)
+		case http.StatusMethodNotAllowed:
+			w.Header().Set("Allow", rec.header.Get("Allow"))
+			writeErrorMessage(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "method "+r.Method+" not allowed for "+r.URL.Path)
+		default:
+			// Redirects to the canonical path
+			handler.ServeHTTP(w, r)
+		}
+	})
+}
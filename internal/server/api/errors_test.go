@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Cl0udRs4/dinot/internal/server/auth"
+	"github.com/Cl0udRs4/dinot/internal/server/client"
+	"github.com/Cl0udRs4/dinot/internal/server/common"
+	"github.com/Cl0udRs4/dinot/internal/server/task"
+)
+
+// TestErrorStatus tests the mapping of errors to statuses and codes
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{client.ErrClientNotFound, http.StatusNotFound, "client_not_found"},
+		{fmt.Errorf("%w: bad operator", client.ErrInvalidFilter), http.StatusBadRequest, "invalid_filter"},
+		{client.ErrGroupAlreadyExists, http.StatusConflict, "group_already_exists"},
+		{task.ErrNoClientsSelected, http.StatusBadRequest, "no_clients_selected"},
+		{auth.ErrLastAdmin, http.StatusConflict, "last_admin"},
+		{common.ErrExpiredToken, http.StatusUnauthorized, "token_expired"},
+		{common.NewServerError(common.ErrListenerAlreadyRunning, "tcp", nil), http.StatusConflict, "listener_already_running"},
+		{common.NewServerError(common.ErrNotImplemented, "doh", nil), http.StatusNotImplemented, "not_implemented"},
+		{errors.New("boom"), http.StatusInternalServerError, CodeInternal},
+	}
+
+	for _, tt := range tests {
+		status, code, _ := errorStatus(tt.err)
+		if status != tt.status || code != tt.code {
+			t.Errorf("%v: got %d %s want %d %s", tt.err, status, code, tt.status, tt.code)
+		}
+	}
+
+	// The cause of a server error is returned in the details
+	_, _, details := errorStatus(common.NewServerError(common.ErrListenerStartFailed, "tcp", errors.New("address in use")))
+	if details["cause"] != "address in use" {
+		t.Errorf("Expected the cause in the details, got %v", details)
+	}
+}
+
+// TestErrorEnvelope tests that handler, routing and auth errors share the
+// error envelope
+func TestErrorEnvelope(t *testing.T) {
+	fixture := setupRouteFixture(t)
+	fixture.handler.authEnabled = true
+	handler := fixture.handler.Handler()
+	token := issueTestToken(t, fixture.handler, "alice")
+
+	tests := []struct {
+		method string
+		path   string
+		auth   bool
+		status int
+		code   string
+	}{
+		{"GET", "/api/v1/clients/missing", true, http.StatusNotFound, "client_not_found"},
+		{"GET", "/api/v1/clients?q=os~", true, http.StatusBadRequest, "invalid_filter"},
+		{"GET", "/api/v1/unknown", true, http.StatusNotFound, CodeNotFound},
+		{"PATCH", "/api/v1/clients", true, http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+		{"GET", "/api/v1/clients", false, http.StatusUnauthorized, CodeUnauthorized},
+		{"GET", "/api/v1/users", true, http.StatusForbidden, CodeForbidden},
+		{"POST", "/api/v1/groups", true, http.StatusForbidden, CodeForbidden},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.auth {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.status {
+			t.Errorf("%s %s: got %d want %d", tt.method, tt.path, rr.Code, tt.status)
+			continue
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s %s: got Content-Type %q", tt.method, tt.path, ct)
+		}
+
+		var body ErrorResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Errorf("%s %s: body is not an error envelope: %s", tt.method, tt.path, rr.Body.String())
+			continue
+		}
+		if body.Error.Code != tt.code || body.Error.Message == "" {
+			t.Errorf("%s %s: got %+v want code %s", tt.method, tt.path, body.Error, tt.code)
+		}
+	}
+}
+
+// TestInvalidBody tests that malformed request bodies get invalid_body
+func TestInvalidBody(t *testing.T) {
+	fixture := setupRouteFixture(t)
+
+	req := httptest.NewRequest("POST", "/api/v1/groups", nil)
+	req.Body = http.NoBody
+	rr := httptest.NewRecorder()
+	fixture.handler.Handler().ServeHTTP(rr, req)
+
+	var body ErrorResponse
+	json.Unmarshal(rr.Body.Bytes(), &body)
+	if rr.Code != http.StatusBadRequest || body.Error.Code != CodeInvalidBody {
+		t.Errorf("got %d %+v", rr.Code, body.Error)
+	}
+}
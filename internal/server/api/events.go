@@ -0,0 +1,299 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Cl0udRs4/dinot/internal/server/client"
+	"github.com/Cl0udRs4/dinot/internal/server/task"
+)
+
+// Event types
+const (
+	// EventClientRegistered is published when a client registers; its data
+	// is the client
+	EventClientRegistered = "client_registered"
+
+	// EventTaskFinished is published when a task reaches a final status;
+	// its data is the task
+	EventTaskFinished = "task_finished"
+
+	// EventExceptionReported is published when an exception is reported;
+	// its data is the exception report
+	EventExceptionReported = "exception_reported"
+)
+
+const (
+	// maxEvents is the number of recent events kept for listing and for
+	// streams resuming after a disconnection
+	maxEvents = 1000
+
+	// eventStreamBuffer is the number of events a stream may fall behind
+	// before it is ended; the caller resumes it with Last-Event-ID
+	eventStreamBuffer = 64
+
+	// eventKeepAlive is how often an idle stream sends a comment so that
+	// proxies keep the connection open
+	eventKeepAlive = 15 * time.Second
+)
+
+// Event is something that happened on the server
+type Event struct {
+	// ID increases with every event; streams resume after an ID
+	ID int64 `json:"id"`
+
+	// Type is the event type, one of the Event constants
+	Type string `json:"type"`
+
+	// Timestamp is when the event happened
+	Timestamp time.Time `json:"timestamp"`
+
+	// ClientID is the client the event is about
+	ClientID string `json:"client_id,omitempty"`
+
+	// Data is the client, task or exception report the event is about
+	Data json.RawMessage `json:"data"`
+}
+
+// eventFilter selects events by type and client
+type eventFilter struct {
+	types    map[string]bool
+	clientID string
+}
+
+// parseEventFilter reads the types and clientId query parameters
+func parseEventFilter(r *http.Request) eventFilter {
+	filter := eventFilter{clientID: r.URL.Query().Get("clientId")}
+	if types := r.URL.Query().Get("types"); types != "" {
+		filter.types = make(map[string]bool)
+		for _, t := range strings.Split(types, ",") {
+			filter.types[strings.TrimSpace(t)] = true
+		}
+	}
+	return filter
+}
+
+// match reports whether an event passes the filter
+func (f eventFilter) match(event *Event) bool {
+	if f.types != nil && !f.types[event.Type] {
+		return false
+	}
+	return f.clientID == "" || event.ClientID == f.clientID
+}
+
+// eventHub keeps the recent events and passes new ones on to the streams
+type eventHub struct {
+	// nextID is the ID of the next event
+	nextID int64
+
+	// recent holds the last maxEvents events, oldest first
+	recent []*Event
+
+	// streams receive new events until they are unsubscribed
+	streams map[chan *Event]bool
+
+	// mu protects the hub
+	mu sync.Mutex
+}
+
+// newEventHub creates an event hub
+func newEventHub() *eventHub {
+	return &eventHub{
+		nextID:  1,
+		streams: make(map[chan *Event]bool),
+	}
+}
+
+// publish records an event about data and passes it on to the streams.
+// Streams too far behind to take it are ended.
+func (hub *eventHub) publish(eventType, clientID string, data interface{}) {
+	raw, ok := data.(json.RawMessage)
+	if !ok {
+		var err error
+		if raw, err = json.Marshal(data); err != nil {
+			return
+		}
+	}
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	event := &Event{
+		ID:        hub.nextID,
+		Type:      eventType,
+		Timestamp: time.Now(),
+		ClientID:  clientID,
+		Data:      raw,
+	}
+	hub.nextID++
+
+	hub.recent = append(hub.recent, event)
+	if len(hub.recent) > maxEvents {
+		hub.recent = hub.recent[len(hub.recent)-maxEvents:]
+	}
+
+	for stream := range hub.streams {
+		select {
+		case stream <- event:
+		default:
+			delete(hub.streams, stream)
+			close(stream)
+		}
+	}
+}
+
+// events returns the recent events passing the filter with an ID greater
+// than after, oldest first
+func (hub *eventHub) events(filter eventFilter, after int64) []*Event {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	return hub.since(filter, after)
+}
+
+// since implements events; callers must hold hub.mu
+func (hub *eventHub) since(filter eventFilter, after int64) []*Event {
+	events := make([]*Event, 0)
+	for _, event := range hub.recent {
+		if event.ID > after && filter.match(event) {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// subscribe returns the recent events after an ID, or none if after is
+// negative, the ID of the latest event, and a channel receiving the events
+// published from then on. The channel is closed if the stream falls
+// behind; unsubscribe must be called once the stream ends.
+func (hub *eventHub) subscribe(filter eventFilter, after int64) ([]*Event, int64, chan *Event) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	stream := make(chan *Event, eventStreamBuffer)
+	hub.streams[stream] = true
+
+	var backlog []*Event
+	if after >= 0 {
+		backlog = hub.since(filter, after)
+	}
+	return backlog, hub.nextID - 1, stream
+}
+
+// unsubscribe stops passing events to a stream
+func (hub *eventHub) unsubscribe(stream chan *Event) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	if hub.streams[stream] {
+		delete(hub.streams, stream)
+		close(stream)
+	}
+}
+
+// publishEvents publishes the events of the managers
+func (h *APIHandler) publishEvents() {
+	h.clientManager.OnRegister(func(c *client.Client, known bool) {
+		if data, err := c.ToJSON(); err == nil {
+			h.events.publish(EventClientRegistered, c.ID, json.RawMessage(data))
+		}
+	})
+
+	h.taskManager.OnTaskFinished(func(t *task.Task) {
+		if data, err := t.ToJSON(); err == nil {
+			h.events.publish(EventTaskFinished, t.ClientID, json.RawMessage(data))
+		}
+	})
+
+	h.clientManager.OnException(func(report *client.ExceptionReport) {
+		h.events.publish(EventExceptionReported, report.ClientID, report)
+	})
+}
+
+// handleListEvents handles GET /api/v1/events, which lists the recent
+// events, oldest first
+func (h *APIHandler) handleListEvents(w http.ResponseWriter, r *http.Request) {
+	writeList(w, r, h.events.events(parseEventFilter(r), 0), listSpec{key: "id"})
+}
+
+// handleStreamEvents handles GET /api/v1/events/stream, which sends events
+// as server-sent events as they happen. A stream resuming after the ID in
+// the Last-Event-ID header or the after parameter starts with the recent
+// events it missed.
+func (h *APIHandler) handleStreamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeErrorMessage(w, http.StatusInternalServerError, CodeInternal, "streaming is not supported")
+		return
+	}
+
+	last := r.Header.Get("Last-Event-ID")
+	if last == "" {
+		last = r.URL.Query().Get("after")
+	}
+	after := int64(-1)
+	if last != "" {
+		var err error
+		if after, err = strconv.ParseInt(last, 10, 64); err != nil || after < 0 {
+			writeErrorMessage(w, http.StatusBadRequest, CodeBadRequest, "invalid event ID: "+last)
+			return
+		}
+	}
+
+	filter := parseEventFilter(r)
+	backlog, latest, stream := h.events.subscribe(filter, after)
+	defer h.events.unsubscribe(stream)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	written := after
+	for _, event := range backlog {
+		writeEvent(w, event)
+		written = event.ID
+	}
+
+	// An ID without data moves the stream's position past the events it
+	// filtered out or started after, so that it resumes from here
+	if latest > written {
+		fmt.Fprintf(w, "id: %d\n\n", latest)
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case event, ok := <-stream:
+			if !ok {
+				// The stream fell behind; the caller resumes it
+				return
+			}
+			if !filter.match(event) || event.ID <= after {
+				continue
+			}
+			writeEvent(w, event)
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes an event in the server-sent events format
+func writeEvent(w http.ResponseWriter, event *Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+}
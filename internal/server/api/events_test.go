@@ -0,0 +1,193 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Cl0udRs4/dinot/internal/server/client"
+	"github.com/Cl0udRs4/dinot/internal/server/task"
+)
+
+// readEvent reads the next event of a server-sent events stream, skipping
+// comments
+func readEvent(t *testing.T, scanner *bufio.Scanner) Event {
+	var id, eventType string
+	var event Event
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if eventType == "" {
+				continue
+			}
+			if eventType != event.Type || id == "" {
+				t.Fatalf("Mismatched event fields: id %s, event %s, data %+v", id, eventType, event)
+			}
+			return event
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			eventType = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+				t.Fatalf("Invalid event data %q: %v", line, err)
+			}
+		}
+	}
+	t.Fatalf("The stream ended: %v", scanner.Err())
+	return event
+}
+
+// openStream opens an event stream and returns a scanner over its lines
+func openStream(t *testing.T, ctx context.Context, url, lastEventID string) *bufio.Scanner {
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Unexpected stream response: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	return bufio.NewScanner(resp.Body)
+}
+
+func TestListEvents(t *testing.T) {
+	apiHandler, clientManager, _ := setupTestAPI()
+
+	clientManager.RegisterClient(client.NewClient("client-2", "Client 2", "10.0.0.2", "linux", "amd64", []string{"shell"}, "tcp"))
+	clientManager.ReportException("client-2", "boom", client.SeverityWarning, "shell", "", nil)
+	created, _ := apiHandler.taskManager.CreateTask("test-client-id", "shell", nil)
+	apiHandler.taskManager.HandleFeedback(task.Feedback{CommandID: created.ID, Status: "completed"})
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{EventClientRegistered, EventExceptionReported, EventTaskFinished}},
+		{"?types=task_finished,exception_reported", []string{EventExceptionReported, EventTaskFinished}},
+		{"?clientId=client-2", []string{EventClientRegistered, EventExceptionReported}},
+		{"?clientId=client-2&types=task_finished", []string{}},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, APIPrefix+"/events"+tt.query, nil)
+		rr := httptest.NewRecorder()
+		apiHandler.Handler().ServeHTTP(rr, req)
+
+		var response struct {
+			Items []Event `json:"items"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s: invalid response %s", tt.query, rr.Body.String())
+		}
+
+		types := make([]string, 0, len(response.Items))
+		for _, event := range response.Items {
+			types = append(types, event.Type)
+		}
+		if strings.Join(types, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: expected %v, got %v", tt.query, tt.want, types)
+		}
+	}
+
+	// The data of an event is what it is about
+	req := httptest.NewRequest(http.MethodGet, APIPrefix+"/events?types=task_finished", nil)
+	rr := httptest.NewRecorder()
+	apiHandler.Handler().ServeHTTP(rr, req)
+	var response struct {
+		Items []struct {
+			Data task.Task `json:"data"`
+		} `json:"items"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	if len(response.Items) != 1 || response.Items[0].Data.ID != created.ID || response.Items[0].Data.Status != task.StatusCompleted {
+		t.Errorf("Expected the finished task, got %s", rr.Body.String())
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	apiHandler, clientManager, _ := setupTestAPI()
+	server := httptest.NewServer(apiHandler.Handler())
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// An event published before the stream opens is sent first
+	clientManager.ReportException("test-client-id", "before", client.SeverityInfo, "", "", nil)
+	stream := openStream(t, ctx, server.URL+APIPrefix+"/events/stream?after=0", "")
+	if event := readEvent(t, stream); event.Type != EventExceptionReported || event.ID != 1 {
+		t.Fatalf("Expected the earlier exception, got %+v", event)
+	}
+
+	// Events are sent as they happen
+	clientManager.RegisterClient(client.NewClient("client-2", "Client 2", "10.0.0.2", "linux", "amd64", []string{"shell"}, "tcp"))
+	event := readEvent(t, stream)
+	var registered client.Client
+	json.Unmarshal(event.Data, &registered)
+	if event.Type != EventClientRegistered || event.ClientID != "client-2" || registered.ID != "client-2" {
+		t.Errorf("Expected client-2 to register, got %+v", event)
+	}
+
+	created, _ := apiHandler.taskManager.CreateTask("client-2", "shell", nil)
+	apiHandler.taskManager.HandleFeedback(task.Feedback{CommandID: created.ID, Status: "failed", Error: "exit status 1"})
+	if event := readEvent(t, stream); event.Type != EventTaskFinished || event.ID != 3 {
+		t.Errorf("Expected the task to finish, got %+v", event)
+	}
+
+	// A new stream only sends new events, but starts at the latest one
+	fresh := openStream(t, ctx, server.URL+APIPrefix+"/events/stream", "")
+	for fresh.Scan() && fresh.Text() != "id: 3" {
+		t.Errorf("Unexpected line before the position of the stream: %q", fresh.Text())
+	}
+	clientManager.ReportException("client-2", "new", client.SeverityInfo, "", "", nil)
+	if event := readEvent(t, fresh); event.ID != 4 {
+		t.Errorf("Expected the new event, got %+v", event)
+	}
+
+	// A stream resumes after the last event it saw, with its filter
+	resumed := openStream(t, ctx, server.URL+APIPrefix+"/events/stream?types=task_finished,exception_reported", "1")
+	if event := readEvent(t, resumed); event.ID != 3 {
+		t.Errorf("Expected to resume with event 3, got %+v", event)
+	}
+	if event := readEvent(t, resumed); event.ID != 4 || event.ClientID != "client-2" {
+		t.Errorf("Expected event 4, got %+v", event)
+	}
+	if event := readEvent(t, stream); event.ID != 4 {
+		t.Errorf("Expected the first stream to receive event 4, got %+v", event)
+	}
+}
+
+func TestEventStreamFallsBehind(t *testing.T) {
+	hub := newEventHub()
+	_, _, stream := hub.subscribe(eventFilter{}, 0)
+
+	// A stream that does not keep up is ended rather than blocking
+	for i := 0; i <= eventStreamBuffer; i++ {
+		hub.publish(EventExceptionReported, "client-1", map[string]int{"n": i})
+	}
+
+	received := 0
+	for range stream {
+		received++
+	}
+	if received != eventStreamBuffer {
+		t.Errorf("Expected %d events before the stream ended, got %d", eventStreamBuffer, received)
+	}
+	hub.unsubscribe(stream)
+
+	if events := hub.events(eventFilter{}, 0); len(events) != eventStreamBuffer+1 {
+		t.Errorf("Expected the events to be kept, got %d", len(events))
+	}
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Cl0udRs4/dinot/internal/server/auth"
+)
+
+const (
+	// IdempotencyKeyHeader carries the client-chosen key that makes a
+	// creation request safe to retry
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// IdempotentReplayedHeader is set on responses replayed for a repeated
+	// idempotency key
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	// IdempotencyTTL is how long responses are kept for replay
+	IdempotencyTTL = 24 * time.Hour
+
+	// maxIdempotencyKeyLength bounds the length of idempotency keys
+	maxIdempotencyKeyLength = 255
+)
+
+// Error codes of idempotent requests
+const (
+	CodeInvalidIdempotencyKey = "invalid_idempotency_key"
+	CodeIdempotencyKeyReused  = "idempotency_key_reused"
+	CodeIdempotencyKeyInUse   = "idempotency_key_in_use"
+)
+
+// idempotentResponse is the recorded outcome of an idempotent request
+type idempotentResponse struct {
+	// fingerprint identifies the request the key was first used with
+	fingerprint [sha256.Size]byte
+
+	// done is false while the first request is being served
+	done bool
+
+	// status, contentType and body make up the recorded response
+	status      int
+	contentType string
+	body        []byte
+
+	// expires is when the key may be forgotten
+	expires time.Time
+}
+
+// idempotencyStore remembers the responses to requests sent with an
+// idempotency key
+type idempotencyStore struct {
+	// responses maps scoped keys to recorded responses
+	responses map[string]*idempotentResponse
+
+	// mu protects concurrent access to the responses
+	mu sync.Mutex
+}
+
+// newIdempotencyStore creates an empty idempotency store
+func newIdempotencyStore() *idempotencyStore {
+	return &idempotencyStore{responses: make(map[string]*idempotentResponse)}
+}
+
+// begin looks up a key. It returns the recorded response if the request was
+// already served, or records the request as in flight and returns nil.
+func (s *idempotencyStore) begin(key string, fingerprint [sha256.Size]byte, now time.Time) (*idempotentResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, response := range s.responses {
+		if response.done && now.After(response.expires) {
+			delete(s.responses, k)
+		}
+	}
+
+	if response, exists := s.responses[key]; exists {
+		copied := *response
+		return &copied, true
+	}
+
+	s.responses[key] = &idempotentResponse{fingerprint: fingerprint}
+	return nil, false
+}
+
+// finish records the response to an in-flight request. Server errors are
+// not recorded, so that the request can be retried with the same key.
+func (s *idempotencyStore) finish(key string, status int, contentType string, body []byte, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if status >= http.StatusInternalServerError {
+		delete(s.responses, key)
+		return
+	}
+
+	response := s.responses[key]
+	response.done = true
+	response.status = status
+	response.contentType = contentType
+	response.body = body
+	response.expires = now.Add(IdempotencyTTL)
+}
+
+// recordingWriter passes a response through while keeping a copy of it
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// WriteHeader records the status
+func (w *recordingWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the body
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+// idempotent makes a creation route safe to retry. A request carrying an
+// Idempotency-Key header is served once; repeating it with the same key and
+// body replays the first response instead of creating the resource again.
+// Keys are scoped to the caller and the route.
+func (h *APIHandler) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			writeErrorMessage(w, http.StatusBadRequest, CodeInvalidIdempotencyKey, "idempotency key is longer than 255 characters")
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeErrorMessage(w, http.StatusBadRequest, CodeInvalidBody, "failed to read request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		scope := ""
+		if identity, ok := auth.IdentityFromContext(r.Context()); ok {
+			scope = identity.Username
+		}
+		scopedKey := scope + "\x00" + r.Method + " " + r.URL.Path + "\x00" + key
+		fingerprint := sha256.Sum256(body)
+
+		recorded, exists := h.idempotency.begin(scopedKey, fingerprint, time.Now())
+		switch {
+		case exists && recorded.fingerprint != fingerprint:
+			writeErrorMessage(w, http.StatusUnprocessableEntity, CodeIdempotencyKeyReused, "idempotency key was already used with a different request")
+			return
+		case exists && !recorded.done:
+			writeErrorMessage(w, http.StatusConflict, CodeIdempotencyKeyInUse, "a request with this idempotency key is still being processed")
+			return
+		case exists:
+			if recorded.contentType != "" {
+				w.Header().Set("Content-Type", recorded.contentType)
+			}
+			w.Header().Set(IdempotentReplayedHeader, "true")
+			w.WriteHeader(recorded.status)
+			w.Write(recorded.body)
+			return
+		}
+
+		recorder := &recordingWriter{ResponseWriter: w}
+		defer func() {
+			status := recorder.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			h.idempotency.finish(scopedKey, status, recorder.Header().Get("Content-Type"), recorder.body.Bytes(), time.Now())
+		}()
+		next(recorder, r)
+	}
+}
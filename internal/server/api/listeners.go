@@ -0,0 +1,175 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Cl0udRs4/dinot/internal/server/common"
+	"github.com/Cl0udRs4/dinot/internal/server/listener"
+)
+
+// ListenerConfigRequest is the configuration of a listener. The domain, TTL
+// and record types only apply to DNS listeners; defaults are used for those
+// left unset.
+type ListenerConfigRequest struct {
+	Address        string   `json:"address"`
+	TLS            bool     `json:"tls,omitempty"`
+	TLSCertFile    string   `json:"tls_cert_file,omitempty"`
+	TLSKeyFile     string   `json:"tls_key_file,omitempty"`
+	BufferSize     int      `json:"buffer_size,omitempty"`
+	MaxConnections int      `json:"max_connections,omitempty"`
+	Timeout        int      `json:"timeout,omitempty"`
+	Domain         string   `json:"domain,omitempty"`
+	TTL            uint32   `json:"ttl,omitempty"`
+	RecordTypes    []string `json:"record_types,omitempty"`
+}
+
+// CreateListenerRequest creates a listener for a protocol: tcp, udp, ws,
+// icmp or dns. The name defaults to the protocol; it must be unique, so
+// listeners for the same protocol need distinct names.
+type CreateListenerRequest struct {
+	Name     string `json:"name,omitempty"`
+	Protocol string `json:"protocol"`
+	ListenerConfigRequest
+}
+
+// dnsConfig converts the request into the configuration of the named
+// listener. The DNS settings are rejected for other protocols.
+func (data ListenerConfigRequest) dnsConfig(name, protocol string) (listener.DNSConfig, error) {
+	config := listener.DNSConfig{
+		Config: listener.Config{
+			Name:           name,
+			Address:        data.Address,
+			EnableTLS:      data.TLS,
+			TLSCertFile:    data.TLSCertFile,
+			TLSKeyFile:     data.TLSKeyFile,
+			BufferSize:     data.BufferSize,
+			MaxConnections: data.MaxConnections,
+			Timeout:        data.Timeout,
+		},
+		Domain:      data.Domain,
+		TTL:         data.TTL,
+		RecordTypes: data.RecordTypes,
+	}
+
+	if protocol != "dns" && (data.Domain != "" || data.TTL != 0 || len(data.RecordTypes) > 0) {
+		return config, common.NewServerError(common.ErrInvalidConfig, "domain, ttl and record_types only apply to DNS listeners", nil)
+	}
+	return config.WithDefaults(), nil
+}
+
+// handleListListeners handles GET /api/v1/listeners
+func (h *APIHandler) handleListListeners(w http.ResponseWriter, r *http.Request) {
+	writeList(w, r, h.listenerManager.DescribeAll(), listSpec{key: "name"})
+}
+
+// handleCreateListener handles POST /api/v1/listeners. The listener is
+// created stopped.
+func (h *APIHandler) handleCreateListener(w http.ResponseWriter, r *http.Request) {
+	var data CreateListenerRequest
+	if !decodeBody(w, r, &data) {
+		return
+	}
+
+	config, err := data.dnsConfig(data.Name, data.Protocol)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var created listener.Listener
+	if data.Protocol == "dns" {
+		created, err = h.listenerManager.CreateDNSListener(config)
+	} else {
+		created, err = h.listenerManager.CreateListener(data.Protocol, config.Config)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, listener.Describe(created))
+}
+
+// handleGetListener handles GET /api/v1/listeners/{name}
+func (h *APIHandler) handleGetListener(w http.ResponseWriter, r *http.Request) {
+	l, err := h.listenerManager.GetListener(r.PathValue("name"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, listener.Describe(l))
+}
+
+// handleUpdateListener handles PUT /api/v1/listeners/{name}, which
+// replaces the configuration of a stopped listener
+func (h *APIHandler) handleUpdateListener(w http.ResponseWriter, r *http.Request) {
+	var data ListenerConfigRequest
+	if !decodeBody(w, r, &data) {
+		return
+	}
+
+	name := r.PathValue("name")
+	l, err := h.listenerManager.GetListener(name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	protocol := l.GetProtocol()
+	config, err := data.dnsConfig(name, protocol)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var updated listener.Listener
+	if protocol == "dns" {
+		updated, err = h.listenerManager.UpdateDNSListener(name, config)
+	} else {
+		updated, err = h.listenerManager.UpdateListener(name, config.Config)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, listener.Describe(updated))
+}
+
+// handleDeleteListener handles DELETE /api/v1/listeners/{name}, which
+// stops the listener if it is running
+func (h *APIHandler) handleDeleteListener(w http.ResponseWriter, r *http.Request) {
+	if err := h.listenerManager.UnregisterListener(r.PathValue("name")); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleStartListener handles POST /api/v1/listeners/{name}/start
+func (h *APIHandler) handleStartListener(w http.ResponseWriter, r *http.Request) {
+	h.listenerAction(w, r.PathValue("name"), h.listenerManager.StartListener)
+}
+
+// handleStopListener handles POST /api/v1/listeners/{name}/stop
+func (h *APIHandler) handleStopListener(w http.ResponseWriter, r *http.Request) {
+	h.listenerAction(w, r.PathValue("name"), h.listenerManager.StopListener)
+}
+
+// listenerAction starts or stops a listener and writes its description
+func (h *APIHandler) listenerAction(w http.ResponseWriter, name string, action func(name string) error) {
+	if err := action(name); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	l, err := h.listenerManager.GetListener(name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, listener.Describe(l))
+}
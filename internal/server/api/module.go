@@ -2,9 +2,7 @@ package api
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
-	"strings"
 	
 	"github.com/Cl0udRs4/dinot/internal/server/client"
 )
@@ -16,173 +14,142 @@ type ModuleInfo struct {
 	Parameters  []string `json:"parameters,omitempty"`
 }
 
-// handleModules handles the /api/modules endpoint
-func (h *APIHandler) handleModules(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		// Get all available modules
-		modules := []ModuleInfo{
-			{Name: "shell", Description: "Execute shell commands", Parameters: []string{"command"}},
-			{Name: "file", Description: "File operations", Parameters: []string{"path", "operation"}},
-			{Name: "process", Description: "Process management", Parameters: []string{"pid", "action"}},
-			{Name: "network", Description: "Network operations", Parameters: []string{"host", "port", "protocol"}},
-		}
-		
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(modules)
-		
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
+// ClientModulesResponse lists the modules active on a client
+type ClientModulesResponse struct {
+	ClientID string   `json:"client_id"`
+	Modules  []string `json:"modules"`
 }
 
-// handleModule handles the /api/modules/{name} endpoint
-func (h *APIHandler) handleModule(w http.ResponseWriter, r *http.Request) {
-	parts := strings.Split(r.URL.Path, "/")
-	if len(parts) < 4 {
-		http.Error(w, "Invalid module name", http.StatusBadRequest)
-		return
+// ClientModuleStatus is the status of a module on a client: "active" or
+// "inactive"
+type ClientModuleStatus struct {
+	ClientID string `json:"client_id"`
+	Module   string `json:"module"`
+	Status   string `json:"status"`
+}
+
+// handleListModules handles GET /api/v1/modules
+func (h *APIHandler) handleListModules(w http.ResponseWriter, r *http.Request) {
+	// Get all available modules
+	modules := []ModuleInfo{
+		{Name: "shell", Description: "Execute shell commands", Parameters: []string{"command"}},
+		{Name: "file", Description: "File operations", Parameters: []string{"path", "operation"}},
+		{Name: "process", Description: "Process management", Parameters: []string{"pid", "action"}},
+		{Name: "network", Description: "Network operations", Parameters: []string{"host", "port", "protocol"}},
 	}
 	
-	moduleName := parts[3]
+	writeList(w, r, modules, listSpec{key: "name"})
+}
+
+// handleGetModule handles GET /api/v1/modules/{name}
+func (h *APIHandler) handleGetModule(w http.ResponseWriter, r *http.Request) {
+	// Get module details based on name
+	var module ModuleInfo
 	
-	switch r.Method {
-	case http.MethodGet:
-		// Get module details based on name
-		var module ModuleInfo
-		
-		switch moduleName {
-		case "shell":
-			module = ModuleInfo{
-				Name:        "shell",
-				Description: "Execute shell commands on the client",
-				Parameters:  []string{"command", "timeout"},
-			}
-		case "file":
-			module = ModuleInfo{
-				Name:        "file",
-				Description: "Perform file operations on the client",
-				Parameters:  []string{"path", "operation", "content"},
-			}
-		case "process":
-			module = ModuleInfo{
-				Name:        "process",
-				Description: "Manage processes on the client",
-				Parameters:  []string{"pid", "action", "priority"},
-			}
-		case "network":
-			module = ModuleInfo{
-				Name:        "network",
-				Description: "Perform network operations on the client",
-				Parameters:  []string{"host", "port", "protocol", "timeout"},
-			}
-		default:
-			http.Error(w, "Module not found", http.StatusNotFound)
-			return
+	switch r.PathValue("name") {
+	case "shell":
+		module = ModuleInfo{
+			Name:        "shell",
+			Description: "Execute shell commands on the client",
+			Parameters:  []string{"command", "timeout"},
+		}
+	case "file":
+		module = ModuleInfo{
+			Name:        "file",
+			Description: "Perform file operations on the client",
+			Parameters:  []string{"path", "operation", "content"},
+		}
+	case "process":
+		module = ModuleInfo{
+			Name:        "process",
+			Description: "Manage processes on the client",
+			Parameters:  []string{"pid", "action", "priority"},
+		}
+	case "network":
+		module = ModuleInfo{
+			Name:        "network",
+			Description: "Perform network operations on the client",
+			Parameters:  []string{"host", "port", "protocol", "timeout"},
 		}
-		
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(module)
-		
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeErrorMessage(w, http.StatusNotFound, "module_not_found", "module not found: "+r.PathValue("name"))
+		return
 	}
+	
+	writeJSON(w, http.StatusOK, module)
 }
 
-// handleClientModules handles client module operations
-// This function handles both /api/clients/{id}/modules and /api/clients/{id}/modules/{name} endpoints
-func (h *APIHandler) handleClientModules(w http.ResponseWriter, r *http.Request) {
-	parts := strings.Split(r.URL.Path, "/")
+// handleListClientModules handles GET /api/v1/clients/{id}/modules
+func (h *APIHandler) handleListClientModules(w http.ResponseWriter, r *http.Request) {
+	client, err := h.clientManager.GetClient(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
 	
-	// Check if this is a client modules request
-	if len(parts) < 5 || parts[3] == "" || parts[4] != "modules" {
-		return // Not a client modules request, let other handlers process it
+	writeJSON(w, http.StatusOK, ClientModulesResponse{ClientID: client.ID, Modules: client.ActiveModules})
+}
+
+// handleGetClientModule handles GET /api/v1/clients/{id}/modules/{name}
+func (h *APIHandler) handleGetClientModule(w http.ResponseWriter, r *http.Request) {
+	cl, err := h.clientManager.GetClient(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
 	}
 	
-	clientID := parts[3]
+	status := "inactive"
+	if cl.IsModuleActive(r.PathValue("name")) {
+		status = "active"
+	}
+	writeJSON(w, http.StatusOK, ClientModuleStatus{ClientID: cl.ID, Module: r.PathValue("name"), Status: status})
+}
+
+// handleExecuteClientModule handles POST /api/v1/clients/{id}/modules/{name},
+// which queues a task that executes the module on the client
+func (h *APIHandler) handleExecuteClientModule(w http.ResponseWriter, r *http.Request) {
+	var params map[string]interface{}
+	if !decodeBody(w, r, &params) {
+		return
+	}
 	
-	// Get client
-	client, err := h.clientManager.GetClient(clientID)
+	rawParams, err := json.Marshal(params)
 	if err != nil {
-		http.Error(w, "Client not found", http.StatusNotFound)
+		writeErrorMessage(w, http.StatusBadRequest, CodeInvalidBody, err.Error())
 		return
 	}
 	
-	// Check if this is a request for a specific module
-	if len(parts) >= 6 && parts[5] != "" {
-		moduleName := parts[5]
-		h.handleClientModule(w, r, client, moduleName)
+	t, err := h.taskManager.CreateTask(r.PathValue("id"), r.PathValue("name"), rawParams)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
 	
-	// Handle client modules list
-	switch r.Method {
-	case http.MethodGet:
-		// Get client modules
-		// In a real implementation, this would get the modules from the client
-		// For now, we'll return a placeholder list
-		modules := []string{"shell", "file"}
-		
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"client_id": clientID,
-			"modules":   modules,
-		})
-		
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
+	writeTask(w, http.StatusAccepted, t)
 }
 
-// handleClientModule handles operations on a specific client module
-func (h *APIHandler) handleClientModule(w http.ResponseWriter, r *http.Request, client *client.Client, moduleName string) {
-	switch r.Method {
-	case http.MethodGet:
-		// Get module status
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"client_id": client.ID,
-			"module":    moduleName,
-			"status":    "loaded", // Placeholder
-		})
-		
-	case http.MethodPost:
-		// Execute module
-		var params map[string]interface{}
-		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-		
-		// In a real implementation, this would execute the module on the client
-		// For now, we'll return a placeholder response
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"client_id": client.ID,
-			"module":    moduleName,
-			"status":    "executed",
-			"result":    fmt.Sprintf("Module %s executed with params %v", moduleName, params),
-		})
-		
-	case http.MethodPut:
-		// Load module
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"client_id": client.ID,
-			"module":    moduleName,
-			"status":    "loaded",
-		})
-		
-	case http.MethodDelete:
-		// Unload module
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"client_id": client.ID,
-			"module":    moduleName,
-			"status":    "unloaded",
-		})
-		
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// handleLoadClientModule handles PUT /api/v1/clients/{id}/modules/{name},
+// which queues a task that loads the module on the client
+func (h *APIHandler) handleLoadClientModule(w http.ResponseWriter, r *http.Request) {
+	h.queueModuleTask(w, r, true)
+}
+
+// handleUnloadClientModule handles DELETE /api/v1/clients/{id}/modules/{name},
+// which queues a task that unloads the module from the client
+func (h *APIHandler) handleUnloadClientModule(w http.ResponseWriter, r *http.Request) {
+	h.queueModuleTask(w, r, false)
+}
+
+// queueModuleTask queues a task that loads or unloads a module on a client.
+// The module becomes active or inactive once the client reports the outcome
+// of the task.
+func (h *APIHandler) queueModuleTask(w http.ResponseWriter, r *http.Request, load bool) {
+	target := client.Target{ClientID: r.PathValue("id")}
+	tasks, err := h.taskManager.CreateModuleTasks(target, r.PathValue("name"), load)
+	if err != nil {
+		writeError(w, err)
+		return
 	}
+	
+	writeTask(w, http.StatusAccepted, tasks[0])
 }
@@ -0,0 +1,386 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// OpenAPIPath is the path the OpenAPI document is served at
+const OpenAPIPath = "/api/openapi.json"
+
+// openAPIDocument is an OpenAPI 3 document
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Servers    []openAPIServer                         `json:"servers"`
+	Security   []map[string][]string                   `json:"security"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+// openAPIInfo describes the API
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// openAPIServer is a base URL the paths are relative to
+type openAPIServer struct {
+	URL string `json:"url"`
+}
+
+// openAPIComponents holds the schemas and security schemes operations refer to
+type openAPIComponents struct {
+	Schemas         map[string]*openAPISchema         `json:"schemas"`
+	SecuritySchemes map[string]*openAPISecurityScheme `json:"securitySchemes"`
+}
+
+// openAPISecurityScheme is a way of authenticating
+type openAPISecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+}
+
+// openAPIOperation is a single route
+type openAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary"`
+	Description string                      `json:"description,omitempty"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Parameters  []openAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+
+	// Security overrides the document's security; public routes set it to
+	// an empty list
+	Security *[]map[string][]string `json:"security,omitempty"`
+
+	// Permission is the permission the caller needs
+	Permission string `json:"x-permission,omitempty"`
+}
+
+// openAPIParameter is a path or query parameter
+type openAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+// openAPIRequestBody is the body of a request
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+// openAPIResponse is a response of an operation
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+// openAPIMediaType is the schema of a body
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+// openAPISchema is a JSON schema
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Description          string                    `json:"description,omitempty"`
+	Enum                 []string                  `json:"enum,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+}
+
+// pathParamPattern matches the wildcards of a route pattern
+var pathParamPattern = regexp.MustCompile(`\{(\w+)\}`)
+
+// openAPI builds the OpenAPI document of every route
+func (h *APIHandler) openAPI() *openAPIDocument {
+	schemas := newSchemaBuilder()
+	errorSchema := schemas.schemaFor(typeOf[ErrorResponse]())
+
+	doc := &openAPIDocument{
+		OpenAPI: "3.0.3",
+		Info: openAPIInfo{
+			Title:       "dinot control API",
+			Description: "Manage clients, tasks and operators of a dinot server",
+			Version:     strings.TrimPrefix(APIPrefix, "/api/"),
+		},
+		Servers:  []openAPIServer{{URL: APIPrefix}},
+		Security: []map[string][]string{{"basicAuth": {}}, {"bearerAuth": {}}, {"apiKeyAuth": {}}},
+		Paths:    make(map[string]map[string]*openAPIOperation),
+		Components: openAPIComponents{
+			Schemas: schemas.schemas,
+			SecuritySchemes: map[string]*openAPISecurityScheme{
+				"basicAuth":  {Type: "http", Scheme: "basic"},
+				"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+				"apiKeyAuth": {Type: "apiKey", In: "header", Name: "X-API-Key"},
+			},
+		},
+	}
+
+	for _, rt := range h.routes() {
+		op := &openAPIOperation{
+			OperationID: operationID(rt.handler),
+			Summary:     rt.summary,
+			Tags:        []string{strings.SplitN(strings.TrimPrefix(rt.pattern, "/"), "/", 2)[0]},
+			Responses:   make(map[string]*openAPIResponse),
+		}
+
+		if rt.public {
+			op.Security = &[]map[string][]string{}
+		} else if rt.perm != "" {
+			op.Permission = string(rt.perm)
+			op.Description = "Requires the " + string(rt.perm) + " permission."
+		}
+
+		for _, match := range pathParamPattern.FindAllStringSubmatch(rt.pattern, -1) {
+			op.Parameters = append(op.Parameters, openAPIParameter{
+				Name:     match[1],
+				In:       "path",
+				Required: true,
+				Schema:   &openAPISchema{Type: "string"},
+			})
+		}
+		for _, param := range rt.query {
+			op.Parameters = append(op.Parameters, openAPIParameter{
+				Name:        param.name,
+				In:          "query",
+				Description: param.description,
+				Schema:      &openAPISchema{Type: "string"},
+			})
+		}
+		if rt.list {
+			op.Parameters = append(op.Parameters, listParameters()...)
+		}
+		if rt.idempotent {
+			op.Parameters = append(op.Parameters, openAPIParameter{
+				Name:        IdempotencyKeyHeader,
+				In:          "header",
+				Description: "Client-chosen key that makes retrying the request safe; repeating it replays the first response",
+				Schema:      &openAPISchema{Type: "string"},
+			})
+		}
+
+		if rt.request != nil {
+			op.RequestBody = &openAPIRequestBody{
+				Required: !rt.optionalBody,
+				Content:  jsonContent(schemas.schemaFor(rt.request)),
+			}
+		}
+
+		status := rt.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := &openAPIResponse{Description: http.StatusText(status)}
+		switch {
+		case rt.list:
+			success.Content = jsonContent(listSchema(schemas.schemaFor(rt.response)))
+		case rt.response != nil:
+			success.Content = jsonContent(schemas.schemaFor(rt.response))
+		}
+		op.Responses[strconv.Itoa(status)] = success
+
+		if !rt.public {
+			op.Responses["401"] = &openAPIResponse{Description: "Missing or invalid credentials", Content: jsonContent(errorSchema)}
+			op.Responses["403"] = &openAPIResponse{Description: "The caller lacks the required permission", Content: jsonContent(errorSchema)}
+		}
+		op.Responses["default"] = &openAPIResponse{Description: "Error", Content: jsonContent(errorSchema)}
+
+		path := rt.pattern
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]*openAPIOperation)
+		}
+		doc.Paths[path][strings.ToLower(rt.method)] = op
+	}
+
+	return doc
+}
+
+// operationID derives an operation ID from the name of a handler method,
+// e.g. handleListClients becomes listClients
+func operationID(handler http.HandlerFunc) string {
+	name := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+	name = strings.TrimSuffix(name[strings.LastIndex(name, ".")+1:], "-fm")
+	name = strings.TrimPrefix(name, "handle")
+
+	runes := []rune(name)
+	if len(runes) > 0 {
+		runes[0] = unicode.ToLower(runes[0])
+	}
+	return string(runes)
+}
+
+// listParameters returns the pagination parameters of collection routes
+func listParameters() []openAPIParameter {
+	return []openAPIParameter{
+		{Name: "limit", In: "query", Description: "Maximum number of items to return, at most " + strconv.Itoa(MaxPageSize), Schema: &openAPISchema{Type: "integer"}},
+		{Name: "cursor", In: "query", Description: "The next cursor of the previous page", Schema: &openAPISchema{Type: "string"}},
+		{Name: "sort", In: "query", Description: "Field to sort by", Schema: &openAPISchema{Type: "string"}},
+		{Name: "order", In: "query", Description: "Sort order", Schema: &openAPISchema{Type: "string", Enum: []string{"asc", "desc"}}},
+		{Name: "fields", In: "query", Description: "Comma separated fields to return", Schema: &openAPISchema{Type: "string"}},
+	}
+}
+
+// listSchema returns the schema of a ListResponse of the given items
+func listSchema(item *openAPISchema) *openAPISchema {
+	return &openAPISchema{
+		Type: "object",
+		Properties: map[string]*openAPISchema{
+			"items": {Type: "array", Items: item},
+			"total": {Type: "integer", Description: "Number of items across all pages"},
+			"next":  {Type: "string", Description: "Cursor of the next page; absent on the last page"},
+		},
+		Required: []string{"items", "total"},
+	}
+}
+
+// jsonContent returns a JSON body of the given schema
+func jsonContent(s *openAPISchema) map[string]openAPIMediaType {
+	return map[string]openAPIMediaType{"application/json": {Schema: s}}
+}
+
+// schemaBuilder derives schemas from Go types, collecting named structs as
+// components
+type schemaBuilder struct {
+	// schemas maps component names to schemas
+	schemas map[string]*openAPISchema
+
+	// names maps struct types to their component names
+	names map[reflect.Type]string
+}
+
+// newSchemaBuilder creates a new schema builder
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{
+		schemas: make(map[string]*openAPISchema),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+// schemaFor returns the schema of a type as encoded by encoding/json
+func (b *schemaBuilder) schemaFor(t reflect.Type) *openAPISchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case reflect.TypeOf(time.Time{}):
+		return &openAPISchema{Type: "string", Format: "date-time"}
+	case reflect.TypeOf(time.Duration(0)):
+		return &openAPISchema{Type: "integer", Format: "int64", Description: "Duration in nanoseconds"}
+	case reflect.TypeOf(json.RawMessage{}):
+		return &openAPISchema{Description: "Arbitrary JSON"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &openAPISchema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &openAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &openAPISchema{Type: "number"}
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &openAPISchema{Type: "string", Format: "byte"}
+		}
+		return &openAPISchema{Type: "array", Items: b.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &openAPISchema{Type: "object", AdditionalProperties: b.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		return &openAPISchema{Ref: "#/components/schemas/" + b.component(t)}
+	default:
+		return &openAPISchema{}
+	}
+}
+
+// component registers a named struct type as a component and returns its name
+func (b *schemaBuilder) component(t reflect.Type) string {
+	if name, ok := b.names[t]; ok {
+		return name
+	}
+
+	// Qualify the name with the package if another type already took it
+	name := t.Name()
+	if _, taken := b.schemas[name]; taken {
+		pkg := t.PkgPath()
+		pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+
+	// Register the name before building the schema so that recursive types
+	// refer to themselves
+	b.names[t] = name
+	b.schemas[name] = nil
+	b.schemas[name] = b.structSchema(t)
+	return name
+}
+
+// structSchema returns the object schema of a struct, flattening embedded
+// structs the way encoding/json does
+func (b *schemaBuilder) structSchema(t reflect.Type) *openAPISchema {
+	s := &openAPISchema{Type: "object", Properties: make(map[string]*openAPISchema)}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			embedded := b.structSchema(fieldType)
+			for prop, schema := range embedded.Properties {
+				s.Properties[prop] = schema
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		s.Properties[name] = b.schemaFor(field.Type)
+		if !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Pointer {
+			s.Required = append(s.Required, name)
+		}
+	}
+
+	return s
+}
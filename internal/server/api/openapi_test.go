@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// openAPISpec is the part of the served OpenAPI document the tests inspect
+type openAPISpec struct {
+	OpenAPI string `json:"openapi"`
+	Paths   map[string]map[string]struct {
+		OperationID string                     `json:"operationId"`
+		Summary     string                     `json:"summary"`
+		RequestBody json.RawMessage            `json:"requestBody"`
+		Responses   map[string]json.RawMessage `json:"responses"`
+	} `json:"paths"`
+	Components struct {
+		Schemas map[string]json.RawMessage `json:"schemas"`
+	} `json:"components"`
+}
+
+// fetchOpenAPI fetches the OpenAPI document from a handler with
+// authentication enabled
+func fetchOpenAPI(t *testing.T) (*APIHandler, []byte) {
+	t.Helper()
+
+	fixture := setupRouteFixture(t)
+	fixture.handler.authEnabled = true
+
+	req := httptest.NewRequest("GET", OpenAPIPath, nil)
+	rr := httptest.NewRecorder()
+	fixture.handler.Handler().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GET %s: got %v: %s", OpenAPIPath, rr.Code, rr.Body.String())
+	}
+	return fixture.handler, rr.Body.Bytes()
+}
+
+// TestOpenAPICoversRoutes fails when a registered route is missing from the
+// OpenAPI document or is not documented
+func TestOpenAPICoversRoutes(t *testing.T) {
+	h, body := fetchOpenAPI(t)
+
+	var spec openAPISpec
+	if err := json.Unmarshal(body, &spec); err != nil {
+		t.Fatalf("invalid OpenAPI document: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Errorf("expected an OpenAPI 3 document, got %q", spec.OpenAPI)
+	}
+
+	operationIDs := make(map[string]string)
+	for _, rt := range h.routes() {
+		key := rt.method + " " + rt.pattern
+		op, ok := spec.Paths[rt.pattern][strings.ToLower(rt.method)]
+		if !ok {
+			t.Errorf("route %s is missing from the OpenAPI document", key)
+			continue
+		}
+
+		if op.Summary == "" {
+			t.Errorf("route %s has no summary", key)
+		}
+		if other, dup := operationIDs[op.OperationID]; dup || op.OperationID == "" {
+			t.Errorf("route %s has operation ID %q, also used by %s", key, op.OperationID, other)
+		}
+		operationIDs[op.OperationID] = key
+
+		status := rt.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if _, ok := op.Responses[strconv.Itoa(status)]; !ok {
+			t.Errorf("route %s does not document its %d response", key, status)
+		}
+		if status != http.StatusNoContent && rt.response == nil {
+			t.Errorf("route %s does not declare its response type", key)
+		}
+	}
+}
+
+// TestOpenAPIReferences tests that every schema reference resolves
+func TestOpenAPIReferences(t *testing.T) {
+	_, body := fetchOpenAPI(t)
+
+	var spec openAPISpec
+	if err := json.Unmarshal(body, &spec); err != nil {
+		t.Fatalf("invalid OpenAPI document: %v", err)
+	}
+
+	for _, required := range []string{"Client", "Task", "ErrorResponse", "CreateTasksRequest"} {
+		if _, ok := spec.Components.Schemas[required]; !ok {
+			t.Errorf("schema %s is missing", required)
+		}
+	}
+
+	const prefix = `"$ref":"#/components/schemas/`
+	for rest := string(body); ; {
+		i := strings.Index(rest, prefix)
+		if i < 0 {
+			break
+		}
+		rest = rest[i+len(prefix):]
+		name := rest[:strings.Index(rest, `"`)]
+		if _, ok := spec.Components.Schemas[name]; !ok {
+			t.Errorf("reference to undefined schema %s", name)
+		}
+	}
+}
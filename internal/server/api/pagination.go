@@ -0,0 +1,403 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidListParams is returned for malformed limit, sort, order or
+	// fields parameters of a collection request
+	ErrInvalidListParams = errors.New("invalid list parameters")
+
+	// ErrInvalidCursor is returned when a cursor cannot be decoded or was
+	// issued for a different sort order
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+const (
+	// DefaultPageSize is the number of items returned when no limit is given
+	DefaultPageSize = 100
+
+	// MaxPageSize is the largest limit a request may ask for
+	MaxPageSize = 1000
+)
+
+// ListResponse is the body of every collection response
+type ListResponse struct {
+	// Items is the current page, projected to the requested fields
+	Items []json.RawMessage `json:"items"`
+
+	// Total is the number of items in the collection across all pages
+	Total int `json:"total"`
+
+	// Next is the cursor of the next page; empty on the last page
+	Next string `json:"next,omitempty"`
+}
+
+// listSpec describes how the items of a collection are identified and
+// ordered
+type listSpec struct {
+	// key is the field that uniquely identifies an item. It breaks ties
+	// between items with the same sort value so that cursors are stable.
+	key string
+
+	// defaultSort is the field sorted by when the request names none;
+	// the key is used when empty
+	defaultSort string
+
+	// canonical optionally resolves a comma separated list of field names,
+	// including aliases, to their JSON names
+	canonical func(list string) ([]string, error)
+}
+
+// listQuery holds the parsed pagination parameters of a request
+type listQuery struct {
+	limit  int
+	sort   string
+	desc   bool
+	fields []string
+	after  *listCursor
+}
+
+// listCursor marks the last item of a page. It is encoded into the opaque
+// next cursor returned to the caller.
+type listCursor struct {
+	Sort  string      `json:"s"`
+	Desc  bool        `json:"d,omitempty"`
+	Value interface{} `json:"v"`
+	Key   interface{} `json:"k"`
+}
+
+// listItem is an item of a collection together with its decoded fields
+type listItem struct {
+	raw    json.RawMessage
+	fields map[string]interface{}
+}
+
+// jsonEncoder is implemented by types that marshal themselves under their
+// own lock
+type jsonEncoder interface {
+	ToJSON() ([]byte, error)
+}
+
+// writeList writes a page of a collection. items must be a slice; the
+// limit, cursor, sort, order and fields query parameters select the page.
+func writeList(w http.ResponseWriter, r *http.Request, items interface{}, spec listSpec) {
+	response, err := paginate(r.URL.Query(), items, spec)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// paginate sorts a collection and returns the page selected by the query
+func paginate(values url.Values, items interface{}, spec listSpec) (*ListResponse, error) {
+	slice := reflect.ValueOf(items)
+	if slice.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("paginate: %T is not a slice", items)
+	}
+
+	query, err := parseListQuery(values, jsonFieldNames(slice.Type().Elem()), spec)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]listItem, 0, slice.Len())
+	for i := 0; i < slice.Len(); i++ {
+		item, err := decodeListItem(slice.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return query.compare(list[i].fields[query.sort], list[i].fields[spec.key],
+			list[j].fields[query.sort], list[j].fields[spec.key]) < 0
+	})
+
+	// Skip the items up to and including the cursor
+	start := 0
+	if query.after != nil {
+		start = sort.Search(len(list), func(i int) bool {
+			return query.compare(list[i].fields[query.sort], list[i].fields[spec.key],
+				query.after.Value, query.after.Key) > 0
+		})
+	}
+
+	end := start + query.limit
+	if end > len(list) {
+		end = len(list)
+	}
+
+	response := &ListResponse{
+		Items: make([]json.RawMessage, 0, end-start),
+		Total: len(list),
+	}
+	for _, item := range list[start:end] {
+		raw, err := item.project(query.fields)
+		if err != nil {
+			return nil, err
+		}
+		response.Items = append(response.Items, raw)
+	}
+
+	if end < len(list) {
+		last := list[end-1]
+		response.Next = encodeCursor(&listCursor{
+			Sort:  query.sort,
+			Desc:  query.desc,
+			Value: last.fields[query.sort],
+			Key:   last.fields[spec.key],
+		})
+	}
+
+	return response, nil
+}
+
+// parseListQuery parses the pagination parameters against the fields the
+// items of a collection have
+func parseListQuery(values url.Values, known map[string]bool, spec listSpec) (*listQuery, error) {
+	query := &listQuery{limit: DefaultPageSize, sort: spec.defaultSort}
+	if query.sort == "" {
+		query.sort = spec.key
+	}
+
+	resolve := func(list string) ([]string, error) {
+		var names []string
+		if spec.canonical != nil {
+			resolved, err := spec.canonical(list)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidListParams, err)
+			}
+			names = resolved
+		} else {
+			for _, name := range strings.Split(list, ",") {
+				if name = strings.TrimSpace(name); name != "" {
+					names = append(names, name)
+				}
+			}
+		}
+
+		for _, name := range names {
+			if !known[name] {
+				return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidListParams, name)
+			}
+		}
+		return names, nil
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > MaxPageSize {
+			return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidListParams, MaxPageSize)
+		}
+		query.limit = n
+	}
+
+	if sortField := values.Get("sort"); sortField != "" {
+		names, err := resolve(sortField)
+		if err != nil {
+			return nil, err
+		}
+		if len(names) != 1 {
+			return nil, fmt.Errorf("%w: sort takes a single field", ErrInvalidListParams)
+		}
+		query.sort = names[0]
+	}
+
+	switch values.Get("order") {
+	case "", "asc":
+	case "desc":
+		query.desc = true
+	default:
+		return nil, fmt.Errorf("%w: order must be asc or desc", ErrInvalidListParams)
+	}
+
+	if fields := values.Get("fields"); fields != "" {
+		names, err := resolve(fields)
+		if err != nil {
+			return nil, err
+		}
+		query.fields = names
+	}
+
+	if cursor := values.Get("cursor"); cursor != "" {
+		after, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		if after.Sort != query.sort || after.Desc != query.desc {
+			return nil, fmt.Errorf("%w: cursor was issued for a different sort order", ErrInvalidCursor)
+		}
+		query.after = after
+	}
+
+	return query, nil
+}
+
+// compare orders two items by their sort value and then by their key
+func (q *listQuery) compare(aValue, aKey, bValue, bKey interface{}) int {
+	c := compareJSON(aValue, bValue)
+	if c == 0 {
+		c = compareJSON(aKey, bKey)
+	}
+	if q.desc {
+		c = -c
+	}
+	return c
+}
+
+// decodeListItem marshals an item and decodes its fields
+func decodeListItem(v interface{}) (listItem, error) {
+	var raw []byte
+	var err error
+	if encoder, ok := v.(jsonEncoder); ok {
+		raw, err = encoder.ToJSON()
+	} else {
+		raw, err = json.Marshal(v)
+	}
+	if err != nil {
+		return listItem{}, err
+	}
+
+	item := listItem{raw: raw}
+	if err := json.Unmarshal(raw, &item.fields); err != nil {
+		return listItem{}, err
+	}
+	return item, nil
+}
+
+// project returns the item restricted to the given fields, or the whole
+// item if no fields are given
+func (item listItem) project(fields []string) (json.RawMessage, error) {
+	if len(fields) == 0 {
+		return item.raw, nil
+	}
+
+	selected := make(map[string]interface{}, len(fields))
+	for _, name := range fields {
+		selected[name] = item.fields[name]
+	}
+	return json.Marshal(selected)
+}
+
+// compareJSON orders two decoded JSON values. Strings holding RFC 3339
+// timestamps are compared as times; missing values sort first.
+func compareJSON(a, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+
+	switch av := a.(type) {
+	case float64:
+		if bv, ok := b.(float64); ok {
+			switch {
+			case av < bv:
+				return -1
+			case av > bv:
+				return 1
+			}
+			return 0
+		}
+	case string:
+		if bv, ok := b.(string); ok {
+			at, aErr := time.Parse(time.RFC3339Nano, av)
+			bt, bErr := time.Parse(time.RFC3339Nano, bv)
+			if aErr == nil && bErr == nil {
+				return at.Compare(bt)
+			}
+			return strings.Compare(av, bv)
+		}
+	case bool:
+		if bv, ok := b.(bool); ok && av != bv {
+			if !av {
+				return -1
+			}
+			return 1
+		}
+		if _, ok := b.(bool); ok {
+			return 0
+		}
+	}
+
+	// Lists, objects and mismatched types compare by their JSON encoding
+	aJSON, _ := json.Marshal(a)
+	bJSON, _ := json.Marshal(b)
+	return strings.Compare(string(aJSON), string(bJSON))
+}
+
+// jsonFieldNames returns the JSON names of the fields of a struct type,
+// including those of embedded structs
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	names := make(map[string]bool)
+	if t.Kind() != reflect.Struct {
+		return names
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			for embedded := range jsonFieldNames(field.Type) {
+				names[embedded] = true
+			}
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names[name] = true
+	}
+	return names
+}
+
+// encodeCursor encodes a cursor as an opaque URL-safe string
+func encodeCursor(c *listCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor decodes a cursor returned by encodeCursor
+func decodeCursor(s string) (*listCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c listCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
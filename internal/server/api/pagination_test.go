@@ -0,0 +1,148 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/Cl0udRs4/dinot/internal/server/client"
+)
+
+// listPage is a decoded ListResponse
+type listPage struct {
+	Items []map[string]interface{} `json:"items"`
+	Total int                      `json:"total"`
+	Next  string                   `json:"next"`
+}
+
+// getPage requests a page of a collection and decodes it
+func getPage(t *testing.T, handler http.Handler, path string) listPage {
+	t.Helper()
+
+	req := httptest.NewRequest("GET", path, nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GET %s: got %v: %s", path, rr.Code, rr.Body.String())
+	}
+
+	var page listPage
+	if err := json.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	return page
+}
+
+// TestPagination walks the pages of a collection with a cursor
+func TestPagination(t *testing.T) {
+	apiHandler, clientManager, _ := setupTestAPI()
+	for i := 0; i < 7; i++ {
+		clientManager.ReportException("test-client-id", fmt.Sprintf("exception %d", i), client.SeverityInfo, "", "", nil)
+	}
+	handler := apiHandler.Handler()
+
+	seen := make(map[string]bool)
+	path := "/api/v1/exceptions?limit=3"
+	pages := 0
+	for {
+		page := getPage(t, handler, path)
+		pages++
+
+		if page.Total != 7 {
+			t.Errorf("expected a total of 7, got %d", page.Total)
+		}
+		for _, item := range page.Items {
+			id := item["id"].(string)
+			if seen[id] {
+				t.Errorf("exception %s returned twice", id)
+			}
+			seen[id] = true
+		}
+
+		if page.Next == "" {
+			break
+		}
+		path = "/api/v1/exceptions?limit=3&cursor=" + url.QueryEscape(page.Next)
+	}
+
+	if pages != 3 || len(seen) != 7 {
+		t.Errorf("expected 7 exceptions in 3 pages, got %d in %d", len(seen), pages)
+	}
+}
+
+// TestSortAndFields tests the sort, order and fields parameters
+func TestSortAndFields(t *testing.T) {
+	apiHandler, clientManager, _ := setupTestAPI()
+	clientManager.RegisterClient(client.NewClient("a-client", "Alpha", "10.0.0.1", "Windows", "x86_64", nil, "ws"))
+	clientManager.RegisterClient(client.NewClient("z-client", "Zulu", "10.0.0.2", "Darwin", "arm64", nil, "tcp"))
+	handler := apiHandler.Handler()
+
+	page := getPage(t, handler, "/api/v1/clients?sort=name&order=desc&fields=id,name")
+	if len(page.Items) != 3 {
+		t.Fatalf("expected 3 clients, got %d", len(page.Items))
+	}
+
+	names := []string{"Zulu", "Test Client", "Alpha"}
+	for i, item := range page.Items {
+		if item["name"] != names[i] || len(item) != 2 {
+			t.Errorf("item %d: got %v want name %s and two fields", i, item, names[i])
+		}
+	}
+
+	// Client field aliases resolve to their JSON names
+	page = getPage(t, handler, "/api/v1/clients?sort=ip&limit=1&fields=ip")
+	if len(page.Items) != 1 || page.Items[0]["ip_address"] != "10.0.0.1" || page.Next == "" {
+		t.Errorf("unexpected page: %+v", page)
+	}
+
+	// A cursor continues in the same order
+	page = getPage(t, handler, "/api/v1/clients?sort=ip&limit=1&fields=ip&cursor="+url.QueryEscape(page.Next))
+	if len(page.Items) != 1 || page.Items[0]["ip_address"] != "10.0.0.2" {
+		t.Errorf("unexpected second page: %+v", page)
+	}
+
+	// Collections without aliases take their JSON field names
+	page = getPage(t, handler, "/api/v1/modules?sort=name&order=desc&fields=name")
+	if page.Total != 4 || page.Items[0]["name"] != "shell" {
+		t.Errorf("unexpected modules: %+v", page)
+	}
+}
+
+// TestInvalidListParams tests that malformed parameters are rejected
+func TestInvalidListParams(t *testing.T) {
+	apiHandler, clientManager, _ := setupTestAPI()
+	clientManager.RegisterClient(client.NewClient("second", "Second", "10.0.0.1", "Linux", "x86_64", nil, "tcp"))
+	handler := apiHandler.Handler()
+
+	next := getPage(t, handler, "/api/v1/clients?limit=1").Next
+
+	tests := []struct {
+		path string
+		code string
+	}{
+		{"/api/v1/clients?limit=0", "invalid_query"},
+		{"/api/v1/clients?limit=5000", "invalid_query"},
+		{"/api/v1/clients?order=sideways", "invalid_query"},
+		{"/api/v1/clients?sort=nope", "invalid_query"},
+		{"/api/v1/tasks?fields=id,nope", "invalid_query"},
+		{"/api/v1/groups?sort=name,created_at", "invalid_query"},
+		{"/api/v1/clients?cursor=%21%21", "invalid_cursor"},
+		{"/api/v1/clients?order=desc&cursor=" + url.QueryEscape(next), "invalid_cursor"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		var body ErrorResponse
+		json.Unmarshal(rr.Body.Bytes(), &body)
+		if rr.Code != http.StatusBadRequest || body.Error.Code != tt.code {
+			t.Errorf("%s: got %d %q want 400 %q", tt.path, rr.Code, body.Error.Code, tt.code)
+		}
+	}
+}
@@ -0,0 +1,497 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+
+	"github.com/Cl0udRs4/dinot/internal/server/auth"
+	"github.com/Cl0udRs4/dinot/internal/server/client"
+	"github.com/Cl0udRs4/dinot/internal/server/listener"
+	"github.com/Cl0udRs4/dinot/internal/server/task"
+)
+
+// APIPrefix is the path prefix of the current API version
+const APIPrefix = "/api/v1"
+
+// route is a single API operation
+type route struct {
+	// method is the HTTP method the route answers
+	method string
+
+	// pattern is the path relative to APIPrefix, with {name} wildcards
+	pattern string
+
+	// perm is the permission the caller needs; empty admits any
+	// authenticated caller
+	perm auth.Permission
+
+	// public routes skip authentication entirely
+	public bool
+
+	// handler serves the route
+	handler http.HandlerFunc
+
+	// summary describes the operation in the OpenAPI document
+	summary string
+
+	// query lists the query parameters the route takes, besides the
+	// pagination parameters of collections
+	query []queryParam
+
+	// request is the type of the request body; nil if the route takes none
+	request reflect.Type
+
+	// response is the type of the success response body, or of its items
+	// if list is set; nil if the route answers without a body
+	response reflect.Type
+
+	// optionalBody marks routes whose request body may be omitted
+	optionalBody bool
+
+	// list marks collection routes, which answer with a ListResponse
+	list bool
+
+	// status is the success status; http.StatusOK if zero
+	status int
+
+	// idempotent routes accept an Idempotency-Key header, which makes
+	// retrying a creation request safe
+	idempotent bool
+}
+
+// queryParam is a query parameter of a route
+type queryParam struct {
+	name        string
+	description string
+}
+
+// typeOf returns the reflect.Type of T
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// routes returns every operation of the API
+func (h *APIHandler) routes() []route {
+	return []route{
+		// Client routes
+		{
+			method: http.MethodGet, pattern: "/clients", perm: auth.PermReadClients, handler: h.handleListClients,
+			summary: "List clients",
+			query: []queryParam{
+				{"q", "Filter expression, e.g. os=linux and last_seen<10m and tag:dmz"},
+				{"status", "Only return clients with this status"},
+			},
+			response: typeOf[client.Client](), list: true,
+		},
+		{
+			method: http.MethodDelete, pattern: "/clients", perm: auth.PermWriteClients, handler: h.handleUnregisterClients,
+			summary: "Unregister every client of a tag or group",
+			query: []queryParam{
+				{"tag", "Unregister the clients carrying this tag"},
+				{"group", "Unregister the members of this group"},
+			},
+			response: typeOf[UnregisterResponse](),
+		},
+		{
+			method: http.MethodGet, pattern: "/clients/{id}", perm: auth.PermReadClients, handler: h.handleGetClient,
+			summary:  "Get a client",
+			response: typeOf[client.Client](),
+		},
+		{
+			method: http.MethodDelete, pattern: "/clients/{id}", perm: auth.PermWriteClients, handler: h.handleUnregisterClient,
+			summary:  "Unregister a client",
+			response: typeOf[UnregisterResponse](),
+		},
+		{
+			method: http.MethodGet, pattern: "/clients/{id}/history", perm: auth.PermReadClients, handler: h.handleGetClientHistory,
+			summary:  "Get the status history of a client",
+			response: typeOf[ClientHistoryResponse](),
+		},
+		{
+			method: http.MethodGet, pattern: "/clients/{id}/tags", perm: auth.PermReadClients, handler: h.handleGetClientTags,
+			summary:  "List the tags of a client",
+			response: typeOf[[]string](),
+		},
+		{
+			method: http.MethodPost, pattern: "/clients/{id}/tags", perm: auth.PermWriteClients, handler: h.handleAddClientTag,
+			summary:  "Tag a client",
+			request:  typeOf[AddTagRequest](),
+			response: typeOf[ClientTagsResponse](),
+		},
+		{
+			method: http.MethodDelete, pattern: "/clients/{id}/tags/{tag}", perm: auth.PermWriteClients, handler: h.handleRemoveClientTag,
+			summary:  "Remove a tag from a client",
+			response: typeOf[ClientTagsResponse](),
+		},
+
+		// Client module routes; executing, loading and unloading modules
+		// create work on the client
+		{
+			method: http.MethodGet, pattern: "/clients/{id}/modules", perm: auth.PermReadClients, handler: h.handleListClientModules,
+			summary:  "List the modules active on a client",
+			response: typeOf[ClientModulesResponse](),
+		},
+		{
+			method: http.MethodGet, pattern: "/clients/{id}/modules/{name}", perm: auth.PermReadClients, handler: h.handleGetClientModule,
+			summary:  "Get the status of a module on a client",
+			response: typeOf[ClientModuleStatus](),
+		},
+		{
+			method: http.MethodPost, pattern: "/clients/{id}/modules/{name}", perm: auth.PermCreateTasks, handler: h.handleExecuteClientModule,
+			summary:  "Execute a module on a client",
+			request:  typeOf[map[string]interface{}](),
+			response: typeOf[task.Task](), status: http.StatusAccepted, idempotent: true,
+		},
+		{
+			method: http.MethodPut, pattern: "/clients/{id}/modules/{name}", perm: auth.PermCreateTasks, handler: h.handleLoadClientModule,
+			summary:  "Load a module on a client",
+			response: typeOf[task.Task](), status: http.StatusAccepted,
+		},
+		{
+			method: http.MethodDelete, pattern: "/clients/{id}/modules/{name}", perm: auth.PermCreateTasks, handler: h.handleUnloadClientModule,
+			summary:  "Unload a module from a client",
+			response: typeOf[task.Task](), status: http.StatusAccepted,
+		},
+
+		// Heartbeat, status and exception routes
+		{
+			method: http.MethodGet, pattern: "/heartbeat", perm: auth.PermReadClients, handler: h.handleGetHeartbeat,
+			summary:  "Get the heartbeat settings",
+			response: typeOf[HeartbeatSettings](),
+		},
+		{
+			method: http.MethodPost, pattern: "/heartbeat", perm: auth.PermWriteClients, handler: h.handleUpdateHeartbeat,
+			summary:  "Update the heartbeat settings or the interval of some clients",
+			request:  typeOf[HeartbeatUpdateRequest](),
+			response: typeOf[MessageResponse](),
+		},
+		{
+			method: http.MethodPost, pattern: "/status", perm: auth.PermWriteClients, handler: h.handleUpdateStatus,
+			summary:  "Set the status of a client",
+			request:  typeOf[StatusUpdateRequest](),
+			response: typeOf[MessageResponse](),
+		},
+		{
+			method: http.MethodGet, pattern: "/exceptions", perm: auth.PermReadClients, handler: h.handleListExceptions,
+			summary:  "List exception reports",
+			query:    []queryParam{{"clientId", "Only return the reports of this client"}},
+			response: typeOf[client.ExceptionReport](), list: true,
+		},
+		{
+			method: http.MethodPost, pattern: "/exceptions", perm: auth.PermWriteClients, handler: h.handleReportException,
+			summary:  "Report an exception for a client",
+			request:  typeOf[ExceptionReportRequest](),
+			response: typeOf[client.ExceptionReport](),
+		},
+		{
+			method: http.MethodGet, pattern: "/exceptions/{id}", perm: auth.PermReadClients, handler: h.handleGetException,
+			summary:  "Get an exception report",
+			response: typeOf[client.ExceptionReport](),
+		},
+
+		// Event routes; the stream sends server-sent events rather than JSON
+		{
+			method: http.MethodGet, pattern: "/events", perm: auth.PermReadTasks, handler: h.handleListEvents,
+			summary: "List the recent events",
+			query: []queryParam{
+				{"types", "Only return events of these comma separated types"},
+				{"clientId", "Only return the events of this client"},
+			},
+			response: typeOf[Event](), list: true,
+		},
+		{
+			method: http.MethodGet, pattern: "/events/stream", perm: auth.PermReadTasks, handler: h.handleStreamEvents,
+			summary: "Stream events as server-sent events, resuming after the Last-Event-ID header",
+			query: []queryParam{
+				{"types", "Only send events of these comma separated types"},
+				{"clientId", "Only send the events of this client"},
+				{"after", "Resume after this event ID, like the Last-Event-ID header"},
+			},
+			response: typeOf[Event](),
+		},
+
+		// Tag and group routes
+		{
+			method: http.MethodGet, pattern: "/tags", perm: auth.PermReadClients, handler: h.handleListTags,
+			summary:  "List the tags in use",
+			response: typeOf[TagInfo](), list: true,
+		},
+		{
+			method: http.MethodGet, pattern: "/groups", perm: auth.PermReadClients, handler: h.handleListGroups,
+			summary:  "List client groups",
+			response: typeOf[client.ClientGroup](), list: true,
+		},
+		{
+			method: http.MethodPost, pattern: "/groups", perm: auth.PermWriteClients, handler: h.handleCreateGroup,
+			summary:  "Create a client group",
+			request:  typeOf[CreateGroupRequest](),
+			response: typeOf[client.ClientGroup](), status: http.StatusCreated,
+		},
+		{
+			method: http.MethodGet, pattern: "/groups/{name}", perm: auth.PermReadClients, handler: h.handleGetGroup,
+			summary:  "Get a client group",
+			response: typeOf[client.ClientGroup](),
+		},
+		{
+			method: http.MethodDelete, pattern: "/groups/{name}", perm: auth.PermWriteClients, handler: h.handleDeleteGroup,
+			summary: "Delete a client group",
+			status:  http.StatusNoContent,
+		},
+		{
+			method: http.MethodPost, pattern: "/groups/{name}/members", perm: auth.PermWriteClients, handler: h.handleAddGroupMember,
+			summary:  "Add a client to a group",
+			request:  typeOf[AddGroupMemberRequest](),
+			response: typeOf[client.ClientGroup](),
+		},
+		{
+			method: http.MethodDelete, pattern: "/groups/{name}/members/{clientId}", perm: auth.PermWriteClients, handler: h.handleRemoveGroupMember,
+			summary:  "Remove a client from a group",
+			response: typeOf[client.ClientGroup](),
+		},
+
+		// Task routes
+		{
+			method: http.MethodGet, pattern: "/tasks", perm: auth.PermReadTasks, handler: h.handleListTasks,
+			summary:  "List tasks",
+			query:    []queryParam{{"clientId", "Only return the tasks of this client"}},
+			response: typeOf[task.Task](), list: true,
+		},
+		{
+			method: http.MethodPost, pattern: "/tasks", perm: auth.PermCreateTasks, handler: h.handleCreateTasks,
+			summary:  "Run a module on a client, tag or group",
+			request:  typeOf[CreateTasksRequest](),
+			response: typeOf[[]*task.Task](), status: http.StatusCreated, idempotent: true,
+		},
+		{
+			method: http.MethodGet, pattern: "/tasks/{id}", perm: auth.PermReadTasks, handler: h.handleGetTask,
+			summary:  "Get a task and its result",
+			response: typeOf[task.Task](),
+		},
+		{
+			method: http.MethodDelete, pattern: "/tasks/{id}", perm: auth.PermCreateTasks, handler: h.handleCancelTask,
+			summary:  "Cancel a task, stopping the module if it is running",
+			response: typeOf[task.Task](), status: http.StatusAccepted,
+		},
+
+		// Batch routes
+		{
+			method: http.MethodGet, pattern: "/batches", perm: auth.PermReadTasks, handler: h.handleListBatches,
+			summary:  "List batches and their aggregate status",
+			response: typeOf[task.BatchSummary](), list: true,
+		},
+		{
+			method: http.MethodPost, pattern: "/batches", perm: auth.PermCreateTasks, handler: h.handleCreateBatch,
+			summary:  "Run a module on a list, tag, group or filter of clients",
+			request:  typeOf[CreateBatchRequest](),
+			response: typeOf[task.BatchSummary](), status: http.StatusCreated, idempotent: true,
+		},
+		{
+			method: http.MethodGet, pattern: "/batches/{id}", perm: auth.PermReadTasks, handler: h.handleGetBatch,
+			summary:  "Get a batch and the status of its tasks",
+			response: typeOf[task.BatchSummary](),
+		},
+		{
+			method: http.MethodGet, pattern: "/batches/{id}/results", perm: auth.PermReadTasks, handler: h.handleGetBatchResults,
+			summary:  "Download the results of every task of a batch",
+			response: typeOf[task.BatchSummary](),
+		},
+
+		// Workflow routes
+		{
+			method: http.MethodGet, pattern: "/workflows", perm: auth.PermReadTasks, handler: h.handleListWorkflows,
+			summary:  "List workflows and the status of their steps",
+			response: typeOf[task.Workflow](), list: true,
+		},
+		{
+			method: http.MethodPost, pattern: "/workflows", perm: auth.PermCreateTasks, handler: h.handleCreateWorkflow,
+			summary:  "Run a workflow of dependent module steps on a list, tag, group or filter of clients",
+			request:  typeOf[CreateWorkflowRequest](),
+			response: typeOf[task.Workflow](), status: http.StatusCreated, idempotent: true,
+		},
+		{
+			method: http.MethodGet, pattern: "/workflows/{id}", perm: auth.PermReadTasks, handler: h.handleGetWorkflow,
+			summary:  "Get a workflow and the status of every step on every client",
+			response: typeOf[task.Workflow](),
+		},
+
+		// Schedule routes
+		{
+			method: http.MethodGet, pattern: "/schedules", perm: auth.PermReadTasks, handler: h.handleListSchedules,
+			summary:  "List scheduled and recurring tasks",
+			response: typeOf[task.Schedule](), list: true,
+		},
+		{
+			method: http.MethodPost, pattern: "/schedules", perm: auth.PermCreateTasks, handler: h.handleCreateSchedule,
+			summary:  "Schedule a module to run once, on a cron schedule or when clients register",
+			request:  typeOf[CreateScheduleRequest](),
+			response: typeOf[task.Schedule](), status: http.StatusCreated, idempotent: true,
+		},
+		{
+			method: http.MethodGet, pattern: "/schedules/{id}", perm: auth.PermReadTasks, handler: h.handleGetSchedule,
+			summary:  "Get a schedule",
+			response: typeOf[task.Schedule](),
+		},
+		{
+			method: http.MethodPut, pattern: "/schedules/{id}", perm: auth.PermCreateTasks, handler: h.handleUpdateSchedule,
+			summary:  "Enable or disable a schedule",
+			request:  typeOf[UpdateScheduleRequest](),
+			response: typeOf[task.Schedule](),
+		},
+		{
+			method: http.MethodDelete, pattern: "/schedules/{id}", perm: auth.PermCreateTasks, handler: h.handleDeleteSchedule,
+			summary: "Delete a schedule",
+			status:  http.StatusNoContent,
+		},
+
+		// Listener routes
+		{
+			method: http.MethodGet, pattern: "/listeners", perm: auth.PermManageListeners, handler: h.handleListListeners,
+			summary:  "List the protocol listeners",
+			response: typeOf[listener.Info](), list: true,
+		},
+		{
+			method: http.MethodPost, pattern: "/listeners", perm: auth.PermManageListeners, handler: h.handleCreateListener,
+			summary:  "Create a stopped, named listener for a protocol",
+			request:  typeOf[CreateListenerRequest](),
+			response: typeOf[listener.Info](), status: http.StatusCreated,
+		},
+		{
+			method: http.MethodGet, pattern: "/listeners/{name}", perm: auth.PermManageListeners, handler: h.handleGetListener,
+			summary:  "Get a listener",
+			response: typeOf[listener.Info](),
+		},
+		{
+			method: http.MethodPut, pattern: "/listeners/{name}", perm: auth.PermManageListeners, handler: h.handleUpdateListener,
+			summary:  "Replace the configuration of a stopped listener",
+			request:  typeOf[ListenerConfigRequest](),
+			response: typeOf[listener.Info](),
+		},
+		{
+			method: http.MethodDelete, pattern: "/listeners/{name}", perm: auth.PermManageListeners, handler: h.handleDeleteListener,
+			summary: "Stop and remove a listener",
+			status:  http.StatusNoContent,
+		},
+		{
+			method: http.MethodPost, pattern: "/listeners/{name}/start", perm: auth.PermManageListeners, handler: h.handleStartListener,
+			summary:  "Start a listener",
+			response: typeOf[listener.Info](),
+		},
+		{
+			method: http.MethodPost, pattern: "/listeners/{name}/stop", perm: auth.PermManageListeners, handler: h.handleStopListener,
+			summary:  "Stop a listener",
+			response: typeOf[listener.Info](),
+		},
+
+		// Module catalogue routes
+		{
+			method: http.MethodGet, pattern: "/modules", perm: auth.PermReadClients, handler: h.handleListModules,
+			summary:  "List the available modules",
+			response: typeOf[ModuleInfo](), list: true,
+		},
+		{
+			method: http.MethodGet, pattern: "/modules/{name}", perm: auth.PermReadClients, handler: h.handleGetModule,
+			summary:  "Get a module and its parameters",
+			response: typeOf[ModuleInfo](),
+		},
+
+		// Token routes; login and refresh carry their own credentials
+		{
+			method: http.MethodPost, pattern: "/auth/login", public: true, handler: h.handleLogin,
+			summary:  "Log in and get a token pair",
+			request:  typeOf[LoginRequest](),
+			response: typeOf[auth.TokenPair](),
+		},
+		{
+			method: http.MethodPost, pattern: "/auth/refresh", public: true, handler: h.handleRefresh,
+			summary:  "Exchange a refresh token for a new token pair",
+			request:  typeOf[RefreshRequest](),
+			response: typeOf[auth.TokenPair](),
+		},
+		{
+			method: http.MethodPost, pattern: "/auth/logout", handler: h.handleLogout,
+			summary: "Revoke the access token and optionally a refresh token",
+			request: typeOf[RefreshRequest](), optionalBody: true,
+			status: http.StatusNoContent,
+		},
+
+		// Operator account and API key routes
+		{
+			method: http.MethodGet, pattern: "/me", handler: h.handleGetMe,
+			summary:  "Get the identity of the caller",
+			response: typeOf[auth.Identity](),
+		},
+		{
+			method: http.MethodGet, pattern: "/users", perm: auth.PermManageUsers, handler: h.handleListUsers,
+			summary:  "List operator accounts",
+			response: typeOf[auth.UserInfo](), list: true,
+		},
+		{
+			method: http.MethodPost, pattern: "/users", perm: auth.PermManageUsers, handler: h.handleCreateUser,
+			summary:  "Create an operator account",
+			request:  typeOf[CreateUserRequest](),
+			response: typeOf[auth.UserInfo](), status: http.StatusCreated,
+		},
+		{
+			method: http.MethodGet, pattern: "/users/{username}", perm: auth.PermManageUsers, handler: h.handleGetUser,
+			summary:  "Get an operator account",
+			response: typeOf[auth.UserInfo](),
+		},
+		{
+			method: http.MethodPut, pattern: "/users/{username}", perm: auth.PermManageUsers, handler: h.handleUpdateUser,
+			summary:  "Change the password or role of an operator account",
+			request:  typeOf[UpdateUserRequest](),
+			response: typeOf[auth.UserInfo](),
+		},
+		{
+			method: http.MethodDelete, pattern: "/users/{username}", perm: auth.PermManageUsers, handler: h.handleDeleteUser,
+			summary: "Delete an operator account",
+			status:  http.StatusNoContent,
+		},
+		{
+			method: http.MethodGet, pattern: "/keys", perm: auth.PermManageUsers, handler: h.handleListAPIKeys,
+			summary:  "List API keys",
+			response: typeOf[auth.APIKey](), list: true,
+		},
+		{
+			method: http.MethodPost, pattern: "/keys", perm: auth.PermManageUsers, handler: h.handleCreateAPIKey,
+			summary:  "Create an API key; the secret is only returned once",
+			request:  typeOf[CreateAPIKeyRequest](),
+			response: typeOf[CreatedAPIKey](), status: http.StatusCreated,
+		},
+		{
+			method: http.MethodGet, pattern: "/keys/{id}", perm: auth.PermManageUsers, handler: h.handleGetAPIKey,
+			summary:  "Get an API key",
+			response: typeOf[auth.APIKey](),
+		},
+		{
+			method: http.MethodDelete, pattern: "/keys/{id}", perm: auth.PermManageUsers, handler: h.handleRevokeAPIKey,
+			summary: "Revoke an API key",
+			status:  http.StatusNoContent,
+		},
+	}
+}
+
+// Handler returns an http.Handler serving every API route under APIPrefix.
+// Each call builds a new mux, so handlers can be served side by side.
+// Requests matching no route get an error envelope like any other error.
+func (h *APIHandler) Handler() http.Handler {
+	mux := http.NewServeMux()
+	for _, rt := range h.routes() {
+		handler := rt.handler
+		if rt.idempotent {
+			handler = h.idempotent(handler)
+		}
+		if !rt.public {
+			handler = h.authMiddleware(rt.perm, handler)
+		}
+		mux.HandleFunc(rt.method+" "+APIPrefix+rt.pattern, handler)
+	}
+
+	// The OpenAPI document lives outside the versioned prefix so that it can
+	// describe several versions later on
+	spec := h.openAPI()
+	mux.HandleFunc("GET "+OpenAPIPath, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, spec)
+	})
+	return withErrorEnvelope(mux)
+}
@@ -0,0 +1,254 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Cl0udRs4/dinot/internal/server/auth"
+	"github.com/Cl0udRs4/dinot/internal/server/client"
+	"github.com/Cl0udRs4/dinot/internal/server/listener"
+	"github.com/Cl0udRs4/dinot/internal/server/task"
+)
+
+// routeFixture is a handler seeded with one of every resource, and the
+// generated IDs of those resources
+type routeFixture struct {
+	handler *APIHandler
+	ids     map[string]string
+}
+
+// setupRouteFixture creates a handler with token support and seeds a client,
+// a tag, a group, an exception, a task, a user, an API key, a refresh token,
+// a stopped TCP listener and a running UDP listener
+func setupRouteFixture(t *testing.T) *routeFixture {
+	t.Helper()
+
+	clientManager := client.NewClientManager()
+	heartbeatMonitor := client.NewHeartbeatMonitor(clientManager, 30*time.Second, 60*time.Second)
+	clientManager.RegisterClient(client.NewClient("test-client-id", "Test Client", "192.168.1.100", "Linux", "x86_64", []string{"shell"}, "tcp"))
+
+	config := Config{
+		Address:    "127.0.0.1:8080",
+		JWTSecret:  "secret",
+		JWTEnabled: true,
+	}
+	h := NewAPIHandler(clientManager, heartbeatMonitor, task.NewTaskManager(clientManager), config)
+
+	clientManager.AddClientTag("test-client-id", "dmz")
+	clientManager.CreateGroup("web", "")
+	clientManager.AddClientToGroup("web", "test-client-id")
+
+	report, err := clientManager.ReportException("test-client-id", "boom", client.SeverityError, "", "", nil)
+	if err != nil {
+		t.Fatalf("ReportException failed: %v", err)
+	}
+
+	tk, err := h.taskManager.CreateTask("test-client-id", "shell", nil)
+	if err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+
+	batch, err := h.taskManager.CreateBatch(client.Target{Tag: "dmz"}, "shell", nil)
+	if err != nil {
+		t.Fatalf("CreateBatch failed: %v", err)
+	}
+
+	workflow, err := h.taskManager.CreateWorkflow(client.Target{Tag: "dmz"}, task.WorkflowDefinition{
+		Steps: []task.StepDefinition{{Name: "probe", Module: "shell"}},
+	})
+	if err != nil {
+		t.Fatalf("CreateWorkflow failed: %v", err)
+	}
+
+	schedule, err := h.scheduler.CreateSchedule(task.ScheduleSpec{Selector: client.Target{Tag: "dmz"}, Module: "shell", Cron: "@daily"})
+	if err != nil {
+		t.Fatalf("CreateSchedule failed: %v", err)
+	}
+
+	t.Cleanup(func() { h.listenerManager.CleanupManager() })
+	h.listenerManager.SetHandler(func(name string, conn net.Conn) { conn.Close() })
+	for _, protocol := range []string{"tcp", "udp"} {
+		if _, err := h.listenerManager.CreateListener(protocol, listener.Config{Address: "127.0.0.1:0"}); err != nil {
+			t.Fatalf("CreateListener failed: %v", err)
+		}
+	}
+	if err := h.listenerManager.StartListener("udp"); err != nil {
+		t.Fatalf("StartListener failed: %v", err)
+	}
+
+	h.userStore.CreateUser("admin", "admin-password", auth.RoleAdmin)
+	h.userStore.CreateUser("alice", "alice-password", auth.RoleViewer)
+
+	key, _, err := h.apiKeyStore.CreateKey("ci", "admin", []auth.Permission{auth.PermReadClients}, nil)
+	if err != nil {
+		t.Fatalf("CreateKey failed: %v", err)
+	}
+
+	tokens, err := h.tokenManager.Issue(&auth.Identity{Username: "alice", Role: auth.RoleViewer})
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+
+	return &routeFixture{
+		handler: h,
+		ids: map[string]string{
+			"{exception}": report.ID,
+			"{task}":      tk.ID,
+			"{batch}":     batch.ID,
+			"{workflow}":  workflow.ID,
+			"{schedule}":  schedule.ID,
+			"{key}":       key.ID,
+			"{refresh}":   tokens.RefreshToken,
+		},
+	}
+}
+
+// expand replaces fixture placeholders in s
+func (f *routeFixture) expand(s string) string {
+	for placeholder, id := range f.ids {
+		s = strings.ReplaceAll(s, placeholder, id)
+	}
+	return s
+}
+
+// routeTests exercises every API route once. route is the method and
+// pattern the case covers, as registered in APIHandler.routes.
+var routeTests = []struct {
+	route string
+	path  string
+	body  string
+	want  int
+}{
+	{"GET /clients", "/clients?status=online", "", http.StatusOK},
+	{"DELETE /clients", "/clients?tag=dmz", "", http.StatusOK},
+	{"GET /clients/{id}", "/clients/test-client-id", "", http.StatusOK},
+	{"DELETE /clients/{id}", "/clients/test-client-id", "", http.StatusOK},
+	{"GET /clients/{id}/history", "/clients/test-client-id/history", "", http.StatusOK},
+	{"GET /clients/{id}/tags", "/clients/test-client-id/tags", "", http.StatusOK},
+	{"POST /clients/{id}/tags", "/clients/test-client-id/tags", `{"tag":"linux"}`, http.StatusOK},
+	{"DELETE /clients/{id}/tags/{tag}", "/clients/test-client-id/tags/dmz", "", http.StatusOK},
+	{"GET /clients/{id}/modules", "/clients/test-client-id/modules", "", http.StatusOK},
+	{"GET /clients/{id}/modules/{name}", "/clients/test-client-id/modules/shell", "", http.StatusOK},
+	{"POST /clients/{id}/modules/{name}", "/clients/test-client-id/modules/shell", `{"command":"id"}`, http.StatusAccepted},
+	{"PUT /clients/{id}/modules/{name}", "/clients/test-client-id/modules/shell", "", http.StatusAccepted},
+	{"DELETE /clients/{id}/modules/{name}", "/clients/test-client-id/modules/shell", "", http.StatusAccepted},
+	{"GET /heartbeat", "/heartbeat", "", http.StatusOK},
+	{"POST /heartbeat", "/heartbeat", `{"timeout":90}`, http.StatusOK},
+	{"POST /status", "/status", `{"clientId":"test-client-id","status":"busy"}`, http.StatusOK},
+	{"GET /exceptions", "/exceptions", "", http.StatusOK},
+	{"POST /exceptions", "/exceptions", `{"clientId":"test-client-id","message":"boom","severity":"warning"}`, http.StatusOK},
+	{"GET /exceptions/{id}", "/exceptions/{exception}", "", http.StatusOK},
+	{"GET /events", "/events?types=exception_reported", "", http.StatusOK},
+	{"GET /events/stream", "/events/stream?after=latest", "", http.StatusBadRequest},
+	{"GET /tags", "/tags", "", http.StatusOK},
+	{"GET /groups", "/groups", "", http.StatusOK},
+	{"POST /groups", "/groups", `{"name":"db"}`, http.StatusCreated},
+	{"GET /groups/{name}", "/groups/web", "", http.StatusOK},
+	{"DELETE /groups/{name}", "/groups/web", "", http.StatusNoContent},
+	{"POST /groups/{name}/members", "/groups/web/members", `{"clientId":"test-client-id"}`, http.StatusOK},
+	{"DELETE /groups/{name}/members/{clientId}", "/groups/web/members/test-client-id", "", http.StatusOK},
+	{"GET /tasks", "/tasks?clientId=test-client-id", "", http.StatusOK},
+	{"POST /tasks", "/tasks", `{"group":"web","module":"shell"}`, http.StatusCreated},
+	{"GET /tasks/{id}", "/tasks/{task}", "", http.StatusOK},
+	{"DELETE /tasks/{id}", "/tasks/{task}", "", http.StatusAccepted},
+	{"GET /batches", "/batches", "", http.StatusOK},
+	{"POST /batches", "/batches", `{"clientIds":["test-client-id"],"module":"shell"}`, http.StatusCreated},
+	{"GET /batches/{id}", "/batches/{batch}", "", http.StatusOK},
+	{"GET /batches/{id}/results", "/batches/{batch}/results", "", http.StatusOK},
+	{"GET /workflows", "/workflows", "", http.StatusOK},
+	{"POST /workflows", "/workflows", `{"group":"web","steps":[{"name":"probe","module":"shell"}]}`, http.StatusCreated},
+	{"GET /workflows/{id}", "/workflows/{workflow}", "", http.StatusOK},
+	{"GET /schedules", "/schedules", "", http.StatusOK},
+	{"POST /schedules", "/schedules", `{"group":"web","module":"shell","cron":"0 */6 * * *"}`, http.StatusCreated},
+	{"GET /schedules/{id}", "/schedules/{schedule}", "", http.StatusOK},
+	{"PUT /schedules/{id}", "/schedules/{schedule}", `{"enabled":false}`, http.StatusOK},
+	{"DELETE /schedules/{id}", "/schedules/{schedule}", "", http.StatusNoContent},
+	{"GET /listeners", "/listeners", "", http.StatusOK},
+	{"POST /listeners", "/listeners", `{"protocol":"dns","address":"127.0.0.1:0","domain":"c2.example.org","ttl":120}`, http.StatusCreated},
+	{"GET /listeners/{name}", "/listeners/tcp", "", http.StatusOK},
+	{"PUT /listeners/{name}", "/listeners/tcp", `{"address":"127.0.0.1:0","buffer_size":8192}`, http.StatusOK},
+	{"DELETE /listeners/{name}", "/listeners/tcp", "", http.StatusNoContent},
+	{"POST /listeners/{name}/start", "/listeners/tcp/start", "", http.StatusOK},
+	{"POST /listeners/{name}/stop", "/listeners/udp/stop", "", http.StatusOK},
+	{"GET /modules", "/modules", "", http.StatusOK},
+	{"GET /modules/{name}", "/modules/file", "", http.StatusOK},
+	{"POST /auth/login", "/auth/login", `{"username":"alice","password":"alice-password"}`, http.StatusOK},
+	{"POST /auth/refresh", "/auth/refresh", `{"refresh_token":"{refresh}"}`, http.StatusOK},
+	{"POST /auth/logout", "/auth/logout", "", http.StatusNoContent},
+	{"GET /me", "/me", "", http.StatusOK},
+	{"GET /users", "/users", "", http.StatusOK},
+	{"POST /users", "/users", `{"username":"bob","password":"bob-password","role":"operator"}`, http.StatusCreated},
+	{"GET /users/{username}", "/users/alice", "", http.StatusOK},
+	{"PUT /users/{username}", "/users/alice", `{"role":"operator"}`, http.StatusOK},
+	{"DELETE /users/{username}", "/users/alice", "", http.StatusNoContent},
+	{"GET /keys", "/keys", "", http.StatusOK},
+	{"POST /keys", "/keys", `{"name":"deploy","scopes":["tasks:create"]}`, http.StatusCreated},
+	{"GET /keys/{id}", "/keys/{key}", "", http.StatusOK},
+	{"DELETE /keys/{id}", "/keys/{key}", "", http.StatusNoContent},
+}
+
+// TestRoutes sends a request to every route on a freshly seeded handler
+func TestRoutes(t *testing.T) {
+	for _, tt := range routeTests {
+		t.Run(tt.route, func(t *testing.T) {
+			fixture := setupRouteFixture(t)
+			method, _, _ := strings.Cut(tt.route, " ")
+
+			req := httptest.NewRequest(method, APIPrefix+fixture.expand(tt.path), strings.NewReader(fixture.expand(tt.body)))
+			rr := httptest.NewRecorder()
+			fixture.handler.Handler().ServeHTTP(rr, req)
+
+			if rr.Code != tt.want {
+				t.Errorf("got %v want %v: %s", rr.Code, tt.want, rr.Body.String())
+			}
+		})
+	}
+}
+
+// TestRoutesCovered fails when a route is added without a case in routeTests
+func TestRoutesCovered(t *testing.T) {
+	covered := make(map[string]bool)
+	for _, tt := range routeTests {
+		covered[tt.route] = true
+	}
+
+	fixture := setupRouteFixture(t)
+	for _, rt := range fixture.handler.routes() {
+		if key := rt.method + " " + rt.pattern; !covered[key] {
+			t.Errorf("route %s has no test case", key)
+		}
+	}
+}
+
+// TestRouterRejects tests unknown paths, wrong methods and the removed
+// unversioned prefix
+func TestRouterRejects(t *testing.T) {
+	fixture := setupRouteFixture(t)
+
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{"GET", "/api/clients", http.StatusNotFound},
+		{"GET", "/api/v1/unknown", http.StatusNotFound},
+		{"PATCH", "/api/v1/clients", http.StatusMethodNotAllowed},
+		{"POST", "/api/v1/tasks/{task}", http.StatusMethodNotAllowed},
+		{"GET", "/api/v1/clients/missing", http.StatusNotFound},
+		{"GET", "/api/v1/clients/missing/modules", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, fixture.expand(tt.path), nil)
+		rr := httptest.NewRecorder()
+		fixture.handler.Handler().ServeHTTP(rr, req)
+
+		if rr.Code != tt.want {
+			t.Errorf("%s %s: got %v want %v", tt.method, tt.path, rr.Code, tt.want)
+		}
+	}
+}
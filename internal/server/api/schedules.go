@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Cl0udRs4/dinot/internal/server/client"
+	"github.com/Cl0udRs4/dinot/internal/server/task"
+)
+
+// CreateScheduleRequest creates a one-shot, recurring or on-register
+// schedule. Exactly one of runAt, cron and onRegister must be set.
+type CreateScheduleRequest struct {
+	client.Target
+	Name       string          `json:"name,omitempty"`
+	Module     string          `json:"module"`
+	Params     json.RawMessage `json:"params,omitempty"`
+	RunAt      *time.Time      `json:"runAt,omitempty"`
+	Cron       string          `json:"cron,omitempty"`
+	OnRegister bool            `json:"onRegister,omitempty"`
+}
+
+// UpdateScheduleRequest enables or disables a schedule
+type UpdateScheduleRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// handleListSchedules handles GET /api/v1/schedules
+func (h *APIHandler) handleListSchedules(w http.ResponseWriter, r *http.Request) {
+	writeList(w, r, h.scheduler.GetAllSchedules(), listSpec{key: "id", defaultSort: "created_at"})
+}
+
+// handleCreateSchedule handles POST /api/v1/schedules
+func (h *APIHandler) handleCreateSchedule(w http.ResponseWriter, r *http.Request) {
+	var data CreateScheduleRequest
+	if !decodeBody(w, r, &data) {
+		return
+	}
+
+	spec := task.ScheduleSpec{
+		Name:       data.Name,
+		Selector:   data.Target,
+		Module:     data.Module,
+		Params:     data.Params,
+		Cron:       data.Cron,
+		OnRegister: data.OnRegister,
+	}
+	if data.RunAt != nil {
+		spec.RunAt = *data.RunAt
+	}
+
+	schedule, err := h.scheduler.CreateSchedule(spec)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, schedule)
+}
+
+// handleGetSchedule handles GET /api/v1/schedules/{id}
+func (h *APIHandler) handleGetSchedule(w http.ResponseWriter, r *http.Request) {
+	schedule, err := h.scheduler.GetSchedule(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, schedule)
+}
+
+// handleUpdateSchedule handles PUT /api/v1/schedules/{id}, which enables or
+// disables a schedule
+func (h *APIHandler) handleUpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	var data UpdateScheduleRequest
+	if !decodeBody(w, r, &data) {
+		return
+	}
+
+	if data.Enabled == nil {
+		writeErrorMessage(w, http.StatusBadRequest, CodeInvalidBody, "enabled is required")
+		return
+	}
+
+	schedule, err := h.scheduler.SetScheduleEnabled(r.PathValue("id"), *data.Enabled)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, schedule)
+}
+
+// handleDeleteSchedule handles DELETE /api/v1/schedules/{id}
+func (h *APIHandler) handleDeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	if err := h.scheduler.DeleteSchedule(r.PathValue("id")); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"net/http"
+)
+
+// TagInfo is a tag in use and the number of clients carrying it
+type TagInfo struct {
+	Name    string `json:"name"`
+	Clients int    `json:"clients"`
+}
+
+// ClientTagsResponse lists the tags of a client
+type ClientTagsResponse struct {
+	ClientID string   `json:"client_id"`
+	Tags     []string `json:"tags"`
+}
+
+// AddTagRequest adds a tag to a client
+type AddTagRequest struct {
+	Tag string `json:"tag"`
+}
+
+// CreateGroupRequest creates a group with optional initial members
+type CreateGroupRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	ClientIDs   []string `json:"clientIds,omitempty"`
+}
+
+// AddGroupMemberRequest adds a client to a group
+type AddGroupMemberRequest struct {
+	ClientID string `json:"clientId"`
+}
+
+// handleListTags handles GET /api/v1/tags
+func (h *APIHandler) handleListTags(w http.ResponseWriter, r *http.Request) {
+	// Get every tag in use with its client count
+	tags := make([]TagInfo, 0)
+	for name, count := range h.clientManager.GetAllTags() {
+		tags = append(tags, TagInfo{Name: name, Clients: count})
+	}
+
+	writeList(w, r, tags, listSpec{key: "name"})
+}
+
+// handleGetClientTags handles GET /api/v1/clients/{id}/tags
+func (h *APIHandler) handleGetClientTags(w http.ResponseWriter, r *http.Request) {
+	c, err := h.clientManager.GetClient(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, c.GetTags())
+}
+
+// handleAddClientTag handles POST /api/v1/clients/{id}/tags
+func (h *APIHandler) handleAddClientTag(w http.ResponseWriter, r *http.Request) {
+	var data AddTagRequest
+	if !decodeBody(w, r, &data) {
+		return
+	}
+
+	clientID := r.PathValue("id")
+	if err := h.clientManager.AddClientTag(clientID, data.Tag); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	h.writeClientTags(w, clientID)
+}
+
+// handleRemoveClientTag handles DELETE /api/v1/clients/{id}/tags/{tag}
+func (h *APIHandler) handleRemoveClientTag(w http.ResponseWriter, r *http.Request) {
+	clientID := r.PathValue("id")
+	if err := h.clientManager.RemoveClientTag(clientID, r.PathValue("tag")); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	h.writeClientTags(w, clientID)
+}
+
+// writeClientTags writes the current tags of a client as JSON
+func (h *APIHandler) writeClientTags(w http.ResponseWriter, clientID string) {
+	c, err := h.clientManager.GetClient(clientID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ClientTagsResponse{ClientID: clientID, Tags: c.GetTags()})
+}
+
+// handleListGroups handles GET /api/v1/groups
+func (h *APIHandler) handleListGroups(w http.ResponseWriter, r *http.Request) {
+	writeList(w, r, h.clientManager.GetAllGroups(), listSpec{key: "name"})
+}
+
+// handleCreateGroup handles POST /api/v1/groups, which creates a group with
+// optional initial members
+func (h *APIHandler) handleCreateGroup(w http.ResponseWriter, r *http.Request) {
+	var data CreateGroupRequest
+	if !decodeBody(w, r, &data) {
+		return
+	}
+
+	if _, err := h.clientManager.CreateGroup(data.Name, data.Description); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	for _, clientID := range data.ClientIDs {
+		if err := h.clientManager.AddClientToGroup(data.Name, clientID); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	h.writeGroup(w, data.Name, http.StatusCreated)
+}
+
+// handleGetGroup handles GET /api/v1/groups/{name}
+func (h *APIHandler) handleGetGroup(w http.ResponseWriter, r *http.Request) {
+	h.writeGroup(w, r.PathValue("name"), http.StatusOK)
+}
+
+// handleDeleteGroup handles DELETE /api/v1/groups/{name}
+func (h *APIHandler) handleDeleteGroup(w http.ResponseWriter, r *http.Request) {
+	if err := h.clientManager.DeleteGroup(r.PathValue("name")); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAddGroupMember handles POST /api/v1/groups/{name}/members
+func (h *APIHandler) handleAddGroupMember(w http.ResponseWriter, r *http.Request) {
+	var data AddGroupMemberRequest
+	if !decodeBody(w, r, &data) {
+		return
+	}
+
+	name := r.PathValue("name")
+	if err := h.clientManager.AddClientToGroup(name, data.ClientID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	h.writeGroup(w, name, http.StatusOK)
+}
+
+// handleRemoveGroupMember handles DELETE /api/v1/groups/{name}/members/{clientId}
+func (h *APIHandler) handleRemoveGroupMember(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if err := h.clientManager.RemoveClientFromGroup(name, r.PathValue("clientId")); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	h.writeGroup(w, name, http.StatusOK)
+}
+
+// writeGroup writes a group as JSON
+func (h *APIHandler) writeGroup(w http.ResponseWriter, name string, status int) {
+	group, err := h.clientManager.GetGroup(name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, status, group)
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Cl0udRs4/dinot/internal/server/client"
+	"github.com/Cl0udRs4/dinot/internal/server/task"
+)
+
+// CreateTasksRequest runs a module on a client, or on every client of a tag
+// or group
+type CreateTasksRequest struct {
+	client.Target
+	Module string          `json:"module"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// handleListTasks handles GET /api/v1/tasks
+func (h *APIHandler) handleListTasks(w http.ResponseWriter, r *http.Request) {
+	// Get all tasks or filter by client ID
+	var tasks []*task.Task
+	if clientID := r.URL.Query().Get("clientId"); clientID != "" {
+		tasks = h.taskManager.GetClientTasks(clientID)
+	} else {
+		tasks = h.taskManager.GetAllTasks()
+	}
+
+	writeList(w, r, tasks, listSpec{key: "id", defaultSort: "created_at"})
+}
+
+// handleCreateTasks handles POST /api/v1/tasks, which creates a task on a
+// client, or one task per client of a tag or group
+func (h *APIHandler) handleCreateTasks(w http.ResponseWriter, r *http.Request) {
+	var data CreateTasksRequest
+	if !decodeBody(w, r, &data) {
+		return
+	}
+
+	tasks, err := h.taskManager.CreateTasks(data.Target, data.Module, data.Params)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	snapshots, err := taskSnapshots(tasks)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, snapshots)
+}
+
+// taskSnapshots encodes tasks under their locks; they may be dispatched or
+// receive feedback while the response is written
+func taskSnapshots(tasks []*task.Task) ([]json.RawMessage, error) {
+	snapshots := make([]json.RawMessage, 0, len(tasks))
+	for _, t := range tasks {
+		data, err := t.ToJSON()
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, data)
+	}
+	return snapshots, nil
+}
+
+// handleGetTask handles GET /api/v1/tasks/{id}
+func (h *APIHandler) handleGetTask(w http.ResponseWriter, r *http.Request) {
+	t, err := h.taskManager.GetTask(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	data, err := t.ToJSON()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// handleCancelTask handles DELETE /api/v1/tasks/{id}, which cancels a task.
+// Cancelling a task that was delivered is asynchronous: the task stays
+// cancelling until the client confirms.
+func (h *APIHandler) handleCancelTask(w http.ResponseWriter, r *http.Request) {
+	t, err := h.taskManager.CancelTask(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeTask(w, http.StatusAccepted, t)
+}
+
+// writeTask writes a snapshot of a task with the given status code
+func writeTask(w http.ResponseWriter, status int, t *task.Task) {
+	data, err := t.ToJSON()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, status, json.RawMessage(data))
+}
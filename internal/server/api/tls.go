@@ -0,0 +1,204 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Cl0udRs4/dinot/internal/server/auth"
+)
+
+// TLSConfig configures HTTPS and operator client certificates for the API
+type TLSConfig struct {
+	// CertFile is the PEM certificate served by the API. If CertFile and
+	// KeyFile are empty, a self-signed certificate is generated in memory;
+	// if they are set but do not exist, one is generated and written there
+	// so its fingerprint stays the same across restarts.
+	CertFile string
+
+	// KeyFile is the PEM private key of CertFile
+	KeyFile string
+
+	// ClientCAFile is a PEM bundle of CAs that sign operator certificates.
+	// When set, operators can authenticate with a certificate whose common
+	// name is their username.
+	ClientCAFile string
+
+	// RequireClientCert rejects TLS handshakes without a valid operator
+	// certificate; requires ClientCAFile
+	RequireClientCert bool
+}
+
+// BuildTLSConfig loads or generates the server certificate and returns the
+// TLS configuration together with the certificate's SHA-256 fingerprint.
+// It is shared by the API and the team server of the remote console.
+func BuildTLSConfig(config *TLSConfig, address string) (*tls.Config, string, error) {
+	cert, err := loadOrGenerateCertificate(config.CertFile, config.KeyFile, address)
+	if err != nil {
+		return nil, "", err
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if config.ClientCAFile != "" {
+		data, err := os.ReadFile(config.ClientCAFile)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read client CA file: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, "", errors.New("no certificates found in client CA file")
+		}
+
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		if config.RequireClientCert {
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	} else if config.RequireClientCert {
+		return nil, "", errors.New("client certificates are required but no client CA file is set")
+	}
+
+	return tlsConfig, CertificateFingerprint(cert.Certificate[0]), nil
+}
+
+// loadOrGenerateCertificate loads a certificate and key, generating a
+// self-signed pair when the files are not configured or do not exist yet
+func loadOrGenerateCertificate(certFile, keyFile, address string) (tls.Certificate, error) {
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return tls.Certificate{}, errors.New("both a certificate and a key file are needed")
+		}
+
+		if _, err := os.Stat(certFile); err == nil {
+			return tls.LoadX509KeyPair(certFile, keyFile)
+		}
+	}
+
+	certPEM, keyPEM, err := generateSelfSignedCert(address)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	if certFile != "" {
+		if err := os.MkdirAll(filepath.Dir(certFile), 0700); err != nil {
+			return tls.Certificate{}, fmt.Errorf("failed to create certificate directory: %w", err)
+		}
+		if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+			return tls.Certificate{}, fmt.Errorf("failed to write key file: %w", err)
+		}
+		if err := os.WriteFile(certFile, certPEM, 0644); err != nil {
+			return tls.Certificate{}, fmt.Errorf("failed to write certificate file: %w", err)
+		}
+	}
+
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// generateSelfSignedCert creates a self-signed ECDSA certificate valid for
+// localhost and the host of the listen address
+func generateSelfSignedCert(address string) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "dinot control API"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+
+	// Add the listen host unless it is a wildcard address
+	if host, _, err := net.SplitHostPort(address); err == nil && host != "" {
+		if ip := net.ParseIP(host); ip != nil {
+			if !ip.IsUnspecified() && !ip.IsLoopback() {
+				template.IPAddresses = append(template.IPAddresses, ip)
+			}
+		} else if host != "localhost" {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// CertificateFingerprint returns the SHA-256 fingerprint of a DER encoded
+// certificate as colon separated hex, the format browsers and openssl print
+func CertificateFingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	hexSum := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	parts := make([]string, 0, len(sum))
+	for i := 0; i < len(hexSum); i += 2 {
+		parts = append(parts, hexSum[i:i+2])
+	}
+	return strings.Join(parts, ":")
+}
+
+// certificateIdentity maps a verified operator certificate to the user
+// named by its common name
+func (h *APIHandler) certificateIdentity(state *tls.ConnectionState) *auth.Identity {
+	return CertificateIdentity(h.userStore, state)
+}
+
+// CertificateIdentity maps the verified operator certificate of a TLS
+// connection to the user named by its common name, or returns nil
+func CertificateIdentity(userStore *auth.UserStore, state *tls.ConnectionState) *auth.Identity {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+
+	username := state.VerifiedChains[0][0].Subject.CommonName
+	if username == "" {
+		return nil
+	}
+
+	user, err := userStore.GetUser(username)
+	if err != nil {
+		return nil
+	}
+
+	return &auth.Identity{Username: user.Username, Role: user.Role, Method: "mtls"}
+}
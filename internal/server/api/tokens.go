@@ -0,0 +1,112 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+)
+
+// LoginRequest exchanges a username and password for a token pair
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// RefreshRequest carries a refresh token, to exchange on refresh or to
+// revoke on logout
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// handleLogin handles POST /api/v1/auth/login
+func (h *APIHandler) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if !h.jwtEnabled {
+		writeTokenAuthDisabled(w)
+		return
+	}
+
+	var data LoginRequest
+	if !decodeBody(w, r, &data) {
+		return
+	}
+
+	identity, err := h.userStore.Authenticate(data.Username, data.Password)
+	if err != nil {
+		writeErrorMessage(w, http.StatusUnauthorized, "invalid_credentials", err.Error())
+		return
+	}
+
+	tokens, err := h.tokenManager.Issue(identity)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, tokens)
+}
+
+// handleRefresh handles POST /api/v1/auth/refresh
+func (h *APIHandler) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	if !h.jwtEnabled {
+		writeTokenAuthDisabled(w)
+		return
+	}
+
+	var data RefreshRequest
+	if !decodeBody(w, r, &data) {
+		return
+	}
+
+	// Refresh tokens are single use; the old one is revoked on success
+	tokens, err := h.tokenManager.Refresh(data.RefreshToken)
+	if err != nil {
+		writeTokenError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, tokens)
+}
+
+// handleLogout handles POST /api/v1/auth/logout. It revokes the access
+// token the request was made with and, if given, a refresh token.
+func (h *APIHandler) handleLogout(w http.ResponseWriter, r *http.Request) {
+	var data RefreshRequest
+	if r.ContentLength != 0 {
+		if !decodeBody(w, r, &data) {
+			return
+		}
+	}
+
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		h.tokenManager.Revoke(strings.TrimPrefix(header, "Bearer "))
+	}
+
+	if data.RefreshToken != "" {
+		if err := h.tokenManager.Revoke(data.RefreshToken); err != nil {
+			writeTokenError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeTokenAuthDisabled writes the error returned by the token routes when
+// token authentication is disabled
+func writeTokenAuthDisabled(w http.ResponseWriter) {
+	writeErrorMessage(w, http.StatusNotFound, "token_auth_disabled", "token authentication is disabled")
+}
+
+// writeTokenError writes a token validation error with the given status. The
+// code is taken from the error if it is a token error, so that clients can
+// tell an expired token from a revoked or malformed one.
+func writeTokenError(w http.ResponseWriter, status int, err error) {
+	_, code, _ := errorStatus(err)
+	switch code {
+	case "token_expired", "token_revoked", CodeInvalidToken:
+	default:
+		code = CodeInvalidToken
+	}
+	writeErrorMessage(w, status, code, err.Error())
+}
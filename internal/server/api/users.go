@@ -0,0 +1,99 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Cl0udRs4/dinot/internal/server/auth"
+)
+
+// CreateUserRequest creates an operator account
+type CreateUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+// UpdateUserRequest changes the password and/or role of an account
+type UpdateUserRequest struct {
+	Password string `json:"password,omitempty"`
+	Role     string `json:"role,omitempty"`
+}
+
+// handleGetMe handles GET /api/v1/me
+func (h *APIHandler) handleGetMe(w http.ResponseWriter, r *http.Request) {
+	// Return the identity of the caller; with authentication disabled
+	// every caller acts as an anonymous admin
+	identity, ok := auth.IdentityFromContext(r.Context())
+	if !ok {
+		identity = &auth.Identity{Role: auth.RoleAdmin, Method: "none"}
+	}
+
+	writeJSON(w, http.StatusOK, identity)
+}
+
+// handleListUsers handles GET /api/v1/users
+func (h *APIHandler) handleListUsers(w http.ResponseWriter, r *http.Request) {
+	writeList(w, r, h.userStore.ListUsers(), listSpec{key: "username"})
+}
+
+// handleCreateUser handles POST /api/v1/users
+func (h *APIHandler) handleCreateUser(w http.ResponseWriter, r *http.Request) {
+	var data CreateUserRequest
+	if !decodeBody(w, r, &data) {
+		return
+	}
+
+	user, err := h.userStore.CreateUser(data.Username, data.Password, auth.Role(data.Role))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, user)
+}
+
+// handleGetUser handles GET /api/v1/users/{username}
+func (h *APIHandler) handleGetUser(w http.ResponseWriter, r *http.Request) {
+	user, err := h.userStore.GetUser(r.PathValue("username"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+// handleUpdateUser handles PUT /api/v1/users/{username}, which changes the
+// password and/or role of an account
+func (h *APIHandler) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
+	var data UpdateUserRequest
+	if !decodeBody(w, r, &data) {
+		return
+	}
+
+	username := r.PathValue("username")
+	if data.Password != "" {
+		if err := h.userStore.SetPassword(username, data.Password); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	if data.Role != "" {
+		if err := h.userStore.SetRole(username, auth.Role(data.Role)); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	h.handleGetUser(w, r)
+}
+
+// handleDeleteUser handles DELETE /api/v1/users/{username}
+func (h *APIHandler) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	if err := h.userStore.DeleteUser(r.PathValue("username")); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
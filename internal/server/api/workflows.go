@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Cl0udRs4/dinot/internal/server/client"
+	"github.com/Cl0udRs4/dinot/internal/server/task"
+)
+
+// CreateWorkflowRequest runs a workflow on a list of clients, a tag, a group
+// or the clients matching a filter
+type CreateWorkflowRequest struct {
+	client.Target
+	task.WorkflowDefinition
+}
+
+// handleListWorkflows handles GET /api/v1/workflows
+func (h *APIHandler) handleListWorkflows(w http.ResponseWriter, r *http.Request) {
+	writeList(w, r, h.taskManager.GetAllWorkflows(), listSpec{key: "id", defaultSort: "created_at"})
+}
+
+// handleCreateWorkflow handles POST /api/v1/workflows, which starts a
+// workflow on every selected client
+func (h *APIHandler) handleCreateWorkflow(w http.ResponseWriter, r *http.Request) {
+	var data CreateWorkflowRequest
+	if !decodeBody(w, r, &data) {
+		return
+	}
+
+	workflow, err := h.taskManager.CreateWorkflow(data.Target, data.WorkflowDefinition)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, workflow)
+}
+
+// handleGetWorkflow handles GET /api/v1/workflows/{id}, which returns the
+// status of every step on every client
+func (h *APIHandler) handleGetWorkflow(w http.ResponseWriter, r *http.Request) {
+	workflow, err := h.taskManager.GetWorkflow(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, workflow)
+}
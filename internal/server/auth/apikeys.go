@@ -0,0 +1,320 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrAPIKeyNotFound is returned when an API key with the specified ID is not found
+	ErrAPIKeyNotFound = errors.New("API key not found")
+
+	// ErrInvalidAPIKey is returned when an API key is malformed, unknown or wrong
+	ErrInvalidAPIKey = errors.New("invalid API key")
+
+	// ErrAPIKeyExpired is returned when an API key is past its expiry time
+	ErrAPIKeyExpired = errors.New("API key expired")
+
+	// ErrInvalidScope is returned when an API key scope is not a known permission
+	ErrInvalidScope = errors.New("invalid scope")
+
+	// ErrMissingScopes is returned when an API key is created without scopes
+	ErrMissingScopes = errors.New("API key needs at least one scope")
+
+	// ErrInvalidKeyName is returned when an API key is created without a name
+	ErrInvalidKeyName = errors.New("invalid API key name")
+)
+
+const (
+	// MethodAPIKey is the identity method of callers using an API key
+	MethodAPIKey = "apikey"
+
+	// APIKeyPrefix starts every API key so keys are easy to recognise
+	APIKeyPrefix = "dk_"
+
+	// lastUsedPersistInterval limits how often last-used times are written
+	// to the store file
+	lastUsedPersistInterval = time.Minute
+)
+
+// APIKey is a long-lived credential for automation. The key itself is
+// APIKeyPrefix, the ID, an underscore and a random secret; only a hash of
+// the secret is stored.
+type APIKey struct {
+	// ID is the public identifier of the key
+	ID string `json:"id"`
+
+	// Name describes what the key is used for
+	Name string `json:"name"`
+
+	// Owner is the operator who created the key
+	Owner string `json:"owner,omitempty"`
+
+	// Scopes are the permissions the key grants
+	Scopes []Permission `json:"scopes"`
+
+	// SecretHash is the SHA-256 hash of the key secret
+	SecretHash string `json:"secret_hash,omitempty"`
+
+	// CreatedAt is when the key was created
+	CreatedAt time.Time `json:"created_at"`
+
+	// ExpiresAt is when the key stops working; nil means never
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	// LastUsedAt is when the key was last used to authenticate
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// APIKeyStore stores API keys
+type APIKeyStore struct {
+	// keys maps key IDs to keys
+	keys map[string]*APIKey
+
+	// path is the file the store is persisted to, if any
+	path string
+
+	// lastSaved is when the store file was last written
+	lastSaved time.Time
+
+	// mu protects concurrent access to the keys map
+	mu sync.RWMutex
+}
+
+// NewAPIKeyStore creates a new, empty API key store
+func NewAPIKeyStore() *APIKeyStore {
+	return &APIKeyStore{
+		keys: make(map[string]*APIKey),
+	}
+}
+
+// SetStorePath sets the file the store is persisted to. Changes are saved
+// automatically once a path is set.
+func (s *APIKeyStore) SetStorePath(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.path = path
+}
+
+// Load reads the keys from the store file. A missing file is not an error.
+func (s *APIKeyStore) Load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read API key store: %w", err)
+	}
+
+	var keys []*APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("failed to decode API key store: %w", err)
+	}
+
+	for _, key := range keys {
+		s.keys[key.ID] = key
+	}
+
+	return nil
+}
+
+// Save writes the keys to the store file, including last-used times that
+// have not been persisted yet
+func (s *APIKeyStore) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.save()
+}
+
+// save writes the keys to the store file; callers must hold s.mu
+func (s *APIKeyStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	keys := make([]*APIKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode API key store: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create API key store directory: %w", err)
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write API key store: %w", err)
+	}
+
+	s.lastSaved = time.Now()
+	return os.Rename(tmpPath, s.path)
+}
+
+// CreateKey creates an API key and returns it together with the plaintext
+// key, which is not stored and cannot be retrieved again
+func (s *APIKeyStore) CreateKey(name, owner string, scopes []Permission, expiresAt *time.Time) (*APIKey, string, error) {
+	if name == "" {
+		return nil, "", ErrInvalidKeyName
+	}
+	if len(scopes) == 0 {
+		return nil, "", ErrMissingScopes
+	}
+	for _, scope := range scopes {
+		if !ValidPermission(scope) {
+			return nil, "", fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+		}
+	}
+
+	id := randomID(8)
+	secret := randomID(24)
+
+	key := &APIKey{
+		ID:         id,
+		Name:       name,
+		Owner:      owner,
+		Scopes:     append([]Permission{}, scopes...),
+		SecretHash: hashSecret(secret),
+		CreatedAt:  time.Now(),
+		ExpiresAt:  expiresAt,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[id] = key
+	if err := s.save(); err != nil {
+		return nil, "", err
+	}
+
+	return key.info(), APIKeyPrefix + id + "_" + secret, nil
+}
+
+// RevokeKey deletes an API key
+func (s *APIKeyStore) RevokeKey(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.keys[id]; !exists {
+		return ErrAPIKeyNotFound
+	}
+
+	delete(s.keys, id)
+	return s.save()
+}
+
+// GetKey returns an API key without its secret hash
+func (s *APIKeyStore) GetKey(id string) (*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, exists := s.keys[id]
+	if !exists {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	return key.info(), nil
+}
+
+// ListKeys returns all API keys without their secret hashes, oldest first
+func (s *APIKeyStore) ListKeys() []*APIKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]*APIKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key.info())
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+
+	return keys
+}
+
+// Authenticate checks an API key and returns an identity limited to its
+// scopes. The key's last-used time is updated.
+func (s *APIKeyStore) Authenticate(token string) (*Identity, error) {
+	if !strings.HasPrefix(token, APIKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	id, secret, ok := strings.Cut(strings.TrimPrefix(token, APIKeyPrefix), "_")
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, exists := s.keys[id]
+	if !exists {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(key.SecretHash)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+
+	now := time.Now()
+	if key.ExpiresAt != nil && now.After(*key.ExpiresAt) {
+		return nil, ErrAPIKeyExpired
+	}
+
+	key.LastUsedAt = &now
+	if now.Sub(s.lastSaved) > lastUsedPersistInterval {
+		s.save()
+	}
+
+	return &Identity{
+		Username: APIKeyPrincipal(key.ID),
+		KeyName:  key.Name,
+		Method:   MethodAPIKey,
+		Scopes:   append([]Permission{}, key.Scopes...),
+	}, nil
+}
+
+// APIKeyPrincipal returns the identity username of an API key. Key names
+// are not unique, so keys are identified by ID.
+func APIKeyPrincipal(id string) string {
+	return "apikey:" + id
+}
+
+// info returns a copy of the key without its secret hash
+func (k *APIKey) info() *APIKey {
+	info := *k
+	info.SecretHash = ""
+	info.Scopes = append([]Permission{}, k.Scopes...)
+	return &info
+}
+
+// hashSecret hashes an API key secret. Secrets are random, so a fast hash
+// is enough.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCreateAndAuthenticateAPIKey(t *testing.T) {
+	store := NewAPIKeyStore()
+
+	if _, _, err := store.CreateKey("ci", "admin", nil, nil); err != ErrMissingScopes {
+		t.Errorf("expected ErrMissingScopes, got %v", err)
+	}
+	if _, _, err := store.CreateKey("ci", "admin", []Permission{"clients:delete"}, nil); err == nil {
+		t.Error("expected unknown scope to be rejected")
+	}
+
+	key, secret, err := store.CreateKey("ci", "admin", []Permission{PermReadClients, PermCreateTasks}, nil)
+	if err != nil {
+		t.Fatalf("CreateKey failed: %v", err)
+	}
+	if key.SecretHash != "" {
+		t.Error("secret hash should not be returned")
+	}
+
+	identity, err := store.Authenticate(secret)
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if identity.Method != MethodAPIKey || !identity.Can(PermCreateTasks) || identity.Can(PermWriteClients) {
+		t.Errorf("unexpected identity: %+v", identity)
+	}
+
+	// Keys are told apart by ID, as their names need not be unique
+	if identity.Username != "apikey:"+key.ID || identity.KeyName != key.Name {
+		t.Errorf("expected principal apikey:%s named %s, got %+v", key.ID, key.Name, identity)
+	}
+
+	stored, _ := store.GetKey(key.ID)
+	if stored.LastUsedAt == nil {
+		t.Error("expected last-used time to be recorded")
+	}
+
+	if _, err := store.Authenticate(secret + "x"); err != ErrInvalidAPIKey {
+		t.Errorf("expected ErrInvalidAPIKey, got %v", err)
+	}
+
+	if err := store.RevokeKey(key.ID); err != nil {
+		t.Fatalf("RevokeKey failed: %v", err)
+	}
+	if _, err := store.Authenticate(secret); err != ErrInvalidAPIKey {
+		t.Errorf("expected ErrInvalidAPIKey, got %v", err)
+	}
+}
+
+func TestExpiredAPIKey(t *testing.T) {
+	store := NewAPIKeyStore()
+
+	expired := time.Now().Add(-time.Minute)
+	_, secret, _ := store.CreateKey("old", "admin", []Permission{PermReadClients}, &expired)
+
+	if _, err := store.Authenticate(secret); err != ErrAPIKeyExpired {
+		t.Errorf("expected ErrAPIKeyExpired, got %v", err)
+	}
+}
+
+func TestAPIKeyStorePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "apikeys.json")
+
+	store := NewAPIKeyStore()
+	store.SetStorePath(path)
+	_, secret, _ := store.CreateKey("ci", "admin", []Permission{PermReadClients}, nil)
+
+	loaded := NewAPIKeyStore()
+	loaded.SetStorePath(path)
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if _, err := loaded.Authenticate(secret); err != nil {
+		t.Errorf("Authenticate failed after reload: %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+// Package auth provides operator accounts, roles and permissions for the control API
+package auth
+
+import (
+	"context"
+)
+
+// Role is an operator role
+type Role string
+
+const (
+	// RoleAdmin can do everything, including managing listeners and users
+	RoleAdmin Role = "admin"
+	// RoleOperator can manage clients and create tasks
+	RoleOperator Role = "operator"
+	// RoleViewer has read-only access
+	RoleViewer Role = "viewer"
+)
+
+// Permission is an action an identity may be allowed to perform
+type Permission string
+
+const (
+	// PermReadClients allows reading clients, groups, tags, exceptions and modules
+	PermReadClients Permission = "clients:read"
+	// PermWriteClients allows changing client status, tags, groups and heartbeat settings
+	PermWriteClients Permission = "clients:write"
+	// PermReadTasks allows reading tasks and their results
+	PermReadTasks Permission = "tasks:read"
+	// PermCreateTasks allows creating tasks and loading or unloading modules
+	PermCreateTasks Permission = "tasks:create"
+	// PermManageListeners allows creating, starting, stopping and removing listeners
+	PermManageListeners Permission = "listeners:manage"
+	// PermManageUsers allows managing operator accounts
+	PermManageUsers Permission = "users:manage"
+)
+
+// rolePermissions maps roles to the permissions they grant
+var rolePermissions = map[Role][]Permission{
+	RoleAdmin: {
+		PermReadClients, PermWriteClients, PermReadTasks, PermCreateTasks,
+		PermManageListeners, PermManageUsers,
+	},
+	RoleOperator: {
+		PermReadClients, PermWriteClients, PermReadTasks, PermCreateTasks,
+	},
+	RoleViewer: {
+		PermReadClients, PermReadTasks,
+	},
+}
+
+// ValidRole reports whether a role is known
+func ValidRole(role Role) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// RoleHasPermission reports whether a role grants a permission
+func RoleHasPermission(role Role, perm Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidPermission reports whether a permission is known
+func ValidPermission(perm Permission) bool {
+	return RoleHasPermission(RoleAdmin, perm)
+}
+
+// Identity is an authenticated caller of the API
+type Identity struct {
+	// Username is the operator's username, or "apikey:" and the ID of an
+	// API key. It is unique per principal, so it can scope per-caller state.
+	Username string `json:"username"`
+
+	// KeyName is the name of an API key; names need not be unique
+	KeyName string `json:"key_name,omitempty"`
+
+	// Role is the operator's role; empty for API keys
+	Role Role `json:"role,omitempty"`
+
+	// Method is how the caller authenticated, e.g. "basic", "jwt" or "apikey"
+	Method string `json:"method"`
+
+	// Scopes restricts an API key to the listed permissions
+	Scopes []Permission `json:"scopes,omitempty"`
+}
+
+// Can reports whether the identity is allowed to perform an action. API
+// keys are limited to their scopes, operators to the permissions of their role.
+func (i *Identity) Can(perm Permission) bool {
+	if i == nil {
+		return false
+	}
+
+	if i.Method == MethodAPIKey {
+		for _, scope := range i.Scopes {
+			if scope == perm {
+				return true
+			}
+		}
+		return false
+	}
+
+	return RoleHasPermission(i.Role, perm)
+}
+
+// identityKey is the context key for the authenticated identity
+type identityKey struct{}
+
+// WithIdentity returns a copy of ctx carrying the identity
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext returns the identity stored in ctx, if any
+func IdentityFromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(*Identity)
+	return identity, ok
+}
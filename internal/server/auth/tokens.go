@@ -0,0 +1,288 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Cl0udRs4/dinot/internal/server/common"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	// ErrTokenRevoked is returned when a token has been revoked by logout or refresh
+	ErrTokenRevoked = errors.New("token revoked")
+
+	// ErrWrongTokenType is returned when a refresh token is used as an access
+	// token or the other way round
+	ErrWrongTokenType = errors.New("wrong token type")
+)
+
+const (
+	// TokenTypeAccess marks short-lived tokens that authorize API requests
+	TokenTypeAccess = "access"
+
+	// TokenTypeRefresh marks long-lived tokens that can only be exchanged for
+	// a new token pair
+	TokenTypeRefresh = "refresh"
+
+	// DefaultAccessTokenTTL is the default lifetime of an access token
+	DefaultAccessTokenTTL = 15 * time.Minute
+
+	// DefaultRefreshTokenTTL is the default lifetime of a refresh token
+	DefaultRefreshTokenTTL = 24 * time.Hour
+)
+
+// TokenPair is the result of a login or refresh
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// TokenManager issues, validates and revokes API tokens
+type TokenManager struct {
+	// users is the store tokens are issued against
+	users *UserStore
+
+	// secret is the HMAC key used to sign tokens
+	secret string
+
+	// accessTTL is the lifetime of access tokens
+	accessTTL time.Duration
+
+	// refreshTTL is the lifetime of refresh tokens
+	refreshTTL time.Duration
+
+	// revoked maps the IDs of revoked tokens to their expiry time, after
+	// which they no longer need to be remembered
+	revoked map[string]time.Time
+
+	// mu protects concurrent access to the revocation list
+	mu sync.Mutex
+}
+
+// NewTokenManager creates a new token manager for the users of a store. A
+// random secret is generated if secret is empty, and zero lifetimes fall
+// back to the defaults.
+func NewTokenManager(users *UserStore, secret string, accessTTL, refreshTTL time.Duration) *TokenManager {
+	if secret == "" {
+		secret = randomID(32)
+	}
+	if accessTTL <= 0 {
+		accessTTL = DefaultAccessTokenTTL
+	}
+	if refreshTTL <= 0 {
+		refreshTTL = DefaultRefreshTokenTTL
+	}
+
+	return &TokenManager{
+		users:      users,
+		secret:     secret,
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+		revoked:    make(map[string]time.Time),
+	}
+}
+
+// Issue creates a new access and refresh token pair for an identity
+func (m *TokenManager) Issue(identity *Identity) (*TokenPair, error) {
+	access, err := m.sign(identity, TokenTypeAccess, m.accessTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := m.sign(identity, TokenTypeRefresh, m.refreshTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(m.accessTTL / time.Second),
+	}, nil
+}
+
+// Authenticate validates an access token and returns the identity of its
+// user. The role is taken from the user store so role changes apply to
+// tokens already issued.
+func (m *TokenManager) Authenticate(token string) (*Identity, error) {
+	claims, err := m.Validate(token, TokenTypeAccess)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.identity(claims.Username)
+}
+
+// Validate checks a token's signature, expiry, type and revocation status
+// and returns its claims
+func (m *TokenManager) Validate(token, tokenType string) (*common.JWTClaims, error) {
+	claims, err := common.ValidateJWT(token, m.secret)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.TokenType != tokenType {
+		return nil, ErrWrongTokenType
+	}
+
+	m.mu.Lock()
+	_, revoked := m.revoked[claims.ID]
+	m.mu.Unlock()
+
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+
+	return claims, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair. The refresh token
+// is revoked so that it can only be used once, even by concurrent requests.
+func (m *TokenManager) Refresh(refreshToken string) (*TokenPair, error) {
+	claims, err := m.Validate(refreshToken, TokenTypeRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check and revoke in one step; Validate only saw a snapshot of the
+	// revocation list
+	if !m.revokeUnused(claims) {
+		return nil, ErrTokenRevoked
+	}
+
+	// Look the user up again so deleted users cannot refresh and role
+	// changes are picked up
+	identity, err := m.identity(claims.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.Issue(identity)
+}
+
+// Revoke revokes a token of either type. It returns an error if the token is
+// not a valid token issued by this manager.
+func (m *TokenManager) Revoke(token string) error {
+	claims, err := common.ValidateJWT(token, m.secret)
+	if err != nil {
+		return err
+	}
+
+	m.revoke(claims)
+	return nil
+}
+
+// revoke adds a token to the revocation list
+func (m *TokenManager) revoke(claims *common.JWTClaims) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.revokeLocked(claims)
+}
+
+// revokeUnused revokes a token unless it is already revoked, and reports
+// whether it did
+func (m *TokenManager) revokeUnused(claims *common.JWTClaims) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, revoked := m.revoked[claims.ID]; revoked {
+		return false
+	}
+
+	m.revokeLocked(claims)
+	return true
+}
+
+// revokeLocked adds a token to the revocation list and prunes expired
+// entries; callers must hold m.mu
+func (m *TokenManager) revokeLocked(claims *common.JWTClaims) {
+	now := time.Now()
+	for id, expiry := range m.revoked {
+		if now.After(expiry) {
+			delete(m.revoked, id)
+		}
+	}
+
+	expiry := now.Add(m.refreshTTL)
+	if claims.ExpiresAt != nil {
+		expiry = claims.ExpiresAt.Time
+	}
+	m.revoked[claims.ID] = expiry
+}
+
+// identity looks up the current identity of a user
+func (m *TokenManager) identity(username string) (*Identity, error) {
+	user, err := m.users.GetUser(username)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Identity{Username: user.Username, Role: user.Role, Method: "jwt"}, nil
+}
+
+// sign creates a signed token of the given type
+func (m *TokenManager) sign(identity *Identity, tokenType string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := &common.JWTClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        randomID(16),
+			Subject:   identity.Username,
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+		Username:  identity.Username,
+		Role:      string(identity.Role),
+		TokenType: tokenType,
+	}
+
+	return common.SignJWT(claims, m.secret)
+}
+
+// LoadSecret reads the token signing secret from a file so that tokens stay
+// valid across restarts. The file is created with a random secret if it
+// does not exist.
+func LoadSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		secret := strings.TrimSpace(string(data))
+		if secret == "" {
+			return "", fmt.Errorf("token secret file %s is empty", path)
+		}
+		return secret, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read token secret: %w", err)
+	}
+
+	secret := randomID(32)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", fmt.Errorf("failed to create token secret directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(secret+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("failed to write token secret: %w", err)
+	}
+
+	return secret, nil
+}
+
+// randomID returns n random bytes encoded as hex
+func randomID(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic("auth: failed to read random bytes: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
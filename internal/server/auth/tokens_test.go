@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestTokenManager(t *testing.T) (*TokenManager, *UserStore) {
+	t.Helper()
+
+	store := NewUserStore()
+	if _, err := store.CreateUser("alice", "password123", RoleOperator); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+
+	return NewTokenManager(store, "secret", time.Minute, time.Hour), store
+}
+
+func TestIssueAndAuthenticate(t *testing.T) {
+	tokens, store := newTestTokenManager(t)
+
+	pair, err := tokens.Issue(&Identity{Username: "alice", Role: RoleOperator})
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+
+	identity, err := tokens.Authenticate(pair.AccessToken)
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if identity.Username != "alice" || identity.Role != RoleOperator || identity.Method != "jwt" {
+		t.Errorf("unexpected identity: %+v", identity)
+	}
+
+	if _, err := tokens.Authenticate(pair.RefreshToken); err != ErrWrongTokenType {
+		t.Errorf("expected ErrWrongTokenType, got %v", err)
+	}
+
+	// Role changes apply to tokens already issued
+	store.CreateUser("root", "password123", RoleAdmin)
+	store.SetRole("alice", RoleViewer)
+	identity, _ = tokens.Authenticate(pair.AccessToken)
+	if identity.Role != RoleViewer {
+		t.Errorf("expected viewer role, got %s", identity.Role)
+	}
+
+	// Tokens of deleted users are rejected
+	store.DeleteUser("alice")
+	if _, err := tokens.Authenticate(pair.AccessToken); err != ErrUserNotFound {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+
+	// Tokens signed with another secret are rejected
+	other := NewTokenManager(store, "other", 0, 0)
+	if _, err := other.Authenticate(pair.AccessToken); err == nil {
+		t.Error("expected token signed with another secret to be rejected")
+	}
+}
+
+func TestRefreshAndRevoke(t *testing.T) {
+	tokens, _ := newTestTokenManager(t)
+
+	pair, _ := tokens.Issue(&Identity{Username: "alice", Role: RoleOperator})
+
+	refreshed, err := tokens.Refresh(pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+
+	if _, err := tokens.Refresh(pair.RefreshToken); err != ErrTokenRevoked {
+		t.Errorf("expected ErrTokenRevoked, got %v", err)
+	}
+
+	if err := tokens.Revoke(refreshed.AccessToken); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	if _, err := tokens.Authenticate(refreshed.AccessToken); err != ErrTokenRevoked {
+		t.Errorf("expected ErrTokenRevoked, got %v", err)
+	}
+}
+
+func TestConcurrentRefresh(t *testing.T) {
+	tokens, _ := newTestTokenManager(t)
+
+	pair, _ := tokens.Issue(&Identity{Username: "alice", Role: RoleOperator})
+
+	// Only one of several concurrent refreshes with the same token succeeds
+	var wg sync.WaitGroup
+	var succeeded int32
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := tokens.Refresh(pair.RefreshToken); err == nil {
+				atomic.AddInt32(&succeeded, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded != 1 {
+		t.Errorf("expected exactly one refresh to succeed, got %d", succeeded)
+	}
+}
+
+func TestLoadSecret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "jwt_secret")
+
+	secret, err := LoadSecret(path)
+	if err != nil {
+		t.Fatalf("LoadSecret failed: %v", err)
+	}
+	if secret == "" {
+		t.Fatal("expected a generated secret")
+	}
+
+	// The secret is kept across restarts
+	again, err := LoadSecret(path)
+	if err != nil {
+		t.Fatalf("LoadSecret failed: %v", err)
+	}
+	if again != secret {
+		t.Errorf("expected the persisted secret %q, got %q", secret, again)
+	}
+
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected a private secret file, got %v %v", info, err)
+	}
+}
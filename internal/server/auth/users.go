@@ -0,0 +1,342 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrUserNotFound is returned when a user with the specified username is not found
+	ErrUserNotFound = errors.New("user not found")
+
+	// ErrUserAlreadyExists is returned when creating a user whose username is taken
+	ErrUserAlreadyExists = errors.New("user already exists")
+
+	// ErrInvalidCredentials is returned when a username or password is wrong
+	ErrInvalidCredentials = errors.New("invalid credentials")
+
+	// ErrInvalidRole is returned when a role is not one of admin, operator or viewer
+	ErrInvalidRole = errors.New("invalid role")
+
+	// ErrInvalidUsername is returned when a username is empty
+	ErrInvalidUsername = errors.New("invalid username")
+
+	// ErrWeakPassword is returned when a password is too short
+	ErrWeakPassword = errors.New("password must be at least 8 characters")
+
+	// ErrLastAdmin is returned when an operation would remove the last admin
+	ErrLastAdmin = errors.New("cannot remove the last admin")
+)
+
+// minPasswordLength is the minimum length of an operator password
+const minPasswordLength = 8
+
+// User is an operator account
+type User struct {
+	// Username is the unique login name
+	Username string `json:"username"`
+
+	// PasswordHash is the bcrypt hash of the password
+	PasswordHash string `json:"password_hash"`
+
+	// Role is the operator's role
+	Role Role `json:"role"`
+
+	// CreatedAt is when the account was created
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserInfo is the public view of a user, without the password hash
+type UserInfo struct {
+	Username  string    `json:"username"`
+	Role      Role      `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserStore stores operator accounts
+type UserStore struct {
+	// users maps usernames to accounts
+	users map[string]*User
+
+	// path is the file the store is persisted to, if any
+	path string
+
+	// mu protects concurrent access to the users map
+	mu sync.RWMutex
+}
+
+// NewUserStore creates a new, empty user store
+func NewUserStore() *UserStore {
+	return &UserStore{
+		users: make(map[string]*User),
+	}
+}
+
+// SetStorePath sets the file the store is persisted to. Changes are saved
+// automatically once a path is set.
+func (s *UserStore) SetStorePath(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.path = path
+}
+
+// Load reads the users from the store file. A missing file is not an error.
+func (s *UserStore) Load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read user store: %w", err)
+	}
+
+	var users []*User
+	if err := json.Unmarshal(data, &users); err != nil {
+		return fmt.Errorf("failed to decode user store: %w", err)
+	}
+
+	for _, user := range users {
+		s.users[user.Username] = user
+	}
+
+	return nil
+}
+
+// save writes the users to the store file; callers must hold s.mu
+func (s *UserStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	users := make([]*User, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, user)
+	}
+
+	data, err := json.MarshalIndent(users, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode user store: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create user store directory: %w", err)
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write user store: %w", err)
+	}
+
+	return os.Rename(tmpPath, s.path)
+}
+
+// CreateUser creates a new operator account
+func (s *UserStore) CreateUser(username, password string, role Role) (*UserInfo, error) {
+	if username == "" {
+		return nil, ErrInvalidUsername
+	}
+	if !ValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.users[username]; exists {
+		return nil, ErrUserAlreadyExists
+	}
+
+	user := &User{
+		Username:     username,
+		PasswordHash: hash,
+		Role:         role,
+		CreatedAt:    time.Now(),
+	}
+	s.users[username] = user
+
+	return user.info(), s.save()
+}
+
+// DeleteUser deletes an operator account
+func (s *UserStore) DeleteUser(username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.users[username]
+	if !exists {
+		return ErrUserNotFound
+	}
+
+	if user.Role == RoleAdmin && s.countRole(RoleAdmin) == 1 {
+		return ErrLastAdmin
+	}
+
+	delete(s.users, username)
+	return s.save()
+}
+
+// SetPassword changes an operator's password
+func (s *UserStore) SetPassword(username, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.users[username]
+	if !exists {
+		return ErrUserNotFound
+	}
+
+	user.PasswordHash = hash
+	return s.save()
+}
+
+// SetRole changes an operator's role
+func (s *UserStore) SetRole(username string, role Role) error {
+	if !ValidRole(role) {
+		return ErrInvalidRole
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.users[username]
+	if !exists {
+		return ErrUserNotFound
+	}
+
+	if user.Role == RoleAdmin && role != RoleAdmin && s.countRole(RoleAdmin) == 1 {
+		return ErrLastAdmin
+	}
+
+	user.Role = role
+	return s.save()
+}
+
+// GetUser returns the public view of an operator account
+func (s *UserStore) GetUser(username string) (*UserInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, exists := s.users[username]
+	if !exists {
+		return nil, ErrUserNotFound
+	}
+
+	return user.info(), nil
+}
+
+// ListUsers returns all operator accounts sorted by username
+func (s *UserStore) ListUsers() []*UserInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]*UserInfo, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, user.info())
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+
+	return users
+}
+
+// Count returns the number of operator accounts
+func (s *UserStore) Count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.users)
+}
+
+// Authenticate checks a username and password and returns the operator's identity
+func (s *UserStore) Authenticate(username, password string) (*Identity, error) {
+	s.mu.RLock()
+	user, exists := s.users[username]
+	var hash string
+	var role Role
+	if exists {
+		hash, role = user.PasswordHash, user.Role
+	}
+	s.mu.RUnlock()
+
+	if !exists {
+		// Compare against a dummy hash so unknown users take as long as known ones
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &Identity{Username: username, Role: role, Method: "basic"}, nil
+}
+
+// countRole counts users with a role; callers must hold s.mu
+func (s *UserStore) countRole(role Role) int {
+	count := 0
+	for _, user := range s.users {
+		if user.Role == role {
+			count++
+		}
+	}
+	return count
+}
+
+// info returns the public view of the user
+func (u *User) info() *UserInfo {
+	return &UserInfo{
+		Username:  u.Username,
+		Role:      u.Role,
+		CreatedAt: u.CreatedAt,
+	}
+}
+
+// GeneratePassword returns a random password, e.g. for a bootstrap account
+func GeneratePassword() string {
+	return randomID(12)
+}
+
+// dummyHash is compared against when authenticating unknown users
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
+// hashPassword validates and hashes a password
+func hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", ErrWeakPassword
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	return string(hash), nil
+}
@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRolePermissions(t *testing.T) {
+	if !RoleHasPermission(RoleViewer, PermReadClients) {
+		t.Error("viewer should be able to read clients")
+	}
+	if RoleHasPermission(RoleViewer, PermCreateTasks) {
+		t.Error("viewer should not be able to create tasks")
+	}
+	if !RoleHasPermission(RoleOperator, PermCreateTasks) {
+		t.Error("operator should be able to create tasks")
+	}
+	if RoleHasPermission(RoleOperator, PermManageUsers) {
+		t.Error("operator should not be able to manage users")
+	}
+	if !RoleHasPermission(RoleAdmin, PermManageUsers) {
+		t.Error("admin should be able to manage users")
+	}
+	if ValidRole(Role("root")) {
+		t.Error("unknown role should be invalid")
+	}
+}
+
+func TestCreateUserAndAuthenticate(t *testing.T) {
+	store := NewUserStore()
+
+	if _, err := store.CreateUser("alice", "short", RoleOperator); err != ErrWeakPassword {
+		t.Errorf("expected ErrWeakPassword, got %v", err)
+	}
+	if _, err := store.CreateUser("alice", "password123", Role("root")); err != ErrInvalidRole {
+		t.Errorf("expected ErrInvalidRole, got %v", err)
+	}
+	if _, err := store.CreateUser("alice", "password123", RoleOperator); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	if _, err := store.CreateUser("alice", "password123", RoleOperator); err != ErrUserAlreadyExists {
+		t.Errorf("expected ErrUserAlreadyExists, got %v", err)
+	}
+
+	identity, err := store.Authenticate("alice", "password123")
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if identity.Role != RoleOperator || !identity.Can(PermCreateTasks) {
+		t.Errorf("unexpected identity: %+v", identity)
+	}
+
+	if _, err := store.Authenticate("alice", "wrong-password"); err != ErrInvalidCredentials {
+		t.Errorf("expected ErrInvalidCredentials, got %v", err)
+	}
+	if _, err := store.Authenticate("bob", "password123"); err != ErrInvalidCredentials {
+		t.Errorf("expected ErrInvalidCredentials, got %v", err)
+	}
+}
+
+func TestLastAdminProtection(t *testing.T) {
+	store := NewUserStore()
+	store.CreateUser("admin", "password123", RoleAdmin)
+
+	if err := store.DeleteUser("admin"); err != ErrLastAdmin {
+		t.Errorf("expected ErrLastAdmin, got %v", err)
+	}
+	if err := store.SetRole("admin", RoleViewer); err != ErrLastAdmin {
+		t.Errorf("expected ErrLastAdmin, got %v", err)
+	}
+
+	store.CreateUser("admin2", "password123", RoleAdmin)
+	if err := store.DeleteUser("admin"); err != nil {
+		t.Errorf("DeleteUser failed: %v", err)
+	}
+}
+
+func TestUserStorePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+
+	store := NewUserStore()
+	store.SetStorePath(path)
+	store.CreateUser("alice", "password123", RoleViewer)
+
+	loaded := NewUserStore()
+	loaded.SetStorePath(path)
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	identity, err := loaded.Authenticate("alice", "password123")
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if identity.Role != RoleViewer {
+		t.Errorf("expected viewer role, got %s", identity.Role)
+	}
+}
@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Cl0udRs4/dinot/internal/server/client"
+	"github.com/Cl0udRs4/dinot/internal/server/listener"
+)
+
+// keywordPattern matches the literal keywords of a usage string, e.g. the
+// subcommands in "<run|list|show>"
+var keywordPattern = regexp.MustCompile(`^[a-z]+$`)
+
+// completions returns the tab completion candidates for the last word of
+// line. The first word completes to command names; later words complete
+// according to the command's usage string: keywords such as subcommands
+// and statuses, client IDs, tags, groups, module names, protocols and
+// listener names.
+func (c *Console) completions(line string) []string {
+	words := strings.Fields(line)
+	if !strings.HasSuffix(line, " ") && len(words) > 0 {
+		words = words[:len(words)-1]
+	}
+
+	if len(words) == 0 {
+		names := make([]string, 0, len(c.commands))
+		for name := range c.commands {
+			names = append(names, name)
+		}
+		if c.target != nil {
+			// Inside a client context, module names are commands too
+			names = append(names, c.targetModules()...)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	cmd, exists := c.commands[words[0]]
+	if !exists {
+		return nil
+	}
+
+	// Match the argument being completed with the placeholder at the same
+	// position in the usage string
+	usage := strings.Fields(cmd.Usage)[1:]
+	index := len(words) - 1
+	if index >= len(usage) {
+		if len(usage) == 0 || !strings.Contains(usage[len(usage)-1], "...") {
+			return nil
+		}
+		index = len(usage) - 1
+	}
+
+	return c.placeholderCompletions(usage[index])
+}
+
+// placeholderCompletions returns the candidates for a usage placeholder
+// such as "<client_id|tag:name|group:name>" or "<add|rm|list>"
+func (c *Console) placeholderCompletions(placeholder string) []string {
+	var candidates []string
+
+	alternatives := strings.Split(strings.Trim(placeholder, "<>[]"), "|")
+	for _, alternative := range alternatives {
+		alternative = strings.Trim(alternative, "<>")
+
+		switch {
+		case strings.Contains(alternative, "client") || strings.HasPrefix(alternative, "ids"):
+			candidates = append(candidates, c.clientIDs()...)
+		case strings.Contains(alternative, "module"):
+			candidates = append(candidates, c.moduleNames()...)
+		case alternative == "tag":
+			for tag := range c.clientManager.GetAllTags() {
+				candidates = append(candidates, tag)
+			}
+		case alternative == "tag:name":
+			for tag := range c.clientManager.GetAllTags() {
+				candidates = append(candidates, "tag:"+tag)
+			}
+		case alternative == "group:name":
+			for _, group := range c.clientManager.GetAllGroups() {
+				candidates = append(candidates, "group:"+group.Name)
+			}
+		case alternative == "protocol":
+			candidates = append(candidates, listener.Protocols...)
+		case alternative == "listener":
+			candidates = append(candidates, c.listenerNames()...)
+		case alternative == "args...":
+			// Subcommand arguments are mostly clients and modules
+			candidates = append(candidates, c.clientIDs()...)
+			candidates = append(candidates, c.moduleNames()...)
+		case strings.HasPrefix(placeholder, "<") && len(alternatives) > 1 && keywordPattern.MatchString(alternative):
+			candidates = append(candidates, alternative)
+		}
+	}
+
+	return candidates
+}
+
+// targetModules returns the modules supported by the context's clients
+func (c *Console) targetModules() []string {
+	clients, err := c.targetClients()
+	if err != nil {
+		return nil
+	}
+	return supportedModules(clients)
+}
+
+// clientIDs returns the IDs of all registered clients
+func (c *Console) clientIDs() []string {
+	clients := c.clientManager.GetAllClients()
+
+	ids := make([]string, 0, len(clients))
+	for _, cl := range clients {
+		ids = append(ids, cl.ID)
+	}
+	return ids
+}
+
+// listenerNames returns the names of all registered listeners
+func (c *Console) listenerNames() []string {
+	if c.listenerManager == nil {
+		return nil
+	}
+
+	var names []string
+	for _, info := range c.listenerManager.DescribeAll() {
+		names = append(names, info.Name)
+	}
+	return names
+}
+
+// moduleNames returns the modules supported by any registered client
+func (c *Console) moduleNames() []string {
+	return supportedModules(c.clientManager.GetAllClients())
+}
+
+// supportedModules returns the modules supported by any of the clients
+func supportedModules(clients []*client.Client) []string {
+	seen := make(map[string]bool)
+	var names []string
+
+	for _, cl := range clients {
+		for _, module := range cl.SupportedModules {
+			if !seen[module] {
+				seen[module] = true
+				names = append(names, module)
+			}
+		}
+	}
+	return names
+}
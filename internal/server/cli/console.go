@@ -1,14 +1,21 @@
 package cli
 
 import (
-	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/Cl0udRs4/dinot/internal/server/auth"
 	"github.com/Cl0udRs4/dinot/internal/server/client"
+	"github.com/Cl0udRs4/dinot/internal/server/listener"
+	"github.com/Cl0udRs4/dinot/internal/server/task"
 )
 
 // Command represents a console command
@@ -24,6 +31,18 @@ type Command struct {
 	
 	// Execute is the function that executes the command
 	Execute func(args []string) error
+	
+	// Perm is the permission remote operators need to run the command;
+	// empty means any operator may run it
+	Perm auth.Permission
+	
+	// SubPerms overrides Perm for the subcommands named by the first
+	// argument, such as the read-only list and show
+	SubPerms map[string]auth.Permission
+	
+	// Sensitive keeps the arguments of the command, such as passwords, out
+	// of the history and of the event feed shared with other operators
+	Sensitive bool
 }
 
 // Console represents the command-line console interface
@@ -34,24 +53,113 @@ type Console struct {
 	// heartbeatMonitor is the heartbeat monitor to interact with
 	heartbeatMonitor *client.HeartbeatMonitor
 	
+	// taskManager is the task manager used to run modules on clients
+	taskManager *task.TaskManager
+	
+	// scheduler runs scheduled, recurring and on-register tasks
+	scheduler *task.Scheduler
+	
+	// listenerManager manages the protocol listeners clients connect to
+	listenerManager *listener.ListenerManager
+	
+	// userStore holds the operator accounts of the control API
+	userStore *auth.UserStore
+	
+	// apiKeyStore holds the API keys of the control API
+	apiKeyStore *auth.APIKeyStore
+	
 	// commands is a map of command names to Command objects
 	commands map[string]*Command
 	
 	// running indicates whether the console is running
 	running bool
 	
-	// reader is the input reader
-	reader *bufio.Reader
+	// editor reads input lines with editing, history and completion
+	editor *LineEditor
+	
+	// target selects the clients of the current "use" context, if any
+	target *client.Target
+	
+	// watched holds the IDs of tasks started from a client context, whose
+	// results are printed when they arrive
+	watched map[string]bool
+	
+	// watchMu protects watched
+	watchMu sync.Mutex
+	
+	// out is where command output is written
+	out io.Writer
+	
+	// format is the output format, OutputTable or OutputJSON
+	format string
+	
+	// color enables colored statuses in the table output format
+	color bool
+	
+	// variables holds the variables set with the set command and by
+	// commands that start tasks
+	variables map[string]string
+	
+	// started holds the IDs of the tasks and workflows started since the
+	// last wait-for, which waits for them by default
+	started []string
+	
+	// data is the result of the command being run in the JSON output
+	// format, if it has one besides its text output
+	data interface{}
+	
+	// scripting is set while a script runs; results are then reported by
+	// wait-for rather than printed as they arrive
+	scripting bool
+	
+	// exitCode is the code passed to the exit command
+	exitCode int
+	
+	// identity is the remote operator of a team server session; nil for
+	// the server's own console, which may run every command
+	identity *auth.Identity
+	
+	// notify prints results and events arriving in the background
+	notify func(text string)
+	
+	// teamServer serves the console to remote operators, if running
+	teamServer *TeamServer
+	
+	// ctx ends when the console's session does, interrupting commands
+	// that wait; nil for the server's own console
+	ctx context.Context
 }
 
+var (
+	// errUnknownCommand is returned for input naming no command
+	errUnknownCommand = errors.New("unknown command")
+	
+	// errForbidden is returned when a remote operator lacks the permission
+	// a command needs
+	errForbidden = errors.New("permission denied")
+)
+
 // NewConsole creates a new console interface
-func NewConsole(clientManager *client.ClientManager, heartbeatMonitor *client.HeartbeatMonitor) *Console {
+func NewConsole(clientManager *client.ClientManager, heartbeatMonitor *client.HeartbeatMonitor, taskManager *task.TaskManager, scheduler *task.Scheduler, listenerManager *listener.ListenerManager, userStore *auth.UserStore, apiKeyStore *auth.APIKeyStore) *Console {
 	console := &Console{
 		clientManager:    clientManager,
 		heartbeatMonitor: heartbeatMonitor,
+		taskManager:      taskManager,
+		scheduler:        scheduler,
+		listenerManager:  listenerManager,
+		userStore:        userStore,
+		apiKeyStore:      apiKeyStore,
 		commands:         make(map[string]*Command),
-		reader:           bufio.NewReader(os.Stdin),
+		watched:          make(map[string]bool),
+		out:              os.Stdout,
+		format:           OutputTable,
+		color:            isTerminal(int(os.Stdout.Fd())),
+		variables:        make(map[string]string),
 	}
+	console.editor = NewLineEditor(os.Stdin, os.Stdout, console.completions)
+	console.editor.SetHistoryRedact(console.historyLine)
+	console.notify = console.editor.Notify
+	taskManager.OnTaskFinished(console.taskFinished)
 	
 	// Register commands
 	console.registerCommands()
@@ -72,9 +180,10 @@ func (c *Console) registerCommands() {
 	// List clients command
 	c.commands["list"] = &Command{
 		Name:        "list",
-		Description: "List all clients or filter by status",
-		Usage:       "list [status]",
+		Description: "List clients, optionally filtered, sorted and projected",
+		Usage:       "list [status|filter] [--sort field] [--desc] [--fields a,b]",
 		Execute:     c.cmdList,
+		Perm:        auth.PermReadClients,
 	}
 	
 	// Show client details command
@@ -83,6 +192,7 @@ func (c *Console) registerCommands() {
 		Description: "Show detailed information about a client",
 		Usage:       "info <client_id>",
 		Execute:     c.cmdInfo,
+		Perm:        auth.PermReadClients,
 	}
 	
 	// Set client status command
@@ -91,14 +201,120 @@ func (c *Console) registerCommands() {
 		Description: "Set a client's status",
 		Usage:       "status <client_id> <online|offline|busy|error> [error_message]",
 		Execute:     c.cmdStatus,
+		Perm:        auth.PermWriteClients,
 	}
 	
 	// Heartbeat settings command
 	c.commands["heartbeat"] = &Command{
 		Name:        "heartbeat",
 		Description: "Configure heartbeat settings",
-		Usage:       "heartbeat <check|timeout|random> [args...]",
+		Usage:       "heartbeat <check|timeout|random|interval> [args...]",
 		Execute:     c.cmdHeartbeat,
+		Perm:        auth.PermWriteClients,
+	}
+	
+	// Tag management command
+	c.commands["tag"] = &Command{
+		Name:        "tag",
+		Description: "Manage client tags",
+		Usage:       "tag <add|rm|list> [client_id] [tag]",
+		Execute:     c.cmdTag,
+		Perm:        auth.PermWriteClients,
+		SubPerms:    map[string]auth.Permission{"list": auth.PermReadClients},
+	}
+	
+	// Group management command
+	c.commands["group"] = &Command{
+		Name:        "group",
+		Description: "Manage client groups",
+		Usage:       "group <create|delete|list|show|add|rm> [args...]",
+		Execute:     c.cmdGroup,
+		Perm:        auth.PermWriteClients,
+		SubPerms:    map[string]auth.Permission{"list": auth.PermReadClients, "show": auth.PermReadClients},
+	}
+	
+	// Task creation command
+	c.commands["task"] = &Command{
+		Name:        "task",
+		Description: "Run a module on a client, tag or group",
+		Usage:       "task <client_id|tag:name|group:name> <module> [json_params]",
+		Execute:     c.cmdTask,
+		Perm:        auth.PermCreateTasks,
+	}
+	
+	// Task listing command
+	c.commands["tasks"] = &Command{
+		Name:        "tasks",
+		Description: "List tasks, optionally for a single client",
+		Usage:       "tasks [client_id]",
+		Execute:     c.cmdTasks,
+		Perm:        auth.PermReadTasks,
+	}
+	
+	// Task timeout command
+	c.commands["timeout"] = &Command{
+		Name:        "timeout",
+		Description: "Show or set the default task timeouts",
+		Usage:       "timeout [default|<module>] [seconds]",
+		Execute:     c.cmdTimeout,
+		Perm:        auth.PermCreateTasks,
+	}
+	
+	// Task cancellation command
+	c.commands["cancel"] = &Command{
+		Name:        "cancel",
+		Description: "Cancel a task, stopping the module if it is running",
+		Usage:       "cancel <task_id>",
+		Execute:     c.cmdCancel,
+		Perm:        auth.PermCreateTasks,
+	}
+	
+	// Batch task command
+	c.commands["batch"] = &Command{
+		Name:        "batch",
+		Description: "Run a module on many clients and track the aggregate result",
+		Usage:       "batch <run|list|show|results> [args...]",
+		Execute:     c.cmdBatch,
+		Perm:        auth.PermCreateTasks,
+		SubPerms:    map[string]auth.Permission{"list": auth.PermReadTasks, "show": auth.PermReadTasks, "results": auth.PermReadTasks},
+	}
+	
+	// Workflow command
+	c.commands["workflow"] = &Command{
+		Name:        "workflow",
+		Description: "Run workflows of dependent module steps and track every step",
+		Usage:       "workflow <run|list|show> [args...]",
+		Execute:     c.cmdWorkflow,
+		Perm:        auth.PermCreateTasks,
+		SubPerms:    map[string]auth.Permission{"list": auth.PermReadTasks, "show": auth.PermReadTasks},
+	}
+	
+	// Schedule command
+	c.commands["schedule"] = &Command{
+		Name:        "schedule",
+		Description: "Schedule modules to run later, repeatedly or on new clients",
+		Usage:       "schedule <at|cron|register|list|show|enable|disable|rm> [args...]",
+		Execute:     c.cmdSchedule,
+		Perm:        auth.PermCreateTasks,
+		SubPerms:    map[string]auth.Permission{"list": auth.PermReadTasks, "show": auth.PermReadTasks},
+	}
+	
+	// Listener command
+	c.commands["listener"] = &Command{
+		Name:        "listener",
+		Description: "Add, start, stop, remove and list named protocol listeners",
+		Usage:       "listener <add|start|stop|rm|list> [protocol|listener] [args...]",
+		Execute:     c.cmdListener,
+		Perm:        auth.PermManageListeners,
+	}
+	
+	// Unregister command
+	c.commands["unregister"] = &Command{
+		Name:        "unregister",
+		Description: "Unregister a client, or every client of a tag or group",
+		Usage:       "unregister <client_id|tag:name|group:name>",
+		Execute:     c.cmdUnregister,
+		Perm:        auth.PermWriteClients,
 	}
 	
 	// Exception management command
@@ -107,13 +323,99 @@ func (c *Console) registerCommands() {
 		Description: "Manage exception reports",
 		Usage:       "exception <list|report> [args...]",
 		Execute:     c.cmdException,
+		Perm:        auth.PermWriteClients,
+		SubPerms:    map[string]auth.Permission{"list": auth.PermReadClients},
+	}
+	
+	// Operator account command
+	c.commands["user"] = &Command{
+		Name:        "user",
+		Description: "Manage API operator accounts",
+		Usage:       "user <add|rm|list|passwd|role> [args...]",
+		Execute:     c.cmdUser,
+		Perm:        auth.PermManageUsers,
+		Sensitive:   true,
+	}
+	
+	// API key command
+	c.commands["apikey"] = &Command{
+		Name:        "apikey",
+		Description: "Manage scoped API keys for automation",
+		Usage:       "apikey <create|revoke|list> [args...]",
+		Execute:     c.cmdAPIKey,
+		Perm:        auth.PermManageUsers,
+		Sensitive:   true,
+	}
+	
+	// Client context commands
+	c.commands["use"] = &Command{
+		Name:        "use",
+		Description: "Enter the context of a client or tag; module names then run as commands",
+		Usage:       "use <client_id|tag>",
+		Execute:     c.cmdUse,
+	}
+	
+	c.commands["back"] = &Command{
+		Name:        "back",
+		Description: "Leave the client context",
+		Usage:       "back",
+		Execute:     c.cmdBack,
+	}
+	
+	c.commands["modules"] = &Command{
+		Name:        "modules",
+		Description: "List the supported and active modules of the context's clients",
+		Usage:       "modules",
+		Execute:     c.cmdModules,
+		Perm:        auth.PermReadClients,
+	}
+	
+	c.commands["load"] = &Command{
+		Name:        "load",
+		Description: "Load a module on the context's clients",
+		Usage:       "load <module>",
+		Execute:     c.cmdLoad(true),
+		Perm:        auth.PermCreateTasks,
+	}
+	
+	c.commands["unload"] = &Command{
+		Name:        "unload",
+		Description: "Unload a module from the context's clients",
+		Usage:       "unload <module>",
+		Execute:     c.cmdLoad(false),
+		Perm:        auth.PermCreateTasks,
+	}
+	
+	// Set variable command
+	c.commands["set"] = &Command{
+		Name:        "set",
+		Description: "Set a variable, expanded as $name in later commands, the output format or color, or list settings",
+		Usage:       "set [output <table|json> | color <on|off> | name [value...]]",
+		Execute:     c.cmdSet,
+	}
+	
+	// Wait for tasks command
+	c.commands["wait-for"] = &Command{
+		Name:        "wait-for",
+		Description: "Wait for tasks, batches or workflows to finish, by default those started since the last wait-for",
+		Usage:       "wait-for [--timeout duration] [task_id|batch_id|workflow_id...]",
+		Execute:     c.cmdWaitFor,
+		Perm:        auth.PermReadTasks,
+	}
+	
+	// Operators command
+	c.commands["operators"] = &Command{
+		Name:        "operators",
+		Description: "List the operators connected to the team server",
+		Usage:       "operators",
+		Execute:     c.cmdOperators,
 	}
 	
 	// Exit command
 	c.commands["exit"] = &Command{
 		Name:        "exit",
-		Description: "Exit the console",
-		Usage:       "exit",
+		Description: "Exit the console, or end a script with an exit code",
+		Usage:       "exit [code]",
 		Execute:     c.cmdExit,
 	}
 }
@@ -122,45 +424,121 @@ func (c *Console) registerCommands() {
 func (c *Console) Start() {
 	c.running = true
 	
-	fmt.Println("C2 Console Interface")
-	fmt.Println("Type 'help' for available commands")
+	fmt.Fprintln(c.out, "C2 Console Interface")
+	fmt.Fprintln(c.out, "Type 'help' for available commands")
 	
 	for c.running {
-		fmt.Print("> ")
-		input, err := c.reader.ReadString('\n')
+		input, err := c.editor.ReadLine(c.prompt())
 		if err != nil {
+			if err == ErrInterrupted {
+				continue
+			}
 			if err == io.EOF {
 				// Handle EOF by exiting the loop
-				fmt.Println("Input stream closed, exiting console")
+				fmt.Fprintln(c.out, "Input stream closed, exiting console")
 				c.Stop()
 				break
 			}
-			fmt.Printf("Error reading input: %v\n", err)
+			fmt.Fprintf(c.out, "Error reading input: %v\n", err)
 			continue
 		}
 		
-		// Trim whitespace and split into command and args
-		input = strings.TrimSpace(input)
-		if input == "" {
+		err = c.run(input, nil)
+		if c.format == OutputJSON {
+			// The error is part of the JSON result
 			continue
 		}
-		
-		parts := strings.Fields(input)
-		cmdName := parts[0]
-		args := parts[1:]
-		
-		// Find and execute the command
-		cmd, exists := c.commands[cmdName]
-		if !exists {
-			fmt.Printf("Unknown command: %s\n", cmdName)
-			fmt.Println("Type 'help' for available commands")
+		if errors.Is(err, errUnknownCommand) {
+			fmt.Fprintf(c.out, "Unknown command: %s\n", strings.Fields(input)[0])
+			fmt.Fprintln(c.out, "Type 'help' for available commands")
 			continue
 		}
-		
-		if err := cmd.Execute(args); err != nil {
-			fmt.Printf("Error: %v\n", err)
+		if err != nil {
+			fmt.Fprintf(c.out, "Error: %v\n", err)
+		}
+	}
+}
+
+// execute expands the variables of an input line and runs its command
+func (c *Console) execute(input string) error {
+	input, err := c.expand(input)
+	if err != nil {
+		return err
+	}
+	
+	// Split into command and args
+	parts := strings.Fields(input)
+	if len(parts) == 0 {
+		return nil
+	}
+	cmdName := parts[0]
+	args := parts[1:]
+	
+	// Find and execute the command
+	cmd, exists := c.commands[cmdName]
+	if !exists && c.isTargetModule(cmdName) {
+		// Inside a client context, module names run the module
+		if !c.allowed(auth.PermCreateTasks) {
+			return errForbidden
 		}
+		return c.runModule(cmdName, args)
 	}
+	if !exists {
+		return fmt.Errorf("%w: %s", errUnknownCommand, cmdName)
+	}
+	if perm := cmd.permission(args); perm != "" && !c.allowed(perm) {
+		return errForbidden
+	}
+	
+	return cmd.Execute(args)
+}
+
+// permission returns the permission needed to run a command with args
+func (cmd *Command) permission(args []string) auth.Permission {
+	if len(args) > 0 {
+		if perm, ok := cmd.SubPerms[args[0]]; ok {
+			return perm
+		}
+	}
+	return cmd.Perm
+}
+
+// historyLine returns the line kept in the history for an input line.
+// Sensitive commands are left out altogether, since recalling a redacted
+// line would run it with the wrong arguments.
+func (c *Console) historyLine(line string) string {
+	words := strings.Fields(line)
+	if len(words) > 0 {
+		if cmd, exists := c.commands[words[0]]; exists && cmd.Sensitive {
+			return ""
+		}
+	}
+	return line
+}
+
+// context returns the context of the console's session
+func (c *Console) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// allowed reports whether the operator of the console is granted a
+// permission; the server's own console is granted all of them
+func (c *Console) allowed(perm auth.Permission) bool {
+	return c.identity == nil || c.identity.Can(perm)
+}
+
+// SetOutput sets where command output is written
+func (c *Console) SetOutput(out io.Writer) {
+	c.out = out
+}
+
+// SetHistoryPath sets the file the command history is kept in across
+// sessions and loads the history saved there
+func (c *Console) SetHistoryPath(path string) error {
+	return c.editor.SetHistoryPath(path)
 }
 
 // Stop stops the console interface
@@ -170,7 +548,7 @@ func (c *Console) Stop() {
 
 // cmdHelp implements the help command
 func (c *Console) cmdHelp(args []string) error {
-	fmt.Println("Available commands:")
+	fmt.Fprintln(c.out, "Available commands:")
 	
 	// Get a sorted list of commands
 	var cmdNames []string
@@ -181,8 +559,8 @@ func (c *Console) cmdHelp(args []string) error {
 	// Print each command with its description and usage
 	for _, name := range cmdNames {
 		cmd := c.commands[name]
-		fmt.Printf("  %-10s - %s\n", cmd.Name, cmd.Description)
-		fmt.Printf("    Usage: %s\n", cmd.Usage)
+		fmt.Fprintf(c.out, "  %-10s - %s\n", cmd.Name, cmd.Description)
+		fmt.Fprintf(c.out, "    Usage: %s\n", cmd.Usage)
 	}
 	
 	return nil
@@ -190,91 +568,177 @@ func (c *Console) cmdHelp(args []string) error {
 
 // cmdList implements the list command
 func (c *Console) cmdList(args []string) error {
-	var clients []*client.Client
+	var query client.ClientQuery
+	var fields []string
+	var terms []string
+	
+	// Separate options from the filter expression
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--sort":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--sort requires a field name")
+			}
+			i++
+			query.Sort = args[i]
+		case "--desc":
+			query.Desc = true
+		case "--fields":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--fields requires a comma-separated field list")
+			}
+			i++
+			var err error
+			if fields, err = client.ParseFieldList(args[i]); err != nil {
+				return err
+			}
+		default:
+			terms = append(terms, args[i])
+		}
+	}
 	
-	if len(args) > 0 {
-		// Filter by status
-		status := client.ClientStatus(args[0])
-		clients = c.clientManager.GetClientsByStatus(status)
-		fmt.Printf("Clients with status '%s':\n", status)
-	} else {
-		// List all clients
-		clients = c.clientManager.GetAllClients()
-		fmt.Println("All clients:")
-	}
-	
-	if len(clients) == 0 {
-		fmt.Println("No clients found")
-		return nil
+	// A single bare status keeps the old "list <status>" form working
+	query.Filter = strings.Join(terms, " ")
+	if len(terms) == 1 && isClientStatus(terms[0]) {
+		query.Filter = "status=" + terms[0]
 	}
 	
-	// Print client information
-	fmt.Printf("%-36s %-15s %-10s %-15s\n", "ID", "IP Address", "Status", "Last Seen")
-	fmt.Println(strings.Repeat("-", 80))
+	clients, err := c.clientManager.Query(query)
+	if err != nil {
+		return err
+	}
 	
-	for _, client := range clients {
-		lastSeen := time.Since(client.LastSeen).Round(time.Second)
-		fmt.Printf("%-36s %-15s %-10s %s ago\n", 
-			client.ID, 
-			client.IPAddress, 
-			client.Status, 
-			lastSeen)
+	table := NewTable("ID", "Name", "IP Address", "OS", "Status", "Last Seen").StatusColumn("Status")
+	if len(fields) > 0 {
+		// One column per selected field
+		table = NewTable(fields...).StatusColumn("status")
+	}
+	table.Title = "All clients:"
+	if query.Filter != "" {
+		table.Title = fmt.Sprintf("Clients matching '%s':", query.Filter)
+	}
+	table.Empty = "No clients found"
+	table.Footer = fmt.Sprintf("Total: %d clients", len(clients))
+	
+	data := make([]interface{}, 0, len(clients))
+	for _, cl := range clients {
+		if len(fields) > 0 {
+			selected, _ := client.SelectFields(cl, fields)
+			row := make([]string, len(fields))
+			for i, field := range fields {
+				row[i] = formatFieldValue(selected[field])
+			}
+			table.AddRow(row...)
+			data = append(data, selected)
+			continue
+		}
+		
+		snapshot, err := cl.ToJSON()
+		if err != nil {
+			return err
+		}
+		var info client.Client
+		json.Unmarshal(snapshot, &info)
+		table.AddRow(info.ID, info.Name, info.IPAddress, info.OS, string(info.Status), formatAge(info.LastSeen))
+		data = append(data, json.RawMessage(snapshot))
 	}
 	
-	fmt.Printf("\nTotal: %d clients\n", len(clients))
+	c.show(data, table)
 	return nil
 }
 
+// isClientStatus reports whether s is a valid client status
+func isClientStatus(s string) bool {
+	switch client.ClientStatus(s) {
+	case client.StatusOnline, client.StatusOffline, client.StatusBusy, client.StatusError:
+		return true
+	}
+	return false
+}
+
+// formatFieldValue formats a selected client field for console output
+func formatFieldValue(value interface{}) string {
+	switch v := value.(type) {
+	case time.Time:
+		return formatAge(v)
+	case []string:
+		return strings.Join(v, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
 // cmdInfo implements the info command
 func (c *Console) cmdInfo(args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("missing client ID")
 	}
 	
-	clientID := args[0]
-	client, err := c.clientManager.GetClient(clientID)
+	cl, err := c.clientManager.GetClient(args[0])
 	if err != nil {
 		return err
 	}
 	
-	fmt.Printf("Client Information:\n")
-	fmt.Printf("  ID:              %s\n", client.ID)
-	fmt.Printf("  Name:            %s\n", client.Name)
-	fmt.Printf("  IP Address:      %s\n", client.IPAddress)
-	fmt.Printf("  OS:              %s\n", client.OS)
-	fmt.Printf("  Architecture:    %s\n", client.Architecture)
-	fmt.Printf("  Status:          %s\n", client.Status)
-	if client.Status == "error" {
-		fmt.Printf("  Error Message:   %s\n", client.ErrorMessage)
-	}
-	fmt.Printf("  Protocol:        %s\n", client.Protocol)
-	fmt.Printf("  Registered At:   %s\n", client.RegisteredAt.Format(time.RFC3339))
-	fmt.Printf("  Last Seen:       %s (%s ago)\n", 
-		client.LastSeen.Format(time.RFC3339),
-		time.Since(client.LastSeen).Round(time.Second))
-	fmt.Printf("  Heartbeat:       %s\n", client.HeartbeatInterval)
-	
-	fmt.Printf("  Supported Modules:\n")
-	if len(client.SupportedModules) == 0 {
-		fmt.Printf("    None\n")
-	} else {
-		for _, module := range client.SupportedModules {
-			fmt.Printf("    - %s\n", module)
-		}
+	// Render a consistent snapshot of the client
+	snapshot, err := cl.ToJSON()
+	if err != nil {
+		return err
 	}
-	
-	fmt.Printf("  Active Modules:\n")
-	if len(client.ActiveModules) == 0 {
-		fmt.Printf("    None\n")
-	} else {
-		for _, module := range client.ActiveModules {
-			fmt.Printf("    - %s\n", module)
+	var info client.Client
+	json.Unmarshal(snapshot, &info)
+	
+	details := NewDetails("Client Information:")
+	details.Add("ID", info.ID)
+	details.Add("Name", info.Name)
+	details.Add("IP Address", info.IPAddress)
+	details.Add("OS", info.OS)
+	details.Add("Architecture", info.Architecture)
+	details.AddStatus("Status", string(info.Status))
+	if info.Status == client.StatusError {
+		details.Add("Error Message", info.ErrorMessage)
+	}
+	details.Add("Protocol", info.Protocol)
+	if info.Listener != "" {
+		details.Add("Listener", info.Listener)
+	}
+	details.Add("Registered At", fmt.Sprintf("%s (%s)", info.RegisteredAt.Format(time.RFC3339), formatAge(info.RegisteredAt)))
+	details.Add("Last Seen", fmt.Sprintf("%s (%s)", info.LastSeen.Format(time.RFC3339), formatAge(info.LastSeen)))
+	details.Add("Heartbeat", info.HeartbeatInterval.String())
+	details.Add("Tags", listOrNone(info.Tags))
+	details.Add("Supported Modules", listOrNone(info.SupportedModules))
+	details.Add("Active Modules", listOrNone(info.ActiveModules))
+	
+	history := NewTable("Time", "From", "To", "Cause", "Via", "Message").StatusColumn("From").StatusColumn("To")
+	history.Title = "Status History:"
+	history.Empty = "  None"
+	transitions := cl.GetStatusHistory()
+	for _, transition := range transitions {
+		from := string(transition.From)
+		if from == "" {
+			from = "-"
 		}
+		history.AddRow(
+			transition.Timestamp.Format("2006-01-02 15:04:05"),
+			from,
+			string(transition.To),
+			string(transition.Cause),
+			transition.Transport,
+			transition.Message,
+		)
 	}
 	
+	c.show(map[string]interface{}{"client": json.RawMessage(snapshot), "history": transitions}, details, history)
 	return nil
 }
 
+// listOrNone joins a list for console output, showing "None" if it is empty
+func listOrNone(items []string) string {
+	if len(items) == 0 {
+		return "None"
+	}
+	return strings.Join(items, ", ")
+}
+
 // cmdStatus implements the status command
 func (c *Console) cmdStatus(args []string) error {
 	if len(args) < 2 {
@@ -303,19 +767,19 @@ func (c *Console) cmdStatus(args []string) error {
 		errorMsg = strings.Join(args[2:], " ")
 	}
 	
-	err := c.clientManager.UpdateClientStatus(clientID, status, errorMsg)
+	err := c.clientManager.UpdateClientStatusWithCause(clientID, status, errorMsg, client.CauseOperator)
 	if err != nil {
 		return err
 	}
 	
-	fmt.Printf("Updated client %s status to %s\n", clientID, status)
+	fmt.Fprintf(c.out, "Updated client %s status to %s\n", clientID, status)
 	return nil
 }
 
 // cmdHeartbeat implements the heartbeat command
 func (c *Console) cmdHeartbeat(args []string) error {
 	if len(args) < 1 {
-		return fmt.Errorf("usage: heartbeat <check|timeout|random> [args...]")
+		return fmt.Errorf("usage: heartbeat <check|timeout|random|interval> [args...]")
 	}
 	
 	subcommand := args[0]
@@ -334,7 +798,7 @@ func (c *Console) cmdHeartbeat(args []string) error {
 		}
 		
 		c.heartbeatMonitor.SetCheckInterval(interval * time.Second)
-		fmt.Printf("Set heartbeat check interval to %s\n", interval*time.Second)
+		fmt.Fprintf(c.out, "Set heartbeat check interval to %s\n", interval*time.Second)
 		
 	case "timeout":
 		// Set timeout
@@ -349,7 +813,7 @@ func (c *Console) cmdHeartbeat(args []string) error {
 		}
 		
 		c.heartbeatMonitor.SetTimeout(timeout * time.Second)
-		fmt.Printf("Set heartbeat timeout to %s\n", timeout*time.Second)
+		fmt.Fprintf(c.out, "Set heartbeat timeout to %s\n", timeout*time.Second)
 		
 	case "random":
 		// Enable/disable random intervals
@@ -375,16 +839,35 @@ func (c *Console) cmdHeartbeat(args []string) error {
 			}
 			
 			c.heartbeatMonitor.EnableRandomIntervals(min*time.Second, max*time.Second)
-			fmt.Printf("Enabled random heartbeat intervals (%s - %s)\n", min*time.Second, max*time.Second)
+			fmt.Fprintf(c.out, "Enabled random heartbeat intervals (%s - %s)\n", min*time.Second, max*time.Second)
 			
 		case "disable":
 			c.heartbeatMonitor.DisableRandomIntervals()
-			fmt.Println("Disabled random heartbeat intervals")
+			fmt.Fprintln(c.out, "Disabled random heartbeat intervals")
 			
 		default:
 			return fmt.Errorf("usage: heartbeat random <enable|disable> [min_seconds max_seconds]")
 		}
 		
+	case "interval":
+		// Set the heartbeat interval of a client, tag or group
+		if len(args) < 3 {
+			return fmt.Errorf("usage: heartbeat interval <client_id|tag:name|group:name> <interval_seconds>")
+		}
+		
+		var interval time.Duration
+		_, err := fmt.Sscanf(args[2], "%d", &interval)
+		if err != nil || interval <= 0 {
+			return fmt.Errorf("invalid interval: %s", args[2])
+		}
+		
+		target := client.ParseTarget(args[1])
+		count, err := c.clientManager.SetTargetHeartbeatInterval(target, interval*time.Second)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out, "Set heartbeat interval of %d client(s) in %s to %s\n", count, target, interval*time.Second)
+		
 	default:
 		return fmt.Errorf("unknown heartbeat subcommand: %s", subcommand)
 	}
@@ -401,53 +884,39 @@ func (c *Console) cmdException(args []string) error {
 	switch args[0] {
 	case "list":
 		// List exceptions
+		var exceptions []*client.ExceptionReport
+		var table *Table
 		if len(args) < 2 {
 			// List all exceptions
-			exceptions := c.clientManager.GetAllExceptionReports()
-			if len(exceptions) == 0 {
-				fmt.Println("No exceptions reported")
-				return nil
-			}
-
-			fmt.Println("All exceptions:")
-			fmt.Println("ID                                   Client ID                 Severity   Timestamp           Message")
-			fmt.Println("--------------------------------------------------------------------------------")
-			for _, exception := range exceptions {
-				fmt.Printf("%-36s %-24s %-10s %-20s %s\n",
-					exception.ID,
-					exception.ClientID,
-					exception.Severity,
-					exception.Timestamp.Format("2006-01-02 15:04:05"),
-					exception.Message,
-				)
-			}
-			fmt.Printf("\nTotal: %d exceptions\n", len(exceptions))
+			exceptions = c.clientManager.GetAllExceptionReports()
+			table = NewTable("ID", "Client ID", "Severity", "Reported", "Message")
+			table.Title = "All exceptions:"
+			table.Empty = "No exceptions reported"
 		} else {
 			// List exceptions for a specific client
 			clientID := args[1]
-			exceptions, err := c.clientManager.GetExceptionReports(clientID)
+			var err error
+			exceptions, err = c.clientManager.GetExceptionReports(clientID)
 			if err != nil {
 				return err
 			}
-
-			if len(exceptions) == 0 {
-				fmt.Printf("No exceptions reported for client %s\n", clientID)
-				return nil
-			}
-
-			fmt.Printf("Exceptions for client %s:\n", clientID)
-			fmt.Println("ID                                   Severity   Timestamp           Message")
-			fmt.Println("--------------------------------------------------------------------------------")
-			for _, exception := range exceptions {
-				fmt.Printf("%-36s %-10s %-20s %s\n",
-					exception.ID,
-					exception.Severity,
-					exception.Timestamp.Format("2006-01-02 15:04:05"),
-					exception.Message,
-				)
+			table = NewTable("ID", "Severity", "Reported", "Message")
+			table.Title = fmt.Sprintf("Exceptions for client %s:", clientID)
+			table.Empty = fmt.Sprintf("No exceptions reported for client %s", clientID)
+		}
+		table.StatusColumn("Severity")
+		
+		for _, exception := range exceptions {
+			if len(args) < 2 {
+				table.AddRow(exception.ID, exception.ClientID, string(exception.Severity), formatAge(exception.Timestamp), exception.Message)
+			} else {
+				table.AddRow(exception.ID, string(exception.Severity), formatAge(exception.Timestamp), exception.Message)
 			}
-			fmt.Printf("\nTotal: %d exceptions\n", len(exceptions))
 		}
+		if table.Len() > 0 {
+			table.Footer = fmt.Sprintf("Total: %d exceptions", table.Len())
+		}
+		c.show(exceptions, table)
 
 	case "report":
 		// Report a new exception
@@ -497,7 +966,7 @@ func (c *Console) cmdException(args []string) error {
 			return err
 		}
 
-		fmt.Printf("Exception reported with ID: %s\n", report.ID)
+		fmt.Fprintf(c.out, "Exception reported with ID: %s\n", report.ID)
 
 	default:
 		return fmt.Errorf("unknown subcommand. Available subcommands: list, report")
@@ -508,6 +977,19 @@ func (c *Console) cmdException(args []string) error {
 
 // cmdExit implements the exit command
 func (c *Console) cmdExit(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: exit [code]")
+	}
+	
+	c.exitCode = 0
+	if len(args) == 1 {
+		code, err := strconv.Atoi(args[0])
+		if err != nil || code < 0 || code > 255 {
+			return fmt.Errorf("invalid exit code: %s", args[0])
+		}
+		c.exitCode = code
+	}
+	
 	c.Stop()
 	return nil
 }
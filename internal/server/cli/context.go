@@ -0,0 +1,257 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Cl0udRs4/dinot/internal/server/client"
+	"github.com/Cl0udRs4/dinot/internal/server/task"
+)
+
+// errNoContext is returned by context commands used outside a client context
+var errNoContext = errors.New("no client selected. Use 'use <client_id|tag>' first")
+
+// prompt returns the console prompt, showing the target of the client
+// context if one is active
+func (c *Console) prompt() string {
+	if c.target == nil {
+		return "> "
+	}
+	return fmt.Sprintf("[%s]> ", c.target)
+}
+
+// cmdUse implements the use command, which enters a client context
+func (c *Console) cmdUse(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: use <client_id|tag>")
+	}
+
+	// A name that is not a client ID may be a tag
+	target := client.ParseTarget(args[0])
+	if target.ClientID != "" {
+		if _, err := c.clientManager.GetClient(target.ClientID); err != nil {
+			if _, tagged := c.clientManager.GetAllTags()[target.ClientID]; !tagged {
+				return err
+			}
+			target = client.Target{Tag: target.ClientID}
+		}
+	}
+
+	clients, err := c.clientManager.ResolveTarget(target)
+	if err != nil {
+		return err
+	}
+	if len(clients) == 0 {
+		return fmt.Errorf("no clients match %s", target)
+	}
+
+	c.target = &target
+	clientIDs := make([]string, 0, len(clients))
+	for _, cl := range clients {
+		clientIDs = append(clientIDs, cl.ID)
+	}
+	c.show(map[string]interface{}{"target": target.String(), "clients": clientIDs},
+		Text(fmt.Sprintf("Using %s (%d clients). Module commands now run there; type 'back' to leave\n", target, len(clients))))
+	return nil
+}
+
+// cmdBack implements the back command, which leaves the client context
+func (c *Console) cmdBack(args []string) error {
+	if c.target == nil {
+		return errNoContext
+	}
+
+	c.target = nil
+	return nil
+}
+
+// targetClients returns the clients of the current client context
+func (c *Console) targetClients() ([]*client.Client, error) {
+	if c.target == nil {
+		return nil, errNoContext
+	}
+	return c.clientManager.ResolveTarget(*c.target)
+}
+
+// cmdModules implements the modules command
+func (c *Console) cmdModules(args []string) error {
+	clients, err := c.targetClients()
+	if err != nil {
+		return err
+	}
+
+	table := NewTable("Client ID", "Supported", "Active")
+	table.Empty = "No clients in context"
+	modules := make([]map[string]interface{}, 0, len(clients))
+	for _, cl := range clients {
+		table.AddRow(cl.ID, strings.Join(cl.SupportedModules, ","), strings.Join(cl.ActiveModules, ","))
+		modules = append(modules, map[string]interface{}{
+			"client_id": cl.ID,
+			"supported": cl.SupportedModules,
+			"active":    cl.ActiveModules,
+		})
+	}
+	c.show(modules, table)
+
+	return nil
+}
+
+// cmdLoad implements the load and unload commands, which ask the clients
+// of the context to load or unload a module. The module becomes active or
+// inactive once a client reports the outcome.
+func (c *Console) cmdLoad(load bool) func(args []string) error {
+	return func(args []string) error {
+		if c.target == nil {
+			return errNoContext
+		}
+
+		if len(args) != 1 {
+			if load {
+				return fmt.Errorf("usage: load <module>")
+			}
+			return fmt.Errorf("usage: unload <module>")
+		}
+		module := args[0]
+
+		verb := "Unloading"
+		if load {
+			verb = "Loading"
+		}
+		return c.startTasks(verb+" "+module, func() ([]*task.Task, error) {
+			return c.taskManager.CreateModuleTasks(*c.target, module, load)
+		})
+	}
+}
+
+// isTargetModule reports whether a module is supported by any client of
+// the context
+func (c *Console) isTargetModule(name string) bool {
+	clients, err := c.targetClients()
+	if err != nil {
+		return false
+	}
+
+	for _, cl := range clients {
+		for _, module := range cl.SupportedModules {
+			if module == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// runModule runs a module on the clients of the context. Free text
+// arguments become the "command" parameter, as the shell module expects;
+// JSON parameters may follow "--". Results are printed as they arrive.
+func (c *Console) runModule(module string, args []string) error {
+	if c.target == nil {
+		return errNoContext
+	}
+
+	rest, params := splitParams(args)
+	if len(rest) > 0 {
+		var err error
+		if params, err = withCommand(params, strings.Join(rest, " ")); err != nil {
+			return err
+		}
+	}
+
+	return c.startTasks("Started "+module, func() ([]*task.Task, error) {
+		return c.taskManager.CreateTasks(*c.target, module, params)
+	})
+}
+
+// startTasks starts tasks with create and shows them, described by action.
+// The tasks are watched before anything can report back on them, so their
+// results are printed as they arrive; scripts wait for results instead.
+func (c *Console) startTasks(action string, create func() ([]*task.Task, error)) error {
+	c.watchMu.Lock()
+	tasks, err := create()
+	for _, t := range tasks {
+		if !c.scripting {
+			c.watched[t.ID] = true
+		}
+	}
+	c.watchMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	var text strings.Builder
+	taskIDs := make([]string, 0, len(tasks))
+	for _, t := range tasks {
+		fmt.Fprintf(&text, "%s on %s (%s)\n", action, t.ClientID, t.ID)
+		taskIDs = append(taskIDs, t.ID)
+	}
+	c.show(taskSnapshots(tasks), Text(text.String()))
+	c.recordTasks(taskIDs)
+	return nil
+}
+
+// withCommand sets the "command" parameter of JSON module parameters
+func withCommand(params json.RawMessage, command string) (json.RawMessage, error) {
+	object := make(map[string]interface{})
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &object); err != nil || object == nil {
+			return nil, task.ErrInvalidParams
+		}
+	}
+
+	object["command"] = command
+	return json.Marshal(object)
+}
+
+// taskFinished prints the result of a task started from a client context
+func (c *Console) taskFinished(t *task.Task) {
+	c.watchMu.Lock()
+	watched := c.watched[t.ID]
+	delete(c.watched, t.ID)
+	c.watchMu.Unlock()
+
+	if watched {
+		c.notify(formatTaskResult(t))
+	}
+}
+
+// formatTaskResult formats the outcome of a finished task: the output of
+// modules reporting one, such as shell, or the raw result otherwise
+func formatTaskResult(t *task.Task) string {
+	var snapshot task.Task
+	if data, err := t.ToJSON(); err == nil {
+		json.Unmarshal(data, &snapshot)
+	}
+
+	module := snapshot.Module
+	if snapshot.Action != "" {
+		module = string(snapshot.Action) + " " + module
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] %s %s (%s)\n", snapshot.ClientID, module, snapshot.Status, snapshot.ID)
+
+	var result struct {
+		Output *string `json:"output"`
+		Error  string  `json:"error"`
+	}
+	if len(snapshot.Result) > 0 {
+		if json.Unmarshal(snapshot.Result, &result) == nil && result.Output != nil {
+			if *result.Output != "" {
+				fmt.Fprintln(&b, strings.TrimRight(*result.Output, "\n"))
+			}
+			if result.Error != "" {
+				fmt.Fprintf(&b, "error: %s\n", result.Error)
+			}
+		} else {
+			fmt.Fprintln(&b, string(snapshot.Result))
+		}
+	}
+
+	if snapshot.Error != "" && snapshot.Error != result.Error {
+		fmt.Fprintf(&b, "error: %s\n", snapshot.Error)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,563 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// ErrInterrupted is returned by ReadLine when the line is abandoned with Ctrl-C
+var ErrInterrupted = errors.New("interrupted")
+
+// MaxHistory is the number of lines kept in the console history
+const MaxHistory = 1000
+
+// Control keys understood by the line editor
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyLF        = 10
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyCR        = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+// CompleteFunc returns the candidates for the last word of line, the text
+// left of the cursor. Candidates not starting with the word are ignored.
+type CompleteFunc func(line string) []string
+
+// LineEditor reads console input with line editing, history and tab
+// completion when the input is a terminal, and line by line otherwise
+type LineEditor struct {
+	// in is the input file
+	in *os.File
+
+	// reader buffers the input
+	reader *bufio.Reader
+
+	// out is where the prompt and the edited line are echoed
+	out io.Writer
+
+	// complete returns the completion candidates, if set
+	complete CompleteFunc
+
+	// history holds previously entered lines, oldest first
+	history []string
+
+	// historyPath is the file history is persisted to, if set
+	historyPath string
+
+	// redact rewrites lines before they are added to the history, if set;
+	// lines it empties are not added
+	redact func(line string) string
+
+	// buf is the line being edited
+	buf []rune
+
+	// pos is the cursor position in buf
+	pos int
+
+	// prompt is the prompt of the line being edited
+	prompt string
+
+	// editing is set while a line is being edited in raw mode
+	editing bool
+
+	// mu serializes editing with notifications printed from other
+	// goroutines
+	mu sync.Mutex
+}
+
+// NewLineEditor creates a line editor reading from in and echoing to out
+func NewLineEditor(in *os.File, out io.Writer, complete CompleteFunc) *LineEditor {
+	return &LineEditor{
+		in:       in,
+		reader:   bufio.NewReader(in),
+		out:      out,
+		complete: complete,
+	}
+}
+
+// SetHistoryPath sets the file history is loaded from and appended to
+func (e *LineEditor) SetHistoryPath(path string) error {
+	e.historyPath = path
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	redacted := false
+	for _, line := range strings.Split(string(data), "\n") {
+		if e.redact != nil && line != "" {
+			kept := e.redact(line)
+			redacted = redacted || kept != line
+			line = kept
+		}
+		if line != "" {
+			e.history = append(e.history, line)
+		}
+	}
+	if len(e.history) > MaxHistory {
+		e.history = e.history[len(e.history)-MaxHistory:]
+	}
+
+	// Rewrite the file without the lines saved before they were redacted
+	if redacted {
+		e.saveHistory()
+	}
+
+	return nil
+}
+
+// SetHistoryRedact sets the function lines are passed through before they
+// are added to the history and persisted
+func (e *LineEditor) SetHistoryRedact(redact func(line string) string) {
+	e.redact = redact
+}
+
+// ReadLine prints the prompt and reads a line. Input that is not a
+// terminal is read as is; otherwise the line can be edited and non-empty
+// lines are added to the history.
+func (e *LineEditor) ReadLine(prompt string) (string, error) {
+	fd := int(e.in.Fd())
+	if !isTerminal(fd) {
+		return e.readPlain(prompt)
+	}
+
+	restore, err := makeRaw(fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+
+	line, err := e.edit(prompt)
+	if err != nil {
+		return "", err
+	}
+
+	e.addHistory(line)
+	return line, nil
+}
+
+// ReadPassword prints the prompt and reads a line without echoing it when
+// the input is a terminal. The line is not added to the history.
+func (e *LineEditor) ReadPassword(prompt string) (string, error) {
+	fd := int(e.in.Fd())
+	if !isTerminal(fd) {
+		return e.readPlain(prompt)
+	}
+
+	restore, err := makeRaw(fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+
+	fmt.Fprint(e.out, prompt)
+	var password []rune
+	for {
+		r, _, err := e.reader.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case keyCR, keyLF:
+			fmt.Fprint(e.out, "\r\n")
+			return string(password), nil
+		case keyCtrlC:
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", ErrInterrupted
+		case keyCtrlD:
+			if len(password) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+		case keyBackspace, keyCtrlH:
+			if len(password) > 0 {
+				password = password[:len(password)-1]
+			}
+		case keyCtrlU:
+			password = password[:0]
+		default:
+			if unicode.IsPrint(r) {
+				password = append(password, r)
+			}
+		}
+	}
+}
+
+// readPlain prints the prompt and reads a line of input that is not a
+// terminal
+func (e *LineEditor) readPlain(prompt string) (string, error) {
+	fmt.Fprint(e.out, prompt)
+	line, err := e.reader.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// edit runs the editing loop until the line is entered
+func (e *LineEditor) edit(prompt string) (string, error) {
+	e.mu.Lock()
+	e.prompt = prompt
+	e.buf = e.buf[:0]
+	e.pos = 0
+	e.editing = true
+	e.refresh()
+	e.mu.Unlock()
+
+	defer func() {
+		e.mu.Lock()
+		e.editing = false
+		e.mu.Unlock()
+	}()
+
+	// index is the history entry shown, len(history) being the new line
+	index := len(e.history)
+	draft := ""
+	lastTab := false
+
+	for {
+		r, _, err := e.reader.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		// Escape sequences are read in full before taking the lock, so
+		// that notifications are not held up while waiting for input
+		var escape rune
+		if r == keyEscape {
+			escape = e.readEscape()
+		}
+
+		e.mu.Lock()
+		tab := false
+		switch r {
+		case keyCR, keyLF:
+			fmt.Fprint(e.out, "\r\n")
+			e.mu.Unlock()
+			return string(e.buf), nil
+
+		case keyCtrlC:
+			fmt.Fprint(e.out, "^C\r\n")
+			e.mu.Unlock()
+			return "", ErrInterrupted
+
+		case keyCtrlD:
+			if len(e.buf) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				e.mu.Unlock()
+				return "", io.EOF
+			}
+			e.deleteRunes(e.pos, e.pos+1)
+
+		case keyBackspace, keyCtrlH:
+			if e.pos > 0 {
+				e.deleteRunes(e.pos-1, e.pos)
+			}
+
+		case keyCtrlA:
+			e.moveTo(0)
+		case keyCtrlE:
+			e.moveTo(len(e.buf))
+		case keyCtrlB:
+			e.moveTo(e.pos - 1)
+		case keyCtrlF:
+			e.moveTo(e.pos + 1)
+
+		case keyCtrlK:
+			e.deleteRunes(e.pos, len(e.buf))
+		case keyCtrlU:
+			e.deleteRunes(0, e.pos)
+		case keyCtrlW:
+			e.deleteRunes(e.wordStart(), e.pos)
+
+		case keyCtrlL:
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+			e.refresh()
+
+		case keyCtrlP, keyCtrlN:
+			index, draft = e.browseHistory(index, draft, r == keyCtrlP)
+
+		case keyTab:
+			tab = true
+			e.completeWord(lastTab)
+
+		case keyEscape:
+			switch escape {
+			case 'A':
+				index, draft = e.browseHistory(index, draft, true)
+			case 'B':
+				index, draft = e.browseHistory(index, draft, false)
+			case 'C':
+				e.moveTo(e.pos + 1)
+			case 'D':
+				e.moveTo(e.pos - 1)
+			case 'H':
+				e.moveTo(0)
+			case 'F':
+				e.moveTo(len(e.buf))
+			case '3':
+				e.deleteRunes(e.pos, e.pos+1)
+			}
+
+		default:
+			if unicode.IsPrint(r) {
+				e.insert([]rune{r})
+			}
+		}
+		lastTab = tab
+		e.mu.Unlock()
+	}
+}
+
+// Notify prints text while a line may be being edited. The line is
+// cleared, the text printed above it and the line redrawn, so that
+// results arriving in the background do not garble the input.
+func (e *LineEditor) Notify(text string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+
+	if !e.editing {
+		fmt.Fprint(e.out, text)
+		return
+	}
+
+	fmt.Fprint(e.out, "\r\x1b[K"+strings.ReplaceAll(text, "\n", "\r\n"))
+	e.refresh()
+}
+
+// readEscape reads the rest of an escape sequence and returns its final
+// byte, mapping the "\x1b[1~" style home, end and delete keys to H, F and 3
+func (e *LineEditor) readEscape() rune {
+	r, _, err := e.reader.ReadRune()
+	if err != nil || (r != '[' && r != 'O') {
+		return 0
+	}
+
+	var params []rune
+	for {
+		r, _, err = e.reader.ReadRune()
+		if err != nil {
+			return 0
+		}
+		if (r < '0' || r > '9') && r != ';' {
+			break
+		}
+		params = append(params, r)
+	}
+
+	if r != '~' {
+		return r
+	}
+	switch string(params) {
+	case "1", "7":
+		return 'H'
+	case "4", "8":
+		return 'F'
+	case "3":
+		return '3'
+	}
+	return 0
+}
+
+// browseHistory replaces the line with the previous or next history entry.
+// The line being typed is kept as the draft while browsing.
+func (e *LineEditor) browseHistory(index int, draft string, back bool) (int, string) {
+	if index == len(e.history) {
+		draft = string(e.buf)
+	}
+
+	switch {
+	case back && index > 0:
+		index--
+	case !back && index < len(e.history):
+		index++
+	default:
+		return index, draft
+	}
+
+	line := draft
+	if index < len(e.history) {
+		line = e.history[index]
+	}
+	e.buf = []rune(line)
+	e.pos = len(e.buf)
+	e.refresh()
+
+	return index, draft
+}
+
+// completeWord completes the word left of the cursor. A unique candidate
+// is inserted in full; otherwise the common prefix of the candidates is
+// inserted, and a second tab lists them.
+func (e *LineEditor) completeWord(list bool) {
+	if e.complete == nil {
+		return
+	}
+
+	start := e.pos
+	for start > 0 && e.buf[start-1] != ' ' {
+		start--
+	}
+	word := string(e.buf[start:e.pos])
+	var matches []string
+	seen := make(map[string]bool)
+	for _, candidate := range e.complete(string(e.buf[:e.pos])) {
+		if strings.HasPrefix(candidate, word) && !seen[candidate] {
+			seen[candidate] = true
+			matches = append(matches, candidate)
+		}
+	}
+
+	switch {
+	case len(matches) == 0:
+		fmt.Fprint(e.out, "\a")
+	case len(matches) == 1:
+		e.insert([]rune(matches[0][len(word):] + " "))
+	default:
+		prefix := commonPrefix(matches)
+		if len(prefix) > len(word) {
+			e.insert([]rune(prefix[len(word):]))
+			return
+		}
+		if !list {
+			fmt.Fprint(e.out, "\a")
+			return
+		}
+
+		sort.Strings(matches)
+		fmt.Fprint(e.out, "\r\n"+strings.Join(matches, "  ")+"\r\n")
+		e.refresh()
+	}
+}
+
+// wordStart returns the start of the word left of the cursor, including
+// the spaces between it and the cursor
+func (e *LineEditor) wordStart() int {
+	start := e.pos
+	for start > 0 && e.buf[start-1] == ' ' {
+		start--
+	}
+	for start > 0 && e.buf[start-1] != ' ' {
+		start--
+	}
+	return start
+}
+
+// insert inserts runes at the cursor
+func (e *LineEditor) insert(runes []rune) {
+	e.buf = append(e.buf[:e.pos], append(runes, e.buf[e.pos:]...)...)
+	e.pos += len(runes)
+	e.refresh()
+}
+
+// deleteRunes deletes the runes between from and to
+func (e *LineEditor) deleteRunes(from, to int) {
+	if from < 0 {
+		from = 0
+	}
+	if to > len(e.buf) {
+		to = len(e.buf)
+	}
+	if from >= to {
+		return
+	}
+
+	e.buf = append(e.buf[:from], e.buf[to:]...)
+	e.pos = from
+	e.refresh()
+}
+
+// moveTo moves the cursor, keeping it within the line
+func (e *LineEditor) moveTo(pos int) {
+	if pos < 0 || pos > len(e.buf) {
+		return
+	}
+	e.pos = pos
+	e.refresh()
+}
+
+// refresh redraws the prompt and the line and places the cursor
+func (e *LineEditor) refresh() {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", e.prompt, string(e.buf))
+	if back := len(e.buf) - e.pos; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
+
+// addHistory appends a non-empty line to the history unless it repeats
+// the previous entry, and persists it if a history file is set
+func (e *LineEditor) addHistory(line string) {
+	line = strings.TrimSpace(line)
+	if e.redact != nil {
+		line = e.redact(line)
+	}
+	if line == "" || (len(e.history) > 0 && e.history[len(e.history)-1] == line) {
+		return
+	}
+
+	e.history = append(e.history, line)
+	if len(e.history) > MaxHistory {
+		e.history = e.history[len(e.history)-MaxHistory:]
+	}
+
+	if e.historyPath != "" {
+		e.saveHistory()
+	}
+}
+
+// saveHistory writes the history file, ignoring errors since losing
+// history must not interrupt the console
+func (e *LineEditor) saveHistory() {
+	if err := os.MkdirAll(filepath.Dir(e.historyPath), 0700); err != nil {
+		return
+	}
+
+	tmp := e.historyPath + ".tmp"
+	data := strings.Join(e.history, "\n") + "\n"
+	if err := os.WriteFile(tmp, []byte(data), 0600); err != nil {
+		return
+	}
+	os.Rename(tmp, e.historyPath)
+}
+
+// commonPrefix returns the longest common prefix of words
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
@@ -0,0 +1,212 @@
+//go:build linux
+
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPTY opens a pseudo-terminal and returns its master and slave ends
+func openPTY(t *testing.T) (*os.File, *os.File) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("No pseudo-terminal available: %v", err)
+	}
+	t.Cleanup(func() { master.Close() })
+
+	fd := int(master.Fd())
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		t.Skipf("Failed to unlock pseudo-terminal: %v", err)
+	}
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		t.Skipf("Failed to get pseudo-terminal number: %v", err)
+	}
+
+	slave, err := os.OpenFile("/dev/pts/"+strconv.Itoa(n), os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("Failed to open pseudo-terminal: %v", err)
+	}
+	t.Cleanup(func() { slave.Close() })
+
+	return master, slave
+}
+
+// syncBuffer is a buffer safe for the editor and the test to share
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// typeInto waits for the editor to put the terminal into raw mode, so that
+// the terminal neither echoes nor buffers the input, and then types it
+func typeInto(t *testing.T, master, slave *os.File, input string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		termios, err := unix.IoctlGetTermios(int(slave.Fd()), ioctlReadTermios)
+		if err == nil && termios.Lflag&unix.ECHO == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("The editor did not enter raw mode")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := master.Write([]byte(input)); err != nil {
+		t.Fatalf("Failed to type input: %v", err)
+	}
+}
+
+// readLines reads one line per input through read, typing each input once
+// the editor waits for it
+func readLines(t *testing.T, master, slave *os.File, read func() (string, error), inputs ...string) []string {
+	lines := make([]string, 0, len(inputs))
+	for _, input := range inputs {
+		done := make(chan struct{})
+		var line string
+		var err error
+		go func() {
+			defer close(done)
+			line, err = read()
+		}()
+
+		typeInto(t, master, slave, input)
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("The editor did not return a line for %q", input)
+		}
+		if err != nil {
+			t.Fatalf("Failed to read %q: %v", input, err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestLineEditorHistory(t *testing.T) {
+	master, slave := openPTY(t)
+	path := filepath.Join(t.TempDir(), "history")
+
+	var out syncBuffer
+	editor := NewLineEditor(slave, &out, nil)
+	if err := editor.SetHistoryPath(path); err != nil {
+		t.Fatalf("Failed to set history path: %v", err)
+	}
+
+	read := func() (string, error) { return editor.ReadLine("> ") }
+	lines := readLines(t, master, slave, read, "clients\r", "clients\r", "  tasks  \r", "\r")
+	if lines[2] != "  tasks  " {
+		t.Errorf("Expected the line as typed, got %q", lines[2])
+	}
+
+	// Repeated and empty lines are not kept; lines are trimmed
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read history file: %v", err)
+	}
+	if string(data) != "clients\ntasks\n" {
+		t.Errorf("Unexpected history file: %q", data)
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the history file to be private, got %v", info.Mode().Perm())
+	}
+
+	// The up arrow recalls the history of an earlier session
+	editor = NewLineEditor(slave, &out, nil)
+	if err := editor.SetHistoryPath(path); err != nil {
+		t.Fatalf("Failed to load history: %v", err)
+	}
+	read = func() (string, error) { return editor.ReadLine("> ") }
+	if lines := readLines(t, master, slave, read, "\x1b[A\x1b[A\r"); lines[0] != "clients" {
+		t.Errorf("Expected to recall clients, got %q", lines[0])
+	}
+}
+
+func TestLineEditorRedactsSensitiveCommands(t *testing.T) {
+	master, slave := openPTY(t)
+	path := filepath.Join(t.TempDir(), "history")
+
+	// A history file saved before sensitive commands were left out
+	if err := os.WriteFile(path, []byte("clients\nuser add bob s3cret admin\n"), 0600); err != nil {
+		t.Fatalf("Failed to write history file: %v", err)
+	}
+
+	console, _ := newTestConsole()
+	var out syncBuffer
+	editor := NewLineEditor(slave, &out, nil)
+	editor.SetHistoryRedact(console.historyLine)
+	if err := editor.SetHistoryPath(path); err != nil {
+		t.Fatalf("Failed to set history path: %v", err)
+	}
+
+	read := func() (string, error) { return editor.ReadLine("> ") }
+	readLines(t, master, slave, read, "apikey create ci operator\r", "tasks\r", "user passwd bob hunter2\r")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read history file: %v", err)
+	}
+	if string(data) != "clients\ntasks\n" {
+		t.Errorf("Expected sensitive commands to be left out, got %q", data)
+	}
+	if strings.Join(editor.history, "\n") != "clients\ntasks" {
+		t.Errorf("Unexpected history: %q", editor.history)
+	}
+}
+
+func TestLineEditorReadPassword(t *testing.T) {
+	master, slave := openPTY(t)
+	path := filepath.Join(t.TempDir(), "history")
+
+	var out syncBuffer
+	editor := NewLineEditor(slave, &out, nil)
+	if err := editor.SetHistoryPath(path); err != nil {
+		t.Fatalf("Failed to set history path: %v", err)
+	}
+
+	// Backspace and Ctrl-U edit the password without showing it
+	read := func() (string, error) { return editor.ReadPassword("Password: ") }
+	passwords := readLines(t, master, slave, read, "wrong\x15hunter22\x7f\r")
+	if passwords[0] != "hunter2" {
+		t.Errorf("Expected hunter2, got %q", passwords[0])
+	}
+
+	if got := out.String(); got != "Password: \r\n" {
+		t.Errorf("Expected only the prompt to be echoed, got %q", got)
+	}
+	if len(editor.history) != 0 {
+		t.Errorf("Expected the password not to be kept, got %q", editor.history)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected no history file, got %v", err)
+	}
+
+	// The terminal echoes again once the password is read
+	termios, err := unix.IoctlGetTermios(int(slave.Fd()), ioctlReadTermios)
+	if err != nil || termios.Lflag&unix.ECHO == 0 {
+		t.Errorf("Expected the terminal mode to be restored, got %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Cl0udRs4/dinot/internal/server/listener"
+)
+
+// listenerAddUsage is the usage of the listener add subcommand
+const listenerAddUsage = "usage: listener add <protocol> <address> [--name name] [--cert file --key file] [--domain name] [--ttl seconds] [--records A,TXT] [--buffer bytes] [--max-conns n] [--timeout seconds]"
+
+// cmdListener implements the listener command
+func (c *Console) cmdListener(args []string) error {
+	if c.listenerManager == nil {
+		return errors.New("listeners are not managed by this server")
+	}
+	if len(args) < 1 {
+		return fmt.Errorf("usage: listener <add|start|stop|rm|list> [protocol|listener] [args...]")
+	}
+
+	switch args[0] {
+	case "add":
+		if len(args) < 3 {
+			return errors.New(listenerAddUsage)
+		}
+
+		protocol := args[1]
+		config, err := parseListenerOptions(protocol, args[2], args[3:])
+		if err != nil {
+			return err
+		}
+
+		var created listener.Listener
+		if protocol == "dns" {
+			created, err = c.listenerManager.CreateDNSListener(config)
+		} else {
+			created, err = c.listenerManager.CreateListener(protocol, config.Config)
+		}
+		if err != nil {
+			return err
+		}
+		info := listener.Describe(created)
+		c.show(info, Text(fmt.Sprintf("Added %s listener %s on %s; start it with: listener start %s\n", protocol, info.Name, info.Address, info.Name)))
+
+	case "start", "stop":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: listener %s <listener>", args[0])
+		}
+
+		action := c.listenerManager.StartListener
+		if args[0] == "stop" {
+			action = c.listenerManager.StopListener
+		}
+		if err := action(args[1]); err != nil {
+			return err
+		}
+
+		l, err := c.listenerManager.GetListener(args[1])
+		if err != nil {
+			return err
+		}
+		info := listener.Describe(l)
+		c.show(info, Text(fmt.Sprintf("%s listener %s on %s %s\n", info.Protocol, info.Name, info.Address, info.Status)))
+
+	case "rm":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: listener rm <listener>")
+		}
+
+		if err := c.listenerManager.UnregisterListener(args[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out, "Removed %s listener\n", args[1])
+
+	case "list":
+		infos := c.listenerManager.DescribeAll()
+		table := NewTable("Name", "Protocol", "Address", "Status", "TLS", "Settings").StatusColumn("Status")
+		table.Empty = "No listeners"
+		for _, info := range infos {
+			tls := "no"
+			if info.TLS {
+				tls = "yes"
+			}
+			settings := fmt.Sprintf("buffer=%d max-conns=%d timeout=%ds", info.BufferSize, info.MaxConnections, info.Timeout)
+			if info.Protocol == "dns" {
+				settings = fmt.Sprintf("domain=%s ttl=%d records=%s %s", info.Domain, info.TTL, strings.Join(info.RecordTypes, ","), settings)
+			}
+			table.AddRow(info.Name, info.Protocol, info.Address, string(info.Status), tls, settings)
+		}
+		c.show(infos, table)
+
+	default:
+		return fmt.Errorf("unknown subcommand. Available subcommands: add, start, stop, rm, list")
+	}
+
+	return nil
+}
+
+// parseListenerOptions parses the options of listener add into a listener
+// configuration. The name defaults to the protocol. The DNS options are
+// rejected for other protocols, and defaults are used for the DNS settings
+// left unset.
+func parseListenerOptions(protocol, address string, args []string) (listener.DNSConfig, error) {
+	config := listener.DNSConfig{Config: listener.Config{Address: address}}
+
+	for i := 0; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			return config, errors.New(listenerAddUsage)
+		}
+		option, value := args[i], args[i+1]
+
+		var err error
+		switch option {
+		case "--name":
+			config.Name = value
+		case "--cert":
+			config.EnableTLS = true
+			config.TLSCertFile = value
+		case "--key":
+			config.EnableTLS = true
+			config.TLSKeyFile = value
+		case "--domain":
+			config.Domain = value
+		case "--ttl":
+			var ttl uint64
+			ttl, err = strconv.ParseUint(value, 10, 32)
+			config.TTL = uint32(ttl)
+		case "--records":
+			config.RecordTypes = strings.Split(value, ",")
+		case "--buffer":
+			config.BufferSize, err = strconv.Atoi(value)
+		case "--max-conns":
+			config.MaxConnections, err = strconv.Atoi(value)
+		case "--timeout":
+			config.Timeout, err = strconv.Atoi(value)
+		default:
+			return config, errors.New(listenerAddUsage)
+		}
+		if err != nil {
+			return config, fmt.Errorf("invalid value for %s: %s", option, value)
+		}
+
+		if protocol != "dns" && (option == "--domain" || option == "--ttl" || option == "--records") {
+			return config, fmt.Errorf("%s only applies to DNS listeners", option)
+		}
+	}
+
+	return config.WithDefaults(), nil
+}
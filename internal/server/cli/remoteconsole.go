@@ -0,0 +1,295 @@
+package cli
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// completeTimeout bounds how long tab completion waits for the team server
+const completeTimeout = 2 * time.Second
+
+// ErrDisconnected is returned when the connection to the team server is lost
+var ErrDisconnected = errors.New("disconnected from the team server")
+
+// RemoteConsoleConfig configures the connection of a remote console
+type RemoteConsoleConfig struct {
+	// Address is the address of the team server
+	Address string
+
+	// TLS configures how the team server certificate is verified and the
+	// operator's client certificate, if any
+	TLS *tls.Config
+
+	// Username is the operator's username
+	Username string
+
+	// Password is the operator's password; it may be empty when the TLS
+	// configuration holds a client certificate naming the operator
+	Password string
+}
+
+// RemoteConsole runs the console of a team server from another machine.
+// Commands are read with line editing, history and completion and run on
+// the server; results of background tasks and the commands of other
+// operators are printed as they arrive.
+type RemoteConsole struct {
+	// conn is the connection to the team server
+	conn net.Conn
+
+	// encoder writes messages to the connection
+	encoder *json.Encoder
+
+	// editor reads the operator's commands
+	editor *LineEditor
+
+	// prompt is the prompt of the next command, as set by the server
+	prompt string
+
+	// welcome is the text the server sent on login
+	welcome string
+
+	// sensitive holds the commands the server reported as sensitive, which
+	// are kept out of the history
+	sensitive map[string]bool
+
+	// nextID is the ID of the last request
+	nextID int
+
+	// pending holds the channels awaiting the results of requests by ID
+	pending map[int]chan RemoteMessage
+
+	// notifications queues events and notifications to print
+	notifications chan string
+
+	// done is closed when the connection is lost
+	done chan struct{}
+
+	// mu protects encoder, nextID and pending
+	mu sync.Mutex
+}
+
+// NewRemoteConsole creates a remote console reading from standard input
+func NewRemoteConsole() *RemoteConsole {
+	r := &RemoteConsole{
+		prompt:        "> ",
+		pending:       make(map[int]chan RemoteMessage),
+		notifications: make(chan string, 256),
+		done:          make(chan struct{}),
+	}
+	r.editor = NewLineEditor(os.Stdin, os.Stdout, r.completions)
+	r.editor.SetHistoryRedact(r.historyLine)
+	return r
+}
+
+// SetHistoryPath sets the file the command history is kept in. Set it
+// after connecting, so that the sensitive commands the server reports are
+// also dropped from the saved history.
+func (r *RemoteConsole) SetHistoryPath(path string) error {
+	return r.editor.SetHistoryPath(path)
+}
+
+// ReadPassword prompts for a password without echoing it
+func (r *RemoteConsole) ReadPassword(prompt string) (string, error) {
+	return r.editor.ReadPassword(prompt)
+}
+
+// Connect connects to the team server and logs in
+func (r *RemoteConsole) Connect(config RemoteConsoleConfig) error {
+	dialer := &net.Dialer{Timeout: authTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", config.Address, config.TLS)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(conn)
+	decoder := json.NewDecoder(conn)
+
+	conn.SetDeadline(time.Now().Add(authTimeout))
+	login := RemoteMessage{Type: MessageAuth, Username: config.Username, Password: config.Password}
+	var reply RemoteMessage
+	if err := encoder.Encode(login); err == nil {
+		err = decoder.Decode(&reply)
+	}
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if reply.Error != "" {
+		conn.Close()
+		return errors.New(reply.Error)
+	}
+	conn.SetDeadline(time.Time{})
+
+	r.conn = conn
+	r.encoder = encoder
+	r.welcome = reply.Output
+	r.sensitive = make(map[string]bool, len(reply.Sensitive))
+	for _, name := range reply.Sensitive {
+		r.sensitive[name] = true
+	}
+	if reply.Prompt != "" {
+		r.prompt = reply.Prompt
+	}
+
+	go r.read(decoder)
+	go r.print()
+
+	return nil
+}
+
+// Run reads commands and runs them on the team server until the operator
+// exits or the connection is lost
+func (r *RemoteConsole) Run() error {
+	if r.welcome != "" {
+		r.editor.Notify(r.welcome)
+	}
+
+	for {
+		line, err := r.editor.ReadLine(r.prompt)
+		if err != nil {
+			if err == ErrInterrupted {
+				continue
+			}
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		reply, err := r.request(RemoteMessage{Type: MessageCommand, Line: line}, 0)
+		if err != nil {
+			return err
+		}
+
+		if reply.Output != "" {
+			r.editor.Notify(reply.Output)
+		}
+		if reply.Error != "" {
+			r.editor.Notify("Error: " + reply.Error)
+		}
+		if reply.Prompt != "" {
+			r.prompt = reply.Prompt
+		}
+		if reply.Closed {
+			return nil
+		}
+	}
+}
+
+// historyLine returns the line kept in the history for an input line,
+// leaving out the lines of sensitive commands
+func (r *RemoteConsole) historyLine(line string) string {
+	words := strings.Fields(line)
+	if len(words) > 0 && r.sensitive[words[0]] {
+		return ""
+	}
+	return line
+}
+
+// Close closes the connection to the team server
+func (r *RemoteConsole) Close() error {
+	if r.conn == nil {
+		return nil
+	}
+	return r.conn.Close()
+}
+
+// request sends a message and waits for its result. A zero timeout waits
+// as long as the command runs.
+func (r *RemoteConsole) request(msg RemoteMessage, timeout time.Duration) (RemoteMessage, error) {
+	reply := make(chan RemoteMessage, 1)
+
+	r.mu.Lock()
+	r.nextID++
+	msg.ID = r.nextID
+	r.pending[msg.ID] = reply
+	err := r.encoder.Encode(msg)
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		delete(r.pending, msg.ID)
+		r.mu.Unlock()
+	}()
+
+	if err != nil {
+		return RemoteMessage{}, ErrDisconnected
+	}
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case result := <-reply:
+		return result, nil
+	case <-r.done:
+		return RemoteMessage{}, ErrDisconnected
+	case <-expired:
+		return RemoteMessage{}, errors.New("team server did not answer in time")
+	}
+}
+
+// read dispatches the messages of the team server until the connection is
+// lost. Events and notifications are queued rather than printed here, so
+// that results are delivered while the editor is busy completing a line.
+func (r *RemoteConsole) read(decoder *json.Decoder) {
+	defer close(r.done)
+
+	for {
+		var msg RemoteMessage
+		if err := decoder.Decode(&msg); err != nil {
+			return
+		}
+
+		switch msg.Type {
+		case MessageResult:
+			r.mu.Lock()
+			reply := r.pending[msg.ID]
+			r.mu.Unlock()
+			if reply != nil {
+				reply <- msg
+			}
+		case MessageEvent:
+			r.notifications <- FormatEvent(msg)
+		case MessageNotify:
+			r.notifications <- msg.Output
+		}
+	}
+}
+
+// print prints queued events and notifications above the line being edited
+func (r *RemoteConsole) print() {
+	for {
+		select {
+		case text := <-r.notifications:
+			r.editor.Notify(text)
+		case <-r.done:
+			r.editor.Notify(ErrDisconnected.Error())
+			return
+		}
+	}
+}
+
+// completions asks the team server for the completion candidates of a line
+func (r *RemoteConsole) completions(line string) []string {
+	reply, err := r.request(RemoteMessage{Type: MessageComplete, Line: line}, completeTimeout)
+	if err != nil {
+		return nil
+	}
+	return reply.Candidates
+}
@@ -0,0 +1,307 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxCellWidth is the width table cells are truncated to. The last column
+// is not truncated, since it cannot push other columns out of alignment.
+const maxCellWidth = 40
+
+// ANSI escape sequences used to color statuses
+const (
+	colorReset  = "\x1b[0m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorGray   = "\x1b[90m"
+)
+
+// statusColors maps the statuses of clients, tasks, batches, workflows,
+// steps and schedules, and the severities of exceptions, to colors: green
+// when done or healthy, yellow while in progress or as a warning, red on
+// failure and gray when inactive
+var statusColors = map[string]string{
+	"online":     colorGreen,
+	"completed":  colorGreen,
+	"enabled":    colorGreen,
+	"busy":       colorYellow,
+	"pending":    colorYellow,
+	"dispatched": colorYellow,
+	"processing": colorYellow,
+	"retrying":   colorYellow,
+	"cancelling": colorYellow,
+	"running":    colorYellow,
+	"waiting":    colorYellow,
+	"partial":    colorYellow,
+	"warning":    colorYellow,
+	"error":      colorRed,
+	"failed":     colorRed,
+	"timed_out":  colorRed,
+	"critical":   colorRed,
+	"offline":    colorGray,
+	"cancelled":  colorGray,
+	"skipped":    colorGray,
+	"disabled":   colorGray,
+}
+
+// view is console output that renders in the table output format
+type view interface {
+	// Render writes the view, coloring statuses if color is set
+	Render(w io.Writer, color bool)
+}
+
+// Text is console output that is already formatted
+type Text string
+
+// Render writes the text
+func (t Text) Render(w io.Writer, color bool) {
+	fmt.Fprint(w, string(t))
+}
+
+// Table is console output in aligned columns
+type Table struct {
+	// Title is printed above the table
+	Title string
+
+	// Empty is printed instead of a table without rows
+	Empty string
+
+	// Footer is printed below the table, e.g. a total
+	Footer string
+
+	// columns holds the column headers
+	columns []string
+
+	// rows holds the cells of each row
+	rows [][]string
+
+	// status marks the columns whose values are colored by status
+	status map[int]bool
+}
+
+// NewTable creates a table with the given column headers
+func NewTable(columns ...string) *Table {
+	return &Table{
+		columns: columns,
+		status:  make(map[int]bool),
+	}
+}
+
+// StatusColumn marks a column whose values are colored by status
+func (t *Table) StatusColumn(column string) *Table {
+	for i, name := range t.columns {
+		if name == column {
+			t.status[i] = true
+		}
+	}
+	return t
+}
+
+// AddRow adds a row; missing cells are left empty
+func (t *Table) AddRow(cells ...string) {
+	row := make([]string, len(t.columns))
+	copy(row, cells)
+	t.rows = append(t.rows, row)
+}
+
+// Len returns the number of rows
+func (t *Table) Len() int {
+	return len(t.rows)
+}
+
+// Render writes the table with its columns aligned
+func (t *Table) Render(w io.Writer, color bool) {
+	if t.Title != "" {
+		fmt.Fprintln(w, t.Title)
+	}
+	if len(t.rows) == 0 {
+		if t.Empty != "" {
+			fmt.Fprintln(w, t.Empty)
+		}
+		return
+	}
+
+	// Truncate the cells and size the columns to fit them
+	last := len(t.columns) - 1
+	widths := make([]int, len(t.columns))
+	cells := make([][]string, len(t.rows))
+	for i, column := range t.columns {
+		widths[i] = utf8.RuneCountInString(column)
+	}
+	for r, row := range t.rows {
+		cells[r] = make([]string, len(row))
+		for i, cell := range row {
+			cell = strings.Join(strings.Fields(cell), " ")
+			if i != last {
+				cell = truncate(cell, maxCellWidth)
+			}
+			cells[r][i] = cell
+			if n := utf8.RuneCountInString(cell); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	rule := make([]string, len(widths))
+	for i, width := range widths {
+		rule[i] = strings.Repeat("-", width)
+	}
+
+	writeRow(w, t.columns, widths, nil, false)
+	writeRow(w, rule, widths, nil, false)
+	for _, row := range cells {
+		writeRow(w, row, widths, t.status, color)
+	}
+
+	if t.Footer != "" {
+		fmt.Fprintf(w, "\n%s\n", t.Footer)
+	}
+}
+
+// writeRow writes one table row, padding the cells to the column widths.
+// Colors are applied around the padded cells so they do not skew the
+// alignment.
+func writeRow(w io.Writer, cells []string, widths []int, status map[int]bool, color bool) {
+	var b strings.Builder
+	for i, cell := range cells {
+		if i > 0 {
+			b.WriteString("  ")
+		}
+
+		padded := cell
+		if i < len(cells)-1 {
+			padded += strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell))
+		}
+		if color && status[i] {
+			padded = colorStatus(cell, padded)
+		}
+		b.WriteString(padded)
+	}
+	fmt.Fprintln(w, strings.TrimRight(b.String(), " "))
+}
+
+// Details is console output of labelled values, such as the properties of
+// a client
+type Details struct {
+	// Title is printed above the values
+	Title string
+
+	// rows holds the labels and values in order
+	rows [][2]string
+
+	// status marks the labels whose values are colored by status
+	status map[string]bool
+}
+
+// NewDetails creates details with a title
+func NewDetails(title string) *Details {
+	return &Details{
+		Title:  title,
+		status: make(map[string]bool),
+	}
+}
+
+// Add adds a labelled value
+func (d *Details) Add(label, value string) {
+	d.rows = append(d.rows, [2]string{label, value})
+}
+
+// AddStatus adds a labelled value colored by status
+func (d *Details) AddStatus(label, value string) {
+	d.Add(label, value)
+	d.status[label] = true
+}
+
+// Render writes the values with their labels aligned
+func (d *Details) Render(w io.Writer, color bool) {
+	if d.Title != "" {
+		fmt.Fprintln(w, d.Title)
+	}
+
+	width := 0
+	for _, row := range d.rows {
+		if n := utf8.RuneCountInString(row[0]); n > width {
+			width = n
+		}
+	}
+
+	for _, row := range d.rows {
+		value := row[1]
+		if color && d.status[row[0]] {
+			value = colorStatus(value, value)
+		}
+		fmt.Fprintf(w, "  %s:%s %s\n", row[0], strings.Repeat(" ", width-utf8.RuneCountInString(row[0])), value)
+	}
+}
+
+// colorStatus colors text by the status it shows; text that is not a known
+// status is left as is
+func colorStatus(status, text string) string {
+	color, ok := statusColors[status]
+	if !ok {
+		return text
+	}
+	return color + text + colorReset
+}
+
+// truncate shortens s to at most width runes, marking the cut with "…"
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:width-1]) + "…"
+}
+
+// formatAge formats a time relative to now, e.g. "3m ago" or "in 2h"
+func formatAge(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+
+	d := time.Since(t)
+	switch {
+	case d < 0:
+		return "in " + formatSpan(-d)
+	case d < time.Second:
+		return "just now"
+	default:
+		return formatSpan(d) + " ago"
+	}
+}
+
+// formatSpan formats a duration in its largest whole unit, e.g. "45s",
+// "3m", "2h" or "5d"
+func formatSpan(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d/time.Second))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d/time.Hour))
+	default:
+		return fmt.Sprintf("%dd", int(d/(24*time.Hour)))
+	}
+}
+
+// show writes the result of a command. In the table output format the
+// views are rendered; in the JSON format data becomes the command's result.
+func (c *Console) show(data interface{}, views ...view) {
+	c.data = data
+	if c.format == OutputJSON {
+		return
+	}
+
+	for i, v := range views {
+		if i > 0 {
+			fmt.Fprintln(c.out)
+		}
+		v.Render(c.out, c.color)
+	}
+}
@@ -0,0 +1,207 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Cl0udRs4/dinot/internal/server/client"
+	"github.com/Cl0udRs4/dinot/internal/server/task"
+)
+
+// cmdSchedule implements the schedule command
+func (c *Console) cmdSchedule(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: schedule <at|cron|register|list|show|enable|disable|rm> [args...]")
+	}
+
+	rest, params := splitParams(args[1:])
+
+	switch args[0] {
+	case "at":
+		if len(rest) != 3 {
+			return fmt.Errorf("usage: schedule at <time|+duration> <module> <ids,...|tag:name|group:name|filter:expr> [-- json_params]")
+		}
+
+		runAt, err := parseRunAt(rest[0])
+		if err != nil {
+			return err
+		}
+
+		return c.createSchedule(task.ScheduleSpec{
+			Selector: client.ParseTarget(rest[2]),
+			Module:   rest[1],
+			Params:   params,
+			RunAt:    runAt,
+		})
+
+	case "cron":
+		if len(rest) < 3 {
+			return fmt.Errorf("usage: schedule cron <module> <ids,...|tag:name|group:name|filter:expr> <expression> [-- json_params]")
+		}
+
+		return c.createSchedule(task.ScheduleSpec{
+			Selector: client.ParseTarget(rest[1]),
+			Module:   rest[0],
+			Params:   params,
+			Cron:     strings.Join(rest[2:], " "),
+		})
+
+	case "register":
+		if len(rest) < 1 || len(rest) > 2 {
+			return fmt.Errorf("usage: schedule register <module> [tag:name|group:name|filter:expr] [-- json_params]")
+		}
+
+		spec := task.ScheduleSpec{Module: rest[0], Params: params, OnRegister: true}
+		if len(rest) == 2 {
+			spec.Selector = client.ParseTarget(rest[1])
+		}
+		return c.createSchedule(spec)
+
+	case "list":
+		schedules := c.scheduler.GetAllSchedules()
+		table := NewTable("ID", "Kind", "Module", "Status", "Next Run", "Selector").StatusColumn("Status")
+		table.Empty = "No schedules defined"
+		for _, schedule := range schedules {
+			table.AddRow(
+				schedule.ID,
+				string(schedule.Kind),
+				schedule.Module,
+				scheduleStatus(schedule),
+				formatScheduleAge(schedule.NextRun),
+				schedule.Selector,
+			)
+		}
+		if table.Len() > 0 {
+			table.Footer = fmt.Sprintf("Total: %d schedules", table.Len())
+		}
+		c.show(schedules, table)
+
+	case "show":
+		if len(rest) < 1 {
+			return fmt.Errorf("usage: schedule show <schedule_id>")
+		}
+
+		schedule, err := c.scheduler.GetSchedule(rest[0])
+		if err != nil {
+			return err
+		}
+
+		title := "Schedule " + schedule.ID
+		if schedule.Name != "" {
+			title += fmt.Sprintf(" (%s)", schedule.Name)
+		}
+		details := NewDetails(title)
+		kind := string(schedule.Kind)
+		switch schedule.Kind {
+		case task.ScheduleOnce:
+			kind += " at " + formatScheduleTime(schedule.RunAt)
+		case task.ScheduleCron:
+			kind += fmt.Sprintf(" %q", schedule.Cron)
+		}
+		details.Add("Kind", kind)
+		details.Add("Module", strings.TrimSpace(schedule.Module+" "+string(schedule.Params)))
+		details.Add("Selector", schedule.Selector)
+		details.AddStatus("Status", scheduleStatus(schedule))
+		details.Add("Next run", formatScheduleTimeAge(schedule.NextRun))
+		details.Add("Last run", formatScheduleTimeAge(schedule.LastRun))
+		details.Add("Runs", fmt.Sprintf("%d", schedule.Runs))
+		if schedule.LastBatchID != "" {
+			details.Add("Last batch", schedule.LastBatchID)
+		}
+		if schedule.LastError != "" {
+			details.Add("Last error", schedule.LastError)
+		}
+		c.show(schedule, details)
+
+	case "enable", "disable":
+		if len(rest) < 1 {
+			return fmt.Errorf("usage: schedule %s <schedule_id>", args[0])
+		}
+
+		if _, err := c.scheduler.SetScheduleEnabled(rest[0], args[0] == "enable"); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out, "Schedule %s %sd\n", rest[0], args[0])
+
+	case "rm":
+		if len(rest) < 1 {
+			return fmt.Errorf("usage: schedule rm <schedule_id>")
+		}
+
+		if err := c.scheduler.DeleteSchedule(rest[0]); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out, "Deleted schedule %s\n", rest[0])
+
+	default:
+		return fmt.Errorf("unknown subcommand. Available subcommands: at, cron, register, list, show, enable, disable, rm")
+	}
+
+	return nil
+}
+
+// createSchedule creates a schedule and shows its ID
+func (c *Console) createSchedule(spec task.ScheduleSpec) error {
+	schedule, err := c.scheduler.CreateSchedule(spec)
+	if err != nil {
+		return err
+	}
+
+	text := "Created schedule " + schedule.ID
+	if schedule.NextRun != nil {
+		text += ", next run " + formatScheduleTime(schedule.NextRun)
+	}
+	c.show(schedule, Text(text+"\n"))
+	return nil
+}
+
+// parseRunAt parses an RFC 3339 timestamp or a "+duration" offset from now
+func parseRunAt(s string) (time.Time, error) {
+	if offset, ok := strings.CutPrefix(s, "+"); ok {
+		d, err := time.ParseDuration(offset)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid duration: %s", offset)
+		}
+		return time.Now().Add(d), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: use RFC 3339 or +duration, e.g. +30m", s)
+	}
+	return t, nil
+}
+
+// formatScheduleTime formats an optional schedule time
+func formatScheduleTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+// formatScheduleAge formats an optional schedule time relative to now
+func formatScheduleAge(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return formatAge(*t)
+}
+
+// formatScheduleTimeAge formats an optional schedule time followed by how
+// long ago or how soon it is
+func formatScheduleTimeAge(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%s (%s)", formatScheduleTime(t), formatAge(*t))
+}
+
+// scheduleStatus returns "enabled" or "disabled"
+func scheduleStatus(schedule *task.Schedule) string {
+	if schedule.Enabled {
+		return "enabled"
+	}
+	return "disabled"
+}
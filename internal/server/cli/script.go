@@ -0,0 +1,396 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Cl0udRs4/dinot/internal/server/task"
+)
+
+// Output formats of the console
+const (
+	// OutputTable writes aligned tables and text for people to read
+	OutputTable = "table"
+
+	// OutputJSON writes one JSON result per command, for machines to read
+	OutputJSON = "json"
+)
+
+// Exit codes returned by RunScript, unless the script exits with its own
+const (
+	// ExitOK means every command succeeded
+	ExitOK = 0
+
+	// ExitFailure means a command failed
+	ExitFailure = 1
+
+	// ExitUsage means the script could not be read
+	ExitUsage = 2
+)
+
+// waitPollInterval is how often wait-for checks what it waits for
+const waitPollInterval = 200 * time.Millisecond
+
+// variablePattern matches variable references: $name, ${name} and the $$
+// escape for a literal dollar sign
+var variablePattern = regexp.MustCompile(`\$(\$|\{[A-Za-z_][A-Za-z0-9_]*\}|[A-Za-z_][A-Za-z0-9_]*)`)
+
+// variableNamePattern matches valid variable names
+var variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// commandResult is the output of a command in the JSON output format
+type commandResult struct {
+	// Line is the line of the command in a script
+	Line int `json:"line,omitempty"`
+
+	// Command is the command as entered
+	Command string `json:"command"`
+
+	// OK reports whether the command succeeded
+	OK bool `json:"ok"`
+
+	// Ignored is set when the command failed but was prefixed with "-"
+	Ignored bool `json:"ignored,omitempty"`
+
+	// Data is the result of the command, such as the clients listed
+	Data interface{} `json:"data,omitempty"`
+
+	// Output is the text the command printed
+	Output string `json:"output,omitempty"`
+
+	// Error is the error of a failed command
+	Error string `json:"error,omitempty"`
+}
+
+// SetOutputFormat sets the output format: OutputTable or OutputJSON
+func (c *Console) SetOutputFormat(format string) error {
+	if format != OutputTable && format != OutputJSON {
+		return fmt.Errorf("unknown output format %q: expected %s or %s", format, OutputTable, OutputJSON)
+	}
+
+	c.format = format
+	return nil
+}
+
+// run executes an input line in the output format of the console. In the
+// JSON format the command's output is captured and written as a single
+// commandResult, which annotate may add to.
+func (c *Console) run(input string, annotate func(result *commandResult)) error {
+	if c.format != OutputJSON {
+		return c.execute(input)
+	}
+
+	out := c.out
+	var output bytes.Buffer
+	c.out = &output
+	c.data = nil
+	err := c.execute(input)
+	c.out = out
+
+	result := commandResult{
+		Command: input,
+		OK:      err == nil,
+		Data:    c.data,
+		Output:  output.String(),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if annotate != nil {
+		annotate(&result)
+	}
+	json.NewEncoder(out).Encode(result)
+
+	return err
+}
+
+// RunScriptFile runs the console commands of a script file, or of standard
+// input if path is "-", and returns the exit code of the script
+func (c *Console) RunScriptFile(path string) int {
+	if path == "-" {
+		return c.RunScript(os.Stdin)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening script: %v\n", err)
+		return ExitUsage
+	}
+	defer file.Close()
+
+	return c.RunScript(file)
+}
+
+// RunScript runs console commands non-interactively, one per line. Blank
+// lines and lines starting with "#" are skipped. The script stops at the
+// first failing command and returns ExitFailure, unless the command is
+// prefixed with "-"; it returns the code passed to exit if it exits early.
+// Errors are written to standard error, or into the results of the JSON
+// output format.
+func (c *Console) RunScript(r io.Reader) int {
+	c.running = true
+	c.scripting = true
+	c.exitCode = ExitOK
+	defer func() {
+		c.scripting = false
+	}()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for line := 1; c.running && scanner.Scan(); line++ {
+		input := strings.TrimSpace(scanner.Text())
+		if input == "" || strings.HasPrefix(input, "#") {
+			continue
+		}
+
+		// A leading "-" lets the script go on if the command fails
+		ignore := strings.HasPrefix(input, "-")
+		if ignore {
+			input = strings.TrimSpace(input[1:])
+		}
+
+		// The output format may change during the script
+		jsonOutput := c.format == OutputJSON
+		err := c.run(input, func(result *commandResult) {
+			result.Line = line
+			result.Ignored = !result.OK && ignore
+		})
+		if err != nil && !jsonOutput {
+			fmt.Fprintf(os.Stderr, "line %d: %s: %v\n", line, input, err)
+		}
+
+		if err != nil && !ignore {
+			return ExitFailure
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading script: %v\n", err)
+		return ExitUsage
+	}
+
+	return c.exitCode
+}
+
+// expand replaces the variable references of an input line with the
+// values of console variables, or of environment variables by that name.
+// Remote sessions only see console variables, since the environment of the
+// server holds its secrets.
+func (c *Console) expand(input string) (string, error) {
+	var err error
+	expanded := variablePattern.ReplaceAllStringFunc(input, func(match string) string {
+		name := strings.Trim(match[1:], "{}")
+		if name == "$" {
+			return "$"
+		}
+		if value, ok := c.variables[name]; ok {
+			return value
+		}
+		if c.identity == nil {
+			if value, ok := os.LookupEnv(name); ok {
+				return value
+			}
+		}
+		if err == nil {
+			err = fmt.Errorf("undefined variable: %s", name)
+		}
+		return match
+	})
+
+	return expanded, err
+}
+
+// recordTasks remembers tasks started by a command for wait-for and sets
+// the TASK and TASKS variables to the first and all of their IDs
+func (c *Console) recordTasks(taskIDs []string) {
+	c.started = append(c.started, taskIDs...)
+	c.variables["TASKS"] = strings.Join(taskIDs, " ")
+	if len(taskIDs) > 0 {
+		c.variables["TASK"] = taskIDs[0]
+	}
+}
+
+// cmdSet implements the set command. The output and color settings are
+// set like variables but change how the console prints.
+func (c *Console) cmdSet(args []string) error {
+	if len(args) == 0 {
+		color := "off"
+		if c.color {
+			color = "on"
+		}
+		table := NewTable("Name", "Value")
+		table.AddRow("output", c.format)
+		table.AddRow("color", color)
+
+		names := make([]string, 0, len(c.variables))
+		for name := range c.variables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			table.AddRow("$"+name, c.variables[name])
+		}
+
+		c.show(map[string]interface{}{"output": c.format, "color": c.color, "variables": c.variables}, table)
+		return nil
+	}
+
+	switch args[0] {
+	case "output":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: set output <table|json>")
+		}
+		return c.SetOutputFormat(args[1])
+
+	case "color":
+		if len(args) != 2 || (args[1] != "on" && args[1] != "off") {
+			return fmt.Errorf("usage: set color <on|off>")
+		}
+		c.color = args[1] == "on"
+		return nil
+	}
+
+	if !variableNamePattern.MatchString(args[0]) {
+		return fmt.Errorf("invalid variable name: %s", args[0])
+	}
+
+	c.variables[args[0]] = strings.Join(args[1:], " ")
+	return nil
+}
+
+// cmdWaitFor implements the wait-for command. It fails if the timeout
+// expires or if anything it waited for did not complete successfully.
+func (c *Console) cmdWaitFor(args []string) error {
+	var timeout time.Duration
+	var ids []string
+	for i := 0; i < len(args); i++ {
+		if args[i] != "--timeout" {
+			ids = append(ids, args[i])
+			continue
+		}
+
+		if i+1 == len(args) {
+			return fmt.Errorf("usage: wait-for [--timeout duration] [task_id|batch_id|workflow_id...]")
+		}
+		i++
+		d, err := time.ParseDuration(args[i])
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid timeout: %s", args[i])
+		}
+		timeout = d
+	}
+
+	if len(ids) == 0 {
+		ids = c.started
+	}
+	c.started = nil
+	if len(ids) == 0 {
+		fmt.Fprintln(c.out, "Nothing to wait for")
+		return nil
+	}
+
+	// Batches are waited for through their tasks
+	var taskIDs, workflowIDs []string
+	for _, id := range ids {
+		if _, err := c.taskManager.GetTask(id); err == nil {
+			taskIDs = append(taskIDs, id)
+		} else if batch, err := c.taskManager.GetBatch(id); err == nil {
+			taskIDs = append(taskIDs, batch.TaskIDs...)
+		} else if _, err := c.taskManager.GetWorkflow(id); err == nil {
+			workflowIDs = append(workflowIDs, id)
+		} else {
+			return fmt.Errorf("no task, batch or workflow with ID %s", id)
+		}
+	}
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	ticker := time.NewTicker(waitPollInterval)
+	defer ticker.Stop()
+
+	done := c.context().Done()
+	for !c.waitDone(taskIDs, workflowIDs) {
+		select {
+		case <-deadline:
+			return fmt.Errorf("timed out after %s", timeout)
+		case <-done:
+			return fmt.Errorf("interrupted: %w", c.context().Err())
+		case <-ticker.C:
+		}
+	}
+
+	return c.reportWait(taskIDs, workflowIDs)
+}
+
+// waitDone reports whether the tasks and workflows have all finished
+func (c *Console) waitDone(taskIDs, workflowIDs []string) bool {
+	for _, id := range taskIDs {
+		if t, err := c.taskManager.GetTask(id); err == nil && !t.GetStatus().IsFinal() {
+			return false
+		}
+	}
+	for _, id := range workflowIDs {
+		if workflow, err := c.taskManager.GetWorkflow(id); err == nil && workflow.Status == task.WorkflowRunning {
+			return false
+		}
+	}
+	return true
+}
+
+// reportWait shows the outcome of the finished tasks and workflows and
+// fails if any did not complete
+func (c *Console) reportWait(taskIDs, workflowIDs []string) error {
+	var results []json.RawMessage
+	var text strings.Builder
+	failed := 0
+
+	for _, id := range taskIDs {
+		t, err := c.taskManager.GetTask(id)
+		if err != nil {
+			return err
+		}
+		if t.GetStatus() != task.StatusCompleted {
+			failed++
+		}
+
+		if data, err := t.ToJSON(); err == nil {
+			results = append(results, data)
+		}
+		text.WriteString(formatTaskResult(t))
+	}
+
+	for _, id := range workflowIDs {
+		workflow, err := c.taskManager.GetWorkflow(id)
+		if err != nil {
+			return err
+		}
+		if workflow.Status != task.WorkflowCompleted {
+			failed++
+		}
+
+		if data, err := json.Marshal(workflow); err == nil {
+			results = append(results, data)
+		}
+		fmt.Fprintf(&text, "Workflow %s %s\n", workflow.ID, workflow.Status)
+	}
+	c.show(results, Text(text.String()))
+
+	if total := len(taskIDs) + len(workflowIDs); failed > 0 {
+		return fmt.Errorf("%d of %d did not complete", failed, total)
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Cl0udRs4/dinot/internal/server/auth"
+	"github.com/Cl0udRs4/dinot/internal/server/client"
+	"github.com/Cl0udRs4/dinot/internal/server/listener"
+	"github.com/Cl0udRs4/dinot/internal/server/task"
+)
+
+// newTestConsole creates a console on fresh managers with a client,
+// writing its output to the returned buffer
+func newTestConsole() (*Console, *bytes.Buffer) {
+	clientManager := client.NewClientManager()
+	clientManager.RegisterClient(client.NewClient("client-1", "Client 1", "10.0.0.1", "linux", "amd64", []string{"shell"}, "tcp"))
+
+	taskManager := task.NewTaskManager(clientManager)
+	console := NewConsole(
+		clientManager,
+		client.NewHeartbeatMonitor(clientManager, time.Minute, time.Minute),
+		taskManager,
+		task.NewScheduler(taskManager),
+		listener.NewListenerManager(listener.Config{}),
+		auth.NewUserStore(),
+		auth.NewAPIKeyStore(),
+	)
+
+	var out bytes.Buffer
+	console.SetOutput(&out)
+	return console, &out
+}
+
+func TestExpandEnvironment(t *testing.T) {
+	t.Setenv("DINOT_TEST_SECRET", "hunter2")
+
+	console, _ := newTestConsole()
+	if got, err := console.expand("echo $DINOT_TEST_SECRET"); err != nil || got != "echo hunter2" {
+		t.Errorf("Expected the server console to expand the environment, got %q, %v", got, err)
+	}
+
+	// A remote session cannot read the environment of the server
+	session := console.session(context.Background(), &auth.Identity{Username: "eve", Role: auth.RoleViewer}, func(string) {})
+	var out bytes.Buffer
+	session.out = &out
+
+	if err := session.run("set x $DINOT_TEST_SECRET", nil); err == nil {
+		t.Error("Expected the environment variable to be undefined in a session")
+	}
+	if got, err := session.expand("${DINOT_TEST_SECRET}"); err == nil || got == "hunter2" {
+		t.Errorf("Expected the session not to expand the environment, got %q, %v", got, err)
+	}
+	if _, exists := session.variables["x"]; exists {
+		t.Error("Expected x not to be set")
+	}
+}
+
+func TestWaitForInterrupted(t *testing.T) {
+	console, _ := newTestConsole()
+	pending, err := console.taskManager.CreateTask("client-1", "shell", nil)
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	// The client never answers; ending the session stops the wait
+	ctx, cancel := context.WithCancel(context.Background())
+	session := console.session(ctx, &auth.Identity{Username: "alice", Role: auth.RoleOperator}, func(string) {})
+	session.out = &bytes.Buffer{}
+
+	time.AfterFunc(100*time.Millisecond, cancel)
+	done := make(chan error, 1)
+	go func() { done <- session.run("wait-for "+pending.ID, nil) }()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected the wait to be interrupted, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait-for did not return once the session ended")
+	}
+}
+
+func TestJSONOutput(t *testing.T) {
+	console, out := newTestConsole()
+	console.SetOutputFormat(OutputJSON)
+
+	// Every command reports structured data rather than text
+	for _, input := range []string{"use client-1", "timeout shell 30", "timeout", "load shell"} {
+		out.Reset()
+		if err := console.run(input, nil); err != nil {
+			t.Fatalf("%s: %v", input, err)
+		}
+
+		var result commandResult
+		if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+			t.Fatalf("%s: invalid JSON %q: %v", input, out.String(), err)
+		}
+		if result.Data == nil || result.Output != "" {
+			t.Errorf("%s: expected data and no text, got %+v", input, result)
+		}
+	}
+
+	out.Reset()
+	console.run("cancel "+console.variables["TASK"], nil)
+	var result struct {
+		Data task.Task `json:"data"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil || result.Data.Status != task.StatusCancelled {
+		t.Errorf("Expected the cancelled task, got %s", out.String())
+	}
+
+	out.Reset()
+	console.run("info client-1", nil)
+	var info struct {
+		Data struct {
+			Client  client.Client             `json:"client"`
+			History []client.StatusTransition `json:"history"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &info); err != nil || info.Data.Client.ID != "client-1" || len(info.Data.History) == 0 {
+		t.Errorf("Expected the client and its history, got %s", out.String())
+	}
+}
+
+// answerTasks plays client-1 until the test ends: shell tasks running
+// "fail" fail, tasks running "hang" never finish and others complete
+func answerTasks(t *testing.T, taskManager *task.TaskManager) {
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	t.Cleanup(func() {
+		close(stop)
+		<-stopped
+	})
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			for _, data := range taskManager.PendingCommands("client-1") {
+				var command task.Command
+				var params struct {
+					CommandID string `json:"command_id"`
+					Command   string `json:"command"`
+				}
+				json.Unmarshal(data, &command)
+				json.Unmarshal(command.Params, &params)
+
+				switch params.Command {
+				case "hang":
+				case "fail":
+					taskManager.HandleFeedback(task.Feedback{CommandID: params.CommandID, Status: "failed", Error: "exit status 1"})
+				default:
+					taskManager.HandleFeedback(task.Feedback{CommandID: params.CommandID, Status: "completed", Result: json.RawMessage(`{"output":"ok"}`)})
+				}
+			}
+		}
+	}()
+}
+
+func TestRunScript(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		code   int
+		check  func(t *testing.T, console *Console)
+	}{
+		{name: "empty", script: "", code: ExitOK},
+		{name: "comments and blank lines", script: "# nothing to do\n\n   \n", code: ExitOK},
+		{name: "success", script: "list\ntasks\n", code: ExitOK},
+		{name: "failure stops the script", script: "bogus\nexit 7\n", code: ExitFailure},
+		{name: "ignored failure", script: "-bogus\nlist\n", code: ExitOK},
+		{name: "exit code", script: "exit 3\nbogus\n", code: 3},
+		{name: "exit without code", script: "exit\nbogus\n", code: ExitOK},
+		{name: "invalid exit code", script: "exit 256\n", code: ExitFailure},
+		{
+			name:   "variables",
+			script: "set id client-1\nset greeting hi $$5\nuse ${id}\n",
+			code:   ExitOK,
+			check: func(t *testing.T, console *Console) {
+				if console.target == nil || console.target.ClientID != "client-1" {
+					t.Errorf("Expected to use client-1, got %v", console.target)
+				}
+				if console.variables["greeting"] != "hi $5" {
+					t.Errorf("Expected the escaped dollar sign, got %q", console.variables["greeting"])
+				}
+			},
+		},
+		{name: "undefined variable", script: "use $nobody\n", code: ExitFailure},
+		{name: "invalid variable name", script: "set 1x y\n", code: ExitFailure},
+		{
+			name:   "task variables",
+			script: "use client-1\nshell id\nwait-for $TASK\n",
+			code:   ExitOK,
+			check: func(t *testing.T, console *Console) {
+				if _, err := console.taskManager.GetTask(console.variables["TASK"]); err != nil {
+					t.Errorf("Expected TASK to name the started task: %v", err)
+				}
+			},
+		},
+		{name: "wait-for completed", script: "use client-1\nshell id\nshell whoami\nwait-for --timeout 5s\n", code: ExitOK},
+		{name: "wait-for failed", script: "use client-1\nshell id\nshell fail\nwait-for --timeout 5s\nexit 9\n", code: ExitFailure},
+		{name: "wait-for timed out", script: "use client-1\nshell hang\nwait-for --timeout 200ms\n", code: ExitFailure},
+		{name: "ignored wait-for failure", script: "use client-1\nshell fail\n-wait-for\nexit 4\n", code: 4},
+		{name: "wait-for nothing", script: "wait-for\n", code: ExitOK},
+		{name: "wait-for unknown ID", script: "wait-for task-0\n", code: ExitFailure},
+		{name: "invalid wait-for timeout", script: "wait-for --timeout soon\n", code: ExitFailure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			console, _ := newTestConsole()
+			answerTasks(t, console.taskManager)
+
+			if code := console.RunScript(strings.NewReader(tt.script)); code != tt.code {
+				t.Errorf("Expected exit code %d, got %d", tt.code, code)
+			}
+			if tt.check != nil {
+				tt.check(t, console)
+			}
+		})
+	}
+}
+
+func TestRunScriptJSON(t *testing.T) {
+	console, out := newTestConsole()
+	answerTasks(t, console.taskManager)
+
+	script := "set output json\n-bogus\nuse client-1\nshell id\nwait-for --timeout 5s\n"
+	if code := console.RunScript(strings.NewReader(script)); code != ExitOK {
+		t.Fatalf("Expected exit code %d, got %d", ExitOK, code)
+	}
+
+	// One result per command after the output format is set
+	decoder := json.NewDecoder(out)
+	var results []commandResult
+	for decoder.More() {
+		var result commandResult
+		if err := decoder.Decode(&result); err != nil {
+			t.Fatalf("Invalid JSON output: %v", err)
+		}
+		results = append(results, result)
+	}
+	if len(results) != 4 {
+		t.Fatalf("Expected 4 results, got %d", len(results))
+	}
+	if results[0].Line != 2 || results[0].OK || !results[0].Ignored {
+		t.Errorf("Expected an ignored failure on line 2, got %+v", results[0])
+	}
+	if last := results[3]; last.Command != "wait-for --timeout 5s" || !last.OK || last.Data == nil {
+		t.Errorf("Expected the results of wait-for, got %+v", last)
+	}
+}
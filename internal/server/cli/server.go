@@ -4,13 +4,16 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
 	"github.com/Cl0udRs4/dinot/internal/server/api"
+	"github.com/Cl0udRs4/dinot/internal/server/auth"
 	"github.com/Cl0udRs4/dinot/internal/server/client"
 	"github.com/Cl0udRs4/dinot/internal/server/listener"
 	"github.com/Cl0udRs4/dinot/internal/server/logging"
+	"github.com/Cl0udRs4/dinot/internal/server/task"
 )
 
 // Server represents the C2 server with console interface
@@ -24,12 +27,30 @@ type Server struct {
 	// heartbeatMonitor monitors client heartbeats
 	heartbeatMonitor *client.HeartbeatMonitor
 	
+	// taskManager tracks tasks dispatched to clients
+	taskManager *task.TaskManager
+	
+	// scheduler runs scheduled, recurring and on-register tasks
+	scheduler *task.Scheduler
+	
+	// userStore holds the API operator accounts
+	userStore *auth.UserStore
+	
+	// apiKeyStore holds the API keys used by automation
+	apiKeyStore *auth.APIKeyStore
+	
 	// console is the command-line interface
 	console *Console
 	
+	// teamServer serves the console to remote operators, if enabled
+	teamServer *TeamServer
+	
 	// apiHandler is the HTTP API handler
 	apiHandler *api.APIHandler
 	
+	// apiAddress is the address the API server listens on
+	apiAddress string
+	
 	// logger is the logging system
 	logger logging.Logger
 	
@@ -46,8 +67,73 @@ type Server struct {
 	logAnalyzer *logging.LogAnalyzer
 }
 
-// NewServer creates a new C2 server with console interface
+// Options configures the control API of the server
+type Options struct {
+	// APIAddress is the address the API server listens on
+	APIAddress string
+	
+	// APITLS enables HTTPS for the API; plain HTTP is served if nil
+	APITLS *api.TLSConfig
+	
+	// APIAuth requires API authentication. It is always required when
+	// APIAddress is not a loopback address.
+	APIAuth bool
+	
+	// APIAdmin is the bootstrap admin account, created when there are no
+	// operator accounts
+	APIAdmin string
+	
+	// APIAdminPassword is the password of the bootstrap admin account; a
+	// random password is generated and printed if empty
+	APIAdminPassword string
+	
+	// APIJWT enables token login, refresh and logout on the API
+	APIJWT bool
+	
+	// APIJWTSecretFile is the file the token signing secret is kept in; it
+	// is generated if missing
+	APIJWTSecretFile string
+	
+	// TaskTimeout is the default timeout of tasks; zero means no limit
+	TaskTimeout time.Duration
+	
+	// ModuleTimeouts overrides TaskTimeout for the tasks of some modules
+	ModuleTimeouts map[string]time.Duration
+	
+	// TeamServerAddress is the address the team server serves the console
+	// to remote operators on; the team server is disabled if empty. It is
+	// not started when running a script.
+	TeamServerAddress string
+	
+	// TeamServerTLS configures the team server certificate and operator
+	// client certificates; a self-signed certificate is generated if nil
+	TeamServerTLS *api.TLSConfig
+	
+	// Output is the output format of the console, OutputTable or
+	// OutputJSON; OutputTable if empty
+	Output string
+}
+
+// DefaultOptions returns the default server options: plain HTTP on the
+// loopback interface, an admin bootstrap account, token login and tasks
+// that time out after an hour
+func DefaultOptions() Options {
+	return Options{
+		APIAddress:       "127.0.0.1:8081",
+		APIAdmin:         "admin",
+		APIJWT:           true,
+		APIJWTSecretFile: filepath.Join("data", "jwt_secret"),
+		TaskTimeout:      time.Hour,
+	}
+}
+
+// NewServer creates a new C2 server with console interface and default options
 func NewServer() *Server {
+	return NewServerWithOptions(DefaultOptions())
+}
+
+// NewServerWithOptions creates a new C2 server with console interface
+func NewServerWithOptions(opts Options) *Server {
 	// Initialize logger
 	logger := logging.GetLogger()
 	logger.SetLevel(logging.InfoLevel)
@@ -66,7 +152,26 @@ func NewServer() *Server {
 	}
 	
 	clientManager := client.NewClientManager()
+	
+	// Restore tags and groups from the client registry
+	clientManager.SetRegistryPath(filepath.Join("data", "registry.json"))
+	if err := clientManager.LoadRegistry(); err != nil {
+		fmt.Printf("Warning: Failed to load client registry: %v\n", err)
+	}
+	
 	heartbeatMonitor := client.NewHeartbeatMonitor(clientManager, 30*time.Second, 60*time.Second)
+	taskManager := task.NewTaskManager(clientManager)
+	taskManager.SetDefaultTimeout(opts.TaskTimeout)
+	for module, timeout := range opts.ModuleTimeouts {
+		taskManager.SetModuleTimeout(module, timeout)
+	}
+	
+	// Restore the task schedules
+	scheduler := task.NewScheduler(taskManager)
+	scheduler.SetStorePath(filepath.Join("data", "schedules.json"))
+	if err := scheduler.Load(); err != nil {
+		fmt.Printf("Warning: Failed to load schedules: %v\n", err)
+	}
 	
 	// Create default listener config
 	defaultConfig := listener.Config{
@@ -78,17 +183,47 @@ func NewServer() *Server {
 	
 	listenerManager := listener.NewListenerManager(defaultConfig)
 	
+	// Load the API operator accounts
+	userStore := auth.NewUserStore()
+	userStore.SetStorePath(filepath.Join("data", "users.json"))
+	if err := userStore.Load(); err != nil {
+		fmt.Printf("Warning: Failed to load operator accounts: %v\n", err)
+	}
+	
+	// Load the API keys
+	apiKeyStore := auth.NewAPIKeyStore()
+	apiKeyStore.SetStorePath(filepath.Join("data", "apikeys.json"))
+	if err := apiKeyStore.Load(); err != nil {
+		fmt.Printf("Warning: Failed to load API keys: %v\n", err)
+	}
+	
+	// Load the token signing secret, kept across restarts
+	jwtSecret := ""
+	if opts.APIJWT {
+		secret, err := auth.LoadSecret(opts.APIJWTSecretFile)
+		if err != nil {
+			fmt.Printf("Warning: Failed to load API token secret, token login is disabled: %v\n", err)
+			opts.APIJWT = false
+		}
+		jwtSecret = secret
+	}
+	
 	// Create API handler
 	apiConfig := api.Config{
-		Address:      "127.0.0.1:8081",
-		AuthEnabled:  false,
-		AuthUser:     "",
-		AuthPassword: "",
-		JWTSecret:    "",
-		JWTEnabled:   false,
+		Address:         opts.APIAddress,
+		AuthEnabled:     opts.APIAuth || !api.IsLoopbackAddress(opts.APIAddress),
+		AuthUser:        opts.APIAdmin,
+		AuthPassword:    opts.APIAdminPassword,
+		JWTSecret:       jwtSecret,
+		JWTEnabled:      opts.APIJWT,
+		UserStore:       userStore,
+		APIKeyStore:     apiKeyStore,
+		TLS:             opts.APITLS,
+		Scheduler:       scheduler,
+		ListenerManager: listenerManager,
 	}
 	
-	apiHandler := api.NewAPIHandler(clientManager, heartbeatMonitor, apiConfig)
+	apiHandler := api.NewAPIHandler(clientManager, heartbeatMonitor, taskManager, apiConfig)
 	
 	// Create monitor manager
 	monitorConfig := logging.MonitorConfig{
@@ -126,12 +261,38 @@ func NewServer() *Server {
 	
 	logAnalyzer := logging.NewLogAnalyzer(analyzerConfig)
 	
+	// Create the console, keeping its command history across sessions
+	console := NewConsole(clientManager, heartbeatMonitor, taskManager, scheduler, listenerManager, userStore, apiKeyStore)
+	if err := console.SetHistoryPath(filepath.Join("data", "console_history")); err != nil {
+		fmt.Printf("Warning: Failed to load console history: %v\n", err)
+	}
+	if opts.Output != "" {
+		if err := console.SetOutputFormat(opts.Output); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+	
+	// Serve the console to remote operators if enabled
+	var teamServer *TeamServer
+	if opts.TeamServerAddress != "" {
+		teamServer = NewTeamServer(console, TeamServerConfig{
+			Address: opts.TeamServerAddress,
+			TLS:     opts.TeamServerTLS,
+		})
+	}
+	
 	return &Server{
 		listenerManager:  listenerManager,
 		clientManager:    clientManager,
 		heartbeatMonitor: heartbeatMonitor,
-		console:          NewConsole(clientManager, heartbeatMonitor),
+		taskManager:      taskManager,
+		scheduler:        scheduler,
+		userStore:        userStore,
+		apiKeyStore:      apiKeyStore,
+		console:          console,
+		teamServer:       teamServer,
 		apiHandler:       apiHandler,
+		apiAddress:       opts.APIAddress,
 		logger:           logger,
 		monitorManager:   monitorManager,
 		resourceMonitor:  resourceMonitor,
@@ -142,6 +303,49 @@ func NewServer() *Server {
 
 // Start starts the C2 server and console interface
 func (s *Server) Start() error {
+	s.startServices()
+	
+	// Start the team server
+	if s.teamServer != nil {
+		fingerprint, err := s.teamServer.Start()
+		if err != nil {
+			s.logger.Error("Error starting team server", map[string]interface{}{
+				"error": err.Error(),
+			})
+			fmt.Printf("Error starting team server: %v\n", err)
+		} else {
+			s.logger.Info("Team server started", map[string]interface{}{
+				"address":     s.teamServer.Addr().String(),
+				"fingerprint": fingerprint,
+			})
+			fmt.Printf("Team server certificate SHA-256 fingerprint: %s\n", fingerprint)
+		}
+	}
+	
+	// Start the console interface
+	s.logger.Info("Starting console interface", nil)
+	s.console.Start()
+	
+	return nil
+}
+
+// RunScript starts the C2 server, runs a console script instead of the
+// interactive console, stops the server and returns the script's exit code.
+// A path of "-" reads the script from standard input.
+func (s *Server) RunScript(path string) int {
+	s.startServices()
+	
+	s.logger.Info("Running console script", map[string]interface{}{
+		"script": path,
+	})
+	code := s.console.RunScriptFile(path)
+	
+	s.Stop()
+	return code
+}
+
+// startServices starts the background services and the API server
+func (s *Server) startServices() {
 	// Log server start
 	s.logger.Info("Starting C2 server", map[string]interface{}{
 		"time": time.Now().Format(time.RFC3339),
@@ -151,6 +355,14 @@ func (s *Server) Start() error {
 	s.heartbeatMonitor.Start()
 	s.logger.Info("Heartbeat monitor started", nil)
 	
+	// Start the task deadline monitor
+	s.taskManager.Start()
+	s.logger.Info("Task deadline monitor started", nil)
+	
+	// Start the task scheduler
+	s.scheduler.Start()
+	s.logger.Info("Task scheduler started", nil)
+	
 	// Start the monitor manager
 	s.monitorManager.Start()
 	s.logger.Info("Monitor manager started", nil)
@@ -179,24 +391,16 @@ func (s *Server) Start() error {
 	
 	// Start the API server in a goroutine
 	go func() {
-		apiAddress := "127.0.0.1:8081"
-		s.logger.Info("Starting HTTP API server", map[string]interface{}{
-			"address": apiAddress,
+		s.logger.Info("Starting API server", map[string]interface{}{
+			"address": s.apiAddress,
 		})
-		fmt.Printf("Starting HTTP API server on %s\n", apiAddress)
-		if err := s.apiHandler.Start(apiAddress); err != nil {
+		if err := s.apiHandler.Start(s.apiAddress); err != nil {
 			s.logger.Error("Error starting API server", map[string]interface{}{
 				"error": err.Error(),
 			})
 			fmt.Printf("Error starting API server: %v\n", err)
 		}
 	}()
-	
-	// Start the console interface
-	s.logger.Info("Starting console interface", nil)
-	s.console.Start()
-	
-	return nil
 }
 
 // Stop stops the C2 server and console interface
@@ -207,6 +411,12 @@ func (s *Server) Stop() {
 	s.logger.Info("Stopping console interface", nil)
 	s.console.Stop()
 	
+	// Disconnect the remote operators
+	if s.teamServer != nil {
+		s.logger.Info("Stopping team server", nil)
+		s.teamServer.Stop()
+	}
+	
 	// Stop the resource monitor
 	s.logger.Info("Stopping resource monitor", nil)
 	s.resourceMonitor.Stop()
@@ -219,10 +429,32 @@ func (s *Server) Stop() {
 	s.logger.Info("Stopping heartbeat monitor", nil)
 	s.heartbeatMonitor.Stop()
 	
+	// Stop the task scheduler
+	s.logger.Info("Stopping task scheduler", nil)
+	s.scheduler.Stop()
+	
+	// Stop the task deadline monitor
+	s.logger.Info("Stopping task deadline monitor", nil)
+	s.taskManager.Stop()
+	
 	// Stop all listeners
 	s.logger.Info("Stopping all listeners", nil)
 	s.listenerManager.HaltAll()
 	
+	// Persist the client registry
+	if err := s.clientManager.SaveRegistry(); err != nil {
+		s.logger.Error("Error saving client registry", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	
+	// Persist the API key last-used times
+	if err := s.apiKeyStore.Save(); err != nil {
+		s.logger.Error("Error saving API keys", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	
 	s.logger.Info("C2 server stopped", nil)
 }
 
@@ -236,6 +468,11 @@ func (s *Server) GetHeartbeatMonitor() *client.HeartbeatMonitor {
 	return s.heartbeatMonitor
 }
 
+// GetTaskManager returns the task manager
+func (s *Server) GetTaskManager() *task.TaskManager {
+	return s.taskManager
+}
+
 // GetListenerManager returns the listener manager
 func (s *Server) GetListenerManager() *listener.ListenerManager {
 	return s.listenerManager
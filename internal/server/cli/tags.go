@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Cl0udRs4/dinot/internal/server/client"
+)
+
+// cmdTag implements the tag command
+func (c *Console) cmdTag(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: tag <add|rm|list> [client_id] [tag]")
+	}
+
+	switch args[0] {
+	case "add":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: tag add <client_id> <tag>")
+		}
+
+		if err := c.clientManager.AddClientTag(args[1], args[2]); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out, "Tagged client %s with '%s'\n", args[1], args[2])
+
+	case "rm":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: tag rm <client_id> <tag>")
+		}
+
+		if err := c.clientManager.RemoveClientTag(args[1], args[2]); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out, "Removed tag '%s' from client %s\n", args[2], args[1])
+
+	case "list":
+		if len(args) > 1 {
+			// List the tags of a single client
+			cl, err := c.clientManager.GetClient(args[1])
+			if err != nil {
+				return err
+			}
+
+			tags := cl.GetTags()
+			text := fmt.Sprintf("Client %s has no tags\n", args[1])
+			if len(tags) > 0 {
+				text = fmt.Sprintf("Tags for client %s: %s\n", args[1], strings.Join(tags, ", "))
+			}
+			c.show(tags, Text(text))
+			return nil
+		}
+
+		// List every tag in use
+		tags := c.clientManager.GetAllTags()
+		names := make([]string, 0, len(tags))
+		for name := range tags {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		table := NewTable("Tag", "Clients")
+		table.Empty = "No tags defined"
+		for _, name := range names {
+			table.AddRow(name, fmt.Sprintf("%d", tags[name]))
+		}
+		c.show(tags, table)
+
+	default:
+		return fmt.Errorf("unknown subcommand. Available subcommands: add, rm, list")
+	}
+
+	return nil
+}
+
+// cmdGroup implements the group command
+func (c *Console) cmdGroup(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: group <create|delete|list|show|add|rm> [args...]")
+	}
+
+	switch args[0] {
+	case "create":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: group create <name> [description]")
+		}
+
+		group, err := c.clientManager.CreateGroup(args[1], strings.Join(args[2:], " "))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out, "Created group %s\n", group.Name)
+
+	case "delete":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: group delete <name>")
+		}
+
+		if err := c.clientManager.DeleteGroup(args[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out, "Deleted group %s\n", args[1])
+
+	case "list":
+		groups := c.clientManager.GetAllGroups()
+		table := NewTable("Name", "Members", "Description")
+		table.Empty = "No groups defined"
+		for _, group := range groups {
+			table.AddRow(group.Name, fmt.Sprintf("%d", len(group.ClientIDs)), group.Description)
+		}
+		c.show(groups, table)
+
+	case "show":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: group show <name>")
+		}
+
+		group, err := c.clientManager.GetGroup(args[1])
+		if err != nil {
+			return err
+		}
+
+		title := "Group " + group.Name
+		if group.Description != "" {
+			title += " - " + group.Description
+		}
+		table := NewTable("Client ID", "Status").StatusColumn("Status")
+		table.Title = title
+		table.Empty = "  No members"
+		for _, id := range group.ClientIDs {
+			status := "not registered"
+			if cl, err := c.clientManager.GetClient(id); err == nil {
+				status = string(cl.Status)
+			}
+			table.AddRow(id, status)
+		}
+		c.show(group, table)
+
+	case "add", "rm":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: group %s <name> <client_id>...", args[0])
+		}
+
+		for _, id := range args[2:] {
+			var err error
+			if args[0] == "add" {
+				err = c.clientManager.AddClientToGroup(args[1], id)
+			} else {
+				err = c.clientManager.RemoveClientFromGroup(args[1], id)
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %v", id, err)
+			}
+		}
+		fmt.Fprintf(c.out, "Updated group %s\n", args[1])
+
+	default:
+		return fmt.Errorf("unknown subcommand. Available subcommands: create, delete, list, show, add, rm")
+	}
+
+	return nil
+}
+
+// cmdUnregister implements the unregister command
+func (c *Console) cmdUnregister(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: unregister <client_id|tag:name|group:name>")
+	}
+
+	removed, err := c.clientManager.UnregisterTarget(client.ParseTarget(args[0]))
+	if err != nil {
+		return err
+	}
+
+	for _, id := range removed {
+		fmt.Fprintf(c.out, "Unregistered client %s\n", id)
+	}
+	fmt.Fprintf(c.out, "\nTotal: %d clients\n", len(removed))
+	return nil
+}
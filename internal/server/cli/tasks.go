@@ -0,0 +1,261 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Cl0udRs4/dinot/internal/server/client"
+	"github.com/Cl0udRs4/dinot/internal/server/task"
+)
+
+// cmdTask implements the task command
+func (c *Console) cmdTask(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: task <client_id|tag:name|group:name> <module> [json_params]")
+	}
+
+	target := client.ParseTarget(args[0])
+	module := args[1]
+
+	var params json.RawMessage
+	if len(args) > 2 {
+		params = json.RawMessage(strings.Join(args[2:], " "))
+	}
+
+	tasks, err := c.taskManager.CreateTasks(target, module, params)
+	if err != nil {
+		return err
+	}
+
+	var text strings.Builder
+	taskIDs := make([]string, 0, len(tasks))
+	for _, t := range tasks {
+		fmt.Fprintf(&text, "Created task %s for client %s\n", t.ID, t.ClientID)
+		taskIDs = append(taskIDs, t.ID)
+	}
+	fmt.Fprintf(&text, "\nTotal: %d tasks\n", len(tasks))
+	c.show(taskSnapshots(tasks), Text(text.String()))
+	c.recordTasks(taskIDs)
+	return nil
+}
+
+// cmdTasks implements the tasks command
+func (c *Console) cmdTasks(args []string) error {
+	var tasks []*task.Task
+	switch {
+	case len(args) > 0:
+		tasks = c.taskManager.GetClientTasks(args[0])
+	case c.target != nil:
+		// Inside a client context, list the tasks of its clients
+		clients, err := c.targetClients()
+		if err != nil {
+			return err
+		}
+		for _, cl := range clients {
+			tasks = append(tasks, c.taskManager.GetClientTasks(cl.ID)...)
+		}
+		sort.Slice(tasks, func(i, j int) bool {
+			return tasks[i].CreatedAt.Before(tasks[j].CreatedAt)
+		})
+	default:
+		tasks = c.taskManager.GetAllTasks()
+	}
+
+	table := NewTable("ID", "Client ID", "Module", "Status", "Created").StatusColumn("Status")
+	table.Empty = "No tasks found"
+	for _, t := range tasks {
+		table.AddRow(t.ID, t.ClientID, t.Module, string(t.GetStatus()), formatAge(t.CreatedAt))
+	}
+	if table.Len() > 0 {
+		table.Footer = fmt.Sprintf("Total: %d tasks", table.Len())
+	}
+	c.show(taskSnapshots(tasks), table)
+	return nil
+}
+
+// taskSnapshots returns JSON snapshots of tasks for the JSON output format
+func taskSnapshots(tasks []*task.Task) []json.RawMessage {
+	snapshots := make([]json.RawMessage, 0, len(tasks))
+	for _, t := range tasks {
+		if data, err := t.ToJSON(); err == nil {
+			snapshots = append(snapshots, data)
+		}
+	}
+	return snapshots
+}
+
+// cmdCancel implements the cancel command
+func (c *Console) cmdCancel(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: cancel <task_id>")
+	}
+
+	t, err := c.taskManager.CancelTask(args[0])
+	if err != nil {
+		return err
+	}
+
+	text := fmt.Sprintf("Cancellation of task %s sent to client %s\n", t.ID, t.ClientID)
+	if t.GetStatus() == task.StatusCancelled {
+		text = fmt.Sprintf("Cancelled task %s\n", t.ID)
+	}
+	data, err := t.ToJSON()
+	if err != nil {
+		return err
+	}
+	c.show(json.RawMessage(data), Text(text))
+	return nil
+}
+
+// cmdTimeout implements the timeout command. Setting a timeout shows the
+// resulting settings in the JSON output format.
+func (c *Console) cmdTimeout(args []string) error {
+	switch len(args) {
+	case 0:
+		var text strings.Builder
+		fmt.Fprintf(&text, "Default: %s\n", formatTimeout(c.taskManager.DefaultTimeout()))
+		for _, timeout := range c.taskManager.ModuleTimeouts() {
+			fmt.Fprintf(&text, "  %s: %s\n", timeout.Module, formatTimeout(time.Duration(timeout.Timeout)*time.Second))
+		}
+		c.show(c.timeoutSettings(), Text(text.String()))
+		return nil
+
+	case 2:
+		seconds, err := strconv.Atoi(args[1])
+		if err != nil || seconds < 0 {
+			return fmt.Errorf("invalid timeout: %s", args[1])
+		}
+		timeout := time.Duration(seconds) * time.Second
+
+		if args[0] == "default" {
+			c.taskManager.SetDefaultTimeout(timeout)
+			c.show(c.timeoutSettings(), Text(fmt.Sprintf("Default task timeout set to %s\n", formatTimeout(timeout))))
+			return nil
+		}
+
+		if err := c.taskManager.SetModuleTimeout(args[0], timeout); err != nil {
+			return err
+		}
+		text := fmt.Sprintf("Task timeout of module %s set to %s\n", args[0], formatTimeout(timeout))
+		if timeout == 0 {
+			text = fmt.Sprintf("Tasks of module %s use the default timeout\n", args[0])
+		}
+		c.show(c.timeoutSettings(), Text(text))
+		return nil
+
+	default:
+		return fmt.Errorf("usage: timeout [default|<module>] [seconds]")
+	}
+}
+
+// timeoutSettings returns the task timeouts in seconds for the JSON output
+// format
+func (c *Console) timeoutSettings() map[string]interface{} {
+	return map[string]interface{}{
+		"default": int(c.taskManager.DefaultTimeout() / time.Second),
+		"modules": c.taskManager.ModuleTimeouts(),
+	}
+}
+
+// formatTimeout formats a task timeout, where zero means no limit
+func formatTimeout(timeout time.Duration) string {
+	if timeout <= 0 {
+		return "none"
+	}
+	return timeout.String()
+}
+
+// cmdBatch implements the batch command
+func (c *Console) cmdBatch(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: batch <run|list|show|results> [args...]")
+	}
+
+	switch args[0] {
+	case "run":
+		rest, params := splitParams(args[1:])
+		if len(rest) < 2 {
+			return fmt.Errorf("usage: batch run <module> <ids,...|tag:name|group:name|filter:expr> [-- json_params]")
+		}
+
+		selector := client.ParseTarget(strings.Join(rest[1:], " "))
+		batch, err := c.taskManager.CreateBatch(selector, rest[0], params)
+		if err != nil {
+			return err
+		}
+		c.show(batch, Text(fmt.Sprintf("Created batch %s with %d tasks\n", batch.ID, len(batch.TaskIDs))))
+		c.recordTasks(batch.TaskIDs)
+		c.variables["BATCH"] = batch.ID
+
+	case "list":
+		batches := c.taskManager.GetAllBatches()
+		table := NewTable("ID", "Module", "Status", "OK", "Failed", "Pending", "Selector").StatusColumn("Status")
+		table.Empty = "No batches found"
+		summaries := make([]*task.BatchSummary, 0, len(batches))
+		for _, batch := range batches {
+			summary := c.taskManager.SummarizeBatch(batch, false, false)
+			summaries = append(summaries, summary)
+			table.AddRow(
+				batch.ID,
+				batch.Module,
+				string(summary.Status),
+				fmt.Sprintf("%d", summary.Succeeded),
+				fmt.Sprintf("%d", summary.Failed),
+				fmt.Sprintf("%d", summary.Pending),
+				batch.Selector,
+			)
+		}
+		if table.Len() > 0 {
+			table.Footer = fmt.Sprintf("Total: %d batches", table.Len())
+		}
+		c.show(summaries, table)
+
+	case "show", "results":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: batch %s <batch_id>", args[0])
+		}
+
+		batch, err := c.taskManager.GetBatch(args[1])
+		if err != nil {
+			return err
+		}
+
+		withOutput := args[0] == "results"
+		summary := c.taskManager.SummarizeBatch(batch, true, withOutput)
+		details := NewDetails(fmt.Sprintf("Batch %s: %s on %s", batch.ID, batch.Module, batch.Selector))
+		details.AddStatus("Status", string(summary.Status))
+		details.Add("Tasks", fmt.Sprintf("%d succeeded, %d failed, %d pending of %d",
+			summary.Succeeded, summary.Failed, summary.Pending, summary.Total))
+
+		columns := []string{"Client ID", "Task ID", "Status", "Error"}
+		if withOutput {
+			columns = append(columns, "Result")
+		}
+		table := NewTable(columns...).StatusColumn("Status")
+		for _, result := range summary.Results {
+			table.AddRow(result.ClientID, result.TaskID, string(result.Status), result.Error, string(result.Result))
+		}
+		c.show(summary, details, table)
+
+	default:
+		return fmt.Errorf("unknown subcommand. Available subcommands: run, list, show, results")
+	}
+
+	return nil
+}
+
+// splitParams splits console arguments at "--". Everything after it is the
+// JSON module parameters, so that both the parameters and the arguments
+// before them may contain spaces.
+func splitParams(args []string) ([]string, json.RawMessage) {
+	for i, arg := range args {
+		if arg == "--" {
+			return args[:i], json.RawMessage(strings.Join(args[i+1:], " "))
+		}
+	}
+	return args, nil
+}
@@ -0,0 +1,569 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Cl0udRs4/dinot/internal/server/api"
+	"github.com/Cl0udRs4/dinot/internal/server/auth"
+	"github.com/Cl0udRs4/dinot/internal/server/task"
+)
+
+// Message types of the remote console protocol
+const (
+	// MessageAuth authenticates an operator; it is the first message a
+	// remote console sends
+	MessageAuth = "auth"
+
+	// MessageCommand runs a console command line
+	MessageCommand = "command"
+
+	// MessageComplete asks for the tab completion candidates of a line
+	MessageComplete = "complete"
+
+	// MessageResult answers an auth, command or complete message
+	MessageResult = "result"
+
+	// MessageEvent reports what another operator did
+	MessageEvent = "event"
+
+	// MessageNotify carries output arriving in the background, such as the
+	// results of modules run from a client context
+	MessageNotify = "notify"
+)
+
+// Actions reported by event messages
+const (
+	// ActionConnected is reported when an operator connects
+	ActionConnected = "connected"
+
+	// ActionDisconnected is reported when an operator disconnects
+	ActionDisconnected = "disconnected"
+
+	// ActionCommand is reported when an operator runs a command
+	ActionCommand = "command"
+)
+
+// authTimeout bounds how long a connection may take to authenticate
+const authTimeout = 10 * time.Second
+
+// ErrTeamServerStarted is returned when starting a team server that was
+// already started
+var ErrTeamServerStarted = errors.New("team server already started")
+
+// RemoteMessage is a message of the remote console protocol. Messages are
+// JSON objects sent one per line in both directions over TLS.
+type RemoteMessage struct {
+	// Type is the message type, one of the Message constants
+	Type string `json:"type"`
+
+	// ID matches a result with the command or complete message it answers
+	ID int `json:"id,omitempty"`
+
+	// Username is the operator logging in with an auth message
+	Username string `json:"username,omitempty"`
+
+	// Password is the operator's password; it may be omitted when the
+	// operator presents a client certificate
+	Password string `json:"password,omitempty"`
+
+	// Line is the command line of command, complete and event messages
+	Line string `json:"line,omitempty"`
+
+	// Output is the text printed by a command, or of a notification
+	Output string `json:"output,omitempty"`
+
+	// Error is the error of a failed command or login
+	Error string `json:"error,omitempty"`
+
+	// Prompt is the prompt to show for the next command
+	Prompt string `json:"prompt,omitempty"`
+
+	// Candidates are the completion candidates of a complete message
+	Candidates []string `json:"candidates,omitempty"`
+
+	// Operator is the operator an event is about
+	Operator string `json:"operator,omitempty"`
+
+	// Action is what an event reports, one of the Action constants
+	Action string `json:"action,omitempty"`
+
+	// Closed is set on the result of the command that ended the session
+	Closed bool `json:"closed,omitempty"`
+
+	// Sensitive lists the commands the remote console keeps out of its
+	// history, sent with the result of a login
+	Sensitive []string `json:"sensitive,omitempty"`
+}
+
+// TeamServerConfig configures the team server
+type TeamServerConfig struct {
+	// Address is the address the team server listens on
+	Address string
+
+	// TLS configures the server certificate and operator client
+	// certificates; a self-signed certificate is generated if nil
+	TLS *api.TLSConfig
+}
+
+// TeamServer serves the console to remote operators over TLS. Each
+// operator gets a console session of their own, with its own client
+// context and variables, on the state of the server. Operators see each
+// other's commands in a shared event feed, as does the server's console.
+type TeamServer struct {
+	// console is the server's console; sessions share its managers
+	console *Console
+
+	// config is the team server configuration
+	config TeamServerConfig
+
+	// listener accepts operator connections while running
+	listener net.Listener
+
+	// stopped is set once the team server was stopped
+	stopped bool
+
+	// sessions holds the connected operators
+	sessions map[*session]bool
+
+	// mu protects listener, stopped and sessions
+	mu sync.Mutex
+
+	// wg tracks the accept loop and the connections
+	wg sync.WaitGroup
+}
+
+// session is the connection of a remote operator
+type session struct {
+	// identity is the authenticated operator
+	identity *auth.Identity
+
+	// address is the remote address of the connection
+	address string
+
+	// connectedAt is when the operator logged in
+	connectedAt time.Time
+
+	// conn is the TLS connection
+	conn net.Conn
+
+	// console runs the operator's commands
+	console *Console
+
+	// cancel ends the session's context, interrupting the command it runs
+	cancel context.CancelFunc
+
+	// encoder writes messages to the connection
+	encoder *json.Encoder
+
+	// mu serializes writes to the connection
+	mu sync.Mutex
+}
+
+// NewTeamServer creates a team server for the server's console
+func NewTeamServer(console *Console, config TeamServerConfig) *TeamServer {
+	ts := &TeamServer{
+		console:  console,
+		config:   config,
+		sessions: make(map[*session]bool),
+	}
+	console.teamServer = ts
+	console.taskManager.OnTaskFinished(ts.taskFinished)
+	return ts
+}
+
+// Start starts accepting operators and returns the SHA-256 fingerprint of
+// the team server certificate, which remote consoles can pin
+func (ts *TeamServer) Start() (string, error) {
+	tlsOptions := ts.config.TLS
+	if tlsOptions == nil {
+		tlsOptions = &api.TLSConfig{}
+	}
+
+	tlsConfig, fingerprint, err := api.BuildTLSConfig(tlsOptions, ts.config.Address)
+	if err != nil {
+		return "", err
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.stopped || ts.listener != nil {
+		return "", ErrTeamServerStarted
+	}
+
+	listener, err := tls.Listen("tcp", ts.config.Address, tlsConfig)
+	if err != nil {
+		return "", err
+	}
+	ts.listener = listener
+
+	ts.wg.Add(1)
+	go ts.accept(listener)
+
+	return fingerprint, nil
+}
+
+// Addr returns the address the team server listens on, or nil if it is
+// not running
+func (ts *TeamServer) Addr() net.Addr {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.listener == nil {
+		return nil
+	}
+	return ts.listener.Addr()
+}
+
+// Stop disconnects every operator and stops accepting new ones
+func (ts *TeamServer) Stop() {
+	ts.mu.Lock()
+	ts.stopped = true
+	if ts.listener != nil {
+		ts.listener.Close()
+		ts.listener = nil
+	}
+	for s := range ts.sessions {
+		s.cancel()
+		s.conn.Close()
+	}
+	ts.mu.Unlock()
+
+	ts.wg.Wait()
+}
+
+// accept accepts operator connections until the listener is closed
+func (ts *TeamServer) accept(listener net.Listener) {
+	defer ts.wg.Done()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		ts.wg.Add(1)
+		go func() {
+			defer ts.wg.Done()
+			ts.serve(conn)
+		}()
+	}
+}
+
+// serve authenticates an operator and runs their commands until they exit
+// or disconnect
+func (ts *TeamServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	s := &session{
+		address: conn.RemoteAddr().String(),
+		conn:    conn,
+		encoder: json.NewEncoder(conn),
+	}
+	decoder := json.NewDecoder(conn)
+
+	conn.SetDeadline(time.Now().Add(authTimeout))
+	identity, err := ts.authenticate(conn, decoder)
+	if err != nil {
+		s.send(RemoteMessage{Type: MessageResult, Error: err.Error()})
+		return
+	}
+	conn.SetDeadline(time.Time{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.identity = identity
+	s.connectedAt = time.Now()
+	s.cancel = cancel
+	s.console = ts.console.session(ctx, identity, s.notify)
+
+	ts.mu.Lock()
+	if ts.stopped {
+		ts.mu.Unlock()
+		return
+	}
+	ts.sessions[s] = true
+	operators := len(ts.sessions)
+	ts.mu.Unlock()
+
+	defer func() {
+		ts.mu.Lock()
+		delete(ts.sessions, s)
+		ts.mu.Unlock()
+		ts.broadcast(s, RemoteMessage{Type: MessageEvent, Operator: identity.Username, Action: ActionDisconnected})
+	}()
+
+	s.send(RemoteMessage{
+		Type:      MessageResult,
+		Output:    fmt.Sprintf("Logged in as %s (%s), operators connected: %d. Type 'help' for available commands\n", identity.Username, identity.Role, operators),
+		Prompt:    s.console.prompt(),
+		Sensitive: s.console.sensitiveCommands(),
+	})
+	ts.broadcast(s, RemoteMessage{Type: MessageEvent, Operator: identity.Username, Action: ActionConnected, Output: s.address})
+
+	// Messages are read in the background so that a lost connection
+	// interrupts the command being run, such as a wait-for
+	messages := make(chan RemoteMessage)
+	ts.wg.Add(1)
+	go func() {
+		defer ts.wg.Done()
+		defer close(messages)
+		defer cancel()
+
+		for {
+			var msg RemoteMessage
+			if err := decoder.Decode(&msg); err != nil {
+				return
+			}
+			select {
+			case messages <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for msg := range messages {
+		switch msg.Type {
+		case MessageCommand:
+			if !ts.runCommand(s, msg) {
+				return
+			}
+		case MessageComplete:
+			s.send(RemoteMessage{Type: MessageResult, ID: msg.ID, Candidates: s.console.completions(msg.Line)})
+		default:
+			s.send(RemoteMessage{Type: MessageResult, ID: msg.ID, Error: fmt.Sprintf("unknown message type %q", msg.Type)})
+		}
+	}
+}
+
+// authenticate reads the auth message of a connection. A verified client
+// certificate naming an operator logs them in without a password.
+func (ts *TeamServer) authenticate(conn net.Conn, decoder *json.Decoder) (*auth.Identity, error) {
+	var msg RemoteMessage
+	if err := decoder.Decode(&msg); err != nil || msg.Type != MessageAuth {
+		return nil, errors.New("expected an auth message")
+	}
+
+	if tlsConn, ok := conn.(*tls.Conn); ok && msg.Password == "" {
+		state := tlsConn.ConnectionState()
+		identity := api.CertificateIdentity(ts.console.userStore, &state)
+		if identity != nil && (msg.Username == "" || msg.Username == identity.Username) {
+			return identity, nil
+		}
+	}
+
+	return ts.console.userStore.Authenticate(msg.Username, msg.Password)
+}
+
+// runCommand runs a command of a session, answers it and reports it to the
+// other operators. It returns false once the session has ended.
+func (ts *TeamServer) runCommand(s *session, msg RemoteMessage) bool {
+	var output bytes.Buffer
+	s.console.out = &output
+	err := s.console.run(msg.Line, nil)
+
+	reply := RemoteMessage{
+		Type:   MessageResult,
+		ID:     msg.ID,
+		Output: output.String(),
+		Prompt: s.console.prompt(),
+		Closed: !s.console.running,
+	}
+	if err != nil {
+		reply.Error = err.Error()
+	}
+	s.send(reply)
+
+	// The disconnection is reported instead of the command ending a session
+	if line := s.console.eventLine(msg.Line); line != "" && s.console.running {
+		ts.broadcast(s, RemoteMessage{
+			Type:     MessageEvent,
+			Operator: s.identity.Username,
+			Action:   ActionCommand,
+			Line:     line,
+			Error:    reply.Error,
+		})
+	}
+
+	return s.console.running
+}
+
+// broadcast sends an event to every operator but the one it is about, and
+// shows it on the server's console
+func (ts *TeamServer) broadcast(from *session, event RemoteMessage) {
+	ts.mu.Lock()
+	sessions := make([]*session, 0, len(ts.sessions))
+	for s := range ts.sessions {
+		if s != from {
+			sessions = append(sessions, s)
+		}
+	}
+	ts.mu.Unlock()
+
+	for _, s := range sessions {
+		s.send(event)
+	}
+
+	if ts.console.notify != nil {
+		ts.console.notify(FormatEvent(event))
+	}
+}
+
+// taskFinished passes a finished task on to the sessions, which print the
+// results of the tasks they watch
+func (ts *TeamServer) taskFinished(t *task.Task) {
+	ts.mu.Lock()
+	sessions := make([]*session, 0, len(ts.sessions))
+	for s := range ts.sessions {
+		sessions = append(sessions, s)
+	}
+	ts.mu.Unlock()
+
+	for _, s := range sessions {
+		s.console.taskFinished(t)
+	}
+}
+
+// operators returns the connected operators, sorted by username
+func (ts *TeamServer) operators() []*session {
+	ts.mu.Lock()
+	sessions := make([]*session, 0, len(ts.sessions))
+	for s := range ts.sessions {
+		sessions = append(sessions, s)
+	}
+	ts.mu.Unlock()
+
+	sort.Slice(sessions, func(i, j int) bool {
+		if sessions[i].identity.Username != sessions[j].identity.Username {
+			return sessions[i].identity.Username < sessions[j].identity.Username
+		}
+		return sessions[i].connectedAt.Before(sessions[j].connectedAt)
+	})
+	return sessions
+}
+
+// send writes a message to the session, ignoring errors since a broken
+// connection ends the session's read loop anyway
+func (s *session) send(msg RemoteMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.conn.SetWriteDeadline(time.Now().Add(authTimeout))
+	s.encoder.Encode(msg)
+}
+
+// notify sends output arriving in the background to the operator
+func (s *session) notify(text string) {
+	s.send(RemoteMessage{Type: MessageNotify, Output: text})
+}
+
+// FormatEvent formats an event message for display
+func FormatEvent(event RemoteMessage) string {
+	switch event.Action {
+	case ActionConnected:
+		return fmt.Sprintf("* %s connected from %s", event.Operator, event.Output)
+	case ActionDisconnected:
+		return fmt.Sprintf("* %s disconnected", event.Operator)
+	}
+
+	text := fmt.Sprintf("* %s: %s", event.Operator, event.Line)
+	if event.Error != "" {
+		text += " (failed: " + event.Error + ")"
+	}
+	return text
+}
+
+// session creates the console of a remote operator's session. It shares
+// the managers of c but has its own client context and variables, prints
+// background output with notify, and interrupts waiting commands once ctx
+// ends.
+func (c *Console) session(ctx context.Context, identity *auth.Identity, notify func(text string)) *Console {
+	console := &Console{
+		clientManager:    c.clientManager,
+		heartbeatMonitor: c.heartbeatMonitor,
+		taskManager:      c.taskManager,
+		scheduler:        c.scheduler,
+		listenerManager:  c.listenerManager,
+		userStore:        c.userStore,
+		apiKeyStore:      c.apiKeyStore,
+		commands:         make(map[string]*Command),
+		running:          true,
+		watched:          make(map[string]bool),
+		format:           OutputTable,
+		color:            true,
+		variables:        make(map[string]string),
+		identity:         identity,
+		notify:           notify,
+		ctx:              ctx,
+		teamServer:       c.teamServer,
+	}
+	console.registerCommands()
+
+	return console
+}
+
+// eventLine returns the command line reported to other operators, with the
+// arguments of sensitive commands left out
+func (c *Console) eventLine(line string) string {
+	words := strings.Fields(line)
+	if len(words) == 0 {
+		return ""
+	}
+
+	if cmd, exists := c.commands[words[0]]; exists && cmd.Sensitive {
+		if len(words) > 1 {
+			// Keep the subcommand, e.g. "user passwd ..."
+			return words[0] + " " + words[1] + " ..."
+		}
+		return words[0]
+	}
+	return strings.Join(words, " ")
+}
+
+// sensitiveCommands returns the names of the sensitive commands, sorted
+func (c *Console) sensitiveCommands() []string {
+	var names []string
+	for name, cmd := range c.commands {
+		if cmd.Sensitive {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// cmdOperators implements the operators command
+func (c *Console) cmdOperators(args []string) error {
+	if c.teamServer == nil {
+		return errors.New("the team server is not running")
+	}
+
+	sessions := c.teamServer.operators()
+	table := NewTable("Operator", "Role", "Address", "Connected")
+	table.Empty = "No operators connected"
+	operators := make([]map[string]interface{}, 0, len(sessions))
+	for _, s := range sessions {
+		table.AddRow(s.identity.Username, string(s.identity.Role), s.address, formatAge(s.connectedAt))
+		operators = append(operators, map[string]interface{}{
+			"username":     s.identity.Username,
+			"role":         s.identity.Role,
+			"address":      s.address,
+			"connected_at": s.connectedAt,
+		})
+	}
+	c.show(operators, table)
+
+	return nil
+}
@@ -0,0 +1,304 @@
+package cli
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Cl0udRs4/dinot/internal/server/api"
+	"github.com/Cl0udRs4/dinot/internal/server/auth"
+)
+
+// remoteConn is the connection of a test operator to a team server
+type remoteConn struct {
+	t       *testing.T
+	conn    *tls.Conn
+	encoder *json.Encoder
+	decoder *json.Decoder
+	id      int
+}
+
+// startTeamServer starts a team server on a local port with an admin,
+// an operator and a viewer account
+func startTeamServer(t *testing.T, config *api.TLSConfig) (*TeamServer, *Console) {
+	console, _ := newTestConsole()
+	console.notify = func(string) {}
+	console.userStore.CreateUser("admin", "admin-password", auth.RoleAdmin)
+	console.userStore.CreateUser("alice", "alice-password", auth.RoleOperator)
+	console.userStore.CreateUser("eve", "eve-password", auth.RoleViewer)
+
+	ts := NewTeamServer(console, TeamServerConfig{Address: "127.0.0.1:0", TLS: config})
+	if _, err := ts.Start(); err != nil {
+		t.Fatalf("Failed to start team server: %v", err)
+	}
+	t.Cleanup(ts.Stop)
+
+	return ts, console
+}
+
+// dial connects to a team server, presenting cert if it is set
+func dial(t *testing.T, ts *TeamServer, cert *tls.Certificate) *remoteConn {
+	config := &tls.Config{InsecureSkipVerify: true}
+	if cert != nil {
+		config.Certificates = []tls.Certificate{*cert}
+	}
+
+	conn, err := tls.Dial("tcp", ts.Addr().String(), config)
+	if err != nil {
+		t.Fatalf("Failed to connect to team server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	return &remoteConn{t: t, conn: conn, encoder: json.NewEncoder(conn), decoder: json.NewDecoder(conn)}
+}
+
+// login sends the auth message and returns its result
+func (r *remoteConn) login(username, password string) RemoteMessage {
+	r.encoder.Encode(RemoteMessage{Type: MessageAuth, Username: username, Password: password})
+	return r.next(MessageResult)
+}
+
+// run runs a command line and returns its result
+func (r *remoteConn) run(line string) RemoteMessage {
+	r.id++
+	r.encoder.Encode(RemoteMessage{Type: MessageCommand, ID: r.id, Line: line})
+	return r.next(MessageResult)
+}
+
+// next returns the next message of a type, skipping the others
+func (r *remoteConn) next(messageType string) RemoteMessage {
+	for {
+		var msg RemoteMessage
+		if err := r.decoder.Decode(&msg); err != nil {
+			r.t.Fatalf("Failed to read %s message: %v", messageType, err)
+		}
+		if msg.Type == messageType {
+			return msg
+		}
+	}
+}
+
+// nextEvent returns the next event reporting an action
+func (r *remoteConn) nextEvent(action string) RemoteMessage {
+	for {
+		if event := r.next(MessageEvent); event.Action == action {
+			return event
+		}
+	}
+}
+
+func TestTeamServerPasswordLogin(t *testing.T) {
+	ts, _ := startTeamServer(t, nil)
+
+	reply := dial(t, ts, nil).login("alice", "alice-password")
+	if reply.Error != "" || !strings.Contains(reply.Output, "Logged in as alice (operator)") {
+		t.Fatalf("Expected to log in, got %+v", reply)
+	}
+	if strings.Join(reply.Sensitive, ",") != "apikey,user" {
+		t.Errorf("Expected the sensitive commands, got %v", reply.Sensitive)
+	}
+
+	for _, tt := range []struct{ username, password string }{
+		{"alice", "wrong-password"},
+		{"nobody", "alice-password"},
+		{"alice", ""},
+	} {
+		if reply := dial(t, ts, nil).login(tt.username, tt.password); reply.Error == "" {
+			t.Errorf("Expected %s to be refused with password %q", tt.username, tt.password)
+		}
+	}
+
+	// The first message must log in
+	conn := dial(t, ts, nil)
+	conn.encoder.Encode(RemoteMessage{Type: MessageCommand, Line: "list"})
+	if reply := conn.next(MessageResult); reply.Error != "expected an auth message" {
+		t.Errorf("Expected the command to be refused, got %+v", reply)
+	}
+}
+
+func TestTeamServerCertificateLogin(t *testing.T) {
+	// An operator CA and certificates signed by it
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "operator CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, _ := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	caCert, _ := x509.ParseCertificate(caDER)
+
+	operatorCert := func(name string, serial int64) *tls.Certificate {
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}
+		der, _ := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+		return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), 0600)
+
+	ts, _ := startTeamServer(t, &api.TLSConfig{ClientCAFile: caFile})
+	alice := operatorCert("alice", 2)
+
+	// The certificate logs in without a password, with or without a username
+	for _, username := range []string{"", "alice"} {
+		reply := dial(t, ts, alice).login(username, "")
+		if reply.Error != "" || !strings.Contains(reply.Output, "Logged in as alice (operator)") {
+			t.Errorf("Expected the certificate of alice to log in as %q, got %+v", username, reply)
+		}
+	}
+
+	// A password still logs in as the account it belongs to
+	reply := dial(t, ts, alice).login("eve", "eve-password")
+	if reply.Error != "" || !strings.Contains(reply.Output, "Logged in as eve (viewer)") {
+		t.Errorf("Expected the password of eve to log in, got %+v", reply)
+	}
+
+	refused := []struct {
+		name     string
+		cert     *tls.Certificate
+		username string
+	}{
+		{"another operator's name", alice, "admin"},
+		{"unknown account", operatorCert("mallory", 3), ""},
+		{"no certificate", nil, "alice"},
+	}
+	for _, tt := range refused {
+		if reply := dial(t, ts, tt.cert).login(tt.username, ""); reply.Error == "" {
+			t.Errorf("%s: expected the login to be refused, got %+v", tt.name, reply)
+		}
+	}
+}
+
+func TestTeamServerViewerPermissions(t *testing.T) {
+	ts, console := startTeamServer(t, nil)
+
+	viewer := dial(t, ts, nil)
+	if reply := viewer.login("eve", "eve-password"); reply.Error != "" {
+		t.Fatalf("Failed to log in: %s", reply.Error)
+	}
+
+	allowed := []string{"list", "tasks", "info client-1", "use client-1", "modules"}
+	for _, line := range allowed {
+		if reply := viewer.run(line); reply.Error != "" {
+			t.Errorf("%s: expected a viewer to be allowed, got %s", line, reply.Error)
+		}
+	}
+
+	denied := []string{
+		"task client-1 shell {\"command\":\"id\"}",
+		"shell id",
+		"load shell",
+		"tag add client-1 linux",
+		"user add mallory viewer mallory-password",
+		"user list",
+		"listener list",
+		"timeout default 60",
+	}
+	for _, line := range denied {
+		if reply := viewer.run(line); reply.Error != errForbidden.Error() {
+			t.Errorf("%s: expected %q, got %+v", line, errForbidden, reply)
+		}
+	}
+
+	if tasks := console.taskManager.GetAllTasks(); len(tasks) != 0 {
+		t.Errorf("Expected no tasks, got %d", len(tasks))
+	}
+	if _, err := console.userStore.GetUser("mallory"); err == nil {
+		t.Error("Expected the account not to be created")
+	}
+
+	// An operator may run modules
+	operator := dial(t, ts, nil)
+	operator.login("alice", "alice-password")
+	if reply := operator.run("task client-1 shell {\"command\":\"id\"}"); reply.Error != "" {
+		t.Errorf("Expected an operator to create tasks, got %s", reply.Error)
+	}
+}
+
+func TestTeamServerEvents(t *testing.T) {
+	ts, console := startTeamServer(t, nil)
+
+	var mu sync.Mutex
+	var notified []string
+	console.notify = func(text string) {
+		mu.Lock()
+		defer mu.Unlock()
+		notified = append(notified, text)
+	}
+
+	alice := dial(t, ts, nil)
+	alice.login("alice", "alice-password")
+	admin := dial(t, ts, nil)
+	admin.login("admin", "admin-password")
+
+	if event := alice.nextEvent(ActionConnected); event.Operator != "admin" {
+		t.Errorf("Expected admin to connect, got %+v", event)
+	}
+
+	// Other operators see commands, without the arguments of sensitive ones
+	commands := []struct {
+		line  string
+		event string
+	}{
+		{"list", "list"},
+		{"user passwd alice n3w-s3cret-password", "user passwd ..."},
+		{"apikey   create ci operator", "apikey create ..."},
+		{"user", "user"},
+		{"tasks   client-1", "tasks client-1"},
+	}
+	for _, tt := range commands {
+		admin.run(tt.line)
+		event := alice.nextEvent(ActionCommand)
+		if event.Operator != "admin" || event.Line != tt.event {
+			t.Errorf("%s: expected event %q, got %+v", tt.line, tt.event, event)
+		}
+	}
+
+	// The last command failed; the event reports it
+	admin.run("bogus")
+	if event := alice.nextEvent(ActionCommand); event.Error == "" {
+		t.Errorf("Expected the failure to be reported, got %+v", event)
+	}
+
+	admin.run("exit")
+	if event := alice.nextEvent(ActionDisconnected); event.Operator != "admin" {
+		t.Errorf("Expected admin to disconnect, got %+v", event)
+	}
+
+	// The server's console sees the same redacted events
+	mu.Lock()
+	defer mu.Unlock()
+	for _, text := range notified {
+		if strings.Contains(text, "n3w-s3cret-password") || strings.Contains(text, " ci ") {
+			t.Errorf("Expected the arguments of sensitive commands to be left out, got %q", text)
+		}
+	}
+	if len(notified) == 0 {
+		t.Error("Expected the server's console to be notified")
+	}
+}
@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Cl0udRs4/dinot/internal/server/auth"
+)
+
+// cmdUser implements the user command
+func (c *Console) cmdUser(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: user <add|rm|list|passwd|role> [args...]")
+	}
+
+	switch args[0] {
+	case "add":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: user add <username> <admin|operator|viewer> [password]")
+		}
+
+		password, err := c.password(args, 3)
+		if err != nil {
+			return err
+		}
+
+		user, err := c.userStore.CreateUser(args[1], password, auth.Role(args[2]))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out, "Created %s account %s\n", user.Role, user.Username)
+
+	case "rm":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: user rm <username>")
+		}
+
+		if err := c.userStore.DeleteUser(args[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out, "Deleted account %s\n", args[1])
+
+	case "list":
+		users := c.userStore.ListUsers()
+		table := NewTable("Username", "Role", "Created")
+		table.Empty = "No operator accounts"
+		for _, user := range users {
+			table.AddRow(user.Username, string(user.Role), formatAge(user.CreatedAt))
+		}
+		if table.Len() > 0 {
+			table.Footer = fmt.Sprintf("Total: %d accounts", table.Len())
+		}
+		c.show(users, table)
+
+	case "passwd":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: user passwd <username> [new_password]")
+		}
+
+		password, err := c.password(args, 2)
+		if err != nil {
+			return err
+		}
+
+		if err := c.userStore.SetPassword(args[1], password); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out, "Changed password of %s\n", args[1])
+
+	case "role":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: user role <username> <admin|operator|viewer>")
+		}
+
+		if err := c.userStore.SetRole(args[1], auth.Role(args[2])); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out, "Changed role of %s to %s\n", args[1], args[2])
+
+	default:
+		return fmt.Errorf("unknown subcommand. Available subcommands: add, rm, list, passwd, role")
+	}
+
+	return nil
+}
+
+// password returns the password given as args[i], or prompts for it
+// without echo if it is left out. Remote sessions have no terminal to
+// prompt on, so they must pass it.
+func (c *Console) password(args []string, i int) (string, error) {
+	if len(args) > i {
+		return args[i], nil
+	}
+	if c.editor == nil {
+		return "", fmt.Errorf("password is required")
+	}
+	return c.editor.ReadPassword("Password: ")
+}
+
+// cmdAPIKey implements the apikey command
+func (c *Console) cmdAPIKey(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: apikey <create|revoke|list> [args...]")
+	}
+
+	switch args[0] {
+	case "create":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: apikey create <name> <scope,...> [expiry_days]")
+		}
+
+		var scopes []auth.Permission
+		for _, scope := range strings.Split(args[2], ",") {
+			scopes = append(scopes, auth.Permission(strings.TrimSpace(scope)))
+		}
+
+		var expiresAt *time.Time
+		if len(args) > 3 {
+			days, err := strconv.Atoi(args[3])
+			if err != nil || days <= 0 {
+				return fmt.Errorf("invalid expiry: %s", args[3])
+			}
+			t := time.Now().AddDate(0, 0, days)
+			expiresAt = &t
+		}
+
+		key, secret, err := c.apiKeyStore.CreateKey(args[1], "console", scopes, expiresAt)
+		if err != nil {
+			return err
+		}
+		text := fmt.Sprintf("Created API key %s (%s)\nKey: %s\nStore the key now; it cannot be shown again.\n", key.ID, key.Name, secret)
+		c.show(map[string]interface{}{"key": key, "secret": secret}, Text(text))
+
+	case "revoke":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: apikey revoke <id>")
+		}
+
+		if err := c.apiKeyStore.RevokeKey(args[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out, "Revoked API key %s\n", args[1])
+
+	case "list":
+		keys := c.apiKeyStore.ListKeys()
+		table := NewTable("ID", "Name", "Scopes", "Expires", "Last Used")
+		table.Empty = "No API keys"
+		for _, key := range keys {
+			scopes := make([]string, len(key.Scopes))
+			for i, scope := range key.Scopes {
+				scopes[i] = string(scope)
+			}
+			table.AddRow(
+				key.ID,
+				key.Name,
+				strings.Join(scopes, ","),
+				formatOptionalTime(key.ExpiresAt, "never"),
+				formatOptionalTime(key.LastUsedAt, "never"),
+			)
+		}
+		if table.Len() > 0 {
+			table.Footer = fmt.Sprintf("Total: %d keys", table.Len())
+		}
+		c.show(keys, table)
+
+	default:
+		return fmt.Errorf("unknown subcommand. Available subcommands: create, revoke, list")
+	}
+
+	return nil
+}
+
+// formatOptionalTime formats a time that may be unset relative to now
+func formatOptionalTime(t *time.Time, unset string) string {
+	if t == nil {
+		return unset
+	}
+	return formatAge(*t)
+}
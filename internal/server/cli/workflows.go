@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Cl0udRs4/dinot/internal/server/client"
+	"github.com/Cl0udRs4/dinot/internal/server/task"
+)
+
+// cmdWorkflow implements the workflow command
+func (c *Console) cmdWorkflow(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: workflow <run|list|show> [args...]")
+	}
+
+	switch args[0] {
+	case "run":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: workflow run <definition.json> <ids,...|tag:name|group:name|filter:expr>")
+		}
+
+		definition, err := loadWorkflowDefinition(args[1])
+		if err != nil {
+			return err
+		}
+
+		selector := client.ParseTarget(strings.Join(args[2:], " "))
+		workflow, err := c.taskManager.CreateWorkflow(selector, definition)
+		if err != nil {
+			return err
+		}
+		c.show(workflow, Text(fmt.Sprintf("Started workflow %s with %d steps on %d clients\n", workflow.ID, len(workflow.Steps), len(workflow.Runs))))
+		c.started = append(c.started, workflow.ID)
+		c.variables["WORKFLOW"] = workflow.ID
+
+	case "list":
+		workflows := c.taskManager.GetAllWorkflows()
+		table := NewTable("ID", "Name", "Status", "Steps", "Clients", "Selector").StatusColumn("Status")
+		table.Empty = "No workflows found"
+		for _, workflow := range workflows {
+			table.AddRow(
+				workflow.ID,
+				workflow.Name,
+				string(workflow.Status),
+				fmt.Sprintf("%d", len(workflow.Steps)),
+				fmt.Sprintf("%d", len(workflow.Runs)),
+				workflow.Selector,
+			)
+		}
+		if table.Len() > 0 {
+			table.Footer = fmt.Sprintf("Total: %d workflows", table.Len())
+		}
+		c.show(workflows, table)
+
+	case "show":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: workflow show <workflow_id>")
+		}
+
+		workflow, err := c.taskManager.GetWorkflow(args[1])
+		if err != nil {
+			return err
+		}
+
+		title := "Workflow " + workflow.ID
+		if workflow.Name != "" {
+			title += fmt.Sprintf(" (%s)", workflow.Name)
+		}
+		details := NewDetails(title)
+		details.Add("Selector", workflow.Selector)
+		details.AddStatus("Status", string(workflow.Status))
+
+		// One table of steps per client
+		views := []view{details}
+		for _, run := range workflow.Runs {
+			table := NewTable("Step", "Status", "Task ID", "Error").StatusColumn("Status")
+			table.Title = fmt.Sprintf("%s: %s", run.ClientID, run.Status)
+			for _, step := range run.Steps {
+				table.AddRow(step.Name, string(step.Status), step.TaskID, step.Error)
+			}
+			views = append(views, table)
+		}
+		c.show(workflow, views...)
+
+	default:
+		return fmt.Errorf("unknown subcommand. Available subcommands: run, list, show")
+	}
+
+	return nil
+}
+
+// loadWorkflowDefinition reads a workflow definition from a JSON file
+func loadWorkflowDefinition(path string) (task.WorkflowDefinition, error) {
+	var definition task.WorkflowDefinition
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return definition, fmt.Errorf("failed to read workflow definition: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &definition); err != nil {
+		return definition, fmt.Errorf("invalid workflow definition: %w", err)
+	}
+
+	return definition, nil
+}
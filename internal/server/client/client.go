@@ -55,12 +55,22 @@ type Client struct {
 	// Protocol is the communication protocol being used by this client
 	Protocol string `json:"protocol"`
 	
+	// Listener is the name of the listener the client connected through
+	Listener string `json:"listener,omitempty"`
+	
 	// HeartbeatInterval is the interval at which this client sends heartbeats
 	HeartbeatInterval time.Duration `json:"heartbeat_interval"`
 	
 	// ErrorMessage contains the last error message if Status is StatusError
 	ErrorMessage string `json:"error_message,omitempty"`
 	
+	// Tags is a list of operator-assigned labels for this client
+	Tags []string `json:"tags"`
+	
+	// StatusHistory records the client's status transitions, oldest first.
+	// It is served on its own by GetClientHistory rather than with the client.
+	StatusHistory []StatusTransition `json:"-"`
+	
 	// mu protects concurrent access to the client data
 	mu sync.RWMutex
 }
@@ -68,7 +78,7 @@ type Client struct {
 // NewClient creates a new client with the given ID and initial data
 func NewClient(id, name, ipAddress, os, arch string, supportedModules []string, protocol string) *Client {
 	now := time.Now()
-	return &Client{
+	client := &Client{
 		ID:                id,
 		Name:              name,
 		IPAddress:         ipAddress,
@@ -81,14 +91,28 @@ func NewClient(id, name, ipAddress, os, arch string, supportedModules []string,
 		ActiveModules:     []string{},
 		Protocol:          protocol,
 		HeartbeatInterval: 60 * time.Second, // Default heartbeat interval
+		Tags:              []string{},
 	}
+	client.recordTransition("", StatusOnline, CauseRegistered, "")
+	return client
 }
 
 // UpdateStatus updates the client's status and last seen time
 func (c *Client) UpdateStatus(status ClientStatus, errorMsg string) {
+	c.UpdateStatusWithCause(status, errorMsg, CauseUnspecified)
+}
+
+// UpdateStatusWithCause updates the client's status and last seen time,
+// recording the transition and its cause in the status history
+func (c *Client) UpdateStatusWithCause(status ClientStatus, errorMsg string, cause StatusCause) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	
+	// Record changes of status, and changes of error while in the error state
+	if c.Status != status || (status == StatusError && c.ErrorMessage != errorMsg) {
+		c.recordTransition(c.Status, status, cause, errorMsg)
+	}
+	
 	c.Status = status
 	c.LastSeen = time.Now()
 	
@@ -107,6 +131,14 @@ func (c *Client) UpdateLastSeen() {
 	c.LastSeen = time.Now()
 }
 
+// SetListener records the name of the listener the client connected through
+func (c *Client) SetListener(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	
+	c.Listener = name
+}
+
 // SetHeartbeatInterval sets the client's heartbeat interval
 func (c *Client) SetHeartbeatInterval(interval time.Duration) {
 	c.mu.Lock()
@@ -175,6 +207,60 @@ func (c *Client) IsModuleActive(module string) bool {
 	return false
 }
 
+// AddTag adds an operator-assigned tag to the client
+func (c *Client) AddTag(tag string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	
+	for _, t := range c.Tags {
+		if t == tag {
+			return false // Already tagged
+		}
+	}
+	
+	c.Tags = append(c.Tags, tag)
+	return true
+}
+
+// RemoveTag removes a tag from the client
+func (c *Client) RemoveTag(tag string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	
+	for i, t := range c.Tags {
+		if t == tag {
+			c.Tags = append(c.Tags[:i], c.Tags[i+1:]...)
+			return true
+		}
+	}
+	
+	return false // Tag was not set
+}
+
+// HasTag checks if the client has a tag
+func (c *Client) HasTag(tag string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	
+	for _, t := range c.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	
+	return false
+}
+
+// GetTags returns a copy of the client's tags
+func (c *Client) GetTags() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	
+	tags := make([]string, len(c.Tags))
+	copy(tags, c.Tags)
+	return tags
+}
+
 // ToJSON converts the client to a JSON string
 func (c *Client) ToJSON() ([]byte, error) {
 	c.mu.RLock()
@@ -0,0 +1,276 @@
+package client
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidTag is returned when a tag is empty or contains unsupported characters
+	ErrInvalidTag = errors.New("invalid tag")
+
+	// ErrInvalidGroupName is returned when a group name is empty or contains unsupported characters
+	ErrInvalidGroupName = errors.New("invalid group name")
+
+	// ErrGroupNotFound is returned when a group with the specified name is not found
+	ErrGroupNotFound = errors.New("group not found")
+
+	// ErrGroupAlreadyExists is returned when trying to create a group with a name that already exists
+	ErrGroupAlreadyExists = errors.New("group already exists")
+)
+
+// ClientGroup represents a named, operator-defined set of clients
+type ClientGroup struct {
+	// Name is the unique name of the group
+	Name string `json:"name"`
+
+	// Description is an optional description of the group
+	Description string `json:"description,omitempty"`
+
+	// ClientIDs is the list of member client IDs
+	ClientIDs []string `json:"client_ids"`
+
+	// CreatedAt is when the group was created
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ValidateLabel checks that a tag or group name only contains letters,
+// digits, '-', '_' and '.'
+func ValidateLabel(label string) bool {
+	if label == "" || len(label) > 64 {
+		return false
+	}
+
+	for _, r := range label {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-' || r == '_' || r == '.':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// AddClientTag adds a tag to a client
+func (m *ClientManager) AddClientTag(clientID, tag string) error {
+	tag = strings.TrimSpace(tag)
+	if !ValidateLabel(tag) {
+		return ErrInvalidTag
+	}
+
+	client, err := m.GetClient(clientID)
+	if err != nil {
+		return err
+	}
+
+	if !client.AddTag(tag) {
+		return nil // Already tagged
+	}
+
+	return m.persist()
+}
+
+// RemoveClientTag removes a tag from a client
+func (m *ClientManager) RemoveClientTag(clientID, tag string) error {
+	client, err := m.GetClient(clientID)
+	if err != nil {
+		return err
+	}
+
+	if !client.RemoveTag(tag) {
+		return nil // Tag was not set
+	}
+
+	return m.persist()
+}
+
+// GetClientsByTag returns a slice of clients carrying the specified tag
+func (m *ClientManager) GetClientsByTag(tag string) []*Client {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	clients := make([]*Client, 0)
+	for _, client := range m.clients {
+		if client.HasTag(tag) {
+			clients = append(clients, client)
+		}
+	}
+
+	return clients
+}
+
+// GetAllTags returns every tag in use along with the number of clients carrying it
+func (m *ClientManager) GetAllTags() map[string]int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tags := make(map[string]int)
+	for _, client := range m.clients {
+		for _, tag := range client.GetTags() {
+			tags[tag]++
+		}
+	}
+
+	return tags
+}
+
+// CreateGroup creates a new, empty client group
+func (m *ClientManager) CreateGroup(name, description string) (*ClientGroup, error) {
+	name = strings.TrimSpace(name)
+	if !ValidateLabel(name) {
+		return nil, ErrInvalidGroupName
+	}
+
+	m.mu.Lock()
+	if _, exists := m.groups[name]; exists {
+		m.mu.Unlock()
+		return nil, ErrGroupAlreadyExists
+	}
+
+	group := &ClientGroup{
+		Name:        name,
+		Description: description,
+		ClientIDs:   []string{},
+		CreatedAt:   time.Now(),
+	}
+	m.groups[name] = group
+	m.mu.Unlock()
+
+	return copyGroup(group), m.persist()
+}
+
+// DeleteGroup deletes a client group; member clients are not affected
+func (m *ClientManager) DeleteGroup(name string) error {
+	m.mu.Lock()
+	if _, exists := m.groups[name]; !exists {
+		m.mu.Unlock()
+		return ErrGroupNotFound
+	}
+
+	delete(m.groups, name)
+	m.mu.Unlock()
+
+	return m.persist()
+}
+
+// GetGroup retrieves a copy of a client group by name
+func (m *ClientManager) GetGroup(name string) (*ClientGroup, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	group, exists := m.groups[name]
+	if !exists {
+		return nil, ErrGroupNotFound
+	}
+
+	return copyGroup(group), nil
+}
+
+// GetAllGroups returns copies of all client groups sorted by name
+func (m *ClientManager) GetAllGroups() []*ClientGroup {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	groups := make([]*ClientGroup, 0, len(m.groups))
+	for _, group := range m.groups {
+		groups = append(groups, copyGroup(group))
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+
+	return groups
+}
+
+// AddClientToGroup adds a registered client to a group
+func (m *ClientManager) AddClientToGroup(name, clientID string) error {
+	m.mu.Lock()
+	group, exists := m.groups[name]
+	if !exists {
+		m.mu.Unlock()
+		return ErrGroupNotFound
+	}
+
+	if _, exists := m.clients[clientID]; !exists {
+		m.mu.Unlock()
+		return ErrClientNotFound
+	}
+
+	for _, id := range group.ClientIDs {
+		if id == clientID {
+			m.mu.Unlock()
+			return nil // Already a member
+		}
+	}
+
+	group.ClientIDs = append(group.ClientIDs, clientID)
+	m.mu.Unlock()
+
+	return m.persist()
+}
+
+// RemoveClientFromGroup removes a client from a group
+func (m *ClientManager) RemoveClientFromGroup(name, clientID string) error {
+	m.mu.Lock()
+	group, exists := m.groups[name]
+	if !exists {
+		m.mu.Unlock()
+		return ErrGroupNotFound
+	}
+
+	removed := false
+	for i, id := range group.ClientIDs {
+		if id == clientID {
+			group.ClientIDs = append(group.ClientIDs[:i], group.ClientIDs[i+1:]...)
+			removed = true
+			break
+		}
+	}
+	m.mu.Unlock()
+
+	if !removed {
+		return ErrClientNotFound
+	}
+
+	return m.persist()
+}
+
+// GetClientsByGroup returns the registered members of a group. Members that
+// are no longer registered are skipped but kept in the group, so they are
+// targeted again once they reconnect.
+func (m *ClientManager) GetClientsByGroup(name string) ([]*Client, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	group, exists := m.groups[name]
+	if !exists {
+		return nil, ErrGroupNotFound
+	}
+
+	clients := make([]*Client, 0, len(group.ClientIDs))
+	for _, id := range group.ClientIDs {
+		if client, exists := m.clients[id]; exists {
+			clients = append(clients, client)
+		}
+	}
+
+	return clients, nil
+}
+
+// copyGroup returns a copy of a group that is safe to hand out to callers
+func copyGroup(group *ClientGroup) *ClientGroup {
+	ids := make([]string, len(group.ClientIDs))
+	copy(ids, group.ClientIDs)
+
+	return &ClientGroup{
+		Name:        group.Name,
+		Description: group.Description,
+		ClientIDs:   ids,
+		CreatedAt:   group.CreatedAt,
+	}
+}
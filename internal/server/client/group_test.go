@@ -0,0 +1,229 @@
+package client
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClientTags(t *testing.T) {
+	manager := NewClientManager()
+	client := NewClient("test-client-1", "Test Client", "192.168.1.100", "linux", "amd64", []string{"shell"}, "tcp")
+	manager.RegisterClient(client)
+
+	if err := manager.AddClientTag("test-client-1", "dmz"); err != nil {
+		t.Fatalf("Failed to add tag: %v", err)
+	}
+
+	// Adding the same tag twice is a no-op
+	if err := manager.AddClientTag("test-client-1", "dmz"); err != nil {
+		t.Fatalf("Failed to add duplicate tag: %v", err)
+	}
+
+	if tags := client.GetTags(); len(tags) != 1 || tags[0] != "dmz" {
+		t.Errorf("Expected tags [dmz], got %v", tags)
+	}
+
+	if err := manager.AddClientTag("test-client-1", "bad tag"); err != ErrInvalidTag {
+		t.Errorf("Expected ErrInvalidTag, got %v", err)
+	}
+
+	if err := manager.AddClientTag("missing", "dmz"); err != ErrClientNotFound {
+		t.Errorf("Expected ErrClientNotFound, got %v", err)
+	}
+
+	if clients := manager.GetClientsByTag("dmz"); len(clients) != 1 {
+		t.Errorf("Expected 1 client tagged dmz, got %d", len(clients))
+	}
+
+	if tags := manager.GetAllTags(); tags["dmz"] != 1 {
+		t.Errorf("Expected dmz count 1, got %d", tags["dmz"])
+	}
+
+	if err := manager.RemoveClientTag("test-client-1", "dmz"); err != nil {
+		t.Fatalf("Failed to remove tag: %v", err)
+	}
+
+	if client.HasTag("dmz") {
+		t.Errorf("Expected tag dmz to be removed")
+	}
+}
+
+func TestClientGroups(t *testing.T) {
+	manager := NewClientManager()
+	manager.RegisterClient(NewClient("test-client-1", "Test Client", "192.168.1.100", "linux", "amd64", []string{"shell"}, "tcp"))
+	manager.RegisterClient(NewClient("test-client-2", "Test Client", "192.168.1.101", "linux", "amd64", []string{"shell"}, "tcp"))
+
+	if _, err := manager.CreateGroup("web", "Web servers"); err != nil {
+		t.Fatalf("Failed to create group: %v", err)
+	}
+
+	if _, err := manager.CreateGroup("web", ""); err != ErrGroupAlreadyExists {
+		t.Errorf("Expected ErrGroupAlreadyExists, got %v", err)
+	}
+
+	if err := manager.AddClientToGroup("web", "test-client-1"); err != nil {
+		t.Fatalf("Failed to add client to group: %v", err)
+	}
+
+	if err := manager.AddClientToGroup("web", "missing"); err != ErrClientNotFound {
+		t.Errorf("Expected ErrClientNotFound, got %v", err)
+	}
+
+	if err := manager.AddClientToGroup("db", "test-client-1"); err != ErrGroupNotFound {
+		t.Errorf("Expected ErrGroupNotFound, got %v", err)
+	}
+
+	clients, err := manager.GetClientsByGroup("web")
+	if err != nil {
+		t.Fatalf("Failed to get group clients: %v", err)
+	}
+	if len(clients) != 1 || clients[0].ID != "test-client-1" {
+		t.Errorf("Expected group to contain test-client-1, got %v", clients)
+	}
+
+	// Unregistered members stay in the group but are not resolved
+	manager.UnregisterClient("test-client-1")
+	clients, _ = manager.GetClientsByGroup("web")
+	if len(clients) != 0 {
+		t.Errorf("Expected no registered members, got %d", len(clients))
+	}
+	group, _ := manager.GetGroup("web")
+	if len(group.ClientIDs) != 1 {
+		t.Errorf("Expected group to keep 1 member, got %d", len(group.ClientIDs))
+	}
+
+	if err := manager.DeleteGroup("web"); err != nil {
+		t.Fatalf("Failed to delete group: %v", err)
+	}
+
+	if len(manager.GetAllGroups()) != 0 {
+		t.Errorf("Expected no groups after delete")
+	}
+}
+
+func TestResolveTarget(t *testing.T) {
+	manager := NewClientManager()
+	manager.RegisterClient(NewClient("test-client-1", "Test Client", "192.168.1.100", "linux", "amd64", []string{"shell"}, "tcp"))
+	manager.RegisterClient(NewClient("test-client-2", "Test Client", "192.168.1.101", "linux", "amd64", []string{"shell"}, "tcp"))
+	manager.AddClientTag("test-client-1", "dmz")
+	manager.AddClientTag("test-client-2", "dmz")
+	manager.CreateGroup("web", "")
+	manager.AddClientToGroup("web", "test-client-2")
+
+	tests := []struct {
+		target string
+		count  int
+	}{
+		{"test-client-1", 1},
+		{"tag:dmz", 2},
+		{"tag:none", 0},
+		{"group:web", 1},
+		{"filter:ip~192.168.1.10", 2},
+		{"test-client-1, test-client-2,test-client-1", 2},
+	}
+
+	for _, tt := range tests {
+		clients, err := manager.ResolveTarget(ParseTarget(tt.target))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.target, err)
+			continue
+		}
+		if len(clients) != tt.count {
+			t.Errorf("%s: expected %d clients, got %d", tt.target, tt.count, len(clients))
+		}
+	}
+
+	if _, err := manager.ResolveTarget(Target{}); err != ErrInvalidTarget {
+		t.Errorf("Expected ErrInvalidTarget, got %v", err)
+	}
+	if _, err := manager.ResolveTarget(Target{Tag: "dmz", Filter: "os=linux"}); err != ErrInvalidTarget {
+		t.Errorf("Expected ErrInvalidTarget, got %v", err)
+	}
+	if _, err := manager.ResolveTarget(ParseTarget("test-client-1,missing")); !errors.Is(err, ErrClientNotFound) {
+		t.Errorf("Expected ErrClientNotFound, got %v", err)
+	}
+
+	count, err := manager.SetTargetHeartbeatInterval(ParseTarget("tag:dmz"), 10*time.Second)
+	if err != nil || count != 2 {
+		t.Errorf("Expected 2 clients updated, got %d (%v)", count, err)
+	}
+
+	removed, err := manager.UnregisterTarget(ParseTarget("tag:dmz"))
+	if err != nil || len(removed) != 2 {
+		t.Errorf("Expected 2 clients unregistered, got %v (%v)", removed, err)
+	}
+
+	if manager.Count() != 0 {
+		t.Errorf("Expected no clients left, got %d", manager.Count())
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"client-1", "client-1"},
+		{"tag:dmz", "tag:dmz"},
+		{"group:web", "group:web"},
+		{"filter:os=linux and tag:dmz", "filter:os=linux and tag:dmz"},
+		{"client-1, client-2", "client-1,client-2"},
+	}
+
+	for _, tt := range tests {
+		target := ParseTarget(tt.input)
+		if err := target.Validate(); err != nil {
+			t.Errorf("%q: unexpected error %v", tt.input, err)
+		}
+		if got := target.String(); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestRegistryPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.json")
+
+	manager := NewClientManager()
+	manager.SetRegistryPath(path)
+	manager.RegisterClient(NewClient("test-client-1", "Test Client", "192.168.1.100", "linux", "amd64", []string{"shell"}, "tcp"))
+	manager.AddClientTag("test-client-1", "dmz")
+	manager.CreateGroup("web", "Web servers")
+	manager.AddClientToGroup("web", "test-client-1")
+
+	restored := NewClientManager()
+	restored.SetRegistryPath(path)
+	if err := restored.LoadRegistry(); err != nil {
+		t.Fatalf("Failed to load registry: %v", err)
+	}
+
+	client, err := restored.GetClient("test-client-1")
+	if err != nil {
+		t.Fatalf("Expected client to be restored: %v", err)
+	}
+	if client.Status != StatusOffline {
+		t.Errorf("Expected restored client to be offline, got %s", client.Status)
+	}
+	if !client.HasTag("dmz") {
+		t.Errorf("Expected restored client to keep tag dmz")
+	}
+	if history := client.GetStatusHistory(); len(history) != 1 || history[0].To != StatusOnline {
+		t.Errorf("Expected restored client to keep its status history, got %+v", history)
+	}
+
+	group, err := restored.GetGroup("web")
+	if err != nil || len(group.ClientIDs) != 1 {
+		t.Errorf("Expected group web with 1 member, got %v (%v)", group, err)
+	}
+
+	// A reconnecting client replaces the offline record and keeps its tags
+	reconnected := NewClient("test-client-1", "Test Client", "192.168.1.100", "linux", "amd64", []string{"shell"}, "tcp")
+	if err := restored.RegisterClient(reconnected); err != nil {
+		t.Fatalf("Failed to re-register client: %v", err)
+	}
+	if !reconnected.HasTag("dmz") {
+		t.Errorf("Expected reconnected client to keep tag dmz")
+	}
+}
@@ -174,7 +174,7 @@ func (m *HeartbeatMonitor) ProcessHeartbeat(clientID string) error {
 	}
 	
 	if client.Status == StatusOffline {
-		client.UpdateStatus(StatusOnline, "")
+		client.UpdateStatusWithCause(StatusOnline, "", CauseHeartbeat)
 	}
 	
 	// Assign a new random interval if enabled
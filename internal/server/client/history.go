@@ -0,0 +1,98 @@
+package client
+
+import (
+	"time"
+)
+
+// StatusCause describes why a client's status changed
+type StatusCause string
+
+const (
+	// CauseUnspecified is used when the caller did not record a cause
+	CauseUnspecified StatusCause = "unspecified"
+	// CauseRegistered indicates the client registered or reconnected
+	CauseRegistered StatusCause = "registered"
+	// CauseHeartbeat indicates a heartbeat was received from the client
+	CauseHeartbeat StatusCause = "heartbeat"
+	// CauseHeartbeatTimeout indicates the client missed its heartbeat deadline
+	CauseHeartbeatTimeout StatusCause = "heartbeat_timeout"
+	// CauseException indicates the client reported an error or critical exception
+	CauseException StatusCause = "exception"
+	// CauseReconnect indicates the server's reconnection handling changed the status
+	CauseReconnect StatusCause = "reconnect"
+	// CauseOperator indicates an operator changed the status via the API or console
+	CauseOperator StatusCause = "operator"
+)
+
+// maxStatusHistory is the number of status transitions kept per client
+const maxStatusHistory = 100
+
+// StatusTransition records a single change of a client's status
+type StatusTransition struct {
+	// Timestamp is when the transition happened
+	Timestamp time.Time `json:"timestamp"`
+
+	// From is the status before the transition; empty for the initial registration
+	From ClientStatus `json:"from,omitempty"`
+
+	// To is the status after the transition
+	To ClientStatus `json:"to"`
+
+	// Cause is why the status changed
+	Cause StatusCause `json:"cause"`
+
+	// Transport is the protocol the client was using at the time
+	Transport string `json:"transport"`
+
+	// Message is the error message or other detail attached to the transition
+	Message string `json:"message,omitempty"`
+}
+
+// recordTransition appends a transition to the history, dropping the oldest
+// entries beyond maxStatusHistory; callers must hold c.mu
+func (c *Client) recordTransition(from, to ClientStatus, cause StatusCause, message string) {
+	c.StatusHistory = append(c.StatusHistory, StatusTransition{
+		Timestamp: time.Now(),
+		From:      from,
+		To:        to,
+		Cause:     cause,
+		Transport: c.Protocol,
+		Message:   message,
+	})
+
+	if overflow := len(c.StatusHistory) - maxStatusHistory; overflow > 0 {
+		c.StatusHistory = append([]StatusTransition{}, c.StatusHistory[overflow:]...)
+	}
+}
+
+// GetStatusHistory returns a copy of the client's status transitions, oldest first
+func (c *Client) GetStatusHistory() []StatusTransition {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	history := make([]StatusTransition, len(c.StatusHistory))
+	copy(history, c.StatusHistory)
+	return history
+}
+
+// prependHistory keeps the history of a previous record of the same client
+// ahead of this client's own transitions
+func (c *Client) prependHistory(previous []StatusTransition) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.StatusHistory = append(append([]StatusTransition{}, previous...), c.StatusHistory...)
+	if overflow := len(c.StatusHistory) - maxStatusHistory; overflow > 0 {
+		c.StatusHistory = c.StatusHistory[overflow:]
+	}
+}
+
+// GetClientHistory returns the status transitions of a client, oldest first
+func (m *ClientManager) GetClientHistory(clientID string) ([]StatusTransition, error) {
+	client, err := m.GetClient(clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.GetStatusHistory(), nil
+}
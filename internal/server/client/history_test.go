@@ -0,0 +1,83 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClientStatusHistory(t *testing.T) {
+	manager := NewClientManager()
+	client := NewClient("test-client-1", "Test Client", "192.168.1.100", "linux", "amd64", []string{"shell"}, "tcp")
+	manager.RegisterClient(client)
+
+	// Registration is the first entry of the timeline
+	history := client.GetStatusHistory()
+	if len(history) != 1 || history[0].Cause != CauseRegistered || history[0].Transport != "tcp" {
+		t.Fatalf("Expected a single registration entry, got %+v", history)
+	}
+
+	// Heartbeat timeout
+	client.LastSeen = time.Now().Add(-time.Hour)
+	manager.CheckOfflineClients(time.Second)
+
+	// Exception
+	manager.ReportException("test-client-1", "disk full", SeverityError, "", "", nil)
+
+	// Operator action
+	manager.UpdateClientStatusWithCause("test-client-1", StatusOnline, "", CauseOperator)
+
+	// Setting the same status again does not add an entry
+	manager.UpdateClientStatusWithCause("test-client-1", StatusOnline, "", CauseOperator)
+
+	history, err := manager.GetClientHistory("test-client-1")
+	if err != nil {
+		t.Fatalf("Failed to get history: %v", err)
+	}
+
+	expected := []struct {
+		from, to ClientStatus
+		cause    StatusCause
+	}{
+		{"", StatusOnline, CauseRegistered},
+		{StatusOnline, StatusOffline, CauseHeartbeatTimeout},
+		{StatusOffline, StatusError, CauseException},
+		{StatusError, StatusOnline, CauseOperator},
+	}
+
+	if len(history) != len(expected) {
+		t.Fatalf("Expected %d transitions, got %d: %+v", len(expected), len(history), history)
+	}
+
+	for i, e := range expected {
+		if history[i].From != e.from || history[i].To != e.to || history[i].Cause != e.cause {
+			t.Errorf("Transition %d: expected %s->%s (%s), got %s->%s (%s)",
+				i, e.from, e.to, e.cause, history[i].From, history[i].To, history[i].Cause)
+		}
+	}
+
+	if history[2].Message != "disk full" {
+		t.Errorf("Expected exception message to be recorded, got %q", history[2].Message)
+	}
+
+	if _, err := manager.GetClientHistory("missing"); err != ErrClientNotFound {
+		t.Errorf("Expected ErrClientNotFound, got %v", err)
+	}
+}
+
+func TestClientStatusHistoryBounded(t *testing.T) {
+	client := NewClient("test-client-1", "Test Client", "192.168.1.100", "linux", "amd64", []string{"shell"}, "tcp")
+
+	for i := 0; i < maxStatusHistory; i++ {
+		client.UpdateStatusWithCause(StatusBusy, "", CauseOperator)
+		client.UpdateStatusWithCause(StatusOnline, "", CauseOperator)
+	}
+
+	history := client.GetStatusHistory()
+	if len(history) != maxStatusHistory {
+		t.Errorf("Expected history to be capped at %d, got %d", maxStatusHistory, len(history))
+	}
+
+	if history[len(history)-1].To != StatusOnline {
+		t.Errorf("Expected the newest transition to be kept")
+	}
+}
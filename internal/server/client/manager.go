@@ -23,7 +23,19 @@ type ClientManager struct {
 	// exceptionManager manages exception reports
 	exceptionManager *ExceptionManager
 	
-	// mu protects concurrent access to the clients map
+	// groups maps group names to operator-defined client groups
+	groups map[string]*ClientGroup
+	
+	// registryPath is the file the registry is persisted to, if any
+	registryPath string
+	
+	// registerHooks are called after a client registers
+	registerHooks []RegisterHook
+	
+	// exceptionHooks are called after an exception is reported
+	exceptionHooks []ExceptionHook
+	
+	// mu protects concurrent access to the clients and groups maps
 	mu sync.RWMutex
 }
 
@@ -32,21 +44,62 @@ func NewClientManager() *ClientManager {
 	return &ClientManager{
 		clients:          make(map[string]*Client),
 		exceptionManager: NewExceptionManager(),
+		groups:           make(map[string]*ClientGroup),
 	}
 }
 
+// RegisterHook is called after a client registers. known is true when the
+// client replaced an offline record of itself, i.e. it reconnected rather
+// than appeared for the first time.
+type RegisterHook func(client *Client, known bool)
+
+// OnRegister adds a hook called after every successful registration
+func (m *ClientManager) OnRegister(hook RegisterHook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	m.registerHooks = append(m.registerHooks, hook)
+}
+
+// ExceptionHook is called after an exception is reported for a client
+type ExceptionHook func(report *ExceptionReport)
+
+// OnException adds a hook called after every exception report
+func (m *ClientManager) OnException(hook ExceptionHook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	m.exceptionHooks = append(m.exceptionHooks, hook)
+}
+
 // RegisterClient registers a new client with the manager
 func (m *ClientManager) RegisterClient(client *Client) error {
 	m.mu.Lock()
-	defer m.mu.Unlock()
 	
-	// Check if a client with this ID already exists
-	if _, exists := m.clients[client.ID]; exists {
-		return ErrClientAlreadyExists
+	// Check if a client with this ID already exists. An offline record
+	// (e.g. one restored from the registry) is replaced by the reconnecting
+	// client, keeping its operator-assigned tags.
+	existing, known := m.clients[client.ID]
+	if known {
+		if existing == client || existing.Status != StatusOffline {
+			m.mu.Unlock()
+			return ErrClientAlreadyExists
+		}
+		for _, tag := range existing.GetTags() {
+			client.AddTag(tag)
+		}
+		client.prependHistory(existing.GetStatusHistory())
 	}
 	
 	// Add the client to the map
 	m.clients[client.ID] = client
+	hooks := m.registerHooks
+	m.mu.Unlock()
+	
+	// Hooks run without the lock so that they can query the manager
+	for _, hook := range hooks {
+		hook(client, known)
+	}
 	return nil
 }
 
@@ -117,6 +170,17 @@ func (m *ClientManager) UpdateClientStatus(clientID string, status ClientStatus,
 	return nil
 }
 
+// UpdateClientStatusWithCause updates the status of a client, recording why it changed
+func (m *ClientManager) UpdateClientStatusWithCause(clientID string, status ClientStatus, errorMsg string, cause StatusCause) error {
+	client, err := m.GetClient(clientID)
+	if err != nil {
+		return err
+	}
+	
+	client.UpdateStatusWithCause(status, errorMsg, cause)
+	return nil
+}
+
 // UpdateClientLastSeen updates the last seen time of a client
 func (m *ClientManager) UpdateClientLastSeen(clientID string) error {
 	client, err := m.GetClient(clientID)
@@ -145,7 +209,7 @@ func (m *ClientManager) CheckOfflineClients(timeout time.Duration) []*Client {
 		
 		// Check if the client has exceeded its heartbeat interval plus timeout
 		if now.Sub(client.LastSeen) > (client.HeartbeatInterval + timeout) {
-			client.UpdateStatus(StatusOffline, "Heartbeat timeout exceeded")
+			client.UpdateStatusWithCause(StatusOffline, "Heartbeat timeout exceeded", CauseHeartbeatTimeout)
 			offlineClients = append(offlineClients, client)
 		}
 	}
@@ -201,7 +265,14 @@ func (m *ClientManager) ReportException(clientID, message string, severity Excep
 	
 	// Update the client's status if the severity is high enough
 	if severity == SeverityError || severity == SeverityCritical {
-		client.UpdateStatus(StatusError, message)
+		client.UpdateStatusWithCause(StatusError, message, CauseException)
+	}
+	
+	m.mu.RLock()
+	hooks := m.exceptionHooks
+	m.mu.RUnlock()
+	for _, hook := range hooks {
+		hook(report)
 	}
 	
 	return report, nil
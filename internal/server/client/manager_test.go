@@ -50,6 +50,34 @@ func TestClientManagerRegisterClient(t *testing.T) {
 	}
 }
 
+func TestClientManagerOnRegister(t *testing.T) {
+	manager := NewClientManager()
+	
+	var calls []bool
+	manager.OnRegister(func(client *Client, known bool) {
+		calls = append(calls, known)
+	})
+	
+	client := NewClient("test-client-1", "Test Client", "192.168.1.100", "linux", "amd64", []string{"shell"}, "tcp")
+	if err := manager.RegisterClient(client); err != nil {
+		t.Fatalf("Failed to register client: %v", err)
+	}
+	
+	// A rejected registration does not call the hooks
+	manager.RegisterClient(client)
+	
+	// A client replacing its own offline record is known
+	manager.UpdateClientStatus(client.ID, StatusOffline, "")
+	reconnected := NewClient("test-client-1", "Test Client", "192.168.1.100", "linux", "amd64", []string{"shell"}, "tcp")
+	if err := manager.RegisterClient(reconnected); err != nil {
+		t.Fatalf("Failed to re-register client: %v", err)
+	}
+	
+	if len(calls) != 2 || calls[0] || !calls[1] {
+		t.Errorf("Expected hook calls [false true], got %v", calls)
+	}
+}
+
 func TestClientManagerUnregisterClient(t *testing.T) {
 	manager := NewClientManager()
 	
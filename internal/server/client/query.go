@@ -0,0 +1,658 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ErrInvalidFilter is returned when a filter expression cannot be parsed
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// ClientQuery selects, orders and projects clients
+type ClientQuery struct {
+	// Filter is a filter expression, e.g. "os=linux and last_seen<10m and tag:dmz"
+	Filter string
+
+	// Sort is the field to sort by; clients are sorted by ID when empty
+	Sort string
+
+	// Desc sorts in descending order
+	Desc bool
+}
+
+// fieldKind describes how a field's values are compared
+type fieldKind int
+
+const (
+	kindString fieldKind = iota
+	kindList
+	kindTime
+	kindDuration
+)
+
+// queryField describes a client field usable in filters, sorting and field selection
+type queryField struct {
+	kind fieldKind
+
+	// get returns the field value; callers must hold the client's read lock
+	get func(c *Client) interface{}
+}
+
+// queryFields maps the JSON field names of Client to their accessors
+var queryFields = map[string]queryField{
+	"id":                 {kindString, func(c *Client) interface{} { return c.ID }},
+	"name":               {kindString, func(c *Client) interface{} { return c.Name }},
+	"ip_address":         {kindString, func(c *Client) interface{} { return c.IPAddress }},
+	"os":                 {kindString, func(c *Client) interface{} { return c.OS }},
+	"architecture":       {kindString, func(c *Client) interface{} { return c.Architecture }},
+	"status":             {kindString, func(c *Client) interface{} { return string(c.Status) }},
+	"protocol":           {kindString, func(c *Client) interface{} { return c.Protocol }},
+	"listener":           {kindString, func(c *Client) interface{} { return c.Listener }},
+	"error_message":      {kindString, func(c *Client) interface{} { return c.ErrorMessage }},
+	"registered_at":      {kindTime, func(c *Client) interface{} { return c.RegisteredAt }},
+	"last_seen":          {kindTime, func(c *Client) interface{} { return c.LastSeen }},
+	"heartbeat_interval": {kindDuration, func(c *Client) interface{} { return c.HeartbeatInterval }},
+	"tags":               {kindList, func(c *Client) interface{} { return copyStrings(c.Tags) }},
+	"supported_modules":  {kindList, func(c *Client) interface{} { return copyStrings(c.SupportedModules) }},
+	"active_modules":     {kindList, func(c *Client) interface{} { return copyStrings(c.ActiveModules) }},
+}
+
+// fieldAliases maps short field names to their JSON names
+var fieldAliases = map[string]string{
+	"ip":         "ip_address",
+	"arch":       "architecture",
+	"tag":        "tags",
+	"module":     "supported_modules",
+	"modules":    "supported_modules",
+	"active":     "active_modules",
+	"heartbeat":  "heartbeat_interval",
+	"registered": "registered_at",
+	"seen":       "last_seen",
+}
+
+// QueryFieldNames returns the names of all queryable client fields, sorted
+func QueryFieldNames() []string {
+	names := make([]string, 0, len(queryFields))
+	for name := range queryFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolveField returns the canonical name and accessor of a field
+func resolveField(name string) (string, queryField, error) {
+	name = strings.ToLower(name)
+	if alias, ok := fieldAliases[name]; ok {
+		name = alias
+	}
+
+	field, ok := queryFields[name]
+	if !ok {
+		return "", queryField{}, fmt.Errorf("%w: unknown field %q", ErrInvalidFilter, name)
+	}
+
+	return name, field, nil
+}
+
+// Filter is a compiled filter expression
+type Filter struct {
+	root filterNode
+}
+
+// filterEnv carries state needed while evaluating a filter
+type filterEnv struct {
+	// groups maps group names to the set of member client IDs
+	groups map[string]map[string]bool
+
+	// now is the reference time for relative time comparisons
+	now time.Time
+}
+
+// filterNode is a node of a compiled filter expression
+type filterNode interface {
+	match(c *Client, env *filterEnv) bool
+}
+
+type andNode struct{ left, right filterNode }
+type orNode struct{ left, right filterNode }
+type notNode struct{ node filterNode }
+type groupNode struct{ name string }
+
+type compareNode struct {
+	field queryField
+	op    string
+	str   string
+	dur   time.Duration
+	at    time.Time
+	isAt  bool
+}
+
+func (n andNode) match(c *Client, env *filterEnv) bool {
+	return n.left.match(c, env) && n.right.match(c, env)
+}
+
+func (n orNode) match(c *Client, env *filterEnv) bool {
+	return n.left.match(c, env) || n.right.match(c, env)
+}
+
+func (n notNode) match(c *Client, env *filterEnv) bool {
+	return !n.node.match(c, env)
+}
+
+func (n groupNode) match(c *Client, env *filterEnv) bool {
+	return env.groups[n.name][c.ID]
+}
+
+func (n compareNode) match(c *Client, env *filterEnv) bool {
+	value := n.field.get(c)
+
+	switch n.field.kind {
+	case kindString:
+		return compareString(value.(string), n.op, n.str)
+
+	case kindList:
+		// "=" and "~" match if any item matches; "!=" and "!~" if none does
+		op, negate := n.op, false
+		if strings.HasPrefix(op, "!") {
+			op, negate = strings.TrimPrefix(op, "!"), true
+		}
+		matched := false
+		for _, item := range value.([]string) {
+			if compareString(item, op, n.str) {
+				matched = true
+				break
+			}
+		}
+		return matched != negate
+
+	case kindTime:
+		t := value.(time.Time)
+		if n.isAt {
+			return compareOrdered(t.Sub(n.at), n.op, 0)
+		}
+		// Durations compare against the age of the timestamp, so
+		// "last_seen<10m" means "seen within the last ten minutes"
+		return compareOrdered(env.now.Sub(t), n.op, n.dur)
+
+	case kindDuration:
+		return compareOrdered(value.(time.Duration), n.op, n.dur)
+	}
+
+	return false
+}
+
+// compareString compares strings case-insensitively; "=" and "!=" accept
+// '*' wildcards and "~" matches substrings
+func compareString(value, op, pattern string) bool {
+	value = strings.ToLower(value)
+	pattern = strings.ToLower(pattern)
+
+	switch op {
+	case "=":
+		ok, err := path.Match(pattern, value)
+		return err == nil && ok
+	case "!=":
+		ok, err := path.Match(pattern, value)
+		return err != nil || !ok
+	case "~":
+		return strings.Contains(value, pattern)
+	case "!~":
+		return !strings.Contains(value, pattern)
+	}
+
+	return false
+}
+
+// compareOrdered compares durations using a comparison operator
+func compareOrdered(value time.Duration, op string, ref time.Duration) bool {
+	switch op {
+	case "=":
+		return value == ref
+	case "!=":
+		return value != ref
+	case "<":
+		return value < ref
+	case "<=":
+		return value <= ref
+	case ">":
+		return value > ref
+	case ">=":
+		return value >= ref
+	}
+
+	return false
+}
+
+// ParseFilter compiles a filter expression. The grammar is:
+//
+//	expr   := and ("or" and)*
+//	and    := unary (["and"] unary)*
+//	unary  := "not" unary | "(" expr ")" | term
+//	term   := field op value | field ":" value
+//	op     := "=" | "!=" | "~" | "!~" | "<" | "<=" | ">" | ">="
+//
+// "field:value" is shorthand for "field=value", so "tag:dmz" selects clients
+// tagged dmz and "group:web" selects members of the web group. Time fields
+// compare against their age ("last_seen<10m") or a quoted RFC 3339 timestamp.
+func ParseFilter(expr string) (*Filter, error) {
+	tokens, err := tokenizeFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokens) == 0 {
+		return &Filter{}, nil
+	}
+
+	p := &filterParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("%w: unexpected %q", ErrInvalidFilter, p.tokens[p.pos].text)
+	}
+
+	return &Filter{root: root}, nil
+}
+
+// Match reports whether a client matches the filter. Group terms never
+// match here; use ClientManager.Query to evaluate them.
+func (f *Filter) Match(c *Client) bool {
+	return f.matchEnv(c, &filterEnv{now: time.Now()})
+}
+
+// matchEnv evaluates the filter against a client under its read lock
+func (f *Filter) matchEnv(c *Client, env *filterEnv) bool {
+	if f == nil || f.root == nil {
+		return true
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return f.root.match(c, env)
+}
+
+// Query returns the clients matching a query, sorted as requested
+func (m *ClientManager) Query(q ClientQuery) ([]*Client, error) {
+	filter, err := ParseFilter(q.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	sortField := "id"
+	if q.Sort != "" {
+		if sortField, _, err = resolveField(q.Sort); err != nil {
+			return nil, err
+		}
+	}
+
+	m.mu.RLock()
+	env := &filterEnv{
+		groups: make(map[string]map[string]bool, len(m.groups)),
+		now:    time.Now(),
+	}
+	for name, group := range m.groups {
+		members := make(map[string]bool, len(group.ClientIDs))
+		for _, id := range group.ClientIDs {
+			members[id] = true
+		}
+		env.groups[name] = members
+	}
+
+	clients := make([]*Client, 0, len(m.clients))
+	for _, client := range m.clients {
+		if filter.matchEnv(client, env) {
+			clients = append(clients, client)
+		}
+	}
+	m.mu.RUnlock()
+
+	SortClients(clients, sortField, q.Desc)
+	return clients, nil
+}
+
+// SortClients sorts clients by a field, falling back to the client ID for ties
+func SortClients(clients []*Client, field string, desc bool) error {
+	name, f, err := resolveField(field)
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[*Client]interface{}, len(clients))
+	for _, c := range clients {
+		c.mu.RLock()
+		keys[c] = f.get(c)
+		c.mu.RUnlock()
+	}
+
+	sort.SliceStable(clients, func(i, j int) bool {
+		cmp := compareValues(keys[clients[i]], keys[clients[j]])
+		if cmp == 0 && name != "id" {
+			cmp = strings.Compare(clients[i].ID, clients[j].ID)
+		}
+		if desc {
+			return cmp > 0
+		}
+		return cmp < 0
+	})
+
+	return nil
+}
+
+// compareValues compares two field values of the same kind
+func compareValues(a, b interface{}) int {
+	switch av := a.(type) {
+	case string:
+		return strings.Compare(strings.ToLower(av), strings.ToLower(b.(string)))
+	case time.Time:
+		return av.Compare(b.(time.Time))
+	case time.Duration:
+		bv := b.(time.Duration)
+		switch {
+		case av < bv:
+			return -1
+		case av > bv:
+			return 1
+		}
+		return 0
+	case []string:
+		return strings.Compare(strings.Join(av, ","), strings.Join(b.([]string), ","))
+	}
+
+	return 0
+}
+
+// SelectFields returns the requested fields of a client keyed by their JSON
+// names. All fields are returned when fields is empty.
+func SelectFields(c *Client, fields []string) (map[string]interface{}, error) {
+	if len(fields) == 0 {
+		fields = QueryFieldNames()
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	selected := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		name, f, err := resolveField(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		selected[name] = f.get(c)
+	}
+
+	return selected, nil
+}
+
+// ParseFieldList splits a comma-separated field list, validating each name
+func ParseFieldList(list string) ([]string, error) {
+	if strings.TrimSpace(list) == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(list, ",")
+	fields := make([]string, 0, len(parts))
+	for _, part := range parts {
+		name, _, err := resolveField(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, name)
+	}
+
+	return fields, nil
+}
+
+// filterToken is a lexical token of a filter expression
+type filterToken struct {
+	text   string
+	op     bool
+	quoted bool
+}
+
+// tokenizeFilter splits a filter expression into words, operators and parentheses
+func tokenizeFilter(expr string) ([]filterToken, error) {
+	tokens := make([]filterToken, 0)
+	runes := []rune(expr)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '(' || r == ')':
+			tokens = append(tokens, filterToken{text: string(r)})
+			i++
+
+		case r == '"' || r == '\'':
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				end++
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("%w: unterminated string", ErrInvalidFilter)
+			}
+			tokens = append(tokens, filterToken{text: string(runes[i+1 : end]), quoted: true})
+			i = end + 1
+
+		case strings.ContainsRune("=!<>~:", r):
+			op := string(r)
+			if i+1 < len(runes) && (runes[i+1] == '=' || (r == '!' && runes[i+1] == '~')) {
+				op += string(runes[i+1])
+			}
+			if op == "!" {
+				return nil, fmt.Errorf("%w: unexpected '!'", ErrInvalidFilter)
+			}
+			tokens = append(tokens, filterToken{text: op, op: true})
+			i += len(op)
+
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune("()\"'=!<>~:", runes[i]) {
+				i++
+			}
+			tokens = append(tokens, filterToken{text: string(runes[start:i])})
+		}
+	}
+
+	return tokens, nil
+}
+
+// filterParser is a recursive descent parser over filter tokens
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+// peek returns the current token, if any
+func (p *filterParser) peek() (filterToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return filterToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+// keyword reports whether the current token is the given keyword
+func (p *filterParser) keyword(word string) bool {
+	tok, ok := p.peek()
+	return ok && !tok.op && !tok.quoted && strings.EqualFold(tok.text, word)
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		tok, ok := p.peek()
+		if !ok || tok.text == ")" || p.keyword("or") {
+			return left, nil
+		}
+
+		// "and" is optional between terms
+		if p.keyword("and") {
+			p.pos++
+		}
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	tok, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("%w: unexpected end of expression", ErrInvalidFilter)
+	}
+
+	if p.keyword("not") {
+		p.pos++
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{node}, nil
+	}
+
+	if tok.text == "(" && !tok.quoted {
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if tok, ok := p.peek(); !ok || tok.text != ")" {
+			return nil, fmt.Errorf("%w: missing ')'", ErrInvalidFilter)
+		}
+		p.pos++
+		return node, nil
+	}
+
+	return p.parseTerm()
+}
+
+func (p *filterParser) parseTerm() (filterNode, error) {
+	if p.pos+3 > len(p.tokens) {
+		return nil, fmt.Errorf("%w: incomplete term", ErrInvalidFilter)
+	}
+
+	fieldTok, opTok, valueTok := p.tokens[p.pos], p.tokens[p.pos+1], p.tokens[p.pos+2]
+	if fieldTok.op || !opTok.op || valueTok.op {
+		return nil, fmt.Errorf("%w: expected <field><op><value> near %q", ErrInvalidFilter, fieldTok.text)
+	}
+	p.pos += 3
+
+	op := opTok.text
+	if op == ":" {
+		op = "="
+	}
+
+	// group:<name> selects members of an operator-defined group
+	if strings.EqualFold(fieldTok.text, "group") {
+		if op != "=" {
+			return nil, fmt.Errorf("%w: group only supports '='", ErrInvalidFilter)
+		}
+		return groupNode{name: valueTok.text}, nil
+	}
+
+	_, field, err := resolveField(fieldTok.text)
+	if err != nil {
+		return nil, err
+	}
+
+	node := compareNode{field: field, op: op, str: valueTok.text}
+
+	switch field.kind {
+	case kindString, kindList:
+		if op != "=" && op != "!=" && op != "~" && op != "!~" {
+			return nil, fmt.Errorf("%w: operator %q not supported for field %q", ErrInvalidFilter, op, fieldTok.text)
+		}
+
+	case kindTime:
+		if op == "~" || op == "!~" {
+			return nil, fmt.Errorf("%w: operator %q not supported for field %q", ErrInvalidFilter, op, fieldTok.text)
+		}
+		if at, err := time.Parse(time.RFC3339, valueTok.text); err == nil {
+			node.at, node.isAt = at, true
+			break
+		}
+		if node.dur, err = parseFilterDuration(valueTok.text); err != nil {
+			return nil, err
+		}
+
+	case kindDuration:
+		if op == "~" || op == "!~" {
+			return nil, fmt.Errorf("%w: operator %q not supported for field %q", ErrInvalidFilter, op, fieldTok.text)
+		}
+		if node.dur, err = parseFilterDuration(valueTok.text); err != nil {
+			return nil, err
+		}
+	}
+
+	return node, nil
+}
+
+// parseFilterDuration parses a Go duration, additionally accepting day ("d")
+// and week ("w") suffixes and bare numbers of seconds
+func parseFilterDuration(s string) (time.Duration, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		return time.Duration(n) * time.Second, nil
+	}
+
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if strings.HasSuffix(s, suffix) {
+			n, err := strconv.ParseFloat(strings.TrimSuffix(s, suffix), 64)
+			if err != nil {
+				break
+			}
+			return time.Duration(n * float64(unit)), nil
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid duration %q", ErrInvalidFilter, s)
+	}
+
+	return d, nil
+}
+
+// copyStrings returns a copy of a string slice
+func copyStrings(values []string) []string {
+	out := make([]string, len(values))
+	copy(out, values)
+	return out
+}
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// setupQueryManager creates a manager with a few varied clients
+func setupQueryManager() *ClientManager {
+	manager := NewClientManager()
+
+	linux := NewClient("client-a", "web-1", "10.0.0.1", "linux", "amd64", []string{"shell", "file"}, "tcp")
+	windows := NewClient("client-b", "desk-1", "10.0.1.7", "windows", "amd64", []string{"shell"}, "ws")
+	stale := NewClient("client-c", "web-2", "10.0.0.2", "linux", "arm64", []string{"shell"}, "dns")
+	stale.LastSeen = time.Now().Add(-2 * time.Hour)
+	stale.Status = StatusOffline
+	linux.SetListener("tcp-internal")
+	stale.SetListener("dns")
+
+	manager.RegisterClient(linux)
+	manager.RegisterClient(windows)
+	manager.RegisterClient(stale)
+	manager.AddClientTag("client-a", "dmz")
+	manager.AddClientTag("client-c", "dmz")
+	manager.CreateGroup("desktops", "")
+	manager.AddClientToGroup("desktops", "client-b")
+
+	return manager
+}
+
+func TestClientQueryFilters(t *testing.T) {
+	manager := setupQueryManager()
+
+	tests := []struct {
+		filter string
+		want   []string
+	}{
+		{"", []string{"client-a", "client-b", "client-c"}},
+		{"os=linux", []string{"client-a", "client-c"}},
+		{"os=LINUX and last_seen<10m", []string{"client-a"}},
+		{"os=linux last_seen<10m tag:dmz", []string{"client-a"}},
+		{"last_seen>1h", []string{"client-c"}},
+		{"tag:dmz or group:desktops", []string{"client-a", "client-b", "client-c"}},
+		{"not tag:dmz", []string{"client-b"}},
+		{"tags!=dmz", []string{"client-b"}},
+		{"name=web-*", []string{"client-a", "client-c"}},
+		{"ip~10.0.1", []string{"client-b"}},
+		{"module:file", []string{"client-a"}},
+		{"(arch=arm64 or protocol=ws) and status!=offline", []string{"client-b"}},
+		{`name="desk-1"`, []string{"client-b"}},
+		{"listener=tcp-internal or listener=dns", []string{"client-a", "client-c"}},
+		{"heartbeat>=60s", []string{"client-a", "client-b", "client-c"}},
+	}
+
+	for _, tt := range tests {
+		clients, err := manager.Query(ClientQuery{Filter: tt.filter})
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.filter, err)
+			continue
+		}
+
+		got := make([]string, 0, len(clients))
+		for _, c := range clients {
+			got = append(got, c.ID)
+		}
+
+		if len(got) != len(tt.want) {
+			t.Errorf("%q: expected %v, got %v", tt.filter, tt.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%q: expected %v, got %v", tt.filter, tt.want, got)
+				break
+			}
+		}
+	}
+}
+
+func TestClientQueryInvalidFilters(t *testing.T) {
+	for _, filter := range []string{
+		"os",
+		"colour=red",
+		"os<linux",
+		"last_seen<soon",
+		"(os=linux",
+		"os=linux or",
+		`name="unterminated`,
+	} {
+		if _, err := ParseFilter(filter); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("%q: expected ErrInvalidFilter, got %v", filter, err)
+		}
+	}
+}
+
+func TestClientQuerySortAndFields(t *testing.T) {
+	manager := setupQueryManager()
+
+	clients, err := manager.Query(ClientQuery{Sort: "last_seen", Desc: true})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if clients[len(clients)-1].ID != "client-c" {
+		t.Errorf("Expected stale client last when sorting by last_seen desc, got %s", clients[len(clients)-1].ID)
+	}
+
+	if _, err := manager.Query(ClientQuery{Sort: "colour"}); err == nil {
+		t.Errorf("Expected error for unknown sort field")
+	}
+
+	fields, err := ParseFieldList("id, os,tag")
+	if err != nil {
+		t.Fatalf("ParseFieldList failed: %v", err)
+	}
+
+	selected, err := SelectFields(clients[0], fields)
+	if err != nil {
+		t.Fatalf("SelectFields failed: %v", err)
+	}
+	if len(selected) != 3 || selected["id"] == nil || selected["tags"] == nil {
+		t.Errorf("Unexpected selected fields: %v", selected)
+	}
+}
@@ -0,0 +1,137 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// registrySnapshot is the on-disk representation of the client registry
+type registrySnapshot struct {
+	// Clients are the known clients, including their tags
+	Clients []json.RawMessage `json:"clients"`
+
+	// Groups are the operator-defined client groups
+	Groups []*ClientGroup `json:"groups"`
+
+	// History holds the status transitions of the clients by client ID
+	History map[string][]StatusTransition `json:"history,omitempty"`
+}
+
+// SetRegistryPath sets the file the registry is persisted to. Tag and group
+// changes are saved automatically once a path is set.
+func (m *ClientManager) SetRegistryPath(path string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.registryPath = path
+}
+
+// SaveRegistry writes the clients and groups to the registry file
+func (m *ClientManager) SaveRegistry() error {
+	m.mu.RLock()
+	path := m.registryPath
+	if path == "" {
+		m.mu.RUnlock()
+		return nil
+	}
+
+	snapshot := registrySnapshot{
+		Clients: make([]json.RawMessage, 0, len(m.clients)),
+		Groups:  make([]*ClientGroup, 0, len(m.groups)),
+		History: make(map[string][]StatusTransition, len(m.clients)),
+	}
+	for _, client := range m.clients {
+		data, err := client.ToJSON()
+		if err != nil {
+			m.mu.RUnlock()
+			return fmt.Errorf("failed to encode client %s: %w", client.ID, err)
+		}
+		snapshot.Clients = append(snapshot.Clients, data)
+		snapshot.History[client.ID] = client.GetStatusHistory()
+	}
+	for _, group := range m.groups {
+		snapshot.Groups = append(snapshot.Groups, copyGroup(group))
+	}
+	m.mu.RUnlock()
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode registry: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create registry directory: %w", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated registry
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write registry: %w", err)
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// LoadRegistry restores clients and groups from the registry file. Restored
+// clients are marked offline until they reconnect. A missing file is not an error.
+func (m *ClientManager) LoadRegistry() error {
+	m.mu.RLock()
+	path := m.registryPath
+	m.mu.RUnlock()
+
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read registry: %w", err)
+	}
+
+	var snapshot registrySnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("failed to decode registry: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, raw := range snapshot.Clients {
+		client := &Client{}
+		if err := client.FromJSON(raw); err != nil {
+			return fmt.Errorf("failed to decode client: %w", err)
+		}
+
+		// Never overwrite a client that is already connected
+		if _, exists := m.clients[client.ID]; exists {
+			continue
+		}
+
+		client.StatusHistory = snapshot.History[client.ID]
+		if client.Tags == nil {
+			client.Tags = []string{}
+		}
+		client.Status = StatusOffline
+		m.clients[client.ID] = client
+	}
+
+	for _, group := range snapshot.Groups {
+		if group.ClientIDs == nil {
+			group.ClientIDs = []string{}
+		}
+		m.groups[group.Name] = group
+	}
+
+	return nil
+}
+
+// persist saves the registry if a registry path is configured
+func (m *ClientManager) persist() error {
+	return m.SaveRegistry()
+}
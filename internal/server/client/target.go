@@ -0,0 +1,168 @@
+package client
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrInvalidTarget is returned when a target does not set exactly one of
+// client ID, client IDs, tag, group or filter
+var ErrInvalidTarget = errors.New("invalid target: specify exactly one of client ID, client IDs, tag, group or filter")
+
+// Target selects the clients an operation applies to. Exactly one of the
+// fields must be set.
+type Target struct {
+	// ClientID selects a single client
+	ClientID string `json:"clientId,omitempty"`
+
+	// ClientIDs selects an explicit list of clients
+	ClientIDs []string `json:"clientIds,omitempty"`
+
+	// Tag selects every client carrying the tag
+	Tag string `json:"tag,omitempty"`
+
+	// Group selects every registered member of the group
+	Group string `json:"group,omitempty"`
+
+	// Filter selects every client matching a filter expression, e.g.
+	// "os=linux and last_seen<10m"
+	Filter string `json:"filter,omitempty"`
+}
+
+// ParseTarget parses a console-style target: "tag:<name>", "group:<name>",
+// "filter:<expression>", a plain client ID or a comma separated list of
+// client IDs
+func ParseTarget(s string) Target {
+	switch {
+	case strings.HasPrefix(s, "tag:"):
+		return Target{Tag: strings.TrimPrefix(s, "tag:")}
+	case strings.HasPrefix(s, "group:"):
+		return Target{Group: strings.TrimPrefix(s, "group:")}
+	case strings.HasPrefix(s, "filter:"):
+		return Target{Filter: strings.TrimPrefix(s, "filter:")}
+	case !strings.Contains(s, ","):
+		return Target{ClientID: s}
+	}
+
+	var target Target
+	for _, id := range strings.Split(s, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			target.ClientIDs = append(target.ClientIDs, id)
+		}
+	}
+	return target
+}
+
+// String returns the console-style representation of the target
+func (t Target) String() string {
+	switch {
+	case t.Tag != "":
+		return "tag:" + t.Tag
+	case t.Group != "":
+		return "group:" + t.Group
+	case t.Filter != "":
+		return "filter:" + t.Filter
+	case len(t.ClientIDs) > 0:
+		return strings.Join(t.ClientIDs, ",")
+	default:
+		return t.ClientID
+	}
+}
+
+// Validate checks that exactly one selector is set
+func (t Target) Validate() error {
+	set := 0
+	if len(t.ClientIDs) > 0 {
+		set++
+	}
+	for _, v := range []string{t.ClientID, t.Tag, t.Group, t.Filter} {
+		if v != "" {
+			set++
+		}
+	}
+
+	if set != 1 {
+		return ErrInvalidTarget
+	}
+
+	return nil
+}
+
+// ResolveTarget returns the clients selected by a target
+func (m *ClientManager) ResolveTarget(target Target) ([]*Client, error) {
+	if err := target.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case target.Tag != "":
+		return m.GetClientsByTag(target.Tag), nil
+	case target.Group != "":
+		return m.GetClientsByGroup(target.Group)
+	case target.Filter != "":
+		return m.Query(ClientQuery{Filter: target.Filter})
+	case len(target.ClientIDs) > 0:
+		return m.resolveClientIDs(target.ClientIDs)
+	default:
+		client, err := m.GetClient(target.ClientID)
+		if err != nil {
+			return nil, err
+		}
+		return []*Client{client}, nil
+	}
+}
+
+// resolveClientIDs returns the listed clients, which must all exist;
+// duplicates are returned once
+func (m *ClientManager) resolveClientIDs(ids []string) ([]*Client, error) {
+	seen := make(map[string]bool, len(ids))
+	clients := make([]*Client, 0, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		client, err := m.GetClient(id)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+
+	return clients, nil
+}
+
+// UnregisterTarget removes every client selected by a target and returns
+// the IDs of the removed clients
+func (m *ClientManager) UnregisterTarget(target Target) ([]string, error) {
+	clients, err := m.ResolveTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	removed := make([]string, 0, len(clients))
+	for _, client := range clients {
+		if err := m.UnregisterClient(client.ID); err == nil {
+			removed = append(removed, client.ID)
+		}
+	}
+
+	return removed, nil
+}
+
+// SetTargetHeartbeatInterval sets the heartbeat interval of every client
+// selected by a target and returns the number of clients updated
+func (m *ClientManager) SetTargetHeartbeatInterval(target Target, interval time.Duration) (int, error) {
+	clients, err := m.ResolveTarget(target)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, client := range clients {
+		client.SetHeartbeatInterval(interval)
+	}
+
+	return len(clients), nil
+}
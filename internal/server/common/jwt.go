@@ -20,6 +20,9 @@ type JWTClaims struct {
     jwt.RegisteredClaims
     Username string `json:"username"`
     Role     string `json:"role"`
+    
+    // TokenType distinguishes access tokens from refresh tokens
+    TokenType string `json:"token_type,omitempty"`
 }
 
 // GenerateJWT generates a new JWT token
@@ -34,6 +37,11 @@ func GenerateJWT(username, role, secret string, expirationTime time.Duration) (s
         Role:     role,
     }
     
+    return SignJWT(&claims, secret)
+}
+
+// SignJWT signs a set of claims with the given secret
+func SignJWT(claims *JWTClaims, secret string) (string, error) {
     token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
     return token.SignedString([]byte(secret))
 }
@@ -41,6 +49,9 @@ func GenerateJWT(username, role, secret string, expirationTime time.Duration) (s
 // ValidateJWT validates a JWT token
 func ValidateJWT(tokenString, secret string) (*JWTClaims, error) {
     token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
+        if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+            return nil, ErrInvalidToken
+        }
         return []byte(secret), nil
     })
     
@@ -2,19 +2,33 @@ package listener
 
 import (
 	"context"
+	"fmt"
+	"net"
+	"regexp"
 	"sync"
 
 	"github.com/Cl0udRs4/dinot/internal/server/common"
 )
 
+// namePattern matches valid listener names, e.g. "tcp" or "tls-443"
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
 // BaseListener provides common functionality for all listeners
 type BaseListener struct {
 	// Protocol is the name of the protocol
 	Protocol string
 	
-	// Config is the listener configuration
+	// Config is the listener configuration. It may be replaced while the
+	// listener is stopped, so goroutines serving the listener work on a
+	// copy taken with GetConfig.
 	Config Config
 	
+	// configMutex protects Config
+	configMutex sync.RWMutex
+	
+	// wg tracks the goroutines serving the listener, which Stop waits for
+	wg sync.WaitGroup
+	
 	// status is the current status of the listener
 	status Status
 	
@@ -55,11 +69,16 @@ func (b *BaseListener) setStatus(status Status) {
 
 // GetConfig returns the current configuration of the listener
 func (b *BaseListener) GetConfig() Config {
+	b.configMutex.RLock()
+	defer b.configMutex.RUnlock()
 	return b.Config
 }
 
 // UpdateConfig updates the listener configuration
 func (b *BaseListener) UpdateConfig(config Config) error {
+	b.configMutex.Lock()
+	defer b.configMutex.Unlock()
+	
 	if b.GetStatus() == StatusRunning {
 		return common.NewServerError(common.ErrListenerAlreadyRunning, "cannot update config while listener is running", nil)
 	}
@@ -72,22 +91,57 @@ func (b *BaseListener) Start(ctx context.Context, handler ConnectionHandler) err
 	return common.NewServerError(common.ErrNotImplemented, "Start method not implemented", nil)
 }
 
-// Stop is a placeholder that should be overridden by specific listeners
+// Stop is a placeholder that should be overridden by specific listeners.
+// It cancels the listener context and waits for the goroutines serving the
+// listener to return.
 func (b *BaseListener) Stop() error {
 	if b.cancel != nil {
 		b.cancel()
 		b.cancel = nil
 	}
+	b.wg.Wait()
 	b.setStatus(StatusStopped)
 	return nil
 }
 
+// spawn runs f in a goroutine that Stop waits for
+func (b *BaseListener) spawn(f func()) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		f()
+	}()
+}
+
 // ValidateConfig validates the listener configuration
 func (b *BaseListener) ValidateConfig() error {
+	b.configMutex.Lock()
+	defer b.configMutex.Unlock()
+	
+	if b.Config.Name == "" {
+		b.Config.Name = b.Protocol
+	} else if !namePattern.MatchString(b.Config.Name) {
+		return common.NewServerError(common.ErrInvalidConfig, fmt.Sprintf("invalid listener name %q", b.Config.Name), nil)
+	}
+	
 	if b.Config.Address == "" {
 		return common.NewServerError(common.ErrInvalidConfig, "address cannot be empty", nil)
 	}
 	
+	// ICMP listens on a host without a port
+	if _, _, err := net.SplitHostPort(b.Config.Address); err != nil && b.Protocol != "icmp" {
+		return common.NewServerError(common.ErrInvalidConfig, fmt.Sprintf("invalid address %q", b.Config.Address), err)
+	}
+	
+	if b.Config.EnableTLS {
+		if !tlsProtocols[b.Protocol] {
+			return common.NewServerError(common.ErrInvalidConfig, fmt.Sprintf("protocol %s does not support TLS", b.Protocol), nil)
+		}
+		if b.Config.TLSCertFile == "" || b.Config.TLSKeyFile == "" {
+			return common.NewServerError(common.ErrInvalidConfig, "TLS requires a certificate and a key file", nil)
+		}
+	}
+	
 	if b.Config.BufferSize <= 0 {
 		b.Config.BufferSize = 4096 // Default buffer size
 	}
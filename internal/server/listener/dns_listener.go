@@ -2,7 +2,9 @@ package listener
 
 import (
 	"context"
+	"fmt"
 	"net"
+	"strings"
 	"sync"
 
 	"github.com/Cl0udRs4/dinot/internal/server/common"
@@ -16,6 +18,11 @@ type DNSListener struct {
 	clientsMtx sync.RWMutex
 	clients    map[string]net.Addr
 	handler    ConnectionHandler
+
+	// domain, ttl and recordTypes are the DNS-specific settings
+	domain      string
+	ttl         uint32
+	recordTypes []string
 }
 
 // DNSConfig extends the base Config with DNS-specific settings
@@ -31,12 +38,82 @@ type DNSConfig struct {
 	RecordTypes []string
 }
 
+// WithDefaults returns the configuration with the default domain, TTL and
+// record types filled in where they are unset
+func (c DNSConfig) WithDefaults() DNSConfig {
+	if c.Domain == "" {
+		c.Domain = "example.com" // Default domain
+	}
+	if c.TTL == 0 {
+		c.TTL = 60 // Default TTL
+	}
+	if len(c.RecordTypes) == 0 {
+		c.RecordTypes = []string{"A", "TXT"}
+	}
+	return c
+}
+
 // NewDNSListener creates a new DNS listener
 func NewDNSListener(config DNSConfig) *DNSListener {
 	return &DNSListener{
 		BaseListener: NewBaseListener("dns", config.Config),
 		clients:      make(map[string]net.Addr),
+		domain:       config.Domain,
+		ttl:          config.TTL,
+		recordTypes:  append([]string(nil), config.RecordTypes...),
+	}
+}
+
+// GetDNSConfig returns the configuration of the listener including the
+// DNS-specific settings
+func (d *DNSListener) GetDNSConfig() DNSConfig {
+	return DNSConfig{
+		Config:      d.GetConfig(),
+		Domain:      d.domain,
+		TTL:         d.ttl,
+		RecordTypes: append([]string(nil), d.recordTypes...),
+	}
+}
+
+// UpdateDNSConfig updates the listener configuration including the
+// DNS-specific settings
+func (d *DNSListener) UpdateDNSConfig(config DNSConfig) error {
+	if err := d.UpdateConfig(config.Config); err != nil {
+		return err
+	}
+	d.domain = config.Domain
+	d.ttl = config.TTL
+	d.recordTypes = append([]string(nil), config.RecordTypes...)
+	return nil
+}
+
+// ValidateConfig validates the common configuration and the DNS-specific
+// settings of the listener
+func (d *DNSListener) ValidateConfig() error {
+	if err := d.BaseListener.ValidateConfig(); err != nil {
+		return err
+	}
+	
+	if _, ok := dns.IsDomainName(d.domain); !ok || d.domain == "" {
+		return common.NewServerError(common.ErrInvalidConfig, fmt.Sprintf("invalid DNS domain %q", d.domain), nil)
+	}
+	
+	if d.ttl == 0 {
+		return common.NewServerError(common.ErrInvalidConfig, "DNS TTL must be positive", nil)
+	}
+	
+	if len(d.recordTypes) == 0 {
+		return common.NewServerError(common.ErrInvalidConfig, "at least one DNS record type is required", nil)
 	}
+	for i, recordType := range d.recordTypes {
+		recordType = strings.ToUpper(recordType)
+		if _, ok := dns.StringToType[recordType]; !ok {
+			return common.NewServerError(common.ErrInvalidConfig, fmt.Sprintf("unknown DNS record type %q", recordType), nil)
+		}
+		d.recordTypes[i] = recordType
+	}
+	
+	return nil
 }
 
 // Start starts the DNS listener
@@ -54,7 +131,7 @@ func (d *DNSListener) Start(ctx context.Context, handler ConnectionHandler) erro
 
 	// Create a new DNS server
 	d.server = &dns.Server{
-		Addr:    d.Config.Address,
+		Addr:    d.GetConfig().Address,
 		Net:     "udp",
 		Handler: dns.HandlerFunc(d.handleDNSRequest),
 	}
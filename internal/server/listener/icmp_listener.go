@@ -34,10 +34,11 @@ func (i *ICMPListener) Start(ctx context.Context, handler ConnectionHandler) err
 	if err := i.ValidateConfig(); err != nil {
 		return err
 	}
+	config := i.GetConfig()
 
 	// Create ICMP connection
 	var err error
-	i.conn, err = net.ListenPacket("ip4:icmp", i.Config.Address)
+	i.conn, err = net.ListenPacket("ip4:icmp", config.Address)
 	if err != nil {
 		i.setStatus(StatusError)
 		return common.NewServerError(common.ErrInvalidConfig, "failed to start ICMP listener", err)
@@ -47,7 +48,7 @@ func (i *ICMPListener) Start(ctx context.Context, handler ConnectionHandler) err
 	i.setStatus(StatusRunning)
 
 	// Start handling ICMP packets in a separate goroutine
-	go i.handlePackets(ctx, handler)
+	i.spawn(func() { i.handlePackets(ctx, handler, config) })
 
 	return nil
 }
@@ -74,20 +75,19 @@ func (i *ICMPListener) Stop() error {
 	return i.BaseListener.Stop()
 }
 // handlePackets handles incoming ICMP packets
-func (i *ICMPListener) handlePackets(ctx context.Context, handler ConnectionHandler) {
+func (i *ICMPListener) handlePackets(ctx context.Context, handler ConnectionHandler, config Config) {
 	// Create a buffer for reading ICMP packets
-	buffer := make([]byte, i.Config.BufferSize)
+	buffer := make([]byte, config.BufferSize)
 
 	// Create a semaphore to limit the number of concurrent packet handlers
-	semaphore := make(chan struct{}, i.Config.MaxConnections)
+	semaphore := make(chan struct{}, config.MaxConnections)
 
 	// Create a goroutine to handle the context cancellation
-	go func() {
+	packetConn := i.conn
+	i.spawn(func() {
 		<-ctx.Done()
-		if i.conn != nil {
-			i.conn.Close()
-		}
-	}()
+		packetConn.Close()
+	})
 
 	for {
 		// Check if the context is done
@@ -100,12 +100,12 @@ func (i *ICMPListener) handlePackets(ctx context.Context, handler ConnectionHand
 		}
 
 		// Set read deadline to allow for context cancellation checks
-		if i.Config.Timeout > 0 {
-			i.conn.SetReadDeadline(time.Now().Add(time.Duration(i.Config.Timeout) * time.Second))
+		if config.Timeout > 0 {
+			packetConn.SetReadDeadline(time.Now().Add(time.Duration(config.Timeout) * time.Second))
 		}
 
 		// Read an ICMP packet
-		n, addr, err := i.conn.ReadFrom(buffer)
+		n, addr, err := packetConn.ReadFrom(buffer)
 		if err != nil {
 			// Check if the error is due to timeout
 			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
@@ -128,8 +128,12 @@ func (i *ICMPListener) handlePackets(ctx context.Context, handler ConnectionHand
 		i.clients[clientKey] = addr
 		i.clientsMtx.Unlock()
 
-		// Acquire a semaphore slot
-		semaphore <- struct{}{}
+		// Acquire a semaphore slot, giving up when the listener stops
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
 
 		// Handle the packet in a separate goroutine
 		go func(data []byte, addr net.Addr) {
@@ -140,9 +144,9 @@ func (i *ICMPListener) handlePackets(ctx context.Context, handler ConnectionHand
 
 			// Create an ICMP connection wrapper to make it compatible with the ConnectionHandler
 			conn := &ICMPConnWrapper{
-				packetConn: i.conn,
+				packetConn: packetConn,
 				remoteAddr: addr,
-				localAddr:  i.conn.LocalAddr(),
+				localAddr:  packetConn.LocalAddr(),
 				buffer:     data,
 			}
 
@@ -8,6 +8,9 @@ import (
 
 // Config defines the common configuration for all listeners
 type Config struct {
+	// Name is the unique name of the listener; it defaults to the protocol
+	Name string
+	
 	// Address is the listening address in format "host:port"
 	Address string
 	
@@ -30,6 +33,15 @@ type Config struct {
 	Timeout int
 }
 
+// Protocols lists the protocols listeners can be created for
+var Protocols = []string{"tcp", "udp", "ws", "icmp", "dns"}
+
+// tlsProtocols lists the protocols that support TLS
+var tlsProtocols = map[string]bool{
+	"tcp": true,
+	"ws":  true,
+}
+
 // Status represents the current status of a listener
 type Status string
 
@@ -47,6 +59,10 @@ const (
 // ConnectionHandler defines the function signature for handling new connections
 type ConnectionHandler func(conn net.Conn)
 
+// NamedConnectionHandler handles a new connection accepted by the named
+// listener of a ListenerManager
+type NamedConnectionHandler func(name string, conn net.Conn)
+
 // Listener defines the interface that all protocol listeners must implement
 type Listener interface {
 	// Start starts the listener with the given context and connection handler
@@ -66,4 +82,85 @@ type Listener interface {
 	
 	// UpdateConfig updates the listener configuration
 	UpdateConfig(config Config) error
+	
+	// ValidateConfig validates the listener configuration and fills in
+	// defaults for unset values
+	ValidateConfig() error
+}
+
+// Info describes a registered listener
+type Info struct {
+	// Name is the unique name of the listener
+	Name string `json:"name"`
+	
+	// Protocol is the protocol of the listener
+	Protocol string `json:"protocol"`
+	
+	// Status is the current status of the listener
+	Status Status `json:"status"`
+	
+	// Address is the listening address
+	Address string `json:"address"`
+	
+	// TLS indicates whether TLS is enabled
+	TLS bool `json:"tls"`
+	
+	// TLSCertFile is the path to the TLS certificate file
+	TLSCertFile string `json:"tls_cert_file,omitempty"`
+	
+	// TLSKeyFile is the path to the TLS key file
+	TLSKeyFile string `json:"tls_key_file,omitempty"`
+	
+	// BufferSize is the size of the buffer for reading data
+	BufferSize int `json:"buffer_size"`
+	
+	// MaxConnections is the maximum number of concurrent connections
+	MaxConnections int `json:"max_connections"`
+	
+	// Timeout is the connection timeout in seconds
+	Timeout int `json:"timeout"`
+	
+	// Domain is the base domain of a DNS listener
+	Domain string `json:"domain,omitempty"`
+	
+	// TTL is the time-to-live of the records of a DNS listener
+	TTL uint32 `json:"ttl,omitempty"`
+	
+	// RecordTypes are the record types a DNS listener supports
+	RecordTypes []string `json:"record_types,omitempty"`
+}
+
+// Name returns the name of a listener, which is its protocol unless the
+// configuration names it
+func Name(listener Listener) string {
+	if name := listener.GetConfig().Name; name != "" {
+		return name
+	}
+	return listener.GetProtocol()
+}
+
+// Describe returns the description of a listener
+func Describe(listener Listener) Info {
+	config := listener.GetConfig()
+	info := Info{
+		Name:           Name(listener),
+		Protocol:       listener.GetProtocol(),
+		Status:         listener.GetStatus(),
+		Address:        config.Address,
+		TLS:            config.EnableTLS,
+		TLSCertFile:    config.TLSCertFile,
+		TLSKeyFile:     config.TLSKeyFile,
+		BufferSize:     config.BufferSize,
+		MaxConnections: config.MaxConnections,
+		Timeout:        config.Timeout,
+	}
+	
+	if dnsListener, ok := listener.(*DNSListener); ok {
+		dnsConfig := dnsListener.GetDNSConfig()
+		info.Domain = dnsConfig.Domain
+		info.TTL = dnsConfig.TTL
+		info.RecordTypes = dnsConfig.RecordTypes
+	}
+	
+	return info
 }
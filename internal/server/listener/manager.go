@@ -3,18 +3,21 @@ package listener
 import (
 	"context"
 	"fmt"
+	"net"
+	"sort"
 	"sync"
 
 	"github.com/Cl0udRs4/dinot/internal/server/common"
 )
 
-// ListenerManager manages multiple protocol listeners
+// ListenerManager manages multiple protocol listeners, keyed by name so
+// that a protocol can have several listeners
 type ListenerManager struct {
 	listeners     map[string]Listener
 	listenersMtx  sync.RWMutex
 	ctx           context.Context
 	cancel        context.CancelFunc
-	handler       ConnectionHandler
+	handler       NamedConnectionHandler
 	defaultConfig Config
 }
 
@@ -29,31 +32,32 @@ func NewListenerManager(defaultConfig Config) *ListenerManager {
 	}
 }
 
-// RegisterListener registers a listener with the manager
+// RegisterListener registers a listener with the manager under its name,
+// see Name
 func (m *ListenerManager) RegisterListener(listener Listener) error {
-	protocol := listener.GetProtocol()
+	name := Name(listener)
 	
 	m.listenersMtx.Lock()
 	defer m.listenersMtx.Unlock()
 	
-	if _, exists := m.listeners[protocol]; exists {
+	if _, exists := m.listeners[name]; exists {
 		return common.NewServerError(common.ErrListenerAlreadyRegistered, 
-			fmt.Sprintf("listener for protocol %s is already registered", protocol), nil)
+			fmt.Sprintf("listener %s is already registered", name), nil)
 	}
 	
-	m.listeners[protocol] = listener
+	m.listeners[name] = listener
 	return nil
 }
 
 // UnregisterListener unregisters a listener from the manager
-func (m *ListenerManager) UnregisterListener(protocol string) error {
+func (m *ListenerManager) UnregisterListener(name string) error {
 	m.listenersMtx.Lock()
 	defer m.listenersMtx.Unlock()
 	
-	listener, exists := m.listeners[protocol]
+	listener, exists := m.listeners[name]
 	if !exists {
 		return common.NewServerError(common.ErrListenerNotRegistered, 
-			fmt.Sprintf("listener for protocol %s is not registered", protocol), nil)
+			fmt.Sprintf("listener %s is not registered", name), nil)
 	}
 	
 	// Stop the listener if it is running
@@ -63,40 +67,43 @@ func (m *ListenerManager) UnregisterListener(protocol string) error {
 		}
 	}
 	
-	delete(m.listeners, protocol)
+	delete(m.listeners, name)
 	return nil
 }
 
-// GetListener gets a listener by protocol
-func (m *ListenerManager) GetListener(protocol string) (Listener, error) {
+// GetListener gets a listener by name
+func (m *ListenerManager) GetListener(name string) (Listener, error) {
 	m.listenersMtx.RLock()
 	defer m.listenersMtx.RUnlock()
 	
-	listener, exists := m.listeners[protocol]
+	listener, exists := m.listeners[name]
 	if !exists {
 		return nil, common.NewServerError(common.ErrListenerNotRegistered, 
-			fmt.Sprintf("listener for protocol %s is not registered", protocol), nil)
+			fmt.Sprintf("listener %s is not registered", name), nil)
 	}
 	
 	return listener, nil
 }
+
 // StartAll starts all registered listeners
 func (m *ListenerManager) StartAll(handler ConnectionHandler) error {
 	m.listenersMtx.Lock()
 	defer m.listenersMtx.Unlock()
 	
 	// Store the connection handler
-	m.handler = handler
+	m.handler = func(name string, conn net.Conn) {
+		handler(conn)
+	}
 	
 	// Start all listeners
-	for protocol, listener := range m.listeners {
+	for name, listener := range m.listeners {
 		if listener.GetStatus() == StatusRunning {
 			continue
 		}
 		
 		if err := listener.Start(m.ctx, handler); err != nil {
 			return common.NewServerError(common.ErrListenerStartFailed, 
-				fmt.Sprintf("failed to start listener for protocol %s", protocol), err)
+				fmt.Sprintf("failed to start listener %s", name), err)
 		}
 	}
 	
@@ -110,13 +117,29 @@ func (m *ListenerManager) GetListeners() map[string]Listener {
 	
 	// Create a copy of the listeners map to avoid concurrent access issues
 	listeners := make(map[string]Listener, len(m.listeners))
-	for protocol, listener := range m.listeners {
-		listeners[protocol] = listener
+	for name, listener := range m.listeners {
+		listeners[name] = listener
 	}
 	
 	return listeners
 }
 
+// DescribeAll returns the descriptions of all registered listeners sorted
+// by name
+func (m *ListenerManager) DescribeAll() []Info {
+	listeners := m.GetListeners()
+	infos := make([]Info, 0, len(listeners))
+	for _, listener := range listeners {
+		infos = append(infos, Describe(listener))
+	}
+	
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	
+	return infos
+}
+
 // HaltAll halts all registered listeners
 func (m *ListenerManager) HaltAll() error {
 	m.listenersMtx.Lock()
@@ -125,14 +148,14 @@ func (m *ListenerManager) HaltAll() error {
 	var lastErr error
 	
 	// Halt all listeners
-	for protocol, listener := range m.listeners {
+	for name, listener := range m.listeners {
 		if listener.GetStatus() != StatusRunning {
 			continue
 		}
 		
 		if err := listener.Stop(); err != nil {
 			lastErr = common.NewServerError(common.ErrListenerStopFailed, 
-				fmt.Sprintf("failed to halt listener for protocol %s", protocol), err)
+				fmt.Sprintf("failed to halt listener %s", name), err)
 		}
 	}
 	
@@ -142,7 +165,10 @@ func (m *ListenerManager) HaltAll() error {
 	return lastErr
 }
 
-// CreateListener creates a new listener for the specified protocol
+// CreateListener creates a new listener for the specified protocol, named
+// by the configuration or else after the protocol. The configuration is
+// validated before the listener is registered; DNS listeners get the
+// default DNS settings, see CreateDNSListener.
 func (m *ListenerManager) CreateListener(protocol string, config Config) (Listener, error) {
 	var listener Listener
 	
@@ -158,18 +184,32 @@ func (m *ListenerManager) CreateListener(protocol string, config Config) (Listen
 		listener = NewICMPListener(config)
 	case "dns":
 		// For DNS, we need to convert the config to DNSConfig
-		dnsConfig := DNSConfig{
-			Config:      config,
-			Domain:      "example.com", // Default domain
-			TTL:         60,            // Default TTL
-			RecordTypes: []string{"A", "TXT"},
-		}
-		listener = NewDNSListener(dnsConfig)
+		return m.CreateDNSListener(DefaultDNSConfig(config))
 	default:
 		return nil, common.NewServerError(common.ErrInvalidConfig, 
 			fmt.Sprintf("unsupported protocol: %s", protocol), nil)
 	}
 	
+	return m.addListener(listener)
+}
+
+// CreateDNSListener creates a new DNS listener with its DNS-specific settings
+func (m *ListenerManager) CreateDNSListener(config DNSConfig) (Listener, error) {
+	return m.addListener(NewDNSListener(config))
+}
+
+// DefaultDNSConfig returns a DNS configuration with the default domain, TTL
+// and record types
+func DefaultDNSConfig(config Config) DNSConfig {
+	return DNSConfig{Config: config}.WithDefaults()
+}
+
+// addListener validates the configuration of a new listener and registers it
+func (m *ListenerManager) addListener(listener Listener) (Listener, error) {
+	if err := listener.ValidateConfig(); err != nil {
+		return nil, err
+	}
+	
 	// Register the listener
 	if err := m.RegisterListener(listener); err != nil {
 		return nil, err
@@ -178,6 +218,117 @@ func (m *ListenerManager) CreateListener(protocol string, config Config) (Listen
 	return listener, nil
 }
 
+// UpdateListener replaces the configuration of a stopped listener; its
+// name cannot change. The DNS settings of a DNS listener are kept. The
+// previous configuration is restored if the new one is invalid.
+func (m *ListenerManager) UpdateListener(name string, config Config) (Listener, error) {
+	listener, err := m.GetListener(name)
+	if err != nil {
+		return nil, err
+	}
+	
+	config.Name = name
+	previous := listener.GetConfig()
+	if err := listener.UpdateConfig(config); err != nil {
+		return nil, err
+	}
+	if err := listener.ValidateConfig(); err != nil {
+		listener.UpdateConfig(previous)
+		return nil, err
+	}
+	
+	return listener, nil
+}
+
+// UpdateDNSListener replaces the configuration and DNS settings of a
+// stopped DNS listener; its name cannot change. The previous configuration
+// is restored if the new one is invalid.
+func (m *ListenerManager) UpdateDNSListener(name string, config DNSConfig) (Listener, error) {
+	listener, err := m.GetListener(name)
+	if err != nil {
+		return nil, err
+	}
+	
+	dnsListener, ok := listener.(*DNSListener)
+	if !ok {
+		return nil, common.NewServerError(common.ErrInvalidConfig, fmt.Sprintf("listener %s is not a DNS listener", name), nil)
+	}
+	
+	config.Name = name
+	previous := dnsListener.GetDNSConfig()
+	if err := dnsListener.UpdateDNSConfig(config); err != nil {
+		return nil, err
+	}
+	if err := dnsListener.ValidateConfig(); err != nil {
+		dnsListener.UpdateDNSConfig(previous)
+		return nil, err
+	}
+	
+	return dnsListener, nil
+}
+
+// SetHandler sets the connection handler of listeners started with
+// StartListener. The handler is told which listener accepted a connection.
+func (m *ListenerManager) SetHandler(handler NamedConnectionHandler) {
+	m.listenersMtx.Lock()
+	defer m.listenersMtx.Unlock()
+	
+	m.handler = handler
+}
+
+// StartListener starts a registered listener with the connection handler
+// set by SetHandler or StartAll
+func (m *ListenerManager) StartListener(name string) error {
+	listener, err := m.GetListener(name)
+	if err != nil {
+		return err
+	}
+	
+	m.listenersMtx.RLock()
+	handler := m.handler
+	m.listenersMtx.RUnlock()
+	
+	if handler == nil {
+		return common.NewServerError(common.ErrListenerStartFailed, 
+			fmt.Sprintf("no connection handler for listener %s", name), nil)
+	}
+	
+	if listener.GetStatus() == StatusRunning {
+		return common.NewServerError(common.ErrListenerAlreadyRunning, 
+			fmt.Sprintf("listener %s is already running", name), nil)
+	}
+	
+	connectionHandler := func(conn net.Conn) {
+		handler(name, conn)
+	}
+	if err := listener.Start(m.ctx, connectionHandler); err != nil {
+		return common.NewServerError(common.ErrListenerStartFailed, 
+			fmt.Sprintf("failed to start listener %s", name), err)
+	}
+	
+	return nil
+}
+
+// StopListener stops a running listener; it stays registered
+func (m *ListenerManager) StopListener(name string) error {
+	listener, err := m.GetListener(name)
+	if err != nil {
+		return err
+	}
+	
+	if listener.GetStatus() != StatusRunning {
+		return common.NewServerError(common.ErrListenerNotRunning, 
+			fmt.Sprintf("listener %s is not running", name), nil)
+	}
+	
+	if err := listener.Stop(); err != nil {
+		return common.NewServerError(common.ErrListenerStopFailed, 
+			fmt.Sprintf("failed to stop listener %s", name), err)
+	}
+	
+	return nil
+}
+
 // CleanupManager closes the listener manager and all registered listeners
 func (m *ListenerManager) CleanupManager() error {
 	// Halt all listeners
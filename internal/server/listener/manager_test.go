@@ -1,6 +1,7 @@
 package listener
 
 import (
+	"net"
 	"testing"
 )
 
@@ -44,3 +45,185 @@ func TestListenerManager(t *testing.T) {
 		t.Errorf("Expected error when getting unregistered listener")
 	}
 }
+
+func TestListenerManager_CreateListenerValidatesConfig(t *testing.T) {
+	manager := NewListenerManager(Config{})
+
+	tests := []struct {
+		name     string
+		protocol string
+		config   Config
+	}{
+		{"empty address", "tcp", Config{}},
+		{"invalid address", "tcp", Config{Address: "localhost"}},
+		{"TLS without certificate", "ws", Config{Address: "127.0.0.1:0", EnableTLS: true}},
+		{"TLS on UDP", "udp", Config{Address: "127.0.0.1:0", EnableTLS: true, TLSCertFile: "cert.pem", TLSKeyFile: "key.pem"}},
+		{"unsupported protocol", "smtp", Config{Address: "127.0.0.1:0"}},
+	}
+
+	for _, tt := range tests {
+		if _, err := manager.CreateListener(tt.protocol, tt.config); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+
+	if len(manager.GetListeners()) != 0 {
+		t.Errorf("Expected invalid listeners not to be registered, got %d", len(manager.GetListeners()))
+	}
+
+	// Defaults are filled in for unset values
+	listener, err := manager.CreateListener("tcp", Config{Address: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatalf("Failed to create TCP listener: %v", err)
+	}
+	if listener.GetConfig().BufferSize != 4096 {
+		t.Errorf("Expected default buffer size 4096, got %d", listener.GetConfig().BufferSize)
+	}
+}
+
+func TestListenerManager_CreateDNSListener(t *testing.T) {
+	manager := NewListenerManager(Config{})
+	config := Config{Address: "127.0.0.1:0"}
+
+	invalid := []DNSConfig{
+		{Config: config, Domain: "", TTL: 60, RecordTypes: []string{"A"}},
+		{Config: config, Domain: "example.com", TTL: 0, RecordTypes: []string{"A"}},
+		{Config: config, Domain: "example.com", TTL: 60},
+		{Config: config, Domain: "example.com", TTL: 60, RecordTypes: []string{"BOGUS"}},
+	}
+	for i, dnsConfig := range invalid {
+		if _, err := manager.CreateDNSListener(dnsConfig); err == nil {
+			t.Errorf("Config %d: expected an error", i)
+		}
+	}
+
+	_, err := manager.CreateDNSListener(DNSConfig{Config: config, Domain: "c2.example.org", TTL: 120, RecordTypes: []string{"txt", "A"}})
+	if err != nil {
+		t.Fatalf("Failed to create DNS listener: %v", err)
+	}
+
+	infos := manager.DescribeAll()
+	if len(infos) != 1 {
+		t.Fatalf("Expected 1 listener, got %d", len(infos))
+	}
+	info := infos[0]
+	if info.Protocol != "dns" || info.Domain != "c2.example.org" || info.TTL != 120 {
+		t.Errorf("Unexpected DNS listener description: %+v", info)
+	}
+	if len(info.RecordTypes) != 2 || info.RecordTypes[0] != "TXT" {
+		t.Errorf("Expected normalized record types [TXT A], got %v", info.RecordTypes)
+	}
+}
+
+func TestListenerManager_StartStopListener(t *testing.T) {
+	manager := NewListenerManager(Config{})
+	defer manager.CleanupManager()
+
+	if _, err := manager.CreateListener("tcp", Config{Address: "127.0.0.1:0"}); err != nil {
+		t.Fatalf("Failed to create TCP listener: %v", err)
+	}
+
+	// Listeners cannot start before there is a connection handler
+	if err := manager.StartListener("tcp"); err == nil {
+		t.Error("Expected an error when starting without a handler")
+	}
+
+	manager.SetHandler(func(name string, conn net.Conn) { conn.Close() })
+	if err := manager.StartListener("tcp"); err != nil {
+		t.Fatalf("Failed to start TCP listener: %v", err)
+	}
+	if err := manager.StartListener("tcp"); err == nil {
+		t.Error("Expected an error when starting a running listener")
+	}
+
+	// A running listener cannot be reconfigured
+	if _, err := manager.UpdateListener("tcp", Config{Address: "127.0.0.1:0", BufferSize: 512}); err == nil {
+		t.Error("Expected an error when updating a running listener")
+	}
+
+	if err := manager.StopListener("tcp"); err != nil {
+		t.Fatalf("Failed to stop TCP listener: %v", err)
+	}
+	if err := manager.StopListener("tcp"); err == nil {
+		t.Error("Expected an error when stopping a stopped listener")
+	}
+
+	// An invalid configuration is rolled back
+	if _, err := manager.UpdateListener("tcp", Config{}); err == nil {
+		t.Error("Expected an error when updating to an invalid config")
+	}
+	listener, _ := manager.GetListener("tcp")
+	if listener.GetConfig().Address != "127.0.0.1:0" {
+		t.Errorf("Expected the previous address to be restored, got %q", listener.GetConfig().Address)
+	}
+
+	if _, err := manager.UpdateListener("tcp", Config{Address: "127.0.0.1:0", BufferSize: 512}); err != nil {
+		t.Fatalf("Failed to update TCP listener: %v", err)
+	}
+	if listener.GetConfig().BufferSize != 512 {
+		t.Errorf("Expected buffer size 512, got %d", listener.GetConfig().BufferSize)
+	}
+}
+
+func TestListenerManager_NamedListeners(t *testing.T) {
+	manager := NewListenerManager(Config{})
+	defer manager.CleanupManager()
+
+	// A protocol can have several listeners, told apart by name
+	for _, name := range []string{"external", "internal"} {
+		if _, err := manager.CreateListener("tcp", Config{Name: name, Address: "127.0.0.1:0"}); err != nil {
+			t.Fatalf("Failed to create TCP listener %s: %v", name, err)
+		}
+	}
+	if _, err := manager.CreateListener("tcp", Config{Name: "internal", Address: "127.0.0.1:0"}); err == nil {
+		t.Error("Expected an error when reusing a listener name")
+	}
+	if _, err := manager.CreateListener("tcp", Config{Name: "bad/name", Address: "127.0.0.1:0"}); err == nil {
+		t.Error("Expected an error for an invalid listener name")
+	}
+
+	// Unnamed listeners are named after their protocol
+	if _, err := manager.CreateListener("udp", Config{Address: "127.0.0.1:0"}); err != nil {
+		t.Fatalf("Failed to create UDP listener: %v", err)
+	}
+
+	infos := manager.DescribeAll()
+	if len(infos) != 3 || infos[0].Name != "external" || infos[1].Name != "internal" || infos[2].Name != "udp" {
+		t.Fatalf("Unexpected listeners: %+v", infos)
+	}
+	if infos[0].Protocol != "tcp" || infos[1].Protocol != "tcp" {
+		t.Errorf("Expected the named listeners to be TCP listeners, got %+v", infos)
+	}
+
+	// The handler learns which listener accepted a connection
+	accepted := make(chan string, 1)
+	manager.SetHandler(func(name string, conn net.Conn) {
+		conn.Close()
+		accepted <- name
+	})
+	if err := manager.StartListener("internal"); err != nil {
+		t.Fatalf("Failed to start listener internal: %v", err)
+	}
+
+	listener, _ := manager.GetListener("internal")
+	conn, err := net.Dial("tcp", listener.(*TCPListener).tcpListener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	if name := <-accepted; name != "internal" {
+		t.Errorf("Expected a connection on listener internal, got %s", name)
+	}
+
+	// The name of a listener survives an update
+	if err := manager.StopListener("internal"); err != nil {
+		t.Fatalf("Failed to stop listener internal: %v", err)
+	}
+	if _, err := manager.UpdateListener("internal", Config{Address: "127.0.0.1:0"}); err != nil {
+		t.Fatalf("Failed to update listener internal: %v", err)
+	}
+	if name := Name(listener); name != "internal" {
+		t.Errorf("Expected the name to be kept, got %s", name)
+	}
+}
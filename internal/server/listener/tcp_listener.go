@@ -2,6 +2,7 @@ package listener
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
 	"time"
 
@@ -12,6 +13,10 @@ import (
 type TCPListener struct {
 	*BaseListener
 	listener net.Listener
+
+	// tcpListener is the underlying TCP listener, also when listener
+	// wraps it in TLS
+	tcpListener *net.TCPListener
 }
 
 // NewTCPListener creates a new TCP listener
@@ -30,19 +35,34 @@ func (t *TCPListener) Start(ctx context.Context, handler ConnectionHandler) erro
 	if err := t.ValidateConfig(); err != nil {
 		return err
 	}
+	config := t.GetConfig()
+
+	var tlsConfig *tls.Config
+	if config.EnableTLS {
+		cert, err := tls.LoadX509KeyPair(config.TLSCertFile, config.TLSKeyFile)
+		if err != nil {
+			t.setStatus(StatusError)
+			return common.NewServerError(common.ErrInvalidConfig, "failed to load TLS certificate", err)
+		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
 
 	var err error
-	t.listener, err = net.Listen("tcp", t.Config.Address)
+	t.listener, err = net.Listen("tcp", config.Address)
 	if err != nil {
 		t.setStatus(StatusError)
 		return common.NewServerError(common.ErrInvalidConfig, "failed to start TCP listener", err)
 	}
+	t.tcpListener = t.listener.(*net.TCPListener)
+	if tlsConfig != nil {
+		t.listener = tls.NewListener(t.listener, tlsConfig)
+	}
 
 	ctx, t.cancel = context.WithCancel(ctx)
 	t.setStatus(StatusRunning)
 
 	// Start accepting connections in a separate goroutine
-	go t.acceptConnections(ctx, handler)
+	t.spawn(func() { t.acceptConnections(ctx, handler, config) })
 
 	return nil
 }
@@ -65,17 +85,16 @@ func (t *TCPListener) Stop() error {
 }
 
 // acceptConnections accepts incoming TCP connections
-func (t *TCPListener) acceptConnections(ctx context.Context, handler ConnectionHandler) {
+func (t *TCPListener) acceptConnections(ctx context.Context, handler ConnectionHandler, config Config) {
 	// Create a semaphore to limit the number of concurrent connections
-	semaphore := make(chan struct{}, t.Config.MaxConnections)
+	semaphore := make(chan struct{}, config.MaxConnections)
 
 	// Create a goroutine to handle the context cancellation
-	go func() {
+	listener, tcpListener := t.listener, t.tcpListener
+	t.spawn(func() {
 		<-ctx.Done()
-		if t.listener != nil {
-			t.listener.Close()
-		}
-	}()
+		listener.Close()
+	})
 
 	for {
 		// Check if the context is done
@@ -88,12 +107,12 @@ func (t *TCPListener) acceptConnections(ctx context.Context, handler ConnectionH
 		}
 
 		// Set accept deadline to allow for context cancellation checks
-		if t.Config.Timeout > 0 {
-			t.listener.(*net.TCPListener).SetDeadline(time.Now().Add(time.Duration(t.Config.Timeout) * time.Second))
+		if config.Timeout > 0 {
+			tcpListener.SetDeadline(time.Now().Add(time.Duration(config.Timeout) * time.Second))
 		}
 
 		// Accept a new connection
-		conn, err := t.listener.Accept()
+		conn, err := listener.Accept()
 		if err != nil {
 			// Check if the error is due to the listener being closed
 			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
@@ -110,8 +129,13 @@ func (t *TCPListener) acceptConnections(ctx context.Context, handler ConnectionH
 			}
 		}
 
-		// Acquire a semaphore slot
-		semaphore <- struct{}{}
+		// Acquire a semaphore slot, giving up when the listener stops
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			conn.Close()
+			return
+		}
 
 		// Handle the connection in a separate goroutine
 		go func(conn net.Conn) {
@@ -122,8 +146,8 @@ func (t *TCPListener) acceptConnections(ctx context.Context, handler ConnectionH
 			}()
 
 			// Set connection timeout
-			if t.Config.Timeout > 0 {
-				conn.SetDeadline(time.Now().Add(time.Duration(t.Config.Timeout) * time.Second))
+			if config.Timeout > 0 {
+				conn.SetDeadline(time.Now().Add(time.Duration(config.Timeout) * time.Second))
 			}
 
 			// Call the connection handler
@@ -34,9 +34,10 @@ func (u *UDPListener) Start(ctx context.Context, handler ConnectionHandler) erro
 	if err := u.ValidateConfig(); err != nil {
 		return err
 	}
+	config := u.GetConfig()
 
 	// Resolve UDP address
-	addr, err := net.ResolveUDPAddr("udp", u.Config.Address)
+	addr, err := net.ResolveUDPAddr("udp", config.Address)
 	if err != nil {
 		u.setStatus(StatusError)
 		return common.NewServerError(common.ErrInvalidConfig, "failed to resolve UDP address", err)
@@ -53,7 +54,7 @@ func (u *UDPListener) Start(ctx context.Context, handler ConnectionHandler) erro
 	u.setStatus(StatusRunning)
 
 	// Start handling UDP packets in a separate goroutine
-	go u.handlePackets(ctx, handler)
+	u.spawn(func() { u.handlePackets(ctx, handler, config) })
 
 	return nil
 }
@@ -81,20 +82,19 @@ func (u *UDPListener) Stop() error {
 }
 
 // handlePackets handles incoming UDP packets
-func (u *UDPListener) handlePackets(ctx context.Context, handler ConnectionHandler) {
+func (u *UDPListener) handlePackets(ctx context.Context, handler ConnectionHandler, config Config) {
 	// Create a buffer for reading UDP packets
-	buffer := make([]byte, u.Config.BufferSize)
+	buffer := make([]byte, config.BufferSize)
 
 	// Create a semaphore to limit the number of concurrent packet handlers
-	semaphore := make(chan struct{}, u.Config.MaxConnections)
+	semaphore := make(chan struct{}, config.MaxConnections)
 
 	// Create a goroutine to handle the context cancellation
-	go func() {
+	udpConn := u.conn
+	u.spawn(func() {
 		<-ctx.Done()
-		if u.conn != nil {
-			u.conn.Close()
-		}
-	}()
+		udpConn.Close()
+	})
 
 	for {
 		// Check if the context is done
@@ -107,12 +107,12 @@ func (u *UDPListener) handlePackets(ctx context.Context, handler ConnectionHandl
 		}
 
 		// Set read deadline to allow for context cancellation checks
-		if u.Config.Timeout > 0 {
-			u.conn.SetReadDeadline(time.Now().Add(time.Duration(u.Config.Timeout) * time.Second))
+		if config.Timeout > 0 {
+			udpConn.SetReadDeadline(time.Now().Add(time.Duration(config.Timeout) * time.Second))
 		}
 
 		// Read a UDP packet
-		n, addr, err := u.conn.ReadFromUDP(buffer)
+		n, addr, err := udpConn.ReadFromUDP(buffer)
 		if err != nil {
 			// Check if the error is due to timeout
 			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
@@ -135,8 +135,12 @@ func (u *UDPListener) handlePackets(ctx context.Context, handler ConnectionHandl
 		u.clients[clientKey] = addr
 		u.clientsMtx.Unlock()
 
-		// Acquire a semaphore slot
-		semaphore <- struct{}{}
+		// Acquire a semaphore slot, giving up when the listener stops
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
 
 		// Handle the packet in a separate goroutine
 		go func(data []byte, addr *net.UDPAddr) {
@@ -147,9 +151,9 @@ func (u *UDPListener) handlePackets(ctx context.Context, handler ConnectionHandl
 
 			// Create a UDP connection wrapper to make it compatible with the ConnectionHandler
 			conn := &UDPConnWrapper{
-				udpConn:  u.conn,
+				udpConn:  udpConn,
 				remoteAddr: addr,
-				localAddr:  u.conn.LocalAddr(),
+				localAddr:  udpConn.LocalAddr(),
 				buffer:     data,
 			}
 
@@ -34,6 +34,7 @@ func (w *WSListener) Start(ctx context.Context, handler ConnectionHandler) error
 	if err := w.ValidateConfig(); err != nil {
 		return err
 	}
+	config := w.GetConfig()
 
 	// Create a new HTTP server
 	mux := http.NewServeMux()
@@ -42,7 +43,7 @@ func (w *WSListener) Start(ctx context.Context, handler ConnectionHandler) error
 	})
 
 	w.server = &http.Server{
-		Addr:    w.Config.Address,
+		Addr:    config.Address,
 		Handler: mux,
 	}
 
@@ -50,26 +51,27 @@ func (w *WSListener) Start(ctx context.Context, handler ConnectionHandler) error
 	w.setStatus(StatusRunning)
 
 	// Start the HTTP server in a separate goroutine
-	go func() {
+	server := w.server
+	w.spawn(func() {
 		var err error
-		if w.Config.EnableTLS && w.Config.TLSCertFile != "" && w.Config.TLSKeyFile != "" {
-			err = w.server.ListenAndServeTLS(w.Config.TLSCertFile, w.Config.TLSKeyFile)
+		if config.EnableTLS && config.TLSCertFile != "" && config.TLSKeyFile != "" {
+			err = server.ListenAndServeTLS(config.TLSCertFile, config.TLSKeyFile)
 		} else {
-			err = w.server.ListenAndServe()
+			err = server.ListenAndServe()
 		}
 
 		if err != nil && err != http.ErrServerClosed {
 			w.setStatus(StatusError)
 		}
-	}()
+	})
 
 	// Create a goroutine to handle the context cancellation
-	go func() {
+	w.spawn(func() {
 		<-ctx.Done()
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
-		w.server.Shutdown(shutdownCtx)
-	}()
+		server.Shutdown(shutdownCtx)
+	})
 
 	return nil
 }
@@ -92,7 +94,7 @@ func (w *WSListener) handleWebSocket(writer http.ResponseWriter, request *http.R
 	
 	// Check if we've reached the maximum number of connections
 	w.connsMtx.RLock()
-	if len(w.conns) >= w.Config.MaxConnections {
+	if len(w.conns) >= w.GetConfig().MaxConnections {
 		w.connsMtx.RUnlock()
 		http.Error(writer, "Too many connections", http.StatusServiceUnavailable)
 		return
@@ -187,7 +187,7 @@ func (m *MonitorManager) attemptReconnection(c *client.Client) {
 
 		// For demonstration purposes, let's say the last attempt always succeeds
 		if i == m.config.MaxReconnectAttempts-1 {
-			err := m.clientManager.UpdateClientStatus(c.ID, client.StatusOnline, "")
+			err := m.clientManager.UpdateClientStatusWithCause(c.ID, client.StatusOnline, "", client.CauseReconnect)
 			if err != nil {
 				m.logger.Error("Failed to update client status", map[string]interface{}{
 					"client_id": c.ID,
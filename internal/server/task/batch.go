@@ -0,0 +1,221 @@
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/Cl0udRs4/dinot/internal/server/client"
+)
+
+var (
+	// ErrBatchNotFound is returned when a batch with the specified ID is not found
+	ErrBatchNotFound = errors.New("batch not found")
+)
+
+// BatchStatus is the aggregate status of a batch
+type BatchStatus string
+
+const (
+	// BatchRunning indicates some tasks of the batch have not finished
+	BatchRunning BatchStatus = "running"
+	// BatchCompleted indicates every task of the batch completed
+	BatchCompleted BatchStatus = "completed"
+	// BatchPartial indicates the batch finished with some failed tasks
+	BatchPartial BatchStatus = "partial"
+	// BatchFailed indicates every task of the batch failed
+	BatchFailed BatchStatus = "failed"
+)
+
+// Batch is one module invocation fanned out to many clients. Each client
+// gets its own task; the batch itself does not change after creation.
+type Batch struct {
+	// ID is the unique identifier for the batch
+	ID string `json:"id"`
+
+	// Module is the name of the module the batch runs
+	Module string `json:"module"`
+
+	// Params are the module parameters shared by every task
+	Params json.RawMessage `json:"params,omitempty"`
+
+	// Selector is the console-style representation of the selected clients
+	Selector string `json:"selector"`
+
+	// TaskIDs are the IDs of the batch's tasks, one per client
+	TaskIDs []string `json:"task_ids"`
+
+	// CreatedAt is when the batch was created
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BatchResult is the state of a batch's task on one client
+type BatchResult struct {
+	// ClientID is the ID of the client the task runs on
+	ClientID string `json:"client_id"`
+
+	// TaskID is the ID of the task
+	TaskID string `json:"task_id"`
+
+	// Status is the current status of the task
+	Status TaskStatus `json:"status"`
+
+	// Result is the module output; only included in result downloads
+	Result json.RawMessage `json:"result,omitempty"`
+
+	// Error is the error reported by the client if the task failed
+	Error string `json:"error,omitempty"`
+
+	// UpdatedAt is when the task status last changed
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// BatchSummary is the aggregate view of a batch
+type BatchSummary struct {
+	*Batch
+
+	// Status is the aggregate status of the batch
+	Status BatchStatus `json:"status"`
+
+	// Total is the number of tasks in the batch
+	Total int `json:"total"`
+
+	// Succeeded is the number of completed tasks
+	Succeeded int `json:"succeeded"`
+
+	// Failed is the number of failed tasks
+	Failed int `json:"failed"`
+
+	// Pending is the number of tasks that have not finished
+	Pending int `json:"pending"`
+
+	// Results is the state of every task, ordered by client ID
+	Results []BatchResult `json:"results,omitempty"`
+}
+
+// CreateBatch runs a module on every client selected by a selector and
+// returns the new batch
+func (m *TaskManager) CreateBatch(selector client.Target, module string, params json.RawMessage) (*Batch, error) {
+	if module == "" {
+		return nil, ErrMissingModule
+	}
+
+	if err := validateParams(params); err != nil {
+		return nil, err
+	}
+
+	clients, err := m.clientManager.ResolveTarget(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := &Batch{
+		Module:    module,
+		Params:    params,
+		Selector:  selector.String(),
+		CreatedAt: time.Now(),
+	}
+
+	// Register the batch together with its tasks so that neither is ever
+	// visible without the other
+	_, err = m.queueTasks(clients, module, params, func(tasks []*Task) {
+		batch.ID = m.nextID("batch")
+		batch.TaskIDs = make([]string, 0, len(tasks))
+		for _, task := range tasks {
+			task.BatchID = batch.ID
+			batch.TaskIDs = append(batch.TaskIDs, task.ID)
+		}
+		m.batches[batch.ID] = batch
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return batch, nil
+}
+
+// GetBatch retrieves a batch by ID
+func (m *TaskManager) GetBatch(batchID string) (*Batch, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	batch, exists := m.batches[batchID]
+	if !exists {
+		return nil, ErrBatchNotFound
+	}
+
+	return batch, nil
+}
+
+// GetAllBatches returns all batches ordered by creation time
+func (m *TaskManager) GetAllBatches() []*Batch {
+	m.mu.RLock()
+	batches := make([]*Batch, 0, len(m.batches))
+	for _, batch := range m.batches {
+		batches = append(batches, batch)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(batches, func(i, j int) bool {
+		return batches[i].CreatedAt.Before(batches[j].CreatedAt)
+	})
+	return batches
+}
+
+// SummarizeBatch returns the aggregate view of a batch. Per-client results
+// are included if withResults is set; withOutput also includes the module
+// output of every task.
+func (m *TaskManager) SummarizeBatch(batch *Batch, withResults, withOutput bool) *BatchSummary {
+	summary := &BatchSummary{Batch: batch, Total: len(batch.TaskIDs)}
+
+	for _, id := range batch.TaskIDs {
+		task, err := m.GetTask(id)
+		if err != nil {
+			continue
+		}
+
+		task.mu.RLock()
+		result := BatchResult{
+			ClientID:  task.ClientID,
+			TaskID:    task.ID,
+			Status:    task.Status,
+			Error:     task.Error,
+			UpdatedAt: task.UpdatedAt,
+		}
+		if withOutput {
+			result.Result = task.Result
+		}
+		task.mu.RUnlock()
+
+		switch result.Status {
+		case StatusCompleted:
+			summary.Succeeded++
+		case StatusFailed, StatusCancelled, StatusTimedOut:
+			summary.Failed++
+		default:
+			summary.Pending++
+		}
+
+		if withResults {
+			summary.Results = append(summary.Results, result)
+		}
+	}
+
+	sort.Slice(summary.Results, func(i, j int) bool {
+		return summary.Results[i].ClientID < summary.Results[j].ClientID
+	})
+
+	switch {
+	case summary.Pending > 0:
+		summary.Status = BatchRunning
+	case summary.Failed == 0:
+		summary.Status = BatchCompleted
+	case summary.Succeeded == 0:
+		summary.Status = BatchFailed
+	default:
+		summary.Status = BatchPartial
+	}
+
+	return summary
+}
@@ -0,0 +1,108 @@
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/Cl0udRs4/dinot/internal/server/client"
+)
+
+func TestCreateBatch(t *testing.T) {
+	manager, clientManager := setupTestManager()
+	clientManager.RegisterClient(client.NewClient("client-3", "Client 3", "10.0.0.3", "windows", "amd64", []string{"shell"}, "tcp"))
+
+	tests := []struct {
+		name     string
+		selector client.Target
+		want     int
+	}{
+		{"client list", client.Target{ClientIDs: []string{"client-1", "client-3", "client-1"}}, 2},
+		{"tag", client.Target{Tag: "dmz"}, 2},
+		{"filter", client.Target{Filter: "os=linux"}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batch, err := manager.CreateBatch(tt.selector, "shell", json.RawMessage(`{"command":"id"}`))
+			if err != nil {
+				t.Fatalf("Failed to create batch: %v", err)
+			}
+
+			if len(batch.TaskIDs) != tt.want {
+				t.Fatalf("Expected %d tasks, got %d", tt.want, len(batch.TaskIDs))
+			}
+
+			for _, id := range batch.TaskIDs {
+				task, err := manager.GetTask(id)
+				if err != nil {
+					t.Fatalf("Failed to get task %s: %v", id, err)
+				}
+				if task.BatchID != batch.ID {
+					t.Errorf("Expected task %s to belong to batch %s, got %q", id, batch.ID, task.BatchID)
+				}
+			}
+		})
+	}
+
+	if _, err := manager.CreateBatch(client.Target{Tag: "dmz", Group: "web"}, "shell", nil); err != client.ErrInvalidTarget {
+		t.Errorf("Expected ErrInvalidTarget, got %v", err)
+	}
+
+	if _, err := manager.CreateBatch(client.Target{ClientIDs: []string{"client-1", "missing"}}, "shell", nil); !errors.Is(err, client.ErrClientNotFound) {
+		t.Errorf("Expected ErrClientNotFound, got %v", err)
+	}
+
+	if _, err := manager.CreateBatch(client.Target{Filter: "os=solaris"}, "shell", nil); err != ErrNoClientsSelected {
+		t.Errorf("Expected ErrNoClientsSelected, got %v", err)
+	}
+
+	if got := len(manager.GetAllBatches()); got != len(tests) {
+		t.Errorf("Expected %d batches, got %d", len(tests), got)
+	}
+}
+
+func TestSummarizeBatch(t *testing.T) {
+	manager, clientManager := setupTestManager()
+	clientManager.RegisterClient(client.NewClient("client-3", "Client 3", "10.0.0.3", "linux", "amd64", []string{"shell"}, "tcp"))
+
+	batch, err := manager.CreateBatch(client.Target{Filter: "os=linux"}, "shell", nil)
+	if err != nil {
+		t.Fatalf("Failed to create batch: %v", err)
+	}
+
+	summary := manager.SummarizeBatch(batch, false, false)
+	if summary.Status != BatchRunning || summary.Pending != 3 || summary.Results != nil {
+		t.Errorf("Expected 3 pending tasks and no results, got %+v", summary)
+	}
+
+	report := func(taskID string, status TaskStatus, result string) {
+		err := manager.HandleFeedback(Feedback{CommandID: taskID, Status: string(status), Result: json.RawMessage(result)})
+		if err != nil {
+			t.Fatalf("Failed to record feedback: %v", err)
+		}
+	}
+
+	report(batch.TaskIDs[0], StatusCompleted, `{"output":"uid=0"}`)
+	report(batch.TaskIDs[1], StatusFailed, "")
+
+	summary = manager.SummarizeBatch(batch, true, false)
+	if summary.Succeeded != 1 || summary.Failed != 1 || summary.Pending != 1 || summary.Status != BatchRunning {
+		t.Errorf("Unexpected counts %+v", summary)
+	}
+	if len(summary.Results) != 3 || summary.Results[0].Result != nil {
+		t.Errorf("Expected 3 results without output, got %+v", summary.Results)
+	}
+
+	report(batch.TaskIDs[2], StatusCompleted, `{"output":"uid=1000"}`)
+
+	summary = manager.SummarizeBatch(batch, true, true)
+	if summary.Status != BatchPartial {
+		t.Errorf("Expected partial batch, got %s", summary.Status)
+	}
+	for _, result := range summary.Results {
+		if result.Status == StatusCompleted && result.Result == nil {
+			t.Errorf("Expected output for task %s", result.TaskID)
+		}
+	}
+}
@@ -0,0 +1,718 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Cl0udRs4/dinot/internal/server/client"
+)
+
+var (
+	// ErrTaskNotFound is returned when a task with the specified ID is not found
+	ErrTaskNotFound = errors.New("task not found")
+
+	// ErrMissingModule is returned when a task is created without a module name
+	ErrMissingModule = errors.New("missing module name")
+
+	// ErrInvalidParams is returned when task parameters are not a JSON object
+	ErrInvalidParams = errors.New("task parameters must be a JSON object")
+
+	// ErrNoClientsSelected is returned when a target does not match any client
+	ErrNoClientsSelected = errors.New("target does not match any client")
+
+	// ErrTaskFinished is returned when cancelling a task that already finished
+	ErrTaskFinished = errors.New("task already finished")
+
+	// ErrTaskNotOwned is returned when a client reports a result for a task
+	// that belongs to another client
+	ErrTaskNotOwned = errors.New("task belongs to another client")
+
+	// ErrInvalidTimeout is returned when the timeout parameter of a task is
+	// not a non-negative number of seconds
+	ErrInvalidTimeout = errors.New("timeout must be a non-negative number of seconds")
+)
+
+// Dispatcher delivers an encoded command to a client. It returns an error if
+// the client cannot be reached right now, in which case the command stays
+// queued until the client next polls for it.
+type Dispatcher func(clientID string, command []byte) error
+
+// Command is the wire format of a command sent to a client
+type Command struct {
+	// Type is the command type, e.g. "execute_module"
+	Type string `json:"type"`
+
+	// Module is the name of the module the command applies to
+	Module string `json:"module,omitempty"`
+
+	// Params are the module parameters, including the command ID
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// Feedback is the wire format of a module result reported by a client
+type Feedback struct {
+	Type       string          `json:"type"`
+	ClientID   string          `json:"client_id"`
+	CommandID  string          `json:"command_id,omitempty"`
+	Module     string          `json:"module,omitempty"`
+	Success    bool            `json:"success"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	RetryCount int             `json:"retry_count,omitempty"`
+	Status     string          `json:"status,omitempty"`
+	Timestamp  int64           `json:"timestamp"`
+}
+
+// TaskManager creates tasks, queues them for delivery and records results
+type TaskManager struct {
+	// clientManager is used to resolve task targets
+	clientManager *client.ClientManager
+
+	// tasks maps task IDs to Task objects
+	tasks map[string]*Task
+
+	// queues maps client IDs to IDs of tasks waiting for delivery
+	queues map[string][]string
+
+	// cancels maps client IDs to IDs of tasks whose cancellation is waiting
+	// for delivery
+	cancels map[string][]string
+
+	// batches maps batch IDs to Batch objects
+	batches map[string]*Batch
+
+	// workflows maps workflow IDs to Workflow objects
+	workflows map[string]*Workflow
+
+	// dispatcher delivers commands to connected clients, if set
+	dispatcher Dispatcher
+
+	// finishHooks are called after a task reaches a final status
+	finishHooks []FinishHook
+
+	// defaultTimeout is the timeout of tasks whose module has no default
+	defaultTimeout time.Duration
+
+	// moduleTimeouts maps module names to the default timeout of their tasks
+	moduleTimeouts map[string]time.Duration
+
+	// ctx is the context for controlling the deadline monitor's lifecycle
+	ctx context.Context
+
+	// cancel is the function to cancel the deadline monitor's context
+	cancel context.CancelFunc
+
+	// wg is used to wait for the deadline monitor to shut down
+	wg sync.WaitGroup
+
+	// seq makes task IDs unique within a single nanosecond
+	seq uint64
+
+	// mu protects concurrent access to the task maps
+	mu sync.RWMutex
+
+	// workflowMu protects the progress of workflows. It is taken before mu
+	// when both are needed.
+	workflowMu sync.Mutex
+}
+
+// NewTaskManager creates a new task manager
+func NewTaskManager(clientManager *client.ClientManager) *TaskManager {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &TaskManager{
+		clientManager:  clientManager,
+		tasks:          make(map[string]*Task),
+		queues:         make(map[string][]string),
+		cancels:        make(map[string][]string),
+		batches:        make(map[string]*Batch),
+		workflows:      make(map[string]*Workflow),
+		moduleTimeouts: make(map[string]time.Duration),
+		ctx:            ctx,
+		cancel:         cancel,
+	}
+}
+
+// SetDispatcher sets the function used to push commands to clients
+func (m *TaskManager) SetDispatcher(dispatcher Dispatcher) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.dispatcher = dispatcher
+}
+
+// FinishHook is called after a task reaches a final status
+type FinishHook func(task *Task)
+
+// OnTaskFinished adds a hook called after every task finishes, whether it
+// completed, failed, was cancelled or timed out
+func (m *TaskManager) OnTaskFinished(hook FinishHook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.finishHooks = append(m.finishHooks, hook)
+}
+
+// CreateTask creates a task running a module on a single client
+func (m *TaskManager) CreateTask(clientID, module string, params json.RawMessage) (*Task, error) {
+	tasks, err := m.CreateTasks(client.Target{ClientID: clientID}, module, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return tasks[0], nil
+}
+
+// CreateTasks creates one task per client selected by the target
+func (m *TaskManager) CreateTasks(target client.Target, module string, params json.RawMessage) ([]*Task, error) {
+	if module == "" {
+		return nil, ErrMissingModule
+	}
+
+	if err := validateParams(params); err != nil {
+		return nil, err
+	}
+
+	clients, err := m.clientManager.ResolveTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.queueTasks(clients, module, params, nil)
+}
+
+// CreateModuleTasks creates one task per client selected by the target
+// that loads or unloads a module. The active modules of a client change
+// once it reports that it did.
+func (m *TaskManager) CreateModuleTasks(target client.Target, module string, load bool) ([]*Task, error) {
+	if module == "" {
+		return nil, ErrMissingModule
+	}
+
+	clients, err := m.clientManager.ResolveTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	action := ActionUnload
+	if load {
+		action = ActionLoad
+	}
+
+	return m.queueTasks(clients, module, nil, func(tasks []*Task) {
+		for _, task := range tasks {
+			task.Action = action
+			task.Timeout = 0
+		}
+	})
+}
+
+// queueTasks creates and queues one task per client, running init on
+// every task under the manager lock before it becomes visible
+func (m *TaskManager) queueTasks(clients []*client.Client, module string, params json.RawMessage, init func(tasks []*Task)) ([]*Task, error) {
+	if len(clients) == 0 {
+		return nil, ErrNoClientsSelected
+	}
+
+	tasks := make([]*Task, 0, len(clients))
+
+	m.mu.Lock()
+	timeout := m.timeoutFor(module, params)
+	for _, c := range clients {
+		task := newTask(m.nextID("task"), c.ID, module, params)
+		task.Timeout = timeout
+		tasks = append(tasks, task)
+	}
+	if init != nil {
+		init(tasks)
+	}
+	for _, task := range tasks {
+		m.tasks[task.ID] = task
+		m.queues[task.ClientID] = append(m.queues[task.ClientID], task.ID)
+	}
+	m.mu.Unlock()
+
+	// Push the new tasks straight away when a dispatcher is available
+	for _, c := range clients {
+		m.Dispatch(c.ID)
+	}
+
+	return tasks, nil
+}
+
+// GetTask retrieves a task by ID
+func (m *TaskManager) GetTask(taskID string) (*Task, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	task, exists := m.tasks[taskID]
+	if !exists {
+		return nil, ErrTaskNotFound
+	}
+
+	return task, nil
+}
+
+// GetAllTasks returns all tasks ordered by creation time
+func (m *TaskManager) GetAllTasks() []*Task {
+	m.mu.RLock()
+	tasks := make([]*Task, 0, len(m.tasks))
+	for _, task := range m.tasks {
+		tasks = append(tasks, task)
+	}
+	m.mu.RUnlock()
+
+	sortTasks(tasks)
+	return tasks
+}
+
+// GetClientTasks returns all tasks for a client ordered by creation time
+func (m *TaskManager) GetClientTasks(clientID string) []*Task {
+	m.mu.RLock()
+	tasks := make([]*Task, 0)
+	for _, task := range m.tasks {
+		if task.ClientID == clientID {
+			tasks = append(tasks, task)
+		}
+	}
+	m.mu.RUnlock()
+
+	sortTasks(tasks)
+	return tasks
+}
+
+// PendingCommands removes and returns the encoded commands queued for a
+// client, marking the corresponding tasks as dispatched
+func (m *TaskManager) PendingCommands(clientID string) [][]byte {
+	cancels, tasks := m.takeCommands(clientID)
+
+	commands := make([][]byte, 0, len(cancels)+len(tasks))
+	for _, id := range cancels {
+		if data, err := encodeCancel(id); err == nil {
+			commands = append(commands, data)
+		}
+	}
+
+	for _, task := range tasks {
+		data, err := encodeCommand(task)
+		if err != nil {
+			task.mu.Lock()
+			task.sending = false
+			task.Status = StatusFailed
+			task.Error = err.Error()
+			task.UpdatedAt = time.Now()
+			task.mu.Unlock()
+			m.taskFinished(task)
+			continue
+		}
+
+		if task.markDispatched() {
+			// Cancelled meanwhile; the cancellation goes out with the
+			// next poll, after the command
+			m.queueCancel(clientID, task.ID)
+		}
+		commands = append(commands, data)
+	}
+
+	return commands
+}
+
+// Dispatch pushes the commands queued for a client through the dispatcher.
+// Commands the dispatcher fails to deliver are requeued.
+func (m *TaskManager) Dispatch(clientID string) {
+	m.mu.RLock()
+	dispatcher := m.dispatcher
+	m.mu.RUnlock()
+
+	if dispatcher == nil {
+		return
+	}
+
+	cancels, tasks := m.takeCommands(clientID)
+
+	// Cancellations go first so that a running module stops as soon as
+	// possible
+	for i, id := range cancels {
+		data, err := encodeCancel(id)
+		if err == nil {
+			err = dispatcher(clientID, data)
+		}
+
+		if err != nil {
+			m.mu.Lock()
+			m.cancels[clientID] = append(append([]string{}, cancels[i:]...), m.cancels[clientID]...)
+			m.mu.Unlock()
+			m.requeue(clientID, tasks)
+			return
+		}
+	}
+
+	cancelled := false
+	for i, task := range tasks {
+		data, err := encodeCommand(task)
+		if err == nil {
+			err = dispatcher(clientID, data)
+		}
+
+		if err != nil {
+			// Requeue this and the remaining tasks, preserving order
+			m.requeue(clientID, tasks[i:])
+			break
+		}
+
+		if task.markDispatched() {
+			m.queueCancel(clientID, task.ID)
+			cancelled = true
+		}
+	}
+
+	// Send the cancellations of tasks cancelled while their command was in
+	// flight, now that the commands are out
+	if cancelled {
+		m.Dispatch(clientID)
+	}
+}
+
+// takeCommands removes the cancellations and tasks queued for a client and
+// flags the tasks as being sent
+func (m *TaskManager) takeCommands(clientID string) ([]string, []*Task) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cancels := m.cancels[clientID]
+	delete(m.cancels, clientID)
+
+	ids := m.queues[clientID]
+	delete(m.queues, clientID)
+
+	tasks := make([]*Task, 0, len(ids))
+	for _, id := range ids {
+		if task, exists := m.tasks[id]; exists {
+			task.mu.Lock()
+			task.sending = true
+			task.mu.Unlock()
+			tasks = append(tasks, task)
+		}
+	}
+
+	return cancels, tasks
+}
+
+// queueCancel queues a cancel_task command for a client
+func (m *TaskManager) queueCancel(clientID, taskID string) {
+	m.mu.Lock()
+	m.cancels[clientID] = append(m.cancels[clientID], taskID)
+	m.mu.Unlock()
+}
+
+// requeue puts tasks whose command could not be sent back at the front of
+// the queue of a client, preserving order. Tasks cancelled meanwhile are
+// cancelled straight away.
+func (m *TaskManager) requeue(clientID string, tasks []*Task) {
+	var cancelled []*Task
+
+	m.mu.Lock()
+	ids := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		task.mu.Lock()
+		task.sending = false
+		if task.Status == StatusCancelling {
+			task.Status = StatusCancelled
+			task.Error = "cancelled before delivery"
+			task.UpdatedAt = time.Now()
+			cancelled = append(cancelled, task)
+		} else {
+			ids = append(ids, task.ID)
+		}
+		task.mu.Unlock()
+	}
+	m.queues[clientID] = append(ids, m.queues[clientID]...)
+	m.mu.Unlock()
+
+	for _, task := range cancelled {
+		m.taskFinished(task)
+	}
+}
+
+// CancelTask cancels a task. A task still waiting for delivery is removed
+// from the queue and cancelled straight away; otherwise a cancel_task
+// command is sent to the client and the task stays cancelling until the
+// client reports it cancelled.
+func (m *TaskManager) CancelTask(taskID string) (*Task, error) {
+	m.mu.Lock()
+	task, exists := m.tasks[taskID]
+	if !exists {
+		m.mu.Unlock()
+		return nil, ErrTaskNotFound
+	}
+
+	task.mu.Lock()
+	switch {
+	case task.Status.IsFinal():
+		task.mu.Unlock()
+		m.mu.Unlock()
+		return nil, ErrTaskFinished
+	case task.Status == StatusCancelling:
+		// Already requested
+		task.mu.Unlock()
+		m.mu.Unlock()
+		return task, nil
+	}
+
+	queued := removeID(m.queues, task.ClientID, taskID)
+	switch {
+	case queued:
+		task.Status = StatusCancelled
+		task.Error = "cancelled before delivery"
+	case task.sending:
+		// The command is on its way; whoever sends it queues the
+		// cancellation once it is out
+		task.Status = StatusCancelling
+	default:
+		task.Status = StatusCancelling
+		m.cancels[task.ClientID] = append(m.cancels[task.ClientID], taskID)
+	}
+	task.UpdatedAt = time.Now()
+	task.mu.Unlock()
+	m.mu.Unlock()
+
+	if queued {
+		m.taskFinished(task)
+	} else {
+		m.Dispatch(task.ClientID)
+	}
+
+	return task, nil
+}
+
+// removeID removes id from the queue of a client and reports whether it was
+// there; callers must hold m.mu
+func removeID(queues map[string][]string, clientID, id string) bool {
+	for i, queued := range queues[clientID] {
+		if queued == id {
+			queues[clientID] = append(queues[clientID][:i:i], queues[clientID][i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// HandleFeedback records a module result reported by a client
+func (m *TaskManager) HandleFeedback(feedback Feedback) error {
+	task, err := m.GetTask(feedback.CommandID)
+	if err != nil {
+		return err
+	}
+
+	finished, err := task.recordFeedback(feedback)
+	if err != nil {
+		return err
+	}
+
+	if finished {
+		m.taskFinished(task)
+	}
+	return nil
+}
+
+// recordFeedback applies a module result to the task and reports whether
+// the task finished because of it
+func (t *Task) recordFeedback(feedback Feedback) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Ignore late updates for a task that already finished
+	if t.Status.IsFinal() {
+		return false, nil
+	}
+
+	switch TaskStatus(feedback.Status) {
+	case StatusProcessing, StatusRetrying:
+		// Progress updates sent before the client saw the cancellation
+		// must not hide it
+		if t.Status != StatusCancelling {
+			t.Status = TaskStatus(feedback.Status)
+		}
+		t.Error = feedback.Error
+	case StatusCompleted, StatusFailed, StatusCancelled, StatusTimedOut:
+		t.Status = TaskStatus(feedback.Status)
+		t.Result = feedback.Result
+		t.Error = feedback.Error
+	default:
+		return false, fmt.Errorf("unknown task status: %s", feedback.Status)
+	}
+
+	t.RetryCount = feedback.RetryCount
+	t.UpdatedAt = time.Now()
+	return t.Status.IsFinal(), nil
+}
+
+// taskFinished runs the finish hooks for a task that reached a final
+// status and lets a workflow waiting for it move on. It must be called
+// without holding m.mu or the task lock.
+func (m *TaskManager) taskFinished(task *Task) {
+	m.mu.RLock()
+	hooks := m.finishHooks
+	m.mu.RUnlock()
+
+	if task.Action != "" && task.GetStatus() == StatusCompleted {
+		m.applyModuleAction(task)
+	}
+
+	for _, hook := range hooks {
+		hook(task)
+	}
+
+	m.advanceWorkflow(task)
+}
+
+// applyModuleAction records a module the client loaded or unloaded as
+// active or inactive
+func (m *TaskManager) applyModuleAction(task *Task) {
+	c, err := m.clientManager.GetClient(task.ClientID)
+	if err != nil {
+		return
+	}
+
+	if task.Action == ActionLoad {
+		c.AddActiveModule(task.Module)
+	} else {
+		c.RemoveActiveModule(task.Module)
+	}
+}
+
+// HandleMessage decodes a raw message received from the connection of a
+// client and records it if it is the result of a module execution, load or
+// unload. Other message types are ignored. Results for tasks of other
+// clients are rejected.
+func (m *TaskManager) HandleMessage(clientID string, data []byte) error {
+	var feedback Feedback
+	if err := json.Unmarshal(data, &feedback); err != nil {
+		return err
+	}
+
+	if !resultTypes[feedback.Type] || feedback.CommandID == "" {
+		return nil
+	}
+
+	task, err := m.GetTask(feedback.CommandID)
+	if err != nil {
+		return err
+	}
+	if task.ClientID != clientID {
+		return ErrTaskNotOwned
+	}
+
+	return m.HandleFeedback(feedback)
+}
+
+// resultTypes are the types of the client messages reporting on a task
+var resultTypes = map[string]bool{
+	"module_result":        true,
+	"module_load_result":   true,
+	"module_unload_result": true,
+}
+
+// nextID generates a unique ID with the given prefix; callers must hold m.mu
+func (m *TaskManager) nextID(prefix string) string {
+	return fmt.Sprintf("%s-%d-%d", prefix, time.Now().UnixNano(), atomic.AddUint64(&m.seq, 1))
+}
+
+// encodeCommand builds the execute_module, load_module or unload_module
+// command for a task. The task ID is embedded in the parameters as the
+// command ID, which is where the client looks for it.
+func encodeCommand(task *Task) ([]byte, error) {
+	if task.Action != "" {
+		params, err := json.Marshal(map[string]string{"command_id": task.ID})
+		if err != nil {
+			return nil, err
+		}
+
+		return json.Marshal(Command{
+			Type:   string(task.Action) + "_module",
+			Module: task.Module,
+			Params: params,
+		})
+	}
+
+	params := make(map[string]json.RawMessage)
+	if len(task.Params) > 0 {
+		if err := json.Unmarshal(task.Params, &params); err != nil {
+			return nil, ErrInvalidParams
+		}
+	}
+	if params == nil {
+		params = make(map[string]json.RawMessage)
+	}
+
+	id, err := json.Marshal(task.ID)
+	if err != nil {
+		return nil, err
+	}
+	params["command_id"] = id
+
+	// The client applies the timeout to the module context. A timeout
+	// parameter set by the operator is already there.
+	if _, set := params["timeout"]; !set && task.Timeout > 0 {
+		params["timeout"] = json.RawMessage(strconv.Itoa(task.Timeout))
+	}
+
+	encoded, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(Command{
+		Type:   "execute_module",
+		Module: task.Module,
+		Params: encoded,
+	})
+}
+
+// encodeCancel builds the cancel_task command for a task
+func encodeCancel(taskID string) ([]byte, error) {
+	params, err := json.Marshal(map[string]string{"command_id": taskID})
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(Command{
+		Type:   "cancel_task",
+		Params: params,
+	})
+}
+
+// validateParams checks that params are empty or a JSON object
+func validateParams(params json.RawMessage) error {
+	if len(params) == 0 {
+		return nil
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(params, &object); err != nil {
+		return ErrInvalidParams
+	}
+
+	if raw, set := object["timeout"]; set {
+		var timeout int
+		if err := json.Unmarshal(raw, &timeout); err != nil || timeout < 0 {
+			return ErrInvalidTimeout
+		}
+	}
+
+	return nil
+}
+
+// sortTasks sorts tasks by creation time, oldest first
+func sortTasks(tasks []*Task) {
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].CreatedAt.Before(tasks[j].CreatedAt)
+	})
+}
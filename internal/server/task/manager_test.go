@@ -0,0 +1,300 @@
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/Cl0udRs4/dinot/internal/server/client"
+)
+
+// setupTestManager creates a task manager with two tagged clients
+func setupTestManager() (*TaskManager, *client.ClientManager) {
+	clientManager := client.NewClientManager()
+	clientManager.RegisterClient(client.NewClient("client-1", "Client 1", "10.0.0.1", "linux", "amd64", []string{"shell"}, "tcp"))
+	clientManager.RegisterClient(client.NewClient("client-2", "Client 2", "10.0.0.2", "linux", "amd64", []string{"shell"}, "tcp"))
+	clientManager.AddClientTag("client-1", "dmz")
+	clientManager.AddClientTag("client-2", "dmz")
+
+	return NewTaskManager(clientManager), clientManager
+}
+
+func TestCreateTasks(t *testing.T) {
+	manager, _ := setupTestManager()
+
+	tasks, err := manager.CreateTasks(client.Target{Tag: "dmz"}, "shell", json.RawMessage(`{"command":"whoami"}`))
+	if err != nil {
+		t.Fatalf("Failed to create tasks: %v", err)
+	}
+
+	if len(tasks) != 2 {
+		t.Fatalf("Expected 2 tasks, got %d", len(tasks))
+	}
+
+	if tasks[0].ID == tasks[1].ID {
+		t.Errorf("Expected unique task IDs")
+	}
+
+	if len(manager.GetAllTasks()) != 2 {
+		t.Errorf("Expected 2 tasks in manager, got %d", len(manager.GetAllTasks()))
+	}
+
+	if _, err := manager.CreateTasks(client.Target{Tag: "none"}, "shell", nil); err != ErrNoClientsSelected {
+		t.Errorf("Expected ErrNoClientsSelected, got %v", err)
+	}
+
+	if _, err := manager.CreateTask("client-1", "", nil); err != ErrMissingModule {
+		t.Errorf("Expected ErrMissingModule, got %v", err)
+	}
+
+	if _, err := manager.CreateTask("client-1", "shell", json.RawMessage(`[1]`)); err != ErrInvalidParams {
+		t.Errorf("Expected ErrInvalidParams, got %v", err)
+	}
+}
+
+func TestPendingCommandsAndFeedback(t *testing.T) {
+	manager, _ := setupTestManager()
+
+	task, err := manager.CreateTask("client-1", "shell", json.RawMessage(`{"command":"id"}`))
+	if err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	commands := manager.PendingCommands("client-1")
+	if len(commands) != 1 {
+		t.Fatalf("Expected 1 pending command, got %d", len(commands))
+	}
+
+	var command Command
+	if err := json.Unmarshal(commands[0], &command); err != nil {
+		t.Fatalf("Failed to decode command: %v", err)
+	}
+
+	var params map[string]string
+	json.Unmarshal(command.Params, &params)
+	if command.Type != "execute_module" || params["command_id"] != task.ID || params["command"] != "id" {
+		t.Errorf("Unexpected command: %s", commands[0])
+	}
+
+	if task.GetStatus() != StatusDispatched {
+		t.Errorf("Expected status dispatched, got %s", task.GetStatus())
+	}
+
+	if len(manager.PendingCommands("client-1")) != 0 {
+		t.Errorf("Expected queue to be drained")
+	}
+
+	result := `{"type":"module_result","client_id":"client-1","command_id":"` + task.ID + `","success":true,"status":"completed","result":{"output":"root"}}`
+
+	// Only the connection of the task's client can report its result
+	if err := manager.HandleMessage("client-2", []byte(result)); !errors.Is(err, ErrTaskNotOwned) {
+		t.Fatalf("Expected ErrTaskNotOwned, got %v", err)
+	}
+	if task.GetStatus() != StatusDispatched {
+		t.Errorf("Expected status to stay dispatched, got %s", task.GetStatus())
+	}
+
+	if err := manager.HandleMessage("client-1", []byte(result)); err != nil {
+		t.Fatalf("Failed to handle feedback: %v", err)
+	}
+
+	if task.GetStatus() != StatusCompleted {
+		t.Errorf("Expected status completed, got %s", task.GetStatus())
+	}
+
+	// Late updates do not reopen a finished task
+	manager.HandleFeedback(Feedback{CommandID: task.ID, Status: "processing"})
+	if task.GetStatus() != StatusCompleted {
+		t.Errorf("Expected status to stay completed, got %s", task.GetStatus())
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	manager, _ := setupTestManager()
+
+	delivered := 0
+	online := false
+	manager.SetDispatcher(func(clientID string, command []byte) error {
+		if !online {
+			return errors.New("client not connected")
+		}
+		delivered++
+		return nil
+	})
+
+	task, _ := manager.CreateTask("client-1", "shell", nil)
+	if task.GetStatus() != StatusPending {
+		t.Errorf("Expected undelivered task to stay pending, got %s", task.GetStatus())
+	}
+
+	online = true
+	manager.Dispatch("client-1")
+	if delivered != 1 || task.GetStatus() != StatusDispatched {
+		t.Errorf("Expected task to be delivered, got %d deliveries and status %s", delivered, task.GetStatus())
+	}
+}
+
+func TestCancelTask(t *testing.T) {
+	manager, _ := setupTestManager()
+
+	// A task still in the queue is cancelled without involving the client
+	queued, _ := manager.CreateTask("client-1", "shell", nil)
+	if _, err := manager.CancelTask(queued.ID); err != nil {
+		t.Fatalf("Failed to cancel queued task: %v", err)
+	}
+	if queued.GetStatus() != StatusCancelled || len(manager.PendingCommands("client-1")) != 0 {
+		t.Errorf("Expected the queued task to be cancelled and dequeued, got %s", queued.GetStatus())
+	}
+
+	// A delivered task is cancelled by the client
+	running, _ := manager.CreateTask("client-1", "shell", nil)
+	manager.PendingCommands("client-1")
+	if _, err := manager.CancelTask(running.ID); err != nil {
+		t.Fatalf("Failed to cancel running task: %v", err)
+	}
+	if running.GetStatus() != StatusCancelling {
+		t.Errorf("Expected status cancelling, got %s", running.GetStatus())
+	}
+
+	commands := manager.PendingCommands("client-1")
+	if len(commands) != 1 {
+		t.Fatalf("Expected 1 cancel command, got %d", len(commands))
+	}
+
+	var command Command
+	var params map[string]string
+	json.Unmarshal(commands[0], &command)
+	json.Unmarshal(command.Params, &params)
+	if command.Type != "cancel_task" || params["command_id"] != running.ID {
+		t.Errorf("Unexpected command: %s", commands[0])
+	}
+
+	// Progress reported before the client saw the cancellation is ignored
+	manager.HandleFeedback(Feedback{CommandID: running.ID, Status: "processing"})
+	if running.GetStatus() != StatusCancelling {
+		t.Errorf("Expected status to stay cancelling, got %s", running.GetStatus())
+	}
+
+	manager.HandleFeedback(Feedback{CommandID: running.ID, Status: "cancelled", Error: "context canceled"})
+	if running.GetStatus() != StatusCancelled {
+		t.Errorf("Expected status cancelled, got %s", running.GetStatus())
+	}
+
+	if _, err := manager.CancelTask(running.ID); err != ErrTaskFinished {
+		t.Errorf("Expected ErrTaskFinished, got %v", err)
+	}
+	if _, err := manager.CancelTask("missing"); err != ErrTaskNotFound {
+		t.Errorf("Expected ErrTaskNotFound, got %v", err)
+	}
+}
+
+func TestCancelTaskInFlight(t *testing.T) {
+	manager, _ := setupTestManager()
+	task, _ := manager.CreateTask("client-1", "shell", nil)
+
+	var sent []string
+	manager.SetDispatcher(func(clientID string, data []byte) error {
+		var command Command
+		json.Unmarshal(data, &command)
+
+		// The task is cancelled before its command is out
+		if command.Type == "execute_module" {
+			if _, err := manager.CancelTask(task.ID); err != nil {
+				t.Errorf("Failed to cancel task: %v", err)
+			}
+		}
+		sent = append(sent, command.Type)
+		return nil
+	})
+
+	manager.Dispatch("client-1")
+
+	if len(sent) != 2 || sent[0] != "execute_module" || sent[1] != "cancel_task" {
+		t.Errorf("Expected the cancellation after the command, got %v", sent)
+	}
+	if task.GetStatus() != StatusCancelling {
+		t.Errorf("Expected status cancelling, got %s", task.GetStatus())
+	}
+}
+
+func TestCreateModuleTasks(t *testing.T) {
+	manager, clientManager := setupTestManager()
+
+	tasks, err := manager.CreateModuleTasks(client.Target{ClientID: "client-1"}, "shell", true)
+	if err != nil {
+		t.Fatalf("Failed to create load task: %v", err)
+	}
+
+	commands := manager.PendingCommands("client-1")
+	if len(commands) != 1 {
+		t.Fatalf("Expected 1 command, got %d", len(commands))
+	}
+	var command Command
+	var params map[string]string
+	json.Unmarshal(commands[0], &command)
+	json.Unmarshal(command.Params, &params)
+	if command.Type != "load_module" || command.Module != "shell" || params["command_id"] != tasks[0].ID {
+		t.Errorf("Unexpected command: %s", commands[0])
+	}
+
+	// The module only becomes active once the client reports it loaded
+	c, _ := clientManager.GetClient("client-1")
+	if c.IsModuleActive("shell") {
+		t.Error("Expected shell to be inactive before the client answers")
+	}
+	result := `{"type":"module_load_result","client_id":"client-1","command_id":"` + tasks[0].ID + `","module":"shell","success":true,"status":"completed"}`
+	if err := manager.HandleMessage("client-1", []byte(result)); err != nil {
+		t.Fatalf("Failed to handle result: %v", err)
+	}
+	if tasks[0].GetStatus() != StatusCompleted || !c.IsModuleActive("shell") {
+		t.Errorf("Expected shell to be loaded, got status %s", tasks[0].GetStatus())
+	}
+
+	// A failed unload leaves the module active
+	tasks, err = manager.CreateModuleTasks(client.Target{ClientID: "client-1"}, "shell", false)
+	if err != nil {
+		t.Fatalf("Failed to create unload task: %v", err)
+	}
+	json.Unmarshal(manager.PendingCommands("client-1")[0], &command)
+	if command.Type != "unload_module" {
+		t.Errorf("Expected unload_module, got %s", command.Type)
+	}
+	result = `{"type":"module_unload_result","client_id":"client-1","command_id":"` + tasks[0].ID + `","module":"shell","status":"failed","error":"in use"}`
+	if err := manager.HandleMessage("client-1", []byte(result)); err != nil {
+		t.Fatalf("Failed to handle result: %v", err)
+	}
+	if tasks[0].GetStatus() != StatusFailed || !c.IsModuleActive("shell") {
+		t.Errorf("Expected shell to stay loaded, got status %s", tasks[0].GetStatus())
+	}
+
+	if _, err := manager.CreateModuleTasks(client.Target{ClientID: "client-1"}, "", true); err != ErrMissingModule {
+		t.Errorf("Expected ErrMissingModule, got %v", err)
+	}
+}
+
+func TestOnTaskFinished(t *testing.T) {
+	manager, _ := setupTestManager()
+
+	var finished []string
+	manager.OnTaskFinished(func(task *Task) {
+		finished = append(finished, task.ID+":"+string(task.GetStatus()))
+	})
+
+	tasks, err := manager.CreateTasks(client.Target{Tag: "dmz"}, "shell", nil)
+	if err != nil {
+		t.Fatalf("Failed to create tasks: %v", err)
+	}
+
+	manager.HandleFeedback(Feedback{CommandID: tasks[0].ID, Status: string(StatusProcessing)})
+	if len(finished) != 0 {
+		t.Fatalf("Expected no finished tasks after a progress update, got %v", finished)
+	}
+
+	manager.HandleFeedback(Feedback{CommandID: tasks[0].ID, Status: string(StatusCompleted)})
+	manager.CancelTask(tasks[1].ID)
+
+	want := []string{tasks[0].ID + ":completed", tasks[1].ID + ":cancelled"}
+	if len(finished) != 2 || finished[0] != want[0] || finished[1] != want[1] {
+		t.Errorf("Expected %v, got %v", want, finished)
+	}
+}
@@ -0,0 +1,539 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Cl0udRs4/dinot/internal/server/client"
+)
+
+var (
+	// ErrScheduleNotFound is returned when a schedule with the specified ID is not found
+	ErrScheduleNotFound = errors.New("schedule not found")
+
+	// ErrInvalidSchedule is returned when a schedule does not set exactly one
+	// of a run time, a cron expression or the on-register trigger
+	ErrInvalidSchedule = errors.New("invalid schedule")
+)
+
+// DefaultScheduleCheckInterval is how often the scheduler looks for due
+// schedules
+const DefaultScheduleCheckInterval = time.Second
+
+// ScheduleKind is what triggers a schedule
+type ScheduleKind string
+
+const (
+	// ScheduleOnce runs once at a fixed time
+	ScheduleOnce ScheduleKind = "once"
+	// ScheduleCron runs whenever a cron expression matches
+	ScheduleCron ScheduleKind = "cron"
+	// ScheduleOnRegister runs on every matching client that registers for
+	// the first time
+	ScheduleOnRegister ScheduleKind = "on_register"
+)
+
+// ScheduleSpec describes a schedule to create. Exactly one of RunAt, Cron
+// and OnRegister must be set.
+type ScheduleSpec struct {
+	// Name is an optional operator-facing label
+	Name string
+
+	// Selector selects the clients each run targets. It may be empty for
+	// on-register schedules, which then apply to every new client.
+	Selector client.Target
+
+	// Module is the name of the module to run
+	Module string
+
+	// Params are the module parameters
+	Params json.RawMessage
+
+	// RunAt is when a one-shot schedule runs
+	RunAt time.Time
+
+	// Cron is the expression of a recurring schedule, see ParseCron
+	Cron string
+
+	// OnRegister runs the module on every matching client that registers
+	// for the first time
+	OnRegister bool
+}
+
+// Schedule is a task that runs later, repeatedly or when clients register.
+// Every run creates a batch.
+type Schedule struct {
+	// ID is the unique identifier for the schedule
+	ID string `json:"id"`
+
+	// Name is an optional operator-facing label
+	Name string `json:"name,omitempty"`
+
+	// Kind is what triggers the schedule
+	Kind ScheduleKind `json:"kind"`
+
+	// Selector is the console-style representation of the selected clients
+	Selector string `json:"selector"`
+
+	// Module is the name of the module the schedule runs
+	Module string `json:"module"`
+
+	// Params are the module parameters
+	Params json.RawMessage `json:"params,omitempty"`
+
+	// RunAt is when a one-shot schedule runs
+	RunAt *time.Time `json:"run_at,omitempty"`
+
+	// Cron is the expression of a recurring schedule
+	Cron string `json:"cron,omitempty"`
+
+	// Enabled indicates whether the schedule fires
+	Enabled bool `json:"enabled"`
+
+	// CreatedAt is when the schedule was created
+	CreatedAt time.Time `json:"created_at"`
+
+	// NextRun is when the schedule fires next; nil if it is disabled, has
+	// no more runs or is triggered by registrations
+	NextRun *time.Time `json:"next_run,omitempty"`
+
+	// LastRun is when the schedule last fired
+	LastRun *time.Time `json:"last_run,omitempty"`
+
+	// LastBatchID is the batch created by the last run
+	LastBatchID string `json:"last_batch_id,omitempty"`
+
+	// LastError is why the last run failed to create a batch, if it did
+	LastError string `json:"last_error,omitempty"`
+
+	// Runs is the number of times the schedule fired
+	Runs int `json:"runs"`
+
+	// cron is the parsed cron expression of a recurring schedule
+	cron *CronSchedule
+}
+
+// Scheduler runs schedules through the task manager
+type Scheduler struct {
+	// taskManager creates the batch of every run
+	taskManager *TaskManager
+
+	// schedules maps schedule IDs to Schedule objects
+	schedules map[string]*Schedule
+
+	// path is the file the schedules are persisted to, if any
+	path string
+
+	// checkInterval is how often to look for due schedules
+	checkInterval time.Duration
+
+	// seq makes schedule IDs unique within a single nanosecond
+	seq uint64
+
+	// ctx is the context for controlling the scheduler's lifecycle
+	ctx context.Context
+
+	// cancel is the function to cancel the scheduler's context
+	cancel context.CancelFunc
+
+	// wg is used to wait for the scheduler to shut down
+	wg sync.WaitGroup
+
+	// mu protects concurrent access to the schedules
+	mu sync.Mutex
+}
+
+// NewScheduler creates a new scheduler and subscribes it to client
+// registrations
+func NewScheduler(taskManager *TaskManager) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s := &Scheduler{
+		taskManager:   taskManager,
+		schedules:     make(map[string]*Schedule),
+		checkInterval: DefaultScheduleCheckInterval,
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+
+	taskManager.clientManager.OnRegister(s.handleRegister)
+	return s
+}
+
+// Start starts running due schedules in the background
+func (s *Scheduler) Start() {
+	s.wg.Add(1)
+	go s.loop()
+}
+
+// Stop stops the scheduler
+func (s *Scheduler) Stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// SetStorePath sets the file the schedules are persisted to. Changes are
+// saved automatically once a path is set.
+func (s *Scheduler) SetStorePath(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.path = path
+}
+
+// Load reads the schedules from the store file. A missing file is not an
+// error. Recurring schedules resume from now, skipping runs missed while
+// the server was down; one-shot schedules that were due run straight away.
+func (s *Scheduler) Load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read schedule store: %w", err)
+	}
+
+	var schedules []*Schedule
+	if err := json.Unmarshal(data, &schedules); err != nil {
+		return fmt.Errorf("failed to decode schedule store: %w", err)
+	}
+
+	now := time.Now()
+	for _, schedule := range schedules {
+		if schedule.Kind == ScheduleCron {
+			if schedule.cron, err = ParseCron(schedule.Cron); err != nil {
+				return fmt.Errorf("schedule %s: %w", schedule.ID, err)
+			}
+		}
+		schedule.NextRun = schedule.nextRun(now)
+		s.schedules[schedule.ID] = schedule
+	}
+
+	return nil
+}
+
+// save writes the schedules to the store file; callers must hold s.mu
+func (s *Scheduler) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s.sorted(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode schedule store: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create schedule store directory: %w", err)
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write schedule store: %w", err)
+	}
+
+	return os.Rename(tmpPath, s.path)
+}
+
+// CreateSchedule validates and stores a new schedule
+func (s *Scheduler) CreateSchedule(spec ScheduleSpec) (*Schedule, error) {
+	if spec.Module == "" {
+		return nil, ErrMissingModule
+	}
+
+	if err := validateParams(spec.Params); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	schedule := &Schedule{
+		Name:      spec.Name,
+		Selector:  spec.Selector.String(),
+		Module:    spec.Module,
+		Params:    spec.Params,
+		Enabled:   true,
+		CreatedAt: now,
+	}
+
+	triggers := 0
+	if !spec.RunAt.IsZero() {
+		triggers++
+		if !spec.RunAt.After(now) {
+			return nil, fmt.Errorf("%w: run time is in the past", ErrInvalidSchedule)
+		}
+		runAt := spec.RunAt
+		schedule.Kind = ScheduleOnce
+		schedule.RunAt = &runAt
+	}
+	if spec.Cron != "" {
+		triggers++
+		cron, err := ParseCron(spec.Cron)
+		if err != nil {
+			return nil, err
+		}
+		schedule.Kind = ScheduleCron
+		schedule.Cron = spec.Cron
+		schedule.cron = cron
+	}
+	if spec.OnRegister {
+		triggers++
+		schedule.Kind = ScheduleOnRegister
+	}
+	if triggers != 1 {
+		return nil, fmt.Errorf("%w: specify exactly one of run time, cron expression or on-register trigger", ErrInvalidSchedule)
+	}
+
+	// Only on-register schedules may omit the selector
+	if schedule.Kind != ScheduleOnRegister || schedule.Selector != "" {
+		if err := spec.Selector.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if spec.Selector.Filter != "" {
+		if _, err := client.ParseFilter(spec.Selector.Filter); err != nil {
+			return nil, err
+		}
+	}
+
+	schedule.NextRun = schedule.nextRun(now)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	schedule.ID = fmt.Sprintf("schedule-%d-%d", now.UnixNano(), s.seq)
+	s.schedules[schedule.ID] = schedule
+
+	if err := s.save(); err != nil {
+		delete(s.schedules, schedule.ID)
+		return nil, err
+	}
+
+	return schedule.clone(), nil
+}
+
+// GetSchedule returns a copy of a schedule
+func (s *Scheduler) GetSchedule(id string) (*Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedule, exists := s.schedules[id]
+	if !exists {
+		return nil, ErrScheduleNotFound
+	}
+
+	return schedule.clone(), nil
+}
+
+// GetAllSchedules returns copies of all schedules ordered by creation time
+func (s *Scheduler) GetAllSchedules() []*Schedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedules := s.sorted()
+	for i, schedule := range schedules {
+		schedules[i] = schedule.clone()
+	}
+	return schedules
+}
+
+// DeleteSchedule removes a schedule. Batches it already created are kept.
+func (s *Scheduler) DeleteSchedule(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedule, exists := s.schedules[id]
+	if !exists {
+		return ErrScheduleNotFound
+	}
+
+	delete(s.schedules, id)
+	if err := s.save(); err != nil {
+		s.schedules[id] = schedule
+		return err
+	}
+
+	return nil
+}
+
+// SetScheduleEnabled enables or disables a schedule and returns a copy of it
+func (s *Scheduler) SetScheduleEnabled(id string, enabled bool) (*Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedule, exists := s.schedules[id]
+	if !exists {
+		return nil, ErrScheduleNotFound
+	}
+
+	schedule.Enabled = enabled
+	schedule.NextRun = schedule.nextRun(time.Now())
+	if err := s.save(); err != nil {
+		return nil, err
+	}
+
+	return schedule.clone(), nil
+}
+
+// loop runs due schedules until the scheduler is stopped
+func (s *Scheduler) loop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.runDue(now)
+		}
+	}
+}
+
+// runDue runs every enabled schedule whose next run is not after now
+func (s *Scheduler) runDue(now time.Time) {
+	s.mu.Lock()
+	var due []*Schedule
+	for _, schedule := range s.sorted() {
+		if schedule.Enabled && schedule.NextRun != nil && !schedule.NextRun.After(now) {
+			due = append(due, schedule.clone())
+		}
+	}
+	s.mu.Unlock()
+
+	for _, schedule := range due {
+		s.run(schedule.ID, client.ParseTarget(schedule.Selector), schedule, now)
+	}
+}
+
+// handleRegister runs the on-register schedules matching a client that
+// registered for the first time
+func (s *Scheduler) handleRegister(c *client.Client, known bool) {
+	if known {
+		return
+	}
+
+	s.mu.Lock()
+	var matching []*Schedule
+	for _, schedule := range s.sorted() {
+		if schedule.Enabled && schedule.Kind == ScheduleOnRegister {
+			matching = append(matching, schedule.clone())
+		}
+	}
+	s.mu.Unlock()
+
+	for _, schedule := range matching {
+		if schedule.Selector != "" && !s.selects(client.ParseTarget(schedule.Selector), c.ID) {
+			continue
+		}
+		s.run(schedule.ID, client.Target{ClientID: c.ID}, schedule, time.Now())
+	}
+}
+
+// selects reports whether a selector currently selects a client
+func (s *Scheduler) selects(selector client.Target, clientID string) bool {
+	clients, err := s.taskManager.clientManager.ResolveTarget(selector)
+	if err != nil {
+		return false
+	}
+
+	for _, c := range clients {
+		if c.ID == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// run creates the batch of a schedule run and records the outcome. snapshot
+// is a copy of the schedule taken when the run was triggered.
+func (s *Scheduler) run(id string, selector client.Target, snapshot *Schedule, now time.Time) {
+	batch, err := s.taskManager.CreateBatch(selector, snapshot.Module, snapshot.Params)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The schedule may have been deleted while the batch was created
+	schedule, exists := s.schedules[id]
+	if !exists {
+		return
+	}
+
+	schedule.Runs++
+	schedule.LastRun = &now
+	schedule.LastError = ""
+	if err != nil {
+		schedule.LastError = err.Error()
+	} else {
+		schedule.LastBatchID = batch.ID
+	}
+	schedule.NextRun = schedule.nextRun(now)
+
+	if err := s.save(); err != nil {
+		fmt.Printf("Warning: Failed to save schedules: %v\n", err)
+	}
+}
+
+// sorted returns the schedules ordered by creation time; callers must hold
+// s.mu
+func (s *Scheduler) sorted() []*Schedule {
+	schedules := make([]*Schedule, 0, len(s.schedules))
+	for _, schedule := range s.schedules {
+		schedules = append(schedules, schedule)
+	}
+
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].CreatedAt.Before(schedules[j].CreatedAt)
+	})
+	return schedules
+}
+
+// nextRun returns when the schedule fires next after now, or nil
+func (sc *Schedule) nextRun(now time.Time) *time.Time {
+	if !sc.Enabled {
+		return nil
+	}
+
+	switch sc.Kind {
+	case ScheduleOnce:
+		if sc.Runs > 0 || sc.RunAt == nil {
+			return nil
+		}
+		runAt := *sc.RunAt
+		return &runAt
+	case ScheduleCron:
+		if sc.cron == nil {
+			return nil
+		}
+		next := sc.cron.Next(now)
+		if next.IsZero() {
+			return nil
+		}
+		return &next
+	default:
+		return nil
+	}
+}
+
+// clone returns a copy of the schedule that is safe to use without the
+// scheduler lock
+func (sc *Schedule) clone() *Schedule {
+	copied := *sc
+	return &copied
+}
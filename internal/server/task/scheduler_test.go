@@ -0,0 +1,161 @@
+package task
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Cl0udRs4/dinot/internal/server/client"
+)
+
+func TestSchedulerOnceAndCron(t *testing.T) {
+	manager, _ := setupTestManager()
+	scheduler := NewScheduler(manager)
+	now := time.Now()
+
+	once, err := scheduler.CreateSchedule(ScheduleSpec{Selector: client.Target{Tag: "dmz"}, Module: "inventory", RunAt: now.Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("Failed to create one-shot schedule: %v", err)
+	}
+
+	recurring, err := scheduler.CreateSchedule(ScheduleSpec{Selector: client.Target{ClientIDs: []string{"client-1"}}, Module: "check", Cron: "@every 6h"})
+	if err != nil {
+		t.Fatalf("Failed to create recurring schedule: %v", err)
+	}
+
+	// Nothing is due yet
+	scheduler.runDue(now)
+	if got := len(manager.GetAllBatches()); got != 0 {
+		t.Fatalf("Expected no batches, got %d", got)
+	}
+
+	scheduler.runDue(now.Add(2 * time.Hour))
+	once, _ = scheduler.GetSchedule(once.ID)
+	if once.Runs != 1 || once.NextRun != nil || once.LastBatchID == "" {
+		t.Errorf("Expected the one-shot schedule to run once, got %+v", once)
+	}
+
+	batch, err := manager.GetBatch(once.LastBatchID)
+	if err != nil || len(batch.TaskIDs) != 2 || batch.Module != "inventory" {
+		t.Errorf("Unexpected batch %+v (%v)", batch, err)
+	}
+
+	recurring, _ = scheduler.GetSchedule(recurring.ID)
+	if recurring.Runs != 0 {
+		t.Errorf("Expected the recurring schedule not to be due, got %d runs", recurring.Runs)
+	}
+
+	due := *recurring.NextRun
+	scheduler.runDue(due)
+	scheduler.runDue(due.Add(time.Minute))
+	recurring, _ = scheduler.GetSchedule(recurring.ID)
+	if recurring.Runs != 1 || !recurring.NextRun.Equal(due.Add(6*time.Hour)) {
+		t.Errorf("Expected one run and the next in 6h, got %+v", recurring)
+	}
+
+	// A disabled schedule does not fire
+	if _, err := scheduler.SetScheduleEnabled(recurring.ID, false); err != nil {
+		t.Fatalf("Failed to disable schedule: %v", err)
+	}
+	scheduler.runDue(due.Add(24 * time.Hour))
+	if got := len(manager.GetAllBatches()); got != 2 {
+		t.Errorf("Expected 2 batches, got %d", got)
+	}
+}
+
+func TestSchedulerOnRegister(t *testing.T) {
+	manager, clientManager := setupTestManager()
+	scheduler := NewScheduler(manager)
+
+	schedule, err := scheduler.CreateSchedule(ScheduleSpec{Selector: client.Target{Filter: "os=windows"}, Module: "inventory", OnRegister: true})
+	if err != nil {
+		t.Fatalf("Failed to create schedule: %v", err)
+	}
+
+	clientManager.RegisterClient(client.NewClient("linux-1", "Linux", "10.0.0.3", "linux", "amd64", []string{"shell"}, "tcp"))
+	clientManager.RegisterClient(client.NewClient("windows-1", "Windows", "10.0.0.4", "windows", "amd64", []string{"shell"}, "tcp"))
+
+	schedule, _ = scheduler.GetSchedule(schedule.ID)
+	if schedule.Runs != 1 {
+		t.Fatalf("Expected 1 run, got %d", schedule.Runs)
+	}
+
+	batch, _ := manager.GetBatch(schedule.LastBatchID)
+	task, _ := manager.GetTask(batch.TaskIDs[0])
+	if len(batch.TaskIDs) != 1 || task.ClientID != "windows-1" {
+		t.Errorf("Expected a task for windows-1 only, got %+v", batch)
+	}
+
+	// Reconnecting clients do not trigger the schedule again
+	clientManager.UpdateClientStatus("windows-1", client.StatusOffline, "")
+	clientManager.RegisterClient(client.NewClient("windows-1", "Windows", "10.0.0.4", "windows", "amd64", []string{"shell"}, "tcp"))
+
+	if schedule, _ = scheduler.GetSchedule(schedule.ID); schedule.Runs != 1 {
+		t.Errorf("Expected a reconnect not to trigger the schedule, got %d runs", schedule.Runs)
+	}
+}
+
+func TestSchedulerPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedules.json")
+
+	manager, _ := setupTestManager()
+	scheduler := NewScheduler(manager)
+	scheduler.SetStorePath(path)
+
+	created, err := scheduler.CreateSchedule(ScheduleSpec{Name: "nightly", Selector: client.Target{Tag: "dmz"}, Module: "check", Cron: "0 3 * * *"})
+	if err != nil {
+		t.Fatalf("Failed to create schedule: %v", err)
+	}
+
+	// A new scheduler restores the schedule and its next run
+	manager, _ = setupTestManager()
+	restored := NewScheduler(manager)
+	restored.SetStorePath(path)
+	if err := restored.Load(); err != nil {
+		t.Fatalf("Failed to load schedules: %v", err)
+	}
+
+	schedule, err := restored.GetSchedule(created.ID)
+	if err != nil {
+		t.Fatalf("Failed to get restored schedule: %v", err)
+	}
+	if schedule.Name != "nightly" || schedule.Selector != "tag:dmz" || schedule.NextRun == nil || schedule.NextRun.Hour() != 3 {
+		t.Errorf("Unexpected restored schedule %+v", schedule)
+	}
+
+	restored.runDue(*schedule.NextRun)
+	if schedule, _ = restored.GetSchedule(created.ID); schedule.Runs != 1 {
+		t.Errorf("Expected the restored schedule to run, got %d runs", schedule.Runs)
+	}
+}
+
+func TestCreateScheduleErrors(t *testing.T) {
+	manager, _ := setupTestManager()
+	scheduler := NewScheduler(manager)
+	dmz := client.Target{Tag: "dmz"}
+
+	tests := []struct {
+		name string
+		spec ScheduleSpec
+		want error
+	}{
+		{"no trigger", ScheduleSpec{Selector: dmz, Module: "check"}, ErrInvalidSchedule},
+		{"two triggers", ScheduleSpec{Selector: dmz, Module: "check", Cron: "@daily", OnRegister: true}, ErrInvalidSchedule},
+		{"past run time", ScheduleSpec{Selector: dmz, Module: "check", RunAt: time.Now().Add(-time.Minute)}, ErrInvalidSchedule},
+		{"bad cron", ScheduleSpec{Selector: dmz, Module: "check", Cron: "every day"}, ErrInvalidCron},
+		{"missing selector", ScheduleSpec{Module: "check", Cron: "@daily"}, client.ErrInvalidTarget},
+		{"bad filter", ScheduleSpec{Selector: client.Target{Filter: "os<"}, Module: "check", OnRegister: true}, client.ErrInvalidFilter},
+		{"missing module", ScheduleSpec{Selector: dmz, Cron: "@daily"}, ErrMissingModule},
+	}
+
+	for _, tt := range tests {
+		if _, err := scheduler.CreateSchedule(tt.spec); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	if _, err := scheduler.CreateSchedule(ScheduleSpec{Module: "inventory", OnRegister: true}); err != nil {
+		t.Errorf("Expected an on-register schedule without selector to be accepted, got %v", err)
+	}
+}
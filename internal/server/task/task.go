@@ -0,0 +1,156 @@
+// Package task tracks module invocations dispatched to clients
+package task
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// TaskStatus represents the current status of a task
+type TaskStatus string
+
+const (
+	// StatusPending indicates the task is queued and has not been sent to the client
+	StatusPending TaskStatus = "pending"
+	// StatusDispatched indicates the task was sent to the client
+	StatusDispatched TaskStatus = "dispatched"
+	// StatusProcessing indicates the client reported it is executing the task
+	StatusProcessing TaskStatus = "processing"
+	// StatusRetrying indicates the client is retrying the task after a failure
+	StatusRetrying TaskStatus = "retrying"
+	// StatusCompleted indicates the task finished successfully
+	StatusCompleted TaskStatus = "completed"
+	// StatusFailed indicates the task finished with an error
+	StatusFailed TaskStatus = "failed"
+	// StatusCancelling indicates a cancellation was sent to the client and
+	// the client has not confirmed it yet
+	StatusCancelling TaskStatus = "cancelling"
+	// StatusCancelled indicates the task was cancelled before it finished
+	StatusCancelled TaskStatus = "cancelled"
+	// StatusTimedOut indicates the task did not finish before its deadline
+	StatusTimedOut TaskStatus = "timed_out"
+)
+
+// TaskAction is what a task asks the client to do with its module
+type TaskAction string
+
+const (
+	// ActionLoad loads the module on the client
+	ActionLoad TaskAction = "load"
+	// ActionUnload unloads the module from the client
+	ActionUnload TaskAction = "unload"
+)
+
+// IsFinal reports whether the status is terminal
+func (s TaskStatus) IsFinal() bool {
+	return s == StatusCompleted || s == StatusFailed || s == StatusCancelled || s == StatusTimedOut
+}
+
+// Task represents a single module invocation on a single client
+type Task struct {
+	// ID is the unique identifier for the task, sent to the client as the command ID
+	ID string `json:"id"`
+
+	// ClientID is the ID of the client the task runs on
+	ClientID string `json:"client_id"`
+
+	// Module is the name of the module to execute
+	Module string `json:"module"`
+
+	// Action is set on tasks that load or unload the module rather than
+	// execute it
+	Action TaskAction `json:"action,omitempty"`
+
+	// Params are the module parameters
+	Params json.RawMessage `json:"params,omitempty"`
+
+	// Status is the current status of the task
+	Status TaskStatus `json:"status"`
+
+	// Result is the module output reported by the client
+	Result json.RawMessage `json:"result,omitempty"`
+
+	// Error is the error reported by the client if the task failed
+	Error string `json:"error,omitempty"`
+
+	// BatchID is the ID of the batch the task belongs to, if any
+	BatchID string `json:"batch_id,omitempty"`
+
+	// WorkflowID is the ID of the workflow the task is a step of, if any
+	WorkflowID string `json:"workflow_id,omitempty"`
+
+	// RetryCount is the number of retries reported by the client
+	RetryCount int `json:"retry_count,omitempty"`
+
+	// Timeout is how long in seconds the task may run once delivered; 0
+	// means no limit
+	Timeout int `json:"timeout,omitempty"`
+
+	// Deadline is when the task times out, set when it is delivered
+	Deadline *time.Time `json:"deadline,omitempty"`
+
+	// CreatedAt is when the task was created
+	CreatedAt time.Time `json:"created_at"`
+
+	// UpdatedAt is when the task status last changed
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// sending is set while the command of the task is on its way to the
+	// client, so that a cancellation is not sent ahead of it
+	sending bool
+
+	// mu protects concurrent access to the task data
+	mu sync.RWMutex
+}
+
+// newTask creates a new pending task
+func newTask(id, clientID, module string, params json.RawMessage) *Task {
+	now := time.Now()
+	return &Task{
+		ID:        id,
+		ClientID:  clientID,
+		Module:    module,
+		Params:    params,
+		Status:    StatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// GetStatus returns the current status of the task
+func (t *Task) GetStatus() TaskStatus {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.Status
+}
+
+// markDispatched moves a pending task to dispatched once its command was
+// sent. It reports whether the task was cancelled while the command was in
+// flight, in which case the cancellation still has to be sent.
+func (t *Task) markDispatched() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.sending = false
+	if t.Status != StatusPending {
+		return t.Status == StatusCancelling
+	}
+	t.Status = StatusDispatched
+	t.UpdatedAt = time.Now()
+
+	if t.Timeout > 0 {
+		deadline := t.UpdatedAt.Add(time.Duration(t.Timeout) * time.Second)
+		t.Deadline = &deadline
+	}
+	return false
+}
+
+// ToJSON converts the task to a JSON string
+func (t *Task) ToJSON() ([]byte, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return json.Marshal(t)
+}
@@ -0,0 +1,155 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// DefaultDeadlineCheckInterval is how often the deadline monitor looks
+	// for overdue tasks
+	DefaultDeadlineCheckInterval = 5 * time.Second
+
+	// TimeoutGrace is how long after a task's deadline the server waits for
+	// the client's result before marking the task timed out. It covers the
+	// delay of delivering the command and reporting the result.
+	TimeoutGrace = 30 * time.Second
+)
+
+// ModuleTimeout is the default timeout of a module's tasks
+type ModuleTimeout struct {
+	// Module is the module name
+	Module string `json:"module"`
+
+	// Timeout is the default timeout in seconds
+	Timeout int `json:"timeout"`
+}
+
+// SetDefaultTimeout sets the timeout of tasks whose module has no default
+// of its own. Zero disables it.
+func (m *TaskManager) SetDefaultTimeout(timeout time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.defaultTimeout = timeout
+}
+
+// DefaultTimeout returns the timeout of tasks whose module has no default
+func (m *TaskManager) DefaultTimeout() time.Duration {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.defaultTimeout
+}
+
+// SetModuleTimeout sets the default timeout of a module's tasks. Zero
+// removes it, so that the module falls back to the global default.
+func (m *TaskManager) SetModuleTimeout(module string, timeout time.Duration) error {
+	if module == "" {
+		return ErrMissingModule
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if timeout <= 0 {
+		delete(m.moduleTimeouts, module)
+	} else {
+		m.moduleTimeouts[module] = timeout
+	}
+	return nil
+}
+
+// ModuleTimeouts returns the per-module default timeouts sorted by module
+func (m *TaskManager) ModuleTimeouts() []ModuleTimeout {
+	m.mu.RLock()
+	timeouts := make([]ModuleTimeout, 0, len(m.moduleTimeouts))
+	for module, timeout := range m.moduleTimeouts {
+		timeouts = append(timeouts, ModuleTimeout{Module: module, Timeout: seconds(timeout)})
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(timeouts, func(i, j int) bool {
+		return timeouts[i].Module < timeouts[j].Module
+	})
+	return timeouts
+}
+
+// Start starts the deadline monitor, which marks overdue tasks timed out
+func (m *TaskManager) Start() {
+	m.wg.Add(1)
+	go m.monitorDeadlines()
+}
+
+// Stop stops the deadline monitor
+func (m *TaskManager) Stop() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+// monitorDeadlines periodically expires overdue tasks
+func (m *TaskManager) monitorDeadlines() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(DefaultDeadlineCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.ExpireTasks(now)
+		}
+	}
+}
+
+// ExpireTasks marks the tasks that have not finished within TimeoutGrace
+// of their deadline as timed out, and returns them
+func (m *TaskManager) ExpireTasks(now time.Time) []*Task {
+	var expired []*Task
+
+	for _, task := range m.GetAllTasks() {
+		task.mu.Lock()
+		if !task.Status.IsFinal() && task.Deadline != nil && now.After(task.Deadline.Add(TimeoutGrace)) {
+			task.Status = StatusTimedOut
+			task.Error = fmt.Sprintf("no result from the client within %ds", task.Timeout)
+			task.UpdatedAt = now
+			expired = append(expired, task)
+		}
+		task.mu.Unlock()
+	}
+
+	for _, task := range expired {
+		m.taskFinished(task)
+	}
+
+	return expired
+}
+
+// timeoutFor returns the timeout in seconds of a new task: the timeout
+// parameter if set, otherwise the module's default or the global default.
+// Callers must hold m.mu and have validated params.
+func (m *TaskManager) timeoutFor(module string, params json.RawMessage) int {
+	var object struct {
+		Timeout *int `json:"timeout"`
+	}
+	if len(params) > 0 && json.Unmarshal(params, &object) == nil && object.Timeout != nil {
+		return *object.Timeout
+	}
+
+	if timeout, ok := m.moduleTimeouts[module]; ok {
+		return seconds(timeout)
+	}
+	return seconds(m.defaultTimeout)
+}
+
+// seconds converts a duration to whole seconds, rounding up
+func seconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int((d + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,78 @@
+package task
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTaskTimeouts(t *testing.T) {
+	manager, _ := setupTestManager()
+	manager.SetDefaultTimeout(10 * time.Minute)
+	manager.SetModuleTimeout("shell", 90*time.Second)
+
+	tests := []struct {
+		module string
+		params string
+		want   int
+	}{
+		{"shell", `{"command":"id","timeout":5}`, 5},
+		{"shell", `{"command":"id"}`, 90},
+		{"inventory", ``, 600},
+	}
+
+	for _, tt := range tests {
+		task, err := manager.CreateTask("client-1", tt.module, json.RawMessage(tt.params))
+		if err != nil {
+			t.Fatalf("Failed to create task: %v", err)
+		}
+		if task.Timeout != tt.want {
+			t.Errorf("%s %s: expected timeout %d, got %d", tt.module, tt.params, tt.want, task.Timeout)
+		}
+	}
+
+	// The timeout is sent to the client along with the command ID
+	commands := manager.PendingCommands("client-1")
+	var command Command
+	var params map[string]interface{}
+	json.Unmarshal(commands[1], &command)
+	json.Unmarshal(command.Params, &params)
+	if params["timeout"] != float64(90) {
+		t.Errorf("Expected the module default to be sent, got %s", command.Params)
+	}
+
+	if _, err := manager.CreateTask("client-1", "shell", json.RawMessage(`{"timeout":"1m"}`)); err != ErrInvalidTimeout {
+		t.Errorf("Expected ErrInvalidTimeout, got %v", err)
+	}
+
+	manager.SetModuleTimeout("shell", 0)
+	if timeouts := manager.ModuleTimeouts(); len(timeouts) != 0 {
+		t.Errorf("Expected no module timeouts, got %+v", timeouts)
+	}
+}
+
+func TestExpireTasks(t *testing.T) {
+	manager, _ := setupTestManager()
+
+	task, _ := manager.CreateTask("client-1", "shell", json.RawMessage(`{"timeout":60}`))
+	done, _ := manager.CreateTask("client-1", "shell", json.RawMessage(`{"timeout":60}`))
+	manager.PendingCommands("client-1")
+
+	if task.Deadline == nil {
+		t.Fatal("Expected the deadline to be set on delivery")
+	}
+	manager.HandleFeedback(Feedback{CommandID: done.ID, Status: "completed"})
+
+	// The client gets a grace period to report the result
+	if expired := manager.ExpireTasks(task.Deadline.Add(time.Second)); len(expired) != 0 {
+		t.Errorf("Expected no task to expire within the grace period, got %d", len(expired))
+	}
+
+	expired := manager.ExpireTasks(task.Deadline.Add(TimeoutGrace + time.Second))
+	if len(expired) != 1 || expired[0] != task || task.GetStatus() != StatusTimedOut {
+		t.Errorf("Expected the unfinished task to time out, got %d tasks and status %s", len(expired), task.GetStatus())
+	}
+	if done.GetStatus() != StatusCompleted {
+		t.Errorf("Expected the finished task to stay completed, got %s", done.GetStatus())
+	}
+}
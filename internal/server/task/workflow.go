@@ -0,0 +1,606 @@
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Cl0udRs4/dinot/internal/server/client"
+)
+
+var (
+	// ErrWorkflowNotFound is returned when a workflow with the specified ID is not found
+	ErrWorkflowNotFound = errors.New("workflow not found")
+
+	// ErrInvalidWorkflow is returned when a workflow definition is malformed
+	ErrInvalidWorkflow = errors.New("invalid workflow")
+)
+
+// stepNamePattern matches valid step names. Dots are not allowed since they
+// separate the parts of a reference.
+var stepNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// WorkflowStatus is the aggregate status of a workflow or of one of its runs
+type WorkflowStatus string
+
+const (
+	// WorkflowRunning indicates some steps have not finished
+	WorkflowRunning WorkflowStatus = "running"
+	// WorkflowCompleted indicates every run finished without a failed step
+	WorkflowCompleted WorkflowStatus = "completed"
+	// WorkflowPartial indicates some runs finished with a failed step
+	WorkflowPartial WorkflowStatus = "partial"
+	// WorkflowFailed indicates every run finished with a failed step
+	WorkflowFailed WorkflowStatus = "failed"
+)
+
+// StepStatus is the status of a workflow step on one client
+type StepStatus string
+
+const (
+	// StepWaiting indicates the step's dependencies have not finished
+	StepWaiting StepStatus = "waiting"
+	// StepRunning indicates the step's task was created and has not finished
+	StepRunning StepStatus = "running"
+	// StepSkipped indicates the step's condition did not hold
+	StepSkipped StepStatus = "skipped"
+	// StepCompleted indicates the step's task completed
+	StepCompleted StepStatus = "completed"
+	// StepFailed indicates the step's task failed or could not be created
+	StepFailed StepStatus = "failed"
+	// StepCancelled indicates the step's task was cancelled
+	StepCancelled StepStatus = "cancelled"
+	// StepTimedOut indicates the step's task timed out
+	StepTimedOut StepStatus = "timed_out"
+)
+
+// IsFinal reports whether the status is terminal
+func (s StepStatus) IsFinal() bool {
+	return s != StepWaiting && s != StepRunning
+}
+
+// StepDefinition describes a step of a new workflow
+type StepDefinition struct {
+	// Name identifies the step within the workflow
+	Name string `json:"name"`
+
+	// Module is the name of the module the step runs
+	Module string `json:"module"`
+
+	// Params are the module parameters. String values may contain
+	// {{steps.<name>.result.<path>}} and {{client.id}} templates.
+	Params json.RawMessage `json:"params,omitempty"`
+
+	// DependsOn are the names of the steps that must finish first
+	DependsOn []string `json:"dependsOn,omitempty"`
+
+	// When is the condition under which the step runs, e.g.
+	// `steps.probe.result.os == linux`. By default a step runs if all its
+	// dependencies completed.
+	When string `json:"when,omitempty"`
+}
+
+// WorkflowDefinition describes a new workflow
+type WorkflowDefinition struct {
+	// Name is an optional human readable name
+	Name string `json:"name,omitempty"`
+
+	// Steps are the steps of the workflow
+	Steps []StepDefinition `json:"steps"`
+}
+
+// WorkflowStep is a step of a workflow
+type WorkflowStep struct {
+	// Name identifies the step within the workflow
+	Name string `json:"name"`
+
+	// Module is the name of the module the step runs
+	Module string `json:"module"`
+
+	// Params are the module parameters before templating
+	Params json.RawMessage `json:"params,omitempty"`
+
+	// DependsOn are the names of the steps that must finish first
+	DependsOn []string `json:"depends_on,omitempty"`
+
+	// When is the condition under which the step runs
+	When string `json:"when,omitempty"`
+
+	// condition is the parsed When expression, if any
+	condition *stepCondition
+}
+
+// StepState is the state of a workflow step on one client
+type StepState struct {
+	// Name is the name of the step
+	Name string `json:"name"`
+
+	// Status is the current status of the step
+	Status StepStatus `json:"status"`
+
+	// TaskID is the ID of the step's task once it was created
+	TaskID string `json:"task_id,omitempty"`
+
+	// Error is the error of the step's task, or why it could not be created
+	Error string `json:"error,omitempty"`
+
+	// UpdatedAt is when the step status last changed
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WorkflowRun is the progress of a workflow on one client
+type WorkflowRun struct {
+	// ClientID is the ID of the client the run executes on
+	ClientID string `json:"client_id"`
+
+	// Status is the status of the run
+	Status WorkflowStatus `json:"status"`
+
+	// Steps is the state of every step, in workflow order
+	Steps []StepState `json:"steps"`
+}
+
+// Workflow is a set of module steps that depend on each other, executed
+// independently on every selected client
+type Workflow struct {
+	// ID is the unique identifier for the workflow
+	ID string `json:"id"`
+
+	// Name is an optional human readable name
+	Name string `json:"name,omitempty"`
+
+	// Selector is the console-style representation of the selected clients
+	Selector string `json:"selector"`
+
+	// Status is the aggregate status of the runs
+	Status WorkflowStatus `json:"status"`
+
+	// Steps are the steps of the workflow
+	Steps []WorkflowStep `json:"steps"`
+
+	// Runs is the progress on every client, ordered by client ID
+	Runs []*WorkflowRun `json:"runs"`
+
+	// CreatedAt is when the workflow was created
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateWorkflow starts a workflow on every client selected by a selector
+// and returns a snapshot of it
+func (m *TaskManager) CreateWorkflow(selector client.Target, definition WorkflowDefinition) (*Workflow, error) {
+	steps, err := compileWorkflow(definition.Steps)
+	if err != nil {
+		return nil, err
+	}
+
+	clients, err := m.clientManager.ResolveTarget(selector)
+	if err != nil {
+		return nil, err
+	}
+	if len(clients) == 0 {
+		return nil, ErrNoClientsSelected
+	}
+
+	now := time.Now()
+	workflow := &Workflow{
+		Name:      definition.Name,
+		Selector:  selector.String(),
+		Status:    WorkflowRunning,
+		Steps:     steps,
+		CreatedAt: now,
+	}
+	for _, c := range clients {
+		run := &WorkflowRun{ClientID: c.ID, Status: WorkflowRunning}
+		for _, step := range steps {
+			run.Steps = append(run.Steps, StepState{Name: step.Name, Status: StepWaiting, UpdatedAt: now})
+		}
+		workflow.Runs = append(workflow.Runs, run)
+	}
+	sort.Slice(workflow.Runs, func(i, j int) bool {
+		return workflow.Runs[i].ClientID < workflow.Runs[j].ClientID
+	})
+
+	m.workflowMu.Lock()
+	defer m.workflowMu.Unlock()
+
+	m.mu.Lock()
+	workflow.ID = m.nextID("workflow")
+	m.mu.Unlock()
+	m.workflows[workflow.ID] = workflow
+
+	for _, run := range workflow.Runs {
+		m.advanceRun(workflow, run)
+	}
+	workflow.Status = workflowStatus(workflow.Runs)
+
+	return workflow.snapshot(), nil
+}
+
+// GetWorkflow returns a snapshot of a workflow by ID
+func (m *TaskManager) GetWorkflow(workflowID string) (*Workflow, error) {
+	m.workflowMu.Lock()
+	defer m.workflowMu.Unlock()
+
+	workflow, exists := m.workflows[workflowID]
+	if !exists {
+		return nil, ErrWorkflowNotFound
+	}
+
+	return workflow.snapshot(), nil
+}
+
+// GetAllWorkflows returns snapshots of all workflows ordered by creation time
+func (m *TaskManager) GetAllWorkflows() []*Workflow {
+	m.workflowMu.Lock()
+	workflows := make([]*Workflow, 0, len(m.workflows))
+	for _, workflow := range m.workflows {
+		workflows = append(workflows, workflow.snapshot())
+	}
+	m.workflowMu.Unlock()
+
+	sort.Slice(workflows, func(i, j int) bool {
+		return workflows[i].CreatedAt.Before(workflows[j].CreatedAt)
+	})
+	return workflows
+}
+
+// advanceWorkflow records the final status of a workflow step's task and
+// starts the steps that were waiting for it
+func (m *TaskManager) advanceWorkflow(task *Task) {
+	task.mu.RLock()
+	workflowID, status, taskErr := task.WorkflowID, task.Status, task.Error
+	task.mu.RUnlock()
+
+	if workflowID == "" || !status.IsFinal() {
+		return
+	}
+
+	m.workflowMu.Lock()
+	defer m.workflowMu.Unlock()
+
+	workflow, exists := m.workflows[workflowID]
+	if !exists {
+		return
+	}
+
+	for _, run := range workflow.Runs {
+		if run.ClientID != task.ClientID {
+			continue
+		}
+
+		for i := range run.Steps {
+			state := &run.Steps[i]
+			if state.TaskID != task.ID || state.Status != StepRunning {
+				continue
+			}
+
+			state.Status = StepStatus(status)
+			state.Error = taskErr
+			state.UpdatedAt = time.Now()
+			m.advanceRun(workflow, run)
+			workflow.Status = workflowStatus(workflow.Runs)
+			return
+		}
+	}
+}
+
+// advanceRun starts or skips every waiting step whose dependencies have
+// finished, until no more steps become ready. Callers must hold
+// m.workflowMu.
+func (m *TaskManager) advanceRun(workflow *Workflow, run *WorkflowRun) {
+	lookup := m.runLookup(run)
+
+	for progressed := true; progressed; {
+		progressed = false
+
+		for i, step := range workflow.Steps {
+			state := &run.Steps[i]
+			if state.Status != StepWaiting || !run.ready(step) {
+				continue
+			}
+			progressed = true
+			state.UpdatedAt = time.Now()
+
+			if !run.shouldRun(step, lookup) {
+				state.Status = StepSkipped
+				continue
+			}
+
+			taskID, err := m.startStep(workflow.ID, run.ClientID, step, lookup)
+			if err != nil {
+				state.Status = StepFailed
+				state.Error = err.Error()
+				continue
+			}
+			state.Status = StepRunning
+			state.TaskID = taskID
+		}
+	}
+
+	run.Status = runStatus(run.Steps)
+}
+
+// startStep renders a step's parameters and queues its task on the run's
+// client
+func (m *TaskManager) startStep(workflowID, clientID string, step WorkflowStep, lookup stepLookup) (string, error) {
+	params, err := renderParams(step.Params, lookup)
+	if err != nil {
+		return "", err
+	}
+	if err := validateParams(params); err != nil {
+		return "", err
+	}
+
+	c, err := m.clientManager.GetClient(clientID)
+	if err != nil {
+		return "", err
+	}
+
+	tasks, err := m.queueTasks([]*client.Client{c}, step.Module, params, func(tasks []*Task) {
+		tasks[0].WorkflowID = workflowID
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return tasks[0].ID, nil
+}
+
+// runLookup resolves references against the step states of a run. Callers
+// must hold m.workflowMu while using it.
+func (m *TaskManager) runLookup(run *WorkflowRun) stepLookup {
+	return func(ref string) (interface{}, bool) {
+		if ref == "client.id" {
+			return run.ClientID, true
+		}
+
+		parts := strings.Split(ref, ".")
+		state := run.step(parts[1])
+		if state == nil {
+			return nil, false
+		}
+
+		switch parts[2] {
+		case "status":
+			return string(state.Status), true
+		case "error":
+			return state.Error, true
+		}
+
+		if state.TaskID == "" {
+			return nil, false
+		}
+		task, err := m.GetTask(state.TaskID)
+		if err != nil {
+			return nil, false
+		}
+
+		task.mu.RLock()
+		result := task.Result
+		task.mu.RUnlock()
+
+		var value interface{}
+		if len(result) == 0 || json.Unmarshal(result, &value) != nil {
+			return nil, false
+		}
+		return walkPath(value, parts[3:])
+	}
+}
+
+// step returns the state of the named step
+func (r *WorkflowRun) step(name string) *StepState {
+	for i := range r.Steps {
+		if r.Steps[i].Name == name {
+			return &r.Steps[i]
+		}
+	}
+	return nil
+}
+
+// ready reports whether all dependencies of a step have finished
+func (r *WorkflowRun) ready(step WorkflowStep) bool {
+	for _, dep := range step.DependsOn {
+		if state := r.step(dep); state == nil || !state.Status.IsFinal() {
+			return false
+		}
+	}
+	return true
+}
+
+// shouldRun reports whether a ready step runs: its condition holds, or
+// without a condition, all its dependencies completed
+func (r *WorkflowRun) shouldRun(step WorkflowStep, lookup stepLookup) bool {
+	if step.condition != nil {
+		return step.condition.eval(lookup)
+	}
+
+	for _, dep := range step.DependsOn {
+		if r.step(dep).Status != StepCompleted {
+			return false
+		}
+	}
+	return true
+}
+
+// runStatus returns the status of a run from the states of its steps. A
+// run fails if any of its steps failed, was cancelled or timed out.
+func runStatus(steps []StepState) WorkflowStatus {
+	status := WorkflowCompleted
+	for _, state := range steps {
+		switch state.Status {
+		case StepWaiting, StepRunning:
+			return WorkflowRunning
+		case StepFailed, StepCancelled, StepTimedOut:
+			status = WorkflowFailed
+		}
+	}
+	return status
+}
+
+// workflowStatus aggregates the statuses of the runs of a workflow
+func workflowStatus(runs []*WorkflowRun) WorkflowStatus {
+	var completed, failed int
+	for _, run := range runs {
+		switch run.Status {
+		case WorkflowCompleted:
+			completed++
+		case WorkflowFailed:
+			failed++
+		default:
+			return WorkflowRunning
+		}
+	}
+
+	switch {
+	case failed == 0:
+		return WorkflowCompleted
+	case completed == 0:
+		return WorkflowFailed
+	default:
+		return WorkflowPartial
+	}
+}
+
+// snapshot returns a copy of the workflow that is safe to use without
+// m.workflowMu
+func (w *Workflow) snapshot() *Workflow {
+	clone := *w
+	clone.Runs = make([]*WorkflowRun, 0, len(w.Runs))
+	for _, run := range w.Runs {
+		runClone := *run
+		runClone.Steps = append([]StepState(nil), run.Steps...)
+		clone.Runs = append(clone.Runs, &runClone)
+	}
+	return &clone
+}
+
+// compileWorkflow validates step definitions and parses their conditions.
+// Step names must be unique, dependencies must exist and must not form a
+// cycle, and conditions and templates may only refer to steps the step
+// transitively depends on.
+func compileWorkflow(definitions []StepDefinition) ([]WorkflowStep, error) {
+	if len(definitions) == 0 {
+		return nil, fmt.Errorf("%w: no steps", ErrInvalidWorkflow)
+	}
+
+	index := make(map[string]int, len(definitions))
+	for i, definition := range definitions {
+		if !stepNamePattern.MatchString(definition.Name) {
+			return nil, fmt.Errorf("%w: invalid step name %q", ErrInvalidWorkflow, definition.Name)
+		}
+		if _, exists := index[definition.Name]; exists {
+			return nil, fmt.Errorf("%w: duplicate step %q", ErrInvalidWorkflow, definition.Name)
+		}
+		index[definition.Name] = i
+	}
+
+	steps := make([]WorkflowStep, 0, len(definitions))
+	for _, definition := range definitions {
+		if definition.Module == "" {
+			return nil, fmt.Errorf("%w: step %q: %v", ErrInvalidWorkflow, definition.Name, ErrMissingModule)
+		}
+		// Templated parameters are validated again once rendered, so only
+		// their shape is checked here
+		var object map[string]json.RawMessage
+		if len(templateRefs(definition.Params)) > 0 {
+			if err := json.Unmarshal(definition.Params, &object); err != nil {
+				return nil, fmt.Errorf("%w: step %q: %v", ErrInvalidWorkflow, definition.Name, ErrInvalidParams)
+			}
+		} else if err := validateParams(definition.Params); err != nil {
+			return nil, fmt.Errorf("%w: step %q: %v", ErrInvalidWorkflow, definition.Name, err)
+		}
+		for _, dep := range definition.DependsOn {
+			if _, exists := index[dep]; !exists {
+				return nil, fmt.Errorf("%w: step %q depends on unknown step %q", ErrInvalidWorkflow, definition.Name, dep)
+			}
+		}
+
+		step := WorkflowStep{
+			Name:      definition.Name,
+			Module:    definition.Module,
+			Params:    definition.Params,
+			DependsOn: definition.DependsOn,
+			When:      definition.When,
+		}
+		if definition.When != "" {
+			condition, err := parseCondition(definition.When)
+			if err != nil {
+				return nil, fmt.Errorf("step %q: %w", definition.Name, err)
+			}
+			step.condition = condition
+		}
+		steps = append(steps, step)
+	}
+
+	ancestors, err := stepAncestors(steps, index)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, step := range steps {
+		refs := templateRefs(step.Params)
+		if step.condition != nil {
+			refs = append(refs, step.condition.refs()...)
+		}
+
+		for _, ref := range refs {
+			if err := checkRef(ref); err != nil {
+				return nil, fmt.Errorf("step %q: %w", step.Name, err)
+			}
+			if name, ok := refStep(ref); ok && !ancestors[i][name] {
+				return nil, fmt.Errorf("%w: step %q refers to %q, which it does not depend on", ErrInvalidWorkflow, step.Name, name)
+			}
+		}
+	}
+
+	return steps, nil
+}
+
+// stepAncestors returns the set of steps every step transitively depends
+// on, failing if the dependencies form a cycle
+func stepAncestors(steps []WorkflowStep, index map[string]int) ([]map[string]bool, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	ancestors := make([]map[string]bool, len(steps))
+	state := make([]int, len(steps))
+
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visiting:
+			return fmt.Errorf("%w: dependency cycle through step %q", ErrInvalidWorkflow, steps[i].Name)
+		case visited:
+			return nil
+		}
+
+		state[i] = visiting
+		ancestors[i] = make(map[string]bool)
+		for _, dep := range steps[i].DependsOn {
+			j := index[dep]
+			if err := visit(j); err != nil {
+				return err
+			}
+			ancestors[i][dep] = true
+			for name := range ancestors[j] {
+				ancestors[i][name] = true
+			}
+		}
+		state[i] = visited
+		return nil
+	}
+
+	for i := range steps {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return ancestors, nil
+}
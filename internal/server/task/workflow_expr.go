@@ -0,0 +1,323 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// templatePattern matches a {{reference}} in step parameters
+var templatePattern = regexp.MustCompile(`\{\{\s*([^{}]+?)\s*\}\}`)
+
+// stepLookup resolves a reference such as "steps.inventory.result.os" or
+// "client.id" against the progress of a workflow run
+type stepLookup func(ref string) (interface{}, bool)
+
+// clauseOps are the comparison operators of a condition clause
+var clauseOps = map[string]bool{"==": true, "!=": true, "contains": true}
+
+// conditionClause is a single comparison of a step condition. A clause
+// without an operator tests whether the reference is set and truthy.
+type conditionClause struct {
+	ref   string
+	op    string
+	value string
+}
+
+// stepCondition is a parsed "when" expression: clauses joined by "and"
+type stepCondition struct {
+	clauses []conditionClause
+}
+
+// parseCondition parses a step condition, e.g.
+// `steps.inventory.status == completed and steps.inventory.result.os == "linux"`
+func parseCondition(expr string) (*stepCondition, error) {
+	tokens, err := tokenizeCondition(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	condition := &stepCondition{}
+	for len(tokens) > 0 {
+		clause := conditionClause{ref: tokens[0]}
+		if err := checkRef(clause.ref); err != nil {
+			return nil, err
+		}
+		tokens = tokens[1:]
+
+		if len(tokens) > 0 && clauseOps[tokens[0]] {
+			if len(tokens) < 2 {
+				return nil, fmt.Errorf("%w: missing value after %q", ErrInvalidWorkflow, tokens[0])
+			}
+			clause.op, clause.value = tokens[0], tokens[1]
+			tokens = tokens[2:]
+		}
+		condition.clauses = append(condition.clauses, clause)
+
+		if len(tokens) > 0 {
+			if !strings.EqualFold(tokens[0], "and") || len(tokens) == 1 {
+				return nil, fmt.Errorf("%w: expected \"and\" in condition %q", ErrInvalidWorkflow, expr)
+			}
+			tokens = tokens[1:]
+		}
+	}
+
+	if len(condition.clauses) == 0 {
+		return nil, fmt.Errorf("%w: empty condition", ErrInvalidWorkflow)
+	}
+	return condition, nil
+}
+
+// tokenizeCondition splits a condition into references, operators, "and"
+// and values. Values may be double-quoted to include spaces.
+func tokenizeCondition(expr string) ([]string, error) {
+	var tokens []string
+
+	for i := 0; i < len(expr); {
+		switch c := expr[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(expr) && expr[end] != '"' {
+				if expr[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(expr) {
+				return nil, fmt.Errorf("%w: unterminated string in condition %q", ErrInvalidWorkflow, expr)
+			}
+			value, err := strconv.Unquote(expr[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid string in condition %q", ErrInvalidWorkflow, expr)
+			}
+			tokens = append(tokens, value)
+			i = end + 1
+		case strings.HasPrefix(expr[i:], "=="), strings.HasPrefix(expr[i:], "!="):
+			tokens = append(tokens, expr[i:i+2])
+			i += 2
+		default:
+			end := i
+			for end < len(expr) && !strings.ContainsRune(" \t\"=!", rune(expr[end])) {
+				end++
+			}
+			if end == i {
+				return nil, fmt.Errorf("%w: unexpected %q in condition %q", ErrInvalidWorkflow, expr[i], expr)
+			}
+			tokens = append(tokens, expr[i:end])
+			i = end
+		}
+	}
+
+	return tokens, nil
+}
+
+// eval evaluates the condition; every clause must hold
+func (c *stepCondition) eval(lookup stepLookup) bool {
+	for _, clause := range c.clauses {
+		value, ok := lookup(clause.ref)
+
+		var holds bool
+		switch clause.op {
+		case "":
+			holds = ok && truthy(value)
+		case "==":
+			holds = ok && stringify(value) == clause.value
+		case "!=":
+			holds = !ok || stringify(value) != clause.value
+		case "contains":
+			holds = ok && strings.Contains(stringify(value), clause.value)
+		}
+
+		if !holds {
+			return false
+		}
+	}
+	return true
+}
+
+// refs returns the references the condition uses
+func (c *stepCondition) refs() []string {
+	refs := make([]string, 0, len(c.clauses))
+	for _, clause := range c.clauses {
+		refs = append(refs, clause.ref)
+	}
+	return refs
+}
+
+// templateRefs returns the references used by the templates in params
+func templateRefs(params json.RawMessage) []string {
+	var refs []string
+	for _, match := range templatePattern.FindAllStringSubmatch(string(params), -1) {
+		refs = append(refs, match[1])
+	}
+	return refs
+}
+
+// renderParams replaces the {{reference}} templates in the string values of
+// params. A string consisting of a single template takes the referenced
+// value as is, so that numbers and objects keep their type; templates
+// within longer strings are replaced by their text.
+func renderParams(params json.RawMessage, lookup stepLookup) (json.RawMessage, error) {
+	if len(params) == 0 || !templatePattern.Match(params) {
+		return params, nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(params, &value); err != nil {
+		return nil, ErrInvalidParams
+	}
+
+	rendered, err := renderValue(value, lookup)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(rendered)
+}
+
+// renderValue renders the templates in a decoded JSON value
+func renderValue(value interface{}, lookup stepLookup) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			rendered, err := renderValue(item, lookup)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = rendered
+		}
+		return v, nil
+
+	case []interface{}:
+		for i, item := range v {
+			rendered, err := renderValue(item, lookup)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = rendered
+		}
+		return v, nil
+
+	case string:
+		if match := templatePattern.FindStringSubmatch(v); match != nil && match[0] == v {
+			resolved, ok := lookup(match[1])
+			if !ok {
+				return nil, fmt.Errorf("unresolved reference %q", match[1])
+			}
+			return resolved, nil
+		}
+
+		var missing string
+		rendered := templatePattern.ReplaceAllStringFunc(v, func(template string) string {
+			ref := templatePattern.FindStringSubmatch(template)[1]
+			resolved, ok := lookup(ref)
+			if !ok {
+				missing = ref
+				return template
+			}
+			return stringify(resolved)
+		})
+		if missing != "" {
+			return nil, fmt.Errorf("unresolved reference %q", missing)
+		}
+		return rendered, nil
+
+	default:
+		return v, nil
+	}
+}
+
+// checkRef checks the syntax of a reference: "client.id", or
+// "steps.<name>.status", "steps.<name>.error" or "steps.<name>.result"
+// followed by an optional path into the result
+func checkRef(ref string) error {
+	if ref == "client.id" {
+		return nil
+	}
+
+	parts := strings.Split(ref, ".")
+	if len(parts) < 3 || parts[0] != "steps" || parts[1] == "" {
+		return fmt.Errorf("%w: invalid reference %q", ErrInvalidWorkflow, ref)
+	}
+
+	switch parts[2] {
+	case "status", "error":
+		if len(parts) == 3 {
+			return nil
+		}
+	case "result":
+		return nil
+	}
+	return fmt.Errorf("%w: invalid reference %q", ErrInvalidWorkflow, ref)
+}
+
+// refStep returns the step a reference points to, if any
+func refStep(ref string) (string, bool) {
+	parts := strings.SplitN(ref, ".", 3)
+	if len(parts) < 3 || parts[0] != "steps" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// walkPath follows a dot separated path of object keys and array indices
+// into a decoded JSON value
+func walkPath(value interface{}, path []string) (interface{}, bool) {
+	for _, key := range path {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			item, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			value = item
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, false
+			}
+			value = v[index]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// stringify returns the text of a decoded JSON value: strings as they are,
+// null as the empty string and anything else as JSON
+func stringify(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+// truthy reports whether a decoded JSON value is set: not null, false, 0,
+// or an empty string, array or object
+func truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	default:
+		return true
+	}
+}
@@ -0,0 +1,243 @@
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/Cl0udRs4/dinot/internal/server/client"
+)
+
+func TestWorkflow(t *testing.T) {
+	manager, _ := setupTestManager()
+
+	definition := WorkflowDefinition{
+		Name: "triage",
+		Steps: []StepDefinition{
+			{Name: "probe", Module: "shell", Params: json.RawMessage(`{"command":"uname"}`)},
+			{
+				Name:      "collect",
+				Module:    "shell",
+				Params:    json.RawMessage(`{"command":"collect {{client.id}} {{steps.probe.result.os}}","timeout":"{{steps.probe.result.timeout}}"}`),
+				DependsOn: []string{"probe"},
+				When:      `steps.probe.result.os == "linux"`,
+			},
+			{Name: "cleanup", Module: "shell", DependsOn: []string{"collect"}},
+			{Name: "report", Module: "shell", DependsOn: []string{"probe"}, When: "steps.probe.status == failed"},
+		},
+	}
+
+	workflow, err := manager.CreateWorkflow(client.Target{Tag: "dmz"}, definition)
+	if err != nil {
+		t.Fatalf("Failed to create workflow: %v", err)
+	}
+	if len(workflow.Runs) != 2 || workflow.Status != WorkflowRunning {
+		t.Fatalf("Expected 2 running runs, got %+v", workflow)
+	}
+
+	stepOf := func(clientID, name string) StepState {
+		t.Helper()
+		workflow, err := manager.GetWorkflow(workflow.ID)
+		if err != nil {
+			t.Fatalf("Failed to get workflow: %v", err)
+		}
+		for _, run := range workflow.Runs {
+			if run.ClientID == clientID {
+				return *run.step(name)
+			}
+		}
+		t.Fatalf("No run for %s", clientID)
+		return StepState{}
+	}
+	report := func(taskID string, status TaskStatus, result string) {
+		t.Helper()
+		feedback := Feedback{CommandID: taskID, Status: string(status)}
+		if result != "" {
+			feedback.Result = json.RawMessage(result)
+		}
+		if err := manager.HandleFeedback(feedback); err != nil {
+			t.Fatalf("Failed to handle feedback: %v", err)
+		}
+	}
+
+	// Only the first step starts
+	probe := stepOf("client-1", "probe")
+	if probe.Status != StepRunning || probe.TaskID == "" {
+		t.Fatalf("Expected probe to be running, got %+v", probe)
+	}
+	if collect := stepOf("client-1", "collect"); collect.Status != StepWaiting {
+		t.Fatalf("Expected collect to be waiting, got %+v", collect)
+	}
+	task, _ := manager.GetTask(probe.TaskID)
+	if task.WorkflowID != workflow.ID {
+		t.Errorf("Expected task to belong to workflow %s, got %q", workflow.ID, task.WorkflowID)
+	}
+
+	// client-1: the probe result is templated into the next step
+	report(probe.TaskID, StatusCompleted, `{"os":"linux","timeout":30}`)
+	collect := stepOf("client-1", "collect")
+	if collect.Status != StepRunning {
+		t.Fatalf("Expected collect to be running, got %+v", collect)
+	}
+	task, _ = manager.GetTask(collect.TaskID)
+	var params map[string]interface{}
+	if err := json.Unmarshal(task.Params, &params); err != nil {
+		t.Fatalf("Failed to decode params: %v", err)
+	}
+	if params["command"] != "collect client-1 linux" || params["timeout"] != float64(30) {
+		t.Errorf("Unexpected rendered params: %s", task.Params)
+	}
+	if task.Timeout != 30 {
+		t.Errorf("Expected templated timeout 30, got %d", task.Timeout)
+	}
+	if report := stepOf("client-1", "report"); report.Status != StepSkipped {
+		t.Errorf("Expected report to be skipped, got %+v", report)
+	}
+
+	report(collect.TaskID, StatusCompleted, "")
+	report(stepOf("client-1", "cleanup").TaskID, StatusCompleted, "")
+
+	// client-2: a failed probe skips the dependent steps and runs report
+	report(stepOf("client-2", "probe").TaskID, StatusFailed, "")
+	for _, name := range []string{"collect", "cleanup"} {
+		if state := stepOf("client-2", name); state.Status != StepSkipped {
+			t.Errorf("Expected %s to be skipped, got %+v", name, state)
+		}
+	}
+	reportStep := stepOf("client-2", "report")
+	if reportStep.Status != StepRunning {
+		t.Fatalf("Expected report to be running, got %+v", reportStep)
+	}
+	if _, err := manager.CancelTask(reportStep.TaskID); err != nil {
+		t.Fatalf("Failed to cancel task: %v", err)
+	}
+	if state := stepOf("client-2", "report"); state.Status != StepCancelled {
+		t.Errorf("Expected report to be cancelled, got %+v", state)
+	}
+
+	workflow, _ = manager.GetWorkflow(workflow.ID)
+	if workflow.Runs[0].Status != WorkflowCompleted || workflow.Runs[1].Status != WorkflowFailed {
+		t.Errorf("Unexpected run statuses %s and %s", workflow.Runs[0].Status, workflow.Runs[1].Status)
+	}
+	if workflow.Status != WorkflowPartial {
+		t.Errorf("Expected partial workflow, got %s", workflow.Status)
+	}
+
+	if got := len(manager.GetAllWorkflows()); got != 1 {
+		t.Errorf("Expected 1 workflow, got %d", got)
+	}
+	if _, err := manager.GetWorkflow("missing"); err != ErrWorkflowNotFound {
+		t.Errorf("Expected ErrWorkflowNotFound, got %v", err)
+	}
+}
+
+func TestWorkflowUnresolvedTemplate(t *testing.T) {
+	manager, _ := setupTestManager()
+
+	workflow, err := manager.CreateWorkflow(client.Target{ClientIDs: []string{"client-1"}}, WorkflowDefinition{
+		Steps: []StepDefinition{
+			{Name: "a", Module: "shell"},
+			{Name: "b", Module: "shell", Params: json.RawMessage(`{"command":"{{steps.a.result.missing}}"}`), DependsOn: []string{"a"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create workflow: %v", err)
+	}
+
+	err = manager.HandleFeedback(Feedback{CommandID: workflow.Runs[0].Steps[0].TaskID, Status: string(StatusCompleted)})
+	if err != nil {
+		t.Fatalf("Failed to handle feedback: %v", err)
+	}
+
+	workflow, _ = manager.GetWorkflow(workflow.ID)
+	if step := workflow.Runs[0].Steps[1]; step.Status != StepFailed || step.Error == "" {
+		t.Errorf("Expected b to fail on the unresolved reference, got %+v", step)
+	}
+	if workflow.Status != WorkflowFailed {
+		t.Errorf("Expected failed workflow, got %s", workflow.Status)
+	}
+}
+
+func TestCompileWorkflowErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []StepDefinition
+	}{
+		{"no steps", nil},
+		{"invalid name", []StepDefinition{{Name: "a.b", Module: "shell"}}},
+		{"duplicate", []StepDefinition{{Name: "a", Module: "shell"}, {Name: "a", Module: "shell"}}},
+		{"missing module", []StepDefinition{{Name: "a"}}},
+		{"unknown dependency", []StepDefinition{{Name: "a", Module: "shell", DependsOn: []string{"b"}}}},
+		{"cycle", []StepDefinition{
+			{Name: "a", Module: "shell", DependsOn: []string{"b"}},
+			{Name: "b", Module: "shell", DependsOn: []string{"a"}},
+		}},
+		{"invalid params", []StepDefinition{{Name: "a", Module: "shell", Params: json.RawMessage(`[1]`)}}},
+		{"invalid condition", []StepDefinition{{Name: "a", Module: "shell", When: "steps.a.status =="}}},
+		{"invalid reference", []StepDefinition{{Name: "a", Module: "shell", When: "status == completed"}}},
+		{"unrelated step in condition", []StepDefinition{
+			{Name: "a", Module: "shell"},
+			{Name: "b", Module: "shell", When: "steps.a.status == completed"},
+		}},
+		{"unrelated step in template", []StepDefinition{
+			{Name: "a", Module: "shell"},
+			{Name: "b", Module: "shell", Params: json.RawMessage(`{"command":"{{steps.a.result}}"}`)},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := compileWorkflow(tt.steps); !errors.Is(err, ErrInvalidWorkflow) {
+				t.Errorf("Expected ErrInvalidWorkflow, got %v", err)
+			}
+		})
+	}
+
+	// Transitive dependencies may be referenced
+	_, err := compileWorkflow([]StepDefinition{
+		{Name: "a", Module: "shell"},
+		{Name: "b", Module: "shell", DependsOn: []string{"a"}},
+		{Name: "c", Module: "shell", DependsOn: []string{"b"}, When: `steps.a.result.out contains "ok" and steps.b.error`},
+	})
+	if err != nil {
+		t.Errorf("Expected a valid workflow, got %v", err)
+	}
+}
+
+func TestStepCondition(t *testing.T) {
+	values := map[string]interface{}{
+		"steps.a.status":      "completed",
+		"steps.a.error":       "",
+		"steps.a.result.os":   "linux",
+		"steps.a.result.up":   true,
+		"steps.a.result.load": float64(2),
+	}
+	lookup := func(ref string) (interface{}, bool) {
+		value, ok := values[ref]
+		return value, ok
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"steps.a.status == completed", true},
+		{"steps.a.status==completed", true},
+		{"steps.a.status != completed", false},
+		{`steps.a.result.os == "linux" and steps.a.result.up`, true},
+		{"steps.a.result.os contains lin AND steps.a.result.load == 2", true},
+		{"steps.a.error", false},
+		{"steps.a.result.missing", false},
+		{"steps.a.result.missing != x", true},
+	}
+
+	for _, tt := range tests {
+		condition, err := parseCondition(tt.expr)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", tt.expr, err)
+		}
+		if got := condition.eval(lookup); got != tt.want {
+			t.Errorf("%q: expected %v, got %v", tt.expr, tt.want, got)
+		}
+	}
+}
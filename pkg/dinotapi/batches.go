@@ -0,0 +1,66 @@
+package dinotapi
+
+import (
+	"context"
+	"net/url"
+)
+
+// BatchRequest runs a module on a list of clients, a tag, a group or the
+// clients matching a filter. Exactly one selector must be set.
+type BatchRequest struct {
+	// ClientIDs selects an explicit list of clients
+	ClientIDs []string `json:"clientIds,omitempty"`
+
+	// Tag selects every client carrying the tag
+	Tag string `json:"tag,omitempty"`
+
+	// Group selects every registered member of the group
+	Group string `json:"group,omitempty"`
+
+	// Filter selects every client matching a filter expression
+	Filter string `json:"filter,omitempty"`
+
+	// Module is the name of the module to run
+	Module string `json:"module"`
+
+	// Params are the module parameters; anything that encodes as a JSON
+	// object
+	Params interface{} `json:"params,omitempty"`
+
+	// IdempotencyKey makes retrying the request safe across processes:
+	// repeating it with the same key returns the first result. A key is
+	// generated for every call if empty.
+	IdempotencyKey string `json:"-"`
+}
+
+// ListBatches returns a page of batches with their aggregate status
+func (c *Client) ListBatches(ctx context.Context, opts *ListOptions) (*Page[Batch], error) {
+	return listPage[Batch](ctx, c, "/batches", opts.values())
+}
+
+// CreateBatch runs a module on every selected client and returns the batch
+func (c *Client) CreateBatch(ctx context.Context, req BatchRequest) (*Batch, error) {
+	var batch Batch
+	if err := c.create(ctx, "/batches", req.IdempotencyKey, req, &batch); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// GetBatch returns a batch and the status of each of its tasks
+func (c *Client) GetBatch(ctx context.Context, id string) (*Batch, error) {
+	var batch Batch
+	if err := c.get(ctx, "/batches/"+url.PathEscape(id), nil, &batch); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// BatchResults returns a batch with the module output of every task
+func (c *Client) BatchResults(ctx context.Context, id string) (*Batch, error) {
+	var batch Batch
+	if err := c.get(ctx, "/batches/"+url.PathEscape(id)+"/results", nil, &batch); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
@@ -0,0 +1,387 @@
+// Package dinotapi is a Go client for the dinot control API.
+//
+// A Client authenticates with an API key, with operator credentials sent as
+// HTTP basic auth, or with operator credentials exchanged for access and
+// refresh tokens. Idempotent requests are retried on network errors and
+// temporary server errors; so are task, batch and schedule creation, which
+// are sent with an idempotency key. Collections can be fetched a page at a
+// time or walked to the end, and events can be listed or streamed as they
+// happen.
+package dinotapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// APIPrefix is the path prefix of the API version this package speaks
+	APIPrefix = "/api/v1"
+
+	// DefaultMaxRetries is the default number of retries of a failed request
+	DefaultMaxRetries = 3
+
+	// DefaultRetryBackoff is the default wait before the first retry; it
+	// doubles with every further retry
+	DefaultRetryBackoff = 200 * time.Millisecond
+)
+
+// ErrMissingBaseURL is returned by New when no base URL is configured
+var ErrMissingBaseURL = errors.New("dinotapi: missing base URL")
+
+// Config configures a Client
+type Config struct {
+	// BaseURL is the address of the server, e.g. https://127.0.0.1:8081
+	BaseURL string
+
+	// APIKey authenticates with an API key. It takes precedence over
+	// Username and Password.
+	APIKey string
+
+	// Username and Password authenticate as an operator
+	Username string
+	Password string
+
+	// UseTokens exchanges Username and Password for access and refresh
+	// tokens on the first request instead of sending them every time
+	UseTokens bool
+
+	// HTTPClient sends the requests; http.DefaultClient if nil. Set its
+	// transport to trust the server's certificate.
+	HTTPClient *http.Client
+
+	// MaxRetries is the number of times an idempotent request is retried;
+	// DefaultMaxRetries if zero, no retries if negative
+	MaxRetries int
+
+	// RetryBackoff is the wait before the first retry; DefaultRetryBackoff
+	// if zero
+	RetryBackoff time.Duration
+}
+
+// Client is a client of the control API. It is safe for concurrent use.
+type Client struct {
+	// baseURL is the server address, without a trailing slash
+	baseURL string
+
+	// config is the configuration the client was created with
+	config Config
+
+	// httpClient sends the requests
+	httpClient *http.Client
+
+	// access is the current access token when UseTokens is set; empty
+	// before the first request and after the server rejected it
+	access string
+
+	// refresh is the refresh token issued with the access token
+	refresh string
+
+	// mu protects access and refresh
+	mu sync.Mutex
+}
+
+// New creates a new client
+func New(config Config) (*Client, error) {
+	if config.BaseURL == "" {
+		return nil, ErrMissingBaseURL
+	}
+	if _, err := url.Parse(config.BaseURL); err != nil {
+		return nil, fmt.Errorf("dinotapi: invalid base URL: %w", err)
+	}
+	if config.MaxRetries == 0 {
+		config.MaxRetries = DefaultMaxRetries
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = DefaultRetryBackoff
+	}
+
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Client{
+		baseURL:    strings.TrimRight(config.BaseURL, "/"),
+		config:     config,
+		httpClient: httpClient,
+	}, nil
+}
+
+// get sends a GET request and decodes the response into out
+func (c *Client) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	return c.do(ctx, http.MethodGet, path, query, nil, out)
+}
+
+// do sends a request to the API and decodes the JSON response into out,
+// unless out is nil. Token authentication is refreshed once if the server
+// rejects the access token.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	return c.request(ctx, method, path, query, body, out, "")
+}
+
+// create sends a POST request with an idempotency key, so that it can be
+// retried without creating the resource twice. A key is generated if key is
+// empty.
+func (c *Client) create(ctx context.Context, path, key string, body, out interface{}) error {
+	if key == "" {
+		var buf [16]byte
+		if _, err := rand.Read(buf[:]); err != nil {
+			return fmt.Errorf("dinotapi: failed to generate idempotency key: %w", err)
+		}
+		key = hex.EncodeToString(buf[:])
+	}
+	return c.request(ctx, http.MethodPost, path, nil, body, out, key)
+}
+
+// request implements do and create; key is the idempotency key, if any
+func (c *Client) request(ctx context.Context, method, path string, query url.Values, body, out interface{}, key string) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("dinotapi: failed to encode request: %w", err)
+		}
+	}
+
+	resp, err := c.send(ctx, method, path, query, payload, key)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized && c.usesTokens() {
+		resp.Body.Close()
+
+		// The access token expired or was revoked; get a new one and retry
+		c.mu.Lock()
+		c.access = ""
+		c.mu.Unlock()
+		if resp, err = c.send(ctx, method, path, query, payload, key); err != nil {
+			return err
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return decodeError(resp)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("dinotapi: failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// send sends a request with authentication, retrying idempotent requests
+// and requests with an idempotency key on network errors and temporary
+// server errors
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, payload []byte, key string) (*http.Response, error) {
+	retries := c.config.MaxRetries
+	if (!idempotent(method) && key == "") || retries < 0 {
+		retries = 0
+	}
+
+	backoff := c.config.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		resp, err := c.sendOnce(ctx, method, path, query, payload, key)
+		if attempt >= retries || !retryable(resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// sendOnce sends a single authenticated request
+func (c *Client) sendOnce(ctx context.Context, method, path string, query url.Values, payload []byte, key string) (*http.Response, error) {
+	target := c.baseURL + APIPrefix + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, fmt.Errorf("dinotapi: failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+
+	if err := c.authenticate(ctx, req); err != nil {
+		return nil, err
+	}
+
+	return c.httpClient.Do(req)
+}
+
+// authenticate adds the configured credentials to a request
+func (c *Client) authenticate(ctx context.Context, req *http.Request) error {
+	switch {
+	case c.config.APIKey != "":
+		req.Header.Set("X-API-Key", c.config.APIKey)
+	case c.usesTokens():
+		token, err := c.accessToken(ctx)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	case c.config.Username != "":
+		req.SetBasicAuth(c.config.Username, c.config.Password)
+	}
+	return nil
+}
+
+// usesTokens reports whether the client authenticates with tokens
+func (c *Client) usesTokens() bool {
+	return c.config.APIKey == "" && c.config.UseTokens && c.config.Username != ""
+}
+
+// accessToken returns the current access token, refreshing the token pair
+// or logging in if there is none
+func (c *Client) accessToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.access != "" {
+		return c.access, nil
+	}
+
+	// Prefer the refresh token of the previous pair, falling back to the
+	// password if it has expired as well
+	if c.refresh != "" {
+		tokens, err := c.tokenRequest(ctx, "/auth/refresh", RefreshRequest{RefreshToken: c.refresh})
+		if err == nil {
+			c.access, c.refresh = tokens.AccessToken, tokens.RefreshToken
+			return c.access, nil
+		}
+	}
+
+	tokens, err := c.tokenRequest(ctx, "/auth/login", LoginRequest{Username: c.config.Username, Password: c.config.Password})
+	if err != nil {
+		return "", err
+	}
+	c.access, c.refresh = tokens.AccessToken, tokens.RefreshToken
+	return c.access, nil
+}
+
+// tokenRequest sends an unauthenticated login or refresh request
+func (c *Client) tokenRequest(ctx context.Context, path string, body interface{}) (*TokenPair, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("dinotapi: failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+APIPrefix+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("dinotapi: failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeError(resp)
+	}
+
+	var tokens TokenPair
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return nil, fmt.Errorf("dinotapi: failed to decode tokens: %w", err)
+	}
+	return &tokens, nil
+}
+
+// Logout revokes the tokens of a client that uses token authentication.
+// The next request logs in again.
+func (c *Client) Logout(ctx context.Context) error {
+	if !c.usesTokens() {
+		return nil
+	}
+
+	c.mu.Lock()
+	refresh := c.refresh
+	c.mu.Unlock()
+	if refresh == "" {
+		return nil
+	}
+
+	err := c.do(ctx, http.MethodPost, "/auth/logout", nil, RefreshRequest{RefreshToken: refresh}, nil)
+
+	c.mu.Lock()
+	c.access, c.refresh = "", ""
+	c.mu.Unlock()
+	return err
+}
+
+// Me returns the identity the client authenticates as
+func (c *Client) Me(ctx context.Context) (*Identity, error) {
+	var identity Identity
+	if err := c.get(ctx, "/me", nil, &identity); err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// idempotent reports whether a request with the method can be repeated
+// safely
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryable reports whether a failed attempt is worth repeating
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		// Cancellation is final
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+
+	return retryableStatus(resp.StatusCode)
+}
+
+// retryableStatus reports whether a response status is worth retrying
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
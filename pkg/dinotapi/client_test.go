@@ -0,0 +1,529 @@
+package dinotapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Cl0udRs4/dinot/internal/server/api"
+	"github.com/Cl0udRs4/dinot/internal/server/auth"
+	"github.com/Cl0udRs4/dinot/internal/server/client"
+	"github.com/Cl0udRs4/dinot/internal/server/task"
+)
+
+// testServer is an API server with authentication and a few clients
+type testServer struct {
+	server  *httptest.Server
+	keys    *auth.APIKeyStore
+	clients *client.ClientManager
+}
+
+// newTestServer starts an API server with an admin account alice, JWT
+// authentication and three registered clients. wrap, if set, wraps the API
+// handler.
+func newTestServer(t *testing.T, wrap func(http.Handler) http.Handler) *testServer {
+	t.Helper()
+
+	clientManager := client.NewClientManager()
+	heartbeatMonitor := client.NewHeartbeatMonitor(clientManager, 30*time.Second, 60*time.Second)
+	for i := 1; i <= 3; i++ {
+		c := client.NewClient(fmt.Sprintf("client-%d", i), fmt.Sprintf("Client %d", i), "10.0.0.1", "Linux", "x86_64", []string{"shell"}, "tcp")
+		if err := clientManager.RegisterClient(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys := auth.NewAPIKeyStore()
+	h := api.NewAPIHandler(clientManager, heartbeatMonitor, task.NewTaskManager(clientManager), api.Config{
+		AuthEnabled:  true,
+		AuthUser:     "alice",
+		AuthPassword: "alice-password",
+		APIKeyStore:  keys,
+		JWTSecret:    "test-secret",
+		JWTEnabled:   true,
+	})
+
+	var handler http.Handler = h.Handler()
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return &testServer{server: server, keys: keys, clients: clientManager}
+}
+
+// newTestClient creates an SDK client for the test server
+func newTestClient(t *testing.T, s *testServer, config Config) *Client {
+	t.Helper()
+
+	config.BaseURL = s.server.URL
+	config.RetryBackoff = time.Millisecond
+	c, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// TestBasicAuth tests requests with operator credentials and API errors
+func TestBasicAuth(t *testing.T) {
+	s := newTestServer(t, nil)
+	ctx := context.Background()
+
+	c := newTestClient(t, s, Config{Username: "alice", Password: "alice-password"})
+	identity, err := c.Me(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Username != "alice" || identity.Method != "basic" {
+		t.Errorf("unexpected identity %+v", identity)
+	}
+
+	got, err := c.GetClient(ctx, "client-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "Client 2" || got.Status != StatusOnline {
+		t.Errorf("unexpected client %+v", got)
+	}
+
+	_, err = c.GetClient(ctx, "missing")
+	if !IsNotFound(err) || ErrorCode(err) != "client_not_found" {
+		t.Errorf("expected client_not_found, got %v", err)
+	}
+
+	bad := newTestClient(t, s, Config{Username: "alice", Password: "wrong"})
+	if _, err := bad.Me(ctx); !IsUnauthorized(err) {
+		t.Errorf("expected unauthorized, got %v", err)
+	}
+}
+
+// TestAPIKeyAuth tests requests with a scoped API key
+func TestAPIKeyAuth(t *testing.T) {
+	s := newTestServer(t, nil)
+	ctx := context.Background()
+
+	_, secret, err := s.keys.CreateKey("ci", "alice", []auth.Permission{auth.PermReadClients}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestClient(t, s, Config{APIKey: secret})
+	page, err := c.ListClients(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 3 || len(page.Items) != 3 {
+		t.Errorf("expected 3 clients, got %d of %d", len(page.Items), page.Total)
+	}
+
+	_, err = c.CreateTasks(ctx, TaskRequest{Target: Target{ClientID: "client-1"}, Module: "shell"})
+	if !IsForbidden(err) {
+		t.Errorf("expected forbidden, got %v", err)
+	}
+}
+
+// TestTokenAuth tests token login, refresh after revocation and logout
+func TestTokenAuth(t *testing.T) {
+	s := newTestServer(t, nil)
+	ctx := context.Background()
+
+	c := newTestClient(t, s, Config{Username: "alice", Password: "alice-password", UseTokens: true})
+	identity, err := c.Me(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Method != "jwt" {
+		t.Errorf("expected jwt authentication, got %q", identity.Method)
+	}
+
+	// A rejected access token is replaced transparently
+	c.mu.Lock()
+	c.access = "not-a-token"
+	c.mu.Unlock()
+	if _, err := c.Me(ctx); err != nil {
+		t.Fatalf("expected the token to be refreshed, got %v", err)
+	}
+
+	// After logout the next request logs in again
+	if err := c.Logout(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Me(ctx); err != nil {
+		t.Fatalf("expected a new login, got %v", err)
+	}
+}
+
+// TestPagination tests walking a collection page by page
+func TestPagination(t *testing.T) {
+	s := newTestServer(t, nil)
+	ctx := context.Background()
+
+	c := newTestClient(t, s, Config{Username: "alice", Password: "alice-password"})
+	for i := 0; i < 5; i++ {
+		_, err := c.ReportException(ctx, ExceptionReport{
+			ClientID: "client-1",
+			Message:  fmt.Sprintf("exception %d", i),
+			Severity: SeverityWarning,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := &ExceptionListOptions{ListOptions: ListOptions{Limit: 2}, ClientID: "client-1"}
+	page, err := c.ListExceptions(ctx, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 2 || page.Total != 5 || page.Next == "" {
+		t.Errorf("unexpected first page: %d items of %d, next %q", len(page.Items), page.Total, page.Next)
+	}
+
+	all, err := c.AllExceptions(ctx, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 5 {
+		t.Fatalf("expected 5 exceptions, got %d", len(all))
+	}
+	for i, exception := range all {
+		if want := fmt.Sprintf("exception %d", i); exception.Message != want {
+			t.Errorf("exception %d: expected %q, got %q", i, want, exception.Message)
+		}
+	}
+
+	_, err = c.ListClients(ctx, &ClientListOptions{ListOptions: ListOptions{Sort: "nope"}})
+	if ErrorCode(err) != "invalid_query" {
+		t.Errorf("expected invalid_query, got %v", err)
+	}
+}
+
+// TestTasks tests creating and fetching tasks
+func TestTasks(t *testing.T) {
+	s := newTestServer(t, nil)
+	ctx := context.Background()
+
+	c := newTestClient(t, s, Config{Username: "alice", Password: "alice-password"})
+	tasks, err := c.CreateTasks(ctx, TaskRequest{
+		Target: Target{ClientID: "client-1"},
+		Module: "shell",
+		Params: map[string]string{"command": "id"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].ClientID != "client-1" {
+		t.Fatalf("unexpected tasks %+v", tasks)
+	}
+
+	got, err := c.GetTask(ctx, tasks[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Module != "shell" || got.Status.IsFinal() {
+		t.Errorf("unexpected task %+v", got)
+	}
+
+	cancelled, err := c.CancelTask(ctx, tasks[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancelled.Status != TaskCancelled {
+		t.Errorf("expected the undelivered task to be cancelled, got %s", cancelled.Status)
+	}
+
+	batch, err := c.CreateBatch(ctx, BatchRequest{ClientIDs: []string{"client-1", "client-2"}, Module: "shell"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch.Total != 2 || batch.Status != BatchRunning || len(batch.Results) != 2 {
+		t.Errorf("unexpected batch %+v", batch)
+	}
+	if _, err := c.BatchResults(ctx, batch.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	workflow, err := c.CreateWorkflow(ctx, WorkflowRequest{
+		ClientIDs: []string{"client-1"},
+		Steps: []StepDefinition{
+			{Name: "probe", Module: "shell", Params: map[string]string{"command": "uname"}},
+			{Name: "collect", Module: "shell", DependsOn: []string{"probe"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(workflow.Runs) != 1 || workflow.Runs[0].Steps[0].Status != StepRunning || workflow.Runs[0].Steps[1].Status != StepWaiting {
+		t.Errorf("unexpected workflow %+v", workflow)
+	}
+	if _, err := c.GetWorkflow(ctx, workflow.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	schedule, err := c.CreateSchedule(ctx, ScheduleRequest{Tag: "dmz", Module: "shell", Cron: "@daily"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schedule.Kind != ScheduleCron || !schedule.Enabled || schedule.NextRun == nil {
+		t.Errorf("unexpected schedule %+v", schedule)
+	}
+	if schedule, err = c.SetScheduleEnabled(ctx, schedule.ID, false); err != nil || schedule.Enabled {
+		t.Errorf("expected the schedule to be disabled, got %+v (%v)", schedule, err)
+	}
+	if err := c.DeleteSchedule(ctx, schedule.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.ExecuteModule(ctx, "client-1", "shell", []string{"id"}); err == nil {
+		t.Error("expected non-object parameters to be rejected")
+	}
+}
+
+// TestRetry tests that idempotent requests are retried on temporary
+// errors and other requests are not
+func TestRetry(t *testing.T) {
+	var failures atomic.Int32
+	flaky := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if failures.Add(-1) >= 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	s := newTestServer(t, flaky)
+	ctx := context.Background()
+	c := newTestClient(t, s, Config{Username: "alice", Password: "alice-password", MaxRetries: 2})
+
+	failures.Store(2)
+	if _, err := c.ListClients(ctx, nil); err != nil {
+		t.Fatalf("expected the request to be retried, got %v", err)
+	}
+
+	failures.Store(3)
+	_, err := c.ListClients(ctx, nil)
+	if apiErr, ok := err.(*APIError); !ok || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 after exhausting retries, got %v", err)
+	}
+
+	failures.Store(1)
+	_, err = c.CreateGroup(ctx, "dmz", "")
+	if apiErr, ok := err.(*APIError); !ok || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected a POST not to be retried, got %v", err)
+	}
+}
+
+// TestIdempotentRetry tests that task creation is retried with the same
+// idempotency key when the response is lost, without creating the task twice
+func TestIdempotentRetry(t *testing.T) {
+	var lost atomic.Int32
+	lossy := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost && lost.Add(-1) >= 0 {
+				// Serve the request but drop the response
+				next.ServeHTTP(httptest.NewRecorder(), r)
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	s := newTestServer(t, lossy)
+	ctx := context.Background()
+	c := newTestClient(t, s, Config{Username: "alice", Password: "alice-password"})
+
+	lost.Store(1)
+	tasks, err := c.CreateTasks(ctx, TaskRequest{Target: Target{ClientID: "client-1"}, Module: "shell"})
+	if err != nil {
+		t.Fatalf("expected the request to be retried, got %v", err)
+	}
+
+	all, err := c.AllTasks(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || all[0].ID != tasks[0].ID {
+		t.Errorf("expected exactly the replayed task, got %d tasks", len(all))
+	}
+}
+
+// TestListeners tests creating, reconfiguring and removing a listener
+func TestListeners(t *testing.T) {
+	s := newTestServer(t, nil)
+	ctx := context.Background()
+	c := newTestClient(t, s, Config{Username: "alice", Password: "alice-password"})
+
+	created, err := c.CreateListener(ctx, "", "dns", ListenerConfig{Address: "127.0.0.1:0", Domain: "c2.example.org", RecordTypes: []string{"txt"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Name != "dns" || created.Status != ListenerStopped || created.TTL != 60 || created.RecordTypes[0] != "TXT" {
+		t.Errorf("unexpected listener: %+v", created)
+	}
+
+	_, err = c.CreateListener(ctx, "", "udp", ListenerConfig{Address: "127.0.0.1:0", TLS: true})
+	if apiErr, ok := err.(*APIError); !ok || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid config, got %v", err)
+	}
+
+	updated, err := c.UpdateListener(ctx, "dns", ListenerConfig{Address: "127.0.0.1:0", Domain: "c2.example.net", TTL: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Domain != "c2.example.net" || updated.TTL != 30 {
+		t.Errorf("unexpected updated listener: %+v", updated)
+	}
+
+	internal, err := c.CreateListener(ctx, "dns-internal", "dns", ListenerConfig{Address: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if internal.Name != "dns-internal" || internal.Domain != "example.com" {
+		t.Errorf("unexpected named listener: %+v", internal)
+	}
+
+	page, err := c.ListListeners(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 2 || page.Items[0].Name != "dns" || page.Items[1].Name != "dns-internal" {
+		t.Errorf("expected both DNS listeners, got %+v", page.Items)
+	}
+
+	if err := c.DeleteListener(ctx, "dns"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetListener(ctx, "dns"); !IsNotFound(err) {
+		t.Errorf("expected not found after deleting, got %v", err)
+	}
+}
+
+// TestEvents tests listing events with a filter and decoding their data
+func TestEvents(t *testing.T) {
+	s := newTestServer(t, nil)
+	ctx := context.Background()
+	c := newTestClient(t, s, Config{Username: "alice", Password: "alice-password"})
+
+	s.clients.ReportException("client-1", "disk full", client.SeverityWarning, "shell", "", nil)
+	s.clients.ReportException("client-2", "out of memory", client.SeverityCritical, "", "", nil)
+	s.clients.RegisterClient(client.NewClient("client-4", "Client 4", "10.0.0.4", "Linux", "x86_64", []string{"shell"}, "tcp"))
+
+	events, err := c.AllEvents(ctx, &EventListOptions{Types: []EventType{EventExceptionReported}, ClientID: "client-2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].ID != 2 || events[0].ClientID != "client-2" {
+		t.Fatalf("expected the exception of client-2, got %+v", events)
+	}
+	exception, err := events[0].Exception()
+	if err != nil || exception.Message != "out of memory" || exception.Severity != SeverityCritical {
+		t.Errorf("unexpected exception: %+v, %v", exception, err)
+	}
+	if _, err := events[0].Client(); err == nil {
+		t.Error("expected an exception event to have no client")
+	}
+
+	page, err := c.ListEvents(ctx, &EventListOptions{Types: []EventType{EventClientRegistered}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 1 {
+		t.Fatalf("expected one registration, got %+v", page.Items)
+	}
+	if registered, err := page.Items[0].Client(); err != nil || registered.ID != "client-4" {
+		t.Errorf("expected client-4 to register, got %+v, %v", registered, err)
+	}
+}
+
+// TestStreamEvents tests that a stream receives events as they happen and
+// resumes after the last event handled when its connection is lost
+func TestStreamEvents(t *testing.T) {
+	var connections atomic.Int32
+	var cutFirst context.CancelFunc
+	cuttable := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == APIPrefix+"/events/stream" && connections.Add(1) == 1 {
+				var ctx context.Context
+				ctx, cutFirst = context.WithCancel(r.Context())
+				r = r.WithContext(ctx)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	s := newTestServer(t, cuttable)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c := newTestClient(t, s, Config{Username: "alice", Password: "alice-password"})
+
+	report := func(message string) {
+		s.clients.ReportException("client-1", message, client.SeverityInfo, "", "", nil)
+	}
+	report("skipped")
+	report("first")
+
+	// The stream starts after the first event and cuts its connection once
+	// the second is handled; the third is received after reconnecting
+	errStop := errors.New("stop")
+	var received []string
+	err := c.StreamEvents(ctx, &EventStreamOptions{Types: []EventType{EventExceptionReported}, After: 1}, func(event Event) error {
+		exception, err := event.Exception()
+		if err != nil {
+			return err
+		}
+		received = append(received, exception.Message)
+
+		switch exception.Message {
+		case "first":
+			report("second")
+		case "second":
+			cutFirst()
+			report("third")
+		case "third":
+			report("fourth")
+		case "fourth":
+			return errStop
+		}
+		return nil
+	})
+	if err != errStop {
+		t.Fatalf("expected the error of the handler, got %v", err)
+	}
+
+	if fmt.Sprint(received) != "[first second third fourth]" {
+		t.Errorf("expected each event once and in order, got %v", received)
+	}
+	if n := connections.Load(); n != 2 {
+		t.Errorf("expected the stream to reconnect once, got %d connections", n)
+	}
+}
+
+// TestStreamEventsErrors tests that a stream ends with its context and
+// gives up on errors that retrying will not fix
+func TestStreamEventsErrors(t *testing.T) {
+	s := newTestServer(t, nil)
+
+	c := newTestClient(t, s, Config{Username: "alice", Password: "wrong-password"})
+	err := c.StreamEvents(context.Background(), nil, func(Event) error { return nil })
+	if apiErr, ok := err.(*APIError); !ok || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %v", err)
+	}
+
+	c = newTestClient(t, s, Config{Username: "alice", Password: "alice-password"})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.StreamEvents(ctx, nil, func(Event) error { return nil }); err != context.DeadlineExceeded {
+		t.Errorf("expected the deadline to end the stream, got %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+package dinotapi
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ClientListOptions selects the clients returned by ListClients
+type ClientListOptions struct {
+	ListOptions
+
+	// Query is a filter expression, e.g. "os=linux and last_seen<10m and tag:dmz"
+	Query string
+
+	// Status only returns clients with this status
+	Status ClientStatus
+}
+
+// values encodes the options as query parameters
+func (o *ClientListOptions) values() url.Values {
+	if o == nil {
+		return url.Values{}
+	}
+
+	values := o.ListOptions.values()
+	if o.Query != "" {
+		values.Set("q", o.Query)
+	}
+	if o.Status != "" {
+		values.Set("status", string(o.Status))
+	}
+	return values
+}
+
+// ListClients returns a page of clients
+func (c *Client) ListClients(ctx context.Context, opts *ClientListOptions) (*Page[ClientInfo], error) {
+	return listPage[ClientInfo](ctx, c, "/clients", opts.values())
+}
+
+// AllClients returns every client matching the options, fetching as many
+// pages as needed
+func (c *Client) AllClients(ctx context.Context, opts *ClientListOptions) ([]ClientInfo, error) {
+	return listAll[ClientInfo](ctx, c, "/clients", opts.values())
+}
+
+// GetClient returns a client by ID
+func (c *Client) GetClient(ctx context.Context, id string) (*ClientInfo, error) {
+	var client ClientInfo
+	if err := c.get(ctx, "/clients/"+url.PathEscape(id), nil, &client); err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+// UnregisterClient unregisters a client
+func (c *Client) UnregisterClient(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/clients/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// UnregisterClients unregisters every client of a tag or group and returns
+// their IDs. target.ClientID is not supported; use UnregisterClient.
+func (c *Client) UnregisterClients(ctx context.Context, target Target) ([]string, error) {
+	query := url.Values{}
+	if target.Tag != "" {
+		query.Set("tag", target.Tag)
+	}
+	if target.Group != "" {
+		query.Set("group", target.Group)
+	}
+
+	var resp struct {
+		Unregistered []string `json:"unregistered"`
+	}
+	if err := c.do(ctx, http.MethodDelete, "/clients", query, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Unregistered, nil
+}
+
+// ClientHistory returns the status transitions of a client, oldest first
+func (c *Client) ClientHistory(ctx context.Context, id string) ([]StatusTransition, error) {
+	var resp struct {
+		History []StatusTransition `json:"history"`
+	}
+	if err := c.get(ctx, "/clients/"+url.PathEscape(id)+"/history", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.History, nil
+}
+
+// SetClientStatus sets the status of a client. The message is recorded as
+// the client's error message.
+func (c *Client) SetClientStatus(ctx context.Context, id string, status ClientStatus, message string) error {
+	body := struct {
+		ClientID     string       `json:"clientId"`
+		Status       ClientStatus `json:"status"`
+		ErrorMessage string       `json:"errorMessage,omitempty"`
+	}{id, status, message}
+
+	return c.do(ctx, http.MethodPost, "/status", nil, body, nil)
+}
+
+// ClientTags returns the tags of a client
+func (c *Client) ClientTags(ctx context.Context, id string) ([]string, error) {
+	var tags []string
+	if err := c.get(ctx, "/clients/"+url.PathEscape(id)+"/tags", nil, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// AddClientTag tags a client and returns its tags
+func (c *Client) AddClientTag(ctx context.Context, id, tag string) ([]string, error) {
+	body := struct {
+		Tag string `json:"tag"`
+	}{tag}
+
+	var resp struct {
+		Tags []string `json:"tags"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/clients/"+url.PathEscape(id)+"/tags", nil, body, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Tags, nil
+}
+
+// RemoveClientTag removes a tag from a client and returns its tags
+func (c *Client) RemoveClientTag(ctx context.Context, id, tag string) ([]string, error) {
+	var resp struct {
+		Tags []string `json:"tags"`
+	}
+	path := "/clients/" + url.PathEscape(id) + "/tags/" + url.PathEscape(tag)
+	if err := c.do(ctx, http.MethodDelete, path, nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Tags, nil
+}
+
+// GetHeartbeat returns the global heartbeat settings
+func (c *Client) GetHeartbeat(ctx context.Context) (*HeartbeatSettings, error) {
+	var settings HeartbeatSettings
+	if err := c.get(ctx, "/heartbeat", nil, &settings); err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// UpdateHeartbeat changes the global heartbeat settings
+func (c *Client) UpdateHeartbeat(ctx context.Context, update HeartbeatUpdate) error {
+	body := make(map[string]interface{})
+	if update.CheckInterval != nil {
+		body["checkInterval"] = update.CheckInterval.Seconds()
+	}
+	if update.Timeout != nil {
+		body["timeout"] = update.Timeout.Seconds()
+	}
+	if update.RandomEnabled != nil {
+		body["randomEnabled"] = *update.RandomEnabled
+		body["randomMinInterval"] = update.RandomMinInterval.Seconds()
+		body["randomMaxInterval"] = update.RandomMaxInterval.Seconds()
+	}
+
+	return c.do(ctx, http.MethodPost, "/heartbeat", nil, body, nil)
+}
+
+// SetHeartbeatInterval sets the heartbeat interval of the clients selected
+// by a target and returns how many were updated
+func (c *Client) SetHeartbeatInterval(ctx context.Context, target Target, interval time.Duration) (int, error) {
+	body := struct {
+		Target   Target  `json:"target"`
+		Interval float64 `json:"interval"`
+	}{target, interval.Seconds()}
+
+	var resp struct {
+		Updated int `json:"updated"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/heartbeat", nil, body, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Updated, nil
+}
@@ -0,0 +1,80 @@
+package dinotapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// APIError is an error response of the API
+type APIError struct {
+	// StatusCode is the HTTP status of the response
+	StatusCode int
+
+	// Code is the machine-readable error code, e.g. client_not_found
+	Code string `json:"code"`
+
+	// Message is a human-readable description of the error
+	Message string `json:"message"`
+
+	// Details carries additional context, e.g. the missing permission
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// Error returns the error message
+func (e *APIError) Error() string {
+	return fmt.Sprintf("dinotapi: %s (%d %s)", e.Message, e.StatusCode, e.Code)
+}
+
+// IsNotFound reports whether err is an API error with status 404
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsUnauthorized reports whether err is an API error with status 401
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized)
+}
+
+// IsForbidden reports whether err is an API error with status 403
+func IsForbidden(err error) bool {
+	return hasStatus(err, http.StatusForbidden)
+}
+
+// ErrorCode returns the code of an API error, or an empty string if err is
+// not one
+func ErrorCode(err error) string {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return ""
+}
+
+// hasStatus reports whether err is an API error with the given status
+func hasStatus(err error, status int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+// decodeError reads the error envelope of a failed response. Bodies that
+// are not an envelope, e.g. from a proxy, become the message.
+func decodeError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	var envelope struct {
+		Error *APIError `json:"error"`
+	}
+	if err := json.Unmarshal(body, &envelope); err == nil && envelope.Error != nil {
+		envelope.Error.StatusCode = resp.StatusCode
+		return envelope.Error
+	}
+
+	message := string(body)
+	if message == "" {
+		message = http.StatusText(resp.StatusCode)
+	}
+	return &APIError{StatusCode: resp.StatusCode, Message: message}
+}
@@ -0,0 +1,289 @@
+package dinotapi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EventType is the type of an event
+type EventType string
+
+const (
+	// EventClientRegistered is sent when a client registers; its data is
+	// the client
+	EventClientRegistered EventType = "client_registered"
+	// EventTaskFinished is sent when a task reaches a final status; its
+	// data is the task
+	EventTaskFinished EventType = "task_finished"
+	// EventExceptionReported is sent when an exception is reported; its
+	// data is the exception report
+	EventExceptionReported EventType = "exception_reported"
+)
+
+// Event is something that happened on the server
+type Event struct {
+	ID        int64           `json:"id"`
+	Type      EventType       `json:"type"`
+	Timestamp time.Time       `json:"timestamp"`
+	ClientID  string          `json:"client_id,omitempty"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Client decodes the client of a client_registered event
+func (e *Event) Client() (*ClientInfo, error) {
+	var c ClientInfo
+	if err := e.decode(EventClientRegistered, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Task decodes the task of a task_finished event
+func (e *Event) Task() (*Task, error) {
+	var t Task
+	if err := e.decode(EventTaskFinished, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Exception decodes the report of an exception_reported event
+func (e *Event) Exception() (*Exception, error) {
+	var exception Exception
+	if err := e.decode(EventExceptionReported, &exception); err != nil {
+		return nil, err
+	}
+	return &exception, nil
+}
+
+// decode decodes the data of an event of the expected type
+func (e *Event) decode(expected EventType, out interface{}) error {
+	if e.Type != expected {
+		return fmt.Errorf("dinotapi: %s event has no %s data", e.Type, expected)
+	}
+	if err := json.Unmarshal(e.Data, out); err != nil {
+		return fmt.Errorf("dinotapi: failed to decode event data: %w", err)
+	}
+	return nil
+}
+
+// EventListOptions selects the events returned by ListEvents
+type EventListOptions struct {
+	ListOptions
+
+	// Types only returns events of these types
+	Types []EventType
+
+	// ClientID only returns the events of this client
+	ClientID string
+}
+
+// values encodes the options as query parameters
+func (o *EventListOptions) values() url.Values {
+	if o == nil {
+		return url.Values{}
+	}
+
+	values := o.ListOptions.values()
+	setEventFilter(values, o.Types, o.ClientID)
+	return values
+}
+
+// EventStreamOptions selects the events sent to StreamEvents
+type EventStreamOptions struct {
+	// Types only sends events of these types
+	Types []EventType
+
+	// ClientID only sends the events of this client
+	ClientID string
+
+	// After starts the stream with the recent events following this event
+	// ID; only new events are sent if zero
+	After int64
+}
+
+// setEventFilter sets the query parameters filtering events
+func setEventFilter(values url.Values, types []EventType, clientID string) {
+	if len(types) > 0 {
+		names := make([]string, len(types))
+		for i, t := range types {
+			names[i] = string(t)
+		}
+		values.Set("types", strings.Join(names, ","))
+	}
+	if clientID != "" {
+		values.Set("clientId", clientID)
+	}
+}
+
+// ListEvents returns a page of the recent events, oldest first
+func (c *Client) ListEvents(ctx context.Context, opts *EventListOptions) (*Page[Event], error) {
+	return listPage[Event](ctx, c, "/events", opts.values())
+}
+
+// AllEvents returns every recent event matching the options, fetching as
+// many pages as needed
+func (c *Client) AllEvents(ctx context.Context, opts *EventListOptions) ([]Event, error) {
+	return listAll[Event](ctx, c, "/events", opts.values())
+}
+
+// handlerError carries the error returned by the handler of StreamEvents
+type handlerError struct {
+	err error
+}
+
+// Error returns the handler's error message
+func (e *handlerError) Error() string {
+	return e.err.Error()
+}
+
+// StreamEvents calls handle with every event as it happens. Events of the
+// same stream are handled in order, one at a time. A lost connection is
+// reopened, resuming after the last event handled, and is given up after
+// MaxRetries failed attempts in a row. StreamEvents returns when ctx ends,
+// with the error of handle if it fails, or with the error of the last
+// attempt. The HTTP client must not set a timeout, which would end every
+// stream.
+func (c *Client) StreamEvents(ctx context.Context, opts *EventStreamOptions, handle func(event Event) error) error {
+	query := url.Values{}
+	after := int64(-1)
+	if opts != nil {
+		setEventFilter(query, opts.Types, opts.ClientID)
+		if opts.After > 0 {
+			after = opts.After
+		}
+	}
+
+	failures := 0
+	backoff := c.config.RetryBackoff
+	for {
+		handled, err := c.streamOnce(ctx, query, &after, handle)
+
+		var handlerErr *handlerError
+		if errors.As(err, &handlerErr) {
+			return handlerErr.err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && !retryableStatus(apiErr.StatusCode) {
+			return err
+		}
+
+		// A stream that got somewhere starts counting failures afresh
+		if handled {
+			failures = 0
+			backoff = c.config.RetryBackoff
+		}
+		failures++
+		if failures > c.config.MaxRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// streamOnce opens an event stream and handles its events until it ends.
+// after is the position to resume from, or -1 to only receive new events;
+// it is updated as the stream moves on. It reports whether any event was
+// handled.
+func (c *Client) streamOnce(ctx context.Context, query url.Values, after *int64, handle func(event Event) error) (bool, error) {
+	values := url.Values{}
+	for name, value := range query {
+		values[name] = value
+	}
+	if *after >= 0 {
+		values.Set("after", strconv.FormatInt(*after, 10))
+	}
+
+	resp, err := c.sendOnce(ctx, http.MethodGet, "/events/stream", values, nil, "")
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && c.usesTokens() {
+		resp.Body.Close()
+
+		// The access token expired or was revoked; get a new one and retry
+		c.mu.Lock()
+		c.access = ""
+		c.mu.Unlock()
+		if resp, err = c.sendOnce(ctx, http.MethodGet, "/events/stream", values, nil, ""); err != nil {
+			return false, err
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return false, decodeError(resp)
+	}
+
+	handled := false
+	reader := bufio.NewReader(resp.Body)
+	var data bytes.Buffer
+	id := int64(-1)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return handled, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		// Events are sent as fields ending with a blank line; comments keep
+		// the connection alive
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch {
+		case field == "data":
+			data.WriteString(value)
+			continue
+		case field == "id":
+			if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+				id = n
+			}
+			continue
+		case line != "":
+			continue
+		}
+
+		// An ID without data is where the stream resumes from, such as the
+		// latest event when it opened
+		if data.Len() == 0 {
+			if id >= 0 {
+				*after = id
+			}
+			continue
+		}
+
+		var event Event
+		if err := json.Unmarshal(data.Bytes(), &event); err != nil {
+			return handled, fmt.Errorf("dinotapi: failed to decode event: %w", err)
+		}
+		data.Reset()
+
+		if err := handle(event); err != nil {
+			return handled, &handlerError{err: err}
+		}
+		*after = event.ID
+		handled = true
+	}
+}
@@ -0,0 +1,68 @@
+package dinotapi
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// ExceptionListOptions selects the reports returned by ListExceptions
+type ExceptionListOptions struct {
+	ListOptions
+
+	// ClientID only returns the reports of this client
+	ClientID string
+}
+
+// values encodes the options as query parameters
+func (o *ExceptionListOptions) values() url.Values {
+	if o == nil {
+		return url.Values{}
+	}
+
+	values := o.ListOptions.values()
+	if o.ClientID != "" {
+		values.Set("clientId", o.ClientID)
+	}
+	return values
+}
+
+// ExceptionReport reports an exception on behalf of a client
+type ExceptionReport struct {
+	ClientID       string            `json:"clientId"`
+	Message        string            `json:"message"`
+	Severity       Severity          `json:"severity"`
+	Module         string            `json:"module,omitempty"`
+	StackTrace     string            `json:"stackTrace,omitempty"`
+	AdditionalInfo map[string]string `json:"additionalInfo,omitempty"`
+}
+
+// ListExceptions returns a page of exception reports, oldest first unless
+// sorted otherwise
+func (c *Client) ListExceptions(ctx context.Context, opts *ExceptionListOptions) (*Page[Exception], error) {
+	return listPage[Exception](ctx, c, "/exceptions", opts.values())
+}
+
+// AllExceptions returns every exception report matching the options,
+// fetching as many pages as needed
+func (c *Client) AllExceptions(ctx context.Context, opts *ExceptionListOptions) ([]Exception, error) {
+	return listAll[Exception](ctx, c, "/exceptions", opts.values())
+}
+
+// GetException returns an exception report
+func (c *Client) GetException(ctx context.Context, id string) (*Exception, error) {
+	var exception Exception
+	if err := c.get(ctx, "/exceptions/"+url.PathEscape(id), nil, &exception); err != nil {
+		return nil, err
+	}
+	return &exception, nil
+}
+
+// ReportException reports an exception and returns the stored report
+func (c *Client) ReportException(ctx context.Context, report ExceptionReport) (*Exception, error) {
+	var exception Exception
+	if err := c.do(ctx, http.MethodPost, "/exceptions", nil, report, &exception); err != nil {
+		return nil, err
+	}
+	return &exception, nil
+}
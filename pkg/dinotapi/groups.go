@@ -0,0 +1,69 @@
+package dinotapi
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// ListTags returns a page of the tags in use
+func (c *Client) ListTags(ctx context.Context, opts *ListOptions) (*Page[Tag], error) {
+	return listPage[Tag](ctx, c, "/tags", opts.values())
+}
+
+// ListGroups returns a page of client groups
+func (c *Client) ListGroups(ctx context.Context, opts *ListOptions) (*Page[Group], error) {
+	return listPage[Group](ctx, c, "/groups", opts.values())
+}
+
+// GetGroup returns a client group
+func (c *Client) GetGroup(ctx context.Context, name string) (*Group, error) {
+	var group Group
+	if err := c.get(ctx, "/groups/"+url.PathEscape(name), nil, &group); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// CreateGroup creates a client group with optional initial members
+func (c *Client) CreateGroup(ctx context.Context, name, description string, clientIDs ...string) (*Group, error) {
+	body := struct {
+		Name        string   `json:"name"`
+		Description string   `json:"description,omitempty"`
+		ClientIDs   []string `json:"clientIds,omitempty"`
+	}{name, description, clientIDs}
+
+	var group Group
+	if err := c.do(ctx, http.MethodPost, "/groups", nil, body, &group); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// DeleteGroup deletes a client group; its members stay registered
+func (c *Client) DeleteGroup(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/groups/"+url.PathEscape(name), nil, nil, nil)
+}
+
+// AddGroupMember adds a client to a group and returns the group
+func (c *Client) AddGroupMember(ctx context.Context, name, clientID string) (*Group, error) {
+	body := struct {
+		ClientID string `json:"clientId"`
+	}{clientID}
+
+	var group Group
+	if err := c.do(ctx, http.MethodPost, "/groups/"+url.PathEscape(name)+"/members", nil, body, &group); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// RemoveGroupMember removes a client from a group and returns the group
+func (c *Client) RemoveGroupMember(ctx context.Context, name, clientID string) (*Group, error) {
+	var group Group
+	path := "/groups/" + url.PathEscape(name) + "/members/" + url.PathEscape(clientID)
+	if err := c.do(ctx, http.MethodDelete, path, nil, nil, &group); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
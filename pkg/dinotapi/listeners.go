@@ -0,0 +1,106 @@
+package dinotapi
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// ListenerConfig is the configuration of a listener. Unset sizes and
+// timeouts get server defaults.
+type ListenerConfig struct {
+	// Address is the listening address, host:port or a host for ICMP
+	Address string `json:"address"`
+
+	// TLS enables TLS; only TCP and WebSocket listeners support it
+	TLS bool `json:"tls,omitempty"`
+
+	// TLSCertFile is the path of the certificate file on the server
+	TLSCertFile string `json:"tls_cert_file,omitempty"`
+
+	// TLSKeyFile is the path of the key file on the server
+	TLSKeyFile string `json:"tls_key_file,omitempty"`
+
+	// BufferSize is the size of the read buffer in bytes
+	BufferSize int `json:"buffer_size,omitempty"`
+
+	// MaxConnections is the maximum number of concurrent connections
+	MaxConnections int `json:"max_connections,omitempty"`
+
+	// Timeout is the connection timeout in seconds
+	Timeout int `json:"timeout,omitempty"`
+
+	// Domain is the base domain of a DNS listener
+	Domain string `json:"domain,omitempty"`
+
+	// TTL is the time-to-live of the records of a DNS listener
+	TTL uint32 `json:"ttl,omitempty"`
+
+	// RecordTypes are the record types a DNS listener answers, e.g. A and
+	// TXT
+	RecordTypes []string `json:"record_types,omitempty"`
+}
+
+// ListListeners returns a page of listeners
+func (c *Client) ListListeners(ctx context.Context, opts *ListOptions) (*Page[Listener], error) {
+	return listPage[Listener](ctx, c, "/listeners", opts.values())
+}
+
+// CreateListener creates a stopped listener for a protocol: tcp, udp, ws,
+// icmp or dns. An empty name defaults to the protocol; listeners for the
+// same protocol need distinct names.
+func (c *Client) CreateListener(ctx context.Context, name, protocol string, config ListenerConfig) (*Listener, error) {
+	body := struct {
+		Name     string `json:"name,omitempty"`
+		Protocol string `json:"protocol"`
+		ListenerConfig
+	}{name, protocol, config}
+
+	var listener Listener
+	if err := c.do(ctx, http.MethodPost, "/listeners", nil, body, &listener); err != nil {
+		return nil, err
+	}
+	return &listener, nil
+}
+
+// GetListener returns a listener by name
+func (c *Client) GetListener(ctx context.Context, name string) (*Listener, error) {
+	var listener Listener
+	if err := c.get(ctx, "/listeners/"+url.PathEscape(name), nil, &listener); err != nil {
+		return nil, err
+	}
+	return &listener, nil
+}
+
+// UpdateListener replaces the configuration of a stopped listener
+func (c *Client) UpdateListener(ctx context.Context, name string, config ListenerConfig) (*Listener, error) {
+	var listener Listener
+	if err := c.do(ctx, http.MethodPut, "/listeners/"+url.PathEscape(name), nil, config, &listener); err != nil {
+		return nil, err
+	}
+	return &listener, nil
+}
+
+// DeleteListener stops and removes a listener
+func (c *Client) DeleteListener(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/listeners/"+url.PathEscape(name), nil, nil, nil)
+}
+
+// StartListener starts a listener
+func (c *Client) StartListener(ctx context.Context, name string) (*Listener, error) {
+	return c.listenerAction(ctx, name, "start")
+}
+
+// StopListener stops a listener
+func (c *Client) StopListener(ctx context.Context, name string) (*Listener, error) {
+	return c.listenerAction(ctx, name, "stop")
+}
+
+// listenerAction starts or stops a listener
+func (c *Client) listenerAction(ctx context.Context, name, action string) (*Listener, error) {
+	var listener Listener
+	if err := c.do(ctx, http.MethodPost, "/listeners/"+url.PathEscape(name)+"/"+action, nil, nil, &listener); err != nil {
+		return nil, err
+	}
+	return &listener, nil
+}
@@ -0,0 +1,92 @@
+package dinotapi
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ListOptions selects a page of a collection
+type ListOptions struct {
+	// Limit is the maximum number of items per page; the server default if
+	// zero
+	Limit int
+
+	// Cursor is the Next cursor of the previous page
+	Cursor string
+
+	// Sort is the field to sort by
+	Sort string
+
+	// Desc sorts in descending order
+	Desc bool
+
+	// Fields restricts the returned fields. Fields that are not selected
+	// are left at their zero value.
+	Fields []string
+}
+
+// values encodes the options as query parameters
+func (o *ListOptions) values() url.Values {
+	values := url.Values{}
+	if o == nil {
+		return values
+	}
+
+	if o.Limit > 0 {
+		values.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Cursor != "" {
+		values.Set("cursor", o.Cursor)
+	}
+	if o.Sort != "" {
+		values.Set("sort", o.Sort)
+	}
+	if o.Desc {
+		values.Set("order", "desc")
+	}
+	if len(o.Fields) > 0 {
+		values.Set("fields", strings.Join(o.Fields, ","))
+	}
+	return values
+}
+
+// Page is a page of a collection
+type Page[T any] struct {
+	// Items are the items of the page
+	Items []T `json:"items"`
+
+	// Total is the number of items across all pages
+	Total int `json:"total"`
+
+	// Next is the cursor of the next page; empty on the last page
+	Next string `json:"next,omitempty"`
+}
+
+// listPage fetches a page of a collection
+func listPage[T any](ctx context.Context, c *Client, path string, query url.Values) (*Page[T], error) {
+	var page Page[T]
+	if err := c.get(ctx, path, query, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// listAll fetches every page of a collection, starting at the cursor in
+// query if there is one
+func listAll[T any](ctx context.Context, c *Client, path string, query url.Values) ([]T, error) {
+	var items []T
+	for {
+		page, err := listPage[T](ctx, c, path, query)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, page.Items...)
+		if page.Next == "" {
+			return items, nil
+		}
+		query.Set("cursor", page.Next)
+	}
+}
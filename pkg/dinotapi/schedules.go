@@ -0,0 +1,90 @@
+package dinotapi
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ScheduleRequest creates a schedule. Exactly one of RunAt, Cron and
+// OnRegister must be set, and a selector is required unless OnRegister is
+// set, in which case it restricts the clients that trigger the schedule.
+type ScheduleRequest struct {
+	// ClientIDs selects an explicit list of clients
+	ClientIDs []string `json:"clientIds,omitempty"`
+
+	// Tag selects every client carrying the tag
+	Tag string `json:"tag,omitempty"`
+
+	// Group selects every registered member of the group
+	Group string `json:"group,omitempty"`
+
+	// Filter selects every client matching a filter expression
+	Filter string `json:"filter,omitempty"`
+
+	// Name is an optional label for the schedule
+	Name string `json:"name,omitempty"`
+
+	// Module is the name of the module to run
+	Module string `json:"module"`
+
+	// Params are the module parameters; anything that encodes as a JSON
+	// object
+	Params interface{} `json:"params,omitempty"`
+
+	// RunAt runs the schedule once at the given time
+	RunAt *time.Time `json:"runAt,omitempty"`
+
+	// Cron runs the schedule whenever the cron expression matches
+	Cron string `json:"cron,omitempty"`
+
+	// OnRegister runs the schedule for every newly registered client
+	OnRegister bool `json:"onRegister,omitempty"`
+
+	// IdempotencyKey makes retrying the request safe across processes:
+	// repeating it with the same key returns the first result. A key is
+	// generated for every call if empty.
+	IdempotencyKey string `json:"-"`
+}
+
+// ListSchedules returns a page of schedules
+func (c *Client) ListSchedules(ctx context.Context, opts *ListOptions) (*Page[Schedule], error) {
+	return listPage[Schedule](ctx, c, "/schedules", opts.values())
+}
+
+// CreateSchedule creates a schedule
+func (c *Client) CreateSchedule(ctx context.Context, req ScheduleRequest) (*Schedule, error) {
+	var schedule Schedule
+	if err := c.create(ctx, "/schedules", req.IdempotencyKey, req, &schedule); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// GetSchedule returns a schedule and the outcome of its last run
+func (c *Client) GetSchedule(ctx context.Context, id string) (*Schedule, error) {
+	var schedule Schedule
+	if err := c.get(ctx, "/schedules/"+url.PathEscape(id), nil, &schedule); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// SetScheduleEnabled enables or disables a schedule
+func (c *Client) SetScheduleEnabled(ctx context.Context, id string, enabled bool) (*Schedule, error) {
+	body := struct {
+		Enabled bool `json:"enabled"`
+	}{enabled}
+
+	var schedule Schedule
+	if err := c.do(ctx, http.MethodPut, "/schedules/"+url.PathEscape(id), nil, body, &schedule); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// DeleteSchedule deletes a schedule
+func (c *Client) DeleteSchedule(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/schedules/"+url.PathEscape(id), nil, nil, nil)
+}
@@ -0,0 +1,180 @@
+package dinotapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// TaskListOptions selects the tasks returned by ListTasks
+type TaskListOptions struct {
+	ListOptions
+
+	// ClientID only returns the tasks of this client
+	ClientID string
+}
+
+// values encodes the options as query parameters
+func (o *TaskListOptions) values() url.Values {
+	if o == nil {
+		return url.Values{}
+	}
+
+	values := o.ListOptions.values()
+	if o.ClientID != "" {
+		values.Set("clientId", o.ClientID)
+	}
+	return values
+}
+
+// TaskRequest runs a module on the clients selected by a target
+type TaskRequest struct {
+	Target
+
+	// Module is the name of the module to run
+	Module string `json:"module"`
+
+	// Params are the module parameters; anything that encodes as JSON. A
+	// "timeout" parameter, in seconds, overrides the default task timeout.
+	Params interface{} `json:"params,omitempty"`
+
+	// IdempotencyKey makes retrying the request safe across processes:
+	// repeating it with the same key returns the first result. A key is
+	// generated for every call if empty.
+	IdempotencyKey string `json:"-"`
+}
+
+// ListTasks returns a page of tasks
+func (c *Client) ListTasks(ctx context.Context, opts *TaskListOptions) (*Page[Task], error) {
+	return listPage[Task](ctx, c, "/tasks", opts.values())
+}
+
+// AllTasks returns every task matching the options, fetching as many pages
+// as needed
+func (c *Client) AllTasks(ctx context.Context, opts *TaskListOptions) ([]Task, error) {
+	return listAll[Task](ctx, c, "/tasks", opts.values())
+}
+
+// GetTask returns a task and its result
+func (c *Client) GetTask(ctx context.Context, id string) (*Task, error) {
+	var task Task
+	if err := c.get(ctx, "/tasks/"+url.PathEscape(id), nil, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// CancelTask cancels a task. A task already delivered to its client stays
+// cancelling until the client confirms; use WaitTask to wait for that.
+func (c *Client) CancelTask(ctx context.Context, id string) (*Task, error) {
+	var task Task
+	if err := c.do(ctx, http.MethodDelete, "/tasks/"+url.PathEscape(id), nil, nil, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// CreateTasks runs a module on a client, or on every client of a tag or
+// group, and returns one task per client
+func (c *Client) CreateTasks(ctx context.Context, req TaskRequest) ([]Task, error) {
+	var tasks []Task
+	if err := c.create(ctx, "/tasks", req.IdempotencyKey, req, &tasks); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// WaitTask polls a task every interval until it finishes or ctx is done
+func (c *Client) WaitTask(ctx context.Context, id string, interval time.Duration) (*Task, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		task, err := c.GetTask(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if task.Status.IsFinal() {
+			return task, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return task, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// ListModules returns a page of the available modules
+func (c *Client) ListModules(ctx context.Context, opts *ListOptions) (*Page[Module], error) {
+	return listPage[Module](ctx, c, "/modules", opts.values())
+}
+
+// GetModule returns a module and its parameters
+func (c *Client) GetModule(ctx context.Context, name string) (*Module, error) {
+	var module Module
+	if err := c.get(ctx, "/modules/"+url.PathEscape(name), nil, &module); err != nil {
+		return nil, err
+	}
+	return &module, nil
+}
+
+// ClientModules returns the modules active on a client
+func (c *Client) ClientModules(ctx context.Context, clientID string) ([]string, error) {
+	var resp struct {
+		Modules []string `json:"modules"`
+	}
+	if err := c.get(ctx, "/clients/"+url.PathEscape(clientID)+"/modules", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Modules, nil
+}
+
+// ExecuteModule runs a module on a client and returns the queued task.
+// params must encode as a JSON object.
+func (c *Client) ExecuteModule(ctx context.Context, clientID, module string, params interface{}) (*Task, error) {
+	body := params
+	if body == nil {
+		body = map[string]interface{}{}
+	}
+
+	// Fail early on parameters the server cannot decode as an object
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("dinotapi: failed to encode parameters: %w", err)
+	}
+	if len(raw) == 0 || raw[0] != '{' {
+		return nil, fmt.Errorf("dinotapi: module parameters must be a JSON object")
+	}
+
+	var task Task
+	path := "/clients/" + url.PathEscape(clientID) + "/modules/" + url.PathEscape(module)
+	if err := c.create(ctx, path, "", json.RawMessage(raw), &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// LoadModule loads a module on a client
+func (c *Client) LoadModule(ctx context.Context, clientID, module string) (*ModuleStatus, error) {
+	return c.moduleStatus(ctx, http.MethodPut, clientID, module)
+}
+
+// UnloadModule unloads a module from a client
+func (c *Client) UnloadModule(ctx context.Context, clientID, module string) (*ModuleStatus, error) {
+	return c.moduleStatus(ctx, http.MethodDelete, clientID, module)
+}
+
+// moduleStatus sends a module request and returns the module's status
+func (c *Client) moduleStatus(ctx context.Context, method, clientID, module string) (*ModuleStatus, error) {
+	var status ModuleStatus
+	path := "/clients/" + url.PathEscape(clientID) + "/modules/" + url.PathEscape(module)
+	if err := c.do(ctx, method, path, nil, nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
@@ -0,0 +1,381 @@
+package dinotapi
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ClientStatus is the status of a client
+type ClientStatus string
+
+const (
+	// StatusOnline indicates the client is online and responsive
+	StatusOnline ClientStatus = "online"
+	// StatusOffline indicates the client is offline or unresponsive
+	StatusOffline ClientStatus = "offline"
+	// StatusBusy indicates the client is online but executing a task
+	StatusBusy ClientStatus = "busy"
+	// StatusError indicates the client is experiencing an error
+	StatusError ClientStatus = "error"
+)
+
+// ClientInfo is a connected client
+type ClientInfo struct {
+	ID                string        `json:"id"`
+	Name              string        `json:"name"`
+	IPAddress         string        `json:"ip_address"`
+	OS                string        `json:"os"`
+	Architecture      string        `json:"architecture"`
+	RegisteredAt      time.Time     `json:"registered_at"`
+	LastSeen          time.Time     `json:"last_seen"`
+	Status            ClientStatus  `json:"status"`
+	SupportedModules  []string      `json:"supported_modules"`
+	ActiveModules     []string      `json:"active_modules"`
+	Protocol          string        `json:"protocol"`
+	Listener          string        `json:"listener,omitempty"`
+	HeartbeatInterval time.Duration `json:"heartbeat_interval"`
+	ErrorMessage      string        `json:"error_message,omitempty"`
+	Tags              []string      `json:"tags"`
+}
+
+// StatusTransition records a change of a client's status
+type StatusTransition struct {
+	Timestamp time.Time    `json:"timestamp"`
+	From      ClientStatus `json:"from,omitempty"`
+	To        ClientStatus `json:"to"`
+	Cause     string       `json:"cause"`
+	Transport string       `json:"transport"`
+	Message   string       `json:"message,omitempty"`
+}
+
+// Target selects a single client, the clients of a tag or the members of a
+// group
+type Target struct {
+	ClientID string `json:"clientId,omitempty"`
+	Tag      string `json:"tag,omitempty"`
+	Group    string `json:"group,omitempty"`
+}
+
+// Group is a named set of clients
+type Group struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	ClientIDs   []string  `json:"client_ids"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Tag is a tag in use and the number of clients carrying it
+type Tag struct {
+	Name    string `json:"name"`
+	Clients int    `json:"clients"`
+}
+
+// Severity is the severity of an exception report
+type Severity string
+
+const (
+	// SeverityInfo represents an informational exception
+	SeverityInfo Severity = "info"
+	// SeverityWarning represents a warning
+	SeverityWarning Severity = "warning"
+	// SeverityError represents an error
+	SeverityError Severity = "error"
+	// SeverityCritical represents a critical error
+	SeverityCritical Severity = "critical"
+)
+
+// Exception is an exception reported by or for a client
+type Exception struct {
+	ID             string            `json:"id"`
+	ClientID       string            `json:"client_id"`
+	Timestamp      time.Time         `json:"timestamp"`
+	Message        string            `json:"message"`
+	Severity       Severity          `json:"severity"`
+	Module         string            `json:"module,omitempty"`
+	StackTrace     string            `json:"stack_trace,omitempty"`
+	AdditionalInfo map[string]string `json:"additional_info,omitempty"`
+}
+
+// TaskStatus is the status of a task
+type TaskStatus string
+
+const (
+	// TaskPending indicates the task has not been sent to the client
+	TaskPending TaskStatus = "pending"
+	// TaskDispatched indicates the task was sent to the client
+	TaskDispatched TaskStatus = "dispatched"
+	// TaskProcessing indicates the client is executing the task
+	TaskProcessing TaskStatus = "processing"
+	// TaskRetrying indicates the client is retrying the task
+	TaskRetrying TaskStatus = "retrying"
+	// TaskCompleted indicates the task finished successfully
+	TaskCompleted TaskStatus = "completed"
+	// TaskFailed indicates the task finished with an error
+	TaskFailed TaskStatus = "failed"
+	// TaskCancelling indicates a cancellation was sent to the client
+	TaskCancelling TaskStatus = "cancelling"
+	// TaskCancelled indicates the task was cancelled before it finished
+	TaskCancelled TaskStatus = "cancelled"
+	// TaskTimedOut indicates the task did not finish before its deadline
+	TaskTimedOut TaskStatus = "timed_out"
+)
+
+// IsFinal reports whether the status is terminal
+func (s TaskStatus) IsFinal() bool {
+	return s == TaskCompleted || s == TaskFailed || s == TaskCancelled || s == TaskTimedOut
+}
+
+// Task is a module invocation on a client
+type Task struct {
+	ID         string          `json:"id"`
+	ClientID   string          `json:"client_id"`
+	Module     string          `json:"module"`
+	Params     json.RawMessage `json:"params,omitempty"`
+	Status     TaskStatus      `json:"status"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	RetryCount int             `json:"retry_count,omitempty"`
+	Timeout    int             `json:"timeout,omitempty"`
+	Deadline   *time.Time      `json:"deadline,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+}
+
+// Module describes a module and its parameters
+type Module struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Parameters  []string `json:"parameters,omitempty"`
+}
+
+// ModuleStatus is the status of a module on a client
+type ModuleStatus struct {
+	ClientID string `json:"client_id"`
+	Module   string `json:"module"`
+	Status   string `json:"status"`
+}
+
+// HeartbeatSettings are the global heartbeat settings
+type HeartbeatSettings struct {
+	CheckInterval     time.Duration `json:"checkInterval"`
+	Timeout           time.Duration `json:"timeout"`
+	RandomEnabled     bool          `json:"randomEnabled"`
+	RandomMinInterval time.Duration `json:"randomMinInterval"`
+	RandomMaxInterval time.Duration `json:"randomMaxInterval"`
+}
+
+// HeartbeatUpdate changes the global heartbeat settings. Nil fields are
+// left unchanged; durations are rounded to seconds.
+type HeartbeatUpdate struct {
+	CheckInterval *time.Duration
+	Timeout       *time.Duration
+
+	// RandomEnabled turns random heartbeat intervals between
+	// RandomMinInterval and RandomMaxInterval on or off
+	RandomEnabled     *bool
+	RandomMinInterval time.Duration
+	RandomMaxInterval time.Duration
+}
+
+// Identity is the identity a caller authenticated as. API keys have the
+// username "apikey:" and their ID, and their name in KeyName.
+type Identity struct {
+	Username string   `json:"username"`
+	KeyName  string   `json:"key_name,omitempty"`
+	Role     string   `json:"role,omitempty"`
+	Method   string   `json:"method"`
+	Scopes   []string `json:"scopes,omitempty"`
+}
+
+// TokenPair is the result of a login or refresh
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// LoginRequest exchanges operator credentials for a token pair
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// RefreshRequest exchanges or revokes a refresh token
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// BatchStatus is the aggregate status of a batch
+type BatchStatus string
+
+const (
+	// BatchRunning indicates some tasks of the batch have not finished
+	BatchRunning BatchStatus = "running"
+	// BatchCompleted indicates every task of the batch completed
+	BatchCompleted BatchStatus = "completed"
+	// BatchPartial indicates the batch finished with some failed tasks
+	BatchPartial BatchStatus = "partial"
+	// BatchFailed indicates every task of the batch failed
+	BatchFailed BatchStatus = "failed"
+)
+
+// Batch is one module invocation fanned out to many clients, with the
+// aggregate status of its tasks
+type Batch struct {
+	ID        string          `json:"id"`
+	Module    string          `json:"module"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	Selector  string          `json:"selector"`
+	TaskIDs   []string        `json:"task_ids"`
+	CreatedAt time.Time       `json:"created_at"`
+	Status    BatchStatus     `json:"status"`
+	Total     int             `json:"total"`
+	Succeeded int             `json:"succeeded"`
+	Failed    int             `json:"failed"`
+	Pending   int             `json:"pending"`
+	Results   []BatchResult   `json:"results,omitempty"`
+}
+
+// BatchResult is the state of a batch's task on one client
+type BatchResult struct {
+	ClientID  string          `json:"client_id"`
+	TaskID    string          `json:"task_id"`
+	Status    TaskStatus      `json:"status"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// WorkflowStatus is the aggregate status of a workflow or of one of its runs
+type WorkflowStatus string
+
+const (
+	// WorkflowRunning indicates some steps have not finished
+	WorkflowRunning WorkflowStatus = "running"
+	// WorkflowCompleted indicates every run finished without a failed step
+	WorkflowCompleted WorkflowStatus = "completed"
+	// WorkflowPartial indicates some runs finished with a failed step
+	WorkflowPartial WorkflowStatus = "partial"
+	// WorkflowFailed indicates every run finished with a failed step
+	WorkflowFailed WorkflowStatus = "failed"
+)
+
+// StepStatus is the status of a workflow step on one client
+type StepStatus string
+
+const (
+	// StepWaiting indicates the step's dependencies have not finished
+	StepWaiting StepStatus = "waiting"
+	// StepRunning indicates the step's task was created and has not finished
+	StepRunning StepStatus = "running"
+	// StepSkipped indicates the step's condition did not hold
+	StepSkipped StepStatus = "skipped"
+	// StepCompleted indicates the step's task completed
+	StepCompleted StepStatus = "completed"
+	// StepFailed indicates the step's task failed or could not be created
+	StepFailed StepStatus = "failed"
+	// StepCancelled indicates the step's task was cancelled
+	StepCancelled StepStatus = "cancelled"
+	// StepTimedOut indicates the step's task timed out
+	StepTimedOut StepStatus = "timed_out"
+)
+
+// Workflow is a set of dependent module steps executed independently on
+// every selected client
+type Workflow struct {
+	ID        string         `json:"id"`
+	Name      string         `json:"name,omitempty"`
+	Selector  string         `json:"selector"`
+	Status    WorkflowStatus `json:"status"`
+	Steps     []WorkflowStep `json:"steps"`
+	Runs      []WorkflowRun  `json:"runs"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// WorkflowStep is a step of a workflow
+type WorkflowStep struct {
+	Name      string          `json:"name"`
+	Module    string          `json:"module"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	DependsOn []string        `json:"depends_on,omitempty"`
+	When      string          `json:"when,omitempty"`
+}
+
+// WorkflowRun is the progress of a workflow on one client
+type WorkflowRun struct {
+	ClientID string         `json:"client_id"`
+	Status   WorkflowStatus `json:"status"`
+	Steps    []StepState    `json:"steps"`
+}
+
+// StepState is the state of a workflow step on one client
+type StepState struct {
+	Name      string     `json:"name"`
+	Status    StepStatus `json:"status"`
+	TaskID    string     `json:"task_id,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// ScheduleKind is the trigger of a schedule
+type ScheduleKind string
+
+const (
+	// ScheduleOnce runs once at a fixed time
+	ScheduleOnce ScheduleKind = "once"
+	// ScheduleCron runs whenever a cron expression matches
+	ScheduleCron ScheduleKind = "cron"
+	// ScheduleOnRegister runs whenever a new client registers
+	ScheduleOnRegister ScheduleKind = "on_register"
+)
+
+// Schedule runs a module as a batch at a time, on a cron expression or
+// whenever a new client registers
+type Schedule struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name,omitempty"`
+	Kind        ScheduleKind    `json:"kind"`
+	Selector    string          `json:"selector"`
+	Module      string          `json:"module"`
+	Params      json.RawMessage `json:"params,omitempty"`
+	RunAt       *time.Time      `json:"run_at,omitempty"`
+	Cron        string          `json:"cron,omitempty"`
+	Enabled     bool            `json:"enabled"`
+	CreatedAt   time.Time       `json:"created_at"`
+	NextRun     *time.Time      `json:"next_run,omitempty"`
+	LastRun     *time.Time      `json:"last_run,omitempty"`
+	LastBatchID string          `json:"last_batch_id,omitempty"`
+	LastError   string          `json:"last_error,omitempty"`
+	Runs        int             `json:"runs"`
+}
+
+// ListenerStatus is the state of a protocol listener
+type ListenerStatus string
+
+const (
+	// ListenerStopped means the listener is not accepting clients
+	ListenerStopped ListenerStatus = "stopped"
+	// ListenerRunning means the listener is accepting clients
+	ListenerRunning ListenerStatus = "running"
+	// ListenerError means the listener failed while running
+	ListenerError ListenerStatus = "error"
+)
+
+// Listener is a named protocol listener clients connect to. Domain, TTL
+// and RecordTypes are only set for DNS listeners.
+type Listener struct {
+	Name           string         `json:"name"`
+	Protocol       string         `json:"protocol"`
+	Status         ListenerStatus `json:"status"`
+	Address        string         `json:"address"`
+	TLS            bool           `json:"tls"`
+	TLSCertFile    string         `json:"tls_cert_file,omitempty"`
+	TLSKeyFile     string         `json:"tls_key_file,omitempty"`
+	BufferSize     int            `json:"buffer_size"`
+	MaxConnections int            `json:"max_connections"`
+	Timeout        int            `json:"timeout"`
+	Domain         string         `json:"domain,omitempty"`
+	TTL            uint32         `json:"ttl,omitempty"`
+	RecordTypes    []string       `json:"record_types,omitempty"`
+}
@@ -0,0 +1,79 @@
+package dinotapi
+
+import (
+	"context"
+	"net/url"
+)
+
+// StepDefinition describes a step of a new workflow
+type StepDefinition struct {
+	// Name identifies the step within the workflow
+	Name string `json:"name"`
+
+	// Module is the name of the module the step runs
+	Module string `json:"module"`
+
+	// Params are the module parameters; anything that encodes as a JSON
+	// object. String values may contain {{steps.<name>.result.<path>}} and
+	// {{client.id}} templates.
+	Params interface{} `json:"params,omitempty"`
+
+	// DependsOn are the names of the steps that must finish first
+	DependsOn []string `json:"dependsOn,omitempty"`
+
+	// When is the condition under which the step runs, e.g.
+	// `steps.probe.status == completed`. By default a step runs if all its
+	// dependencies completed.
+	When string `json:"when,omitempty"`
+}
+
+// WorkflowRequest runs a workflow on a list of clients, a tag, a group or
+// the clients matching a filter. Exactly one selector must be set.
+type WorkflowRequest struct {
+	// ClientIDs selects an explicit list of clients
+	ClientIDs []string `json:"clientIds,omitempty"`
+
+	// Tag selects every client carrying the tag
+	Tag string `json:"tag,omitempty"`
+
+	// Group selects every registered member of the group
+	Group string `json:"group,omitempty"`
+
+	// Filter selects every client matching a filter expression
+	Filter string `json:"filter,omitempty"`
+
+	// Name is an optional human readable name
+	Name string `json:"name,omitempty"`
+
+	// Steps are the steps of the workflow
+	Steps []StepDefinition `json:"steps"`
+
+	// IdempotencyKey makes retrying the request safe across processes:
+	// repeating it with the same key returns the first result. A key is
+	// generated for every call if empty.
+	IdempotencyKey string `json:"-"`
+}
+
+// ListWorkflows returns a page of workflows with the status of their steps
+func (c *Client) ListWorkflows(ctx context.Context, opts *ListOptions) (*Page[Workflow], error) {
+	return listPage[Workflow](ctx, c, "/workflows", opts.values())
+}
+
+// CreateWorkflow starts a workflow on every selected client
+func (c *Client) CreateWorkflow(ctx context.Context, req WorkflowRequest) (*Workflow, error) {
+	var workflow Workflow
+	if err := c.create(ctx, "/workflows", req.IdempotencyKey, req, &workflow); err != nil {
+		return nil, err
+	}
+	return &workflow, nil
+}
+
+// GetWorkflow returns a workflow and the status of every step on every
+// client
+func (c *Client) GetWorkflow(ctx context.Context, id string) (*Workflow, error) {
+	var workflow Workflow
+	if err := c.get(ctx, "/workflows/"+url.PathEscape(id), nil, &workflow); err != nil {
+		return nil, err
+	}
+	return &workflow, nil
+}